@@ -71,19 +71,15 @@ import UserNotifications
           let bucket = args["bucket"] as? String,
           let accessKeyId = args["accessKeyId"] as? String,
           let accessKeySecret = args["accessKeySecret"] as? String,
-          let securityToken = args["securityToken"] as? String else {
+          let securityToken = args["securityToken"] as? String,
+          let objectKeys = args["objectKeys"] as? [String],
+          objectKeys.count == paths.count else {
       result(FlutterError(code: "invalid_args", message: "Missing OSS parameters", details: nil))
       return
     }
-    let prefix = (args["prefix"] as? String) ?? "uploads"
-    let userId = (args["userId"] as? Int) ?? 0
-
     DispatchQueue.global(qos: .userInitiated).async {
       let provider = OSSStsTokenCredentialProvider(accessKeyId: accessKeyId, secretKeyId: accessKeySecret, securityToken: securityToken)
       let client = OSSClient(endpoint: endpoint, credentialProvider: provider)
-      let formatter = DateFormatter()
-      formatter.dateFormat = "yyyy/MM/dd"
-      let datePath = formatter.string(from: Date())
       let endpointHost = endpoint
         .replacingOccurrences(of: "https://", with: "")
         .replacingOccurrences(of: "http://", with: "")
@@ -91,8 +87,8 @@ import UserNotifications
       var urls: [String] = []
       for (index, path) in paths.enumerated() {
         let fileURL = URL(fileURLWithPath: path)
-        let ext = fileURL.pathExtension.isEmpty ? "jpg" : fileURL.pathExtension
-        let objectKey = "\(prefix)/\(userId)/\(datePath)/\(Int(Date().timeIntervalSince1970 * 1000))_\(index).\(ext)"
+        // 对象名由服务端生成，STS 凭证只允许写入当前用户目录
+        let objectKey = objectKeys[index]
 
         let put = OSSPutObjectRequest()
         put.bucketName = bucket
@@ -42,13 +42,20 @@ class _DevHttpOverrides extends HttpOverrides {
 class OssUploadService {
   static const MethodChannel _channel = MethodChannel('eatclean/oss_upload');
 
-  static Future<Map<String, dynamic>?> _fetchSts() async {
+  static Future<Map<String, dynamic>?> _fetchSts({
+    required String category,
+    required List<String> exts,
+  }) async {
     final auth = AuthStore.instance;
     await auth.ensureLoaded();
     if (auth.token.isEmpty) return null;
     try {
+      final query = [
+        'category=${Uri.encodeQueryComponent(category)}',
+        ...exts.map((ext) => 'ext=${Uri.encodeQueryComponent(ext)}'),
+      ].join('&');
       final response = await http.get(
-        Uri.parse('$_apiBaseUrl/oss/sts'),
+        Uri.parse('$_apiBaseUrl/oss/sts?$query'),
         headers: {'Authorization': 'Bearer ${auth.token}'},
       );
       final payload = jsonDecode(response.body) as Map<String, dynamic>;
@@ -66,17 +73,25 @@ class OssUploadService {
     required List<XFile> images,
     required String category,
   }) async {
-    if (!Platform.isIOS) {
-      return [];
-    }
     if (images.isEmpty) return [];
     final auth = AuthStore.instance;
     await auth.ensureLoaded();
     if (auth.token.isEmpty) {
       return [];
     }
-    final sts = await _fetchSts();
+    // 上传对象名由服务端生成，凭证只能写入当前用户目录
+    final exts = images.map((image) {
+      final name = image.path.toLowerCase();
+      final dot = name.lastIndexOf('.');
+      return dot >= 0 ? name.substring(dot + 1) : 'jpg';
+    }).toList();
+    final sts = await _fetchSts(category: category, exts: exts);
     if (sts == null) return [];
+    final objectKeys = sts['object_keys'] is List
+        ? (sts['object_keys'] as List).map((e) => e.toString()).toList()
+        : <String>[];
+    if (objectKeys.length != images.length) return [];
+    final uploads = sts['uploads'] is List ? sts['uploads'] as List : const [];
     final accessKeyId = sts['access_key_id']?.toString() ?? '';
     final accessKeySecret = sts['access_key_secret']?.toString() ?? '';
     final securityToken = sts['security_token']?.toString() ?? '';
@@ -86,8 +101,10 @@ class OssUploadService {
         accessKeySecret.isEmpty ||
         securityToken.isEmpty ||
         endpointRaw.isEmpty ||
-        bucket.isEmpty) {
-      return [];
+        bucket.isEmpty ||
+        !Platform.isIOS) {
+      // 非 OSS 后端或未签发 STS 时，使用服务端返回的预签名 PUT 直传
+      return _uploadPresigned(images, uploads);
     }
     final endpoint = endpointRaw.startsWith('http')
         ? endpointRaw
@@ -100,8 +117,7 @@ class OssUploadService {
         'accessKeyId': accessKeyId,
         'accessKeySecret': accessKeySecret,
         'securityToken': securityToken,
-        'prefix': category,
-        'userId': auth.userId ?? 0,
+        'objectKeys': objectKeys,
       });
       if (result is Map && result['urls'] is List) {
         return (result['urls'] as List).map((e) => e.toString()).toList();
@@ -113,6 +129,40 @@ class OssUploadService {
     return [];
   }
 
+  static Future<List<String>> _uploadPresigned(
+    List<XFile> images,
+    List uploads,
+  ) async {
+    if (uploads.length != images.length) return [];
+    final urls = <String>[];
+    try {
+      for (var i = 0; i < images.length; i++) {
+        final upload = uploads[i];
+        if (upload is! Map) return [];
+        final url = upload['url']?.toString() ?? '';
+        if (url.isEmpty) return [];
+        final headers = <String, String>{};
+        if (upload['headers'] is Map) {
+          (upload['headers'] as Map).forEach((key, value) {
+            headers[key.toString()] = value.toString();
+          });
+        }
+        final response = await http.put(
+          Uri.parse(url),
+          headers: headers,
+          body: await images[i].readAsBytes(),
+        );
+        if (response.statusCode < 200 || response.statusCode >= 300) {
+          return [];
+        }
+        urls.add(url.split('?').first);
+      }
+    } catch (_) {
+      return [];
+    }
+    return urls;
+  }
+
   static Future<List<String>> signUrls(List<String> urls) async {
     if (urls.isEmpty) return [];
     final auth = AuthStore.instance;
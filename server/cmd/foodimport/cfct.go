@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 中国食物成分表 CSV 的列，按表头关键词识别（不同版本/整理者的表头不完全一致）。
+const (
+	cfctID = iota
+	cfctName
+	cfctAlias
+	cfctCategory
+	cfctEnergyKcal
+	cfctEnergyKJ
+	cfctProtein
+	cfctSatFat
+	cfctFat
+	cfctCarbs
+	cfctSugar
+	cfctFiber
+	cfctSodium
+)
+
+// importCFCT 读取 UTF-8 CSV，首行为表头；数值为每 100g 可食部，Tr（微量）、—、… 视为 0。
+func importCFCT(path string, emit func(foodRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	columns := map[int]int{}
+	for idx, title := range header {
+		if !utf8.ValidString(title) {
+			return errors.New("CSV 需为 UTF-8 编码（GBK 文件可先用 iconv -f GBK -t UTF-8 转换）")
+		}
+		field, ok := cfctColumn(title)
+		if !ok {
+			continue
+		}
+		if _, exists := columns[field]; !exists {
+			columns[field] = idx
+		}
+	}
+	if _, ok := columns[cfctName]; !ok {
+		return errors.New("CSV 缺少食物名称列")
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cell := func(field int) string {
+			idx, ok := columns[field]
+			if !ok || idx >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[idx])
+		}
+		record := foodRecord{
+			SourceID: cell(cfctID),
+			Name:     cell(cfctName),
+			Aliases:  splitAliases(cell(cfctAlias)),
+			Category: cell(cfctCategory),
+		}
+		record.Per100g.EnergyKcal = parseCompositionValue(cell(cfctEnergyKcal))
+		if record.Per100g.EnergyKcal == 0 {
+			record.Per100g.EnergyKcal = roundTenth(parseCompositionValue(cell(cfctEnergyKJ)) / 4.184)
+		}
+		record.Per100g.ProteinG = parseCompositionValue(cell(cfctProtein))
+		record.Per100g.FatG = parseCompositionValue(cell(cfctFat))
+		record.Per100g.SaturatedFatG = parseCompositionValue(cell(cfctSatFat))
+		record.Per100g.CarbsG = parseCompositionValue(cell(cfctCarbs))
+		record.Per100g.SugarG = parseCompositionValue(cell(cfctSugar))
+		record.Per100g.FiberG = parseCompositionValue(cell(cfctFiber))
+		record.Per100g.SodiumMg = parseCompositionValue(cell(cfctSodium))
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+}
+
+// cfctColumn 表头归类，如 "能量(kcal)"、"蛋白质/g"、"Sodium (mg)"。
+func cfctColumn(title string) (int, bool) {
+	t := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(title, "\ufeff")))
+	t = strings.NewReplacer(" ", "", "（", "(", "）", ")").Replace(t)
+	has := func(words ...string) bool {
+		for _, word := range words {
+			if strings.Contains(t, word) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("编码", "编号", "code") || t == "id":
+		return cfctID, true
+	case has("别名", "alias"):
+		return cfctAlias, true
+	case has("食物名称", "名称", "name"):
+		return cfctName, true
+	case has("分类", "类别", "category"):
+		return cfctCategory, true
+	case has("能量", "energy", "热量"):
+		if has("kj", "千焦") {
+			return cfctEnergyKJ, true
+		}
+		return cfctEnergyKcal, true
+	case has("蛋白质", "protein"):
+		return cfctProtein, true
+	case has("不饱和", "unsaturated"):
+		return 0, false
+	case has("饱和脂肪", "saturated"):
+		return cfctSatFat, true
+	case has("脂肪酸", "fatty"):
+		return 0, false
+	case has("脂肪", "fat"):
+		return cfctFat, true
+	case has("碳水化合物", "carbohydrate"):
+		return cfctCarbs, true
+	case has("膳食纤维", "不溶性纤维", "fiber", "fibre"):
+		return cfctFiber, true
+	case has("总糖", "糖", "sugar"):
+		return cfctSugar, true
+	case has("钠", "sodium"):
+		return cfctSodium, true
+	}
+	return 0, false
+}
+
+var compositionNumber = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// parseCompositionValue 成分表数值：Tr、—、…、空白与无法解析的值记为 0。
+func parseCompositionValue(raw string) float64 {
+	match := compositionNumber.FindString(raw)
+	if match == "" {
+		return 0
+	}
+	value, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func splitAliases(raw string) []string {
+	parts := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == ';' || r == '；' || r == '/'
+	})
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func roundTenth(value float64) float64 {
+	return float64(int64(value*10+0.5)) / 10
+}
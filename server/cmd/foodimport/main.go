@@ -0,0 +1,229 @@
+// foodimport 把本地的公开食物成分数据导入 food 表：
+//
+//	go run ./cmd/foodimport -source cfct -file 中国食物成分表.csv
+//	go run ./cmd/foodimport -source usda -file FoodData_Central_sr_legacy_food_json.json
+//	go run ./cmd/foodimport -source usda -dir FoodData_Central_csv/
+//	go run ./cmd/foodimport -source off -file openfoodfacts-products.jsonl.gz -country china
+//	go run ./cmd/foodimport -normalize-dishes
+//
+// cfct/usda 写入 food 表，同一来源重复导入时按 source_id 更新；off 写入 food_barcode 表（包装食品条码）。
+// -normalize-dishes 不导入数据，只按当前规则重算 dish 表已有行的名称键并补齐拼音列，
+// 并把旧格式的 nutrition_estimate 转换为 model.Nutrition（advice 移到 advice 列），可重复执行。
+// 数据库配置与服务端相同（config.yaml）。
+package main
+
+import (
+	"database/sql"
+	"eatclean/internal/config"
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+	"eatclean/internal/service"
+	"errors"
+	"flag"
+	"log"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// foodRecord 解析出的一条数据，营养为每 100g 可食部。
+type foodRecord struct {
+	SourceID string
+	Name     string
+	Aliases  []string
+	Category string
+	Per100g  model.NutritionFacts
+}
+
+// errImportLimit 达到 -limit 后停止读取。
+var errImportLimit = errors.New("import limit reached")
+
+func main() {
+	source := flag.String("source", "", "数据来源：cfct（中国食物成分表 CSV）、usda（FoodData Central JSON/CSV）或 off（Open Food Facts JSONL/CSV）")
+	file := flag.String("file", "", "CSV（cfct）、JSON（usda）或 JSONL/CSV（off，可为 .gz）文件")
+	dir := flag.String("dir", "", "USDA FoodData Central CSV 导出目录（含 food.csv、nutrient.csv、food_nutrient.csv）")
+	usdaTypes := flag.String("usda-types", "foundation_food,sr_legacy_food,survey_fndds_food", "CSV 导入时保留的 data_type，逗号分隔")
+	country := flag.String("country", "", "off 导入时只保留该国家/地区销售的商品，如 china")
+	limit := flag.Int("limit", 0, "最多导入条数，0 为不限")
+	dryRun := flag.Bool("dry-run", false, "只解析并统计，不写入数据库")
+	normalizeDishes := flag.Bool("normalize-dishes", false, "重算 dish 表已有行的名称键与拼音列、转换旧格式营养数据后退出")
+	flag.Parse()
+
+	if *normalizeDishes {
+		db := openDB()
+		defer db.Close()
+		dishRepo := repository.NewDishRepository(db)
+		if err := dishRepo.EnsureTable(); err != nil {
+			log.Fatal("Failed to ensure dish table:", err)
+		}
+		renamed, err := dishRepo.NormalizeNames()
+		if err != nil {
+			log.Fatal("Failed to normalize dish names:", err)
+		}
+		log.Printf("dish names normalized: updated=%d", renamed)
+		converted, err := dishRepo.NormalizeNutrition()
+		if err != nil {
+			log.Fatal("Failed to normalize dish nutrition:", err)
+		}
+		log.Printf("dish nutrition normalized: converted=%d", converted)
+		return
+	}
+
+	var repo *repository.FoodRepository
+	var barcodeRepo *repository.FoodBarcodeRepository
+	if !*dryRun {
+		db := openDB()
+		defer db.Close()
+		repo = repository.NewFoodRepository(db)
+		if err := repo.EnsureTable(); err != nil {
+			log.Fatal("Failed to ensure food table:", err)
+		}
+		barcodeRepo = repository.NewFoodBarcodeRepository(db)
+		if err := barcodeRepo.EnsureTable(); err != nil {
+			log.Fatal("Failed to ensure food_barcode table:", err)
+		}
+	}
+
+	var nutritionSource string
+	imported, skipped := 0, 0
+	emit := func(record foodRecord) error {
+		name := strings.TrimSpace(record.Name)
+		normalized := model.NormalizeFoodName(name)
+		if normalized == "" || record.Per100g.IsZero() {
+			skipped++
+			return nil
+		}
+		if *limit > 0 && imported >= *limit {
+			return errImportLimit
+		}
+		aliases := make([]string, 0, len(record.Aliases))
+		for _, alias := range record.Aliases {
+			if norm := model.NormalizeFoodName(alias); norm != "" && norm != normalized {
+				aliases = append(aliases, norm)
+			}
+		}
+		per100 := record.Per100g
+		food := &model.Food{
+			Source:         nutritionSource,
+			SourceID:       firstNonEmpty(strings.TrimSpace(record.SourceID), normalized),
+			Name:           name,
+			NormalizedName: normalized,
+			Aliases:        aliases,
+			Category:       strings.TrimSpace(record.Category),
+			Nutrition: &model.Nutrition{
+				Per100g:    &per100,
+				Confidence: model.DefaultNutritionConfidence(nutritionSource),
+				Source:     nutritionSource,
+			},
+		}
+		if repo != nil {
+			if err := repo.Upsert(food); err != nil {
+				return err
+			}
+		}
+		imported++
+		if imported%1000 == 0 {
+			log.Printf("imported %d foods", imported)
+		}
+		return nil
+	}
+
+	emitBarcode := func(record barcodeRecord) error {
+		barcode, err := service.NormalizeBarcode(record.Code)
+		name := strings.TrimSpace(record.Name)
+		// 能量超过 900 kcal/100g 的多为录入错误
+		if err != nil || name == "" || record.Per100g.EnergyKcal <= 0 || record.Per100g.EnergyKcal > 900 {
+			skipped++
+			return nil
+		}
+		if *limit > 0 && imported >= *limit {
+			return errImportLimit
+		}
+		per100 := record.Per100g
+		nutrition := &model.Nutrition{
+			Per100g:    &per100,
+			Confidence: model.DefaultNutritionConfidence(model.NutritionSourceOFF),
+			Source:     model.NutritionSourceOFF,
+		}
+		if record.ServingSizeG > 0 && record.ServingSizeG <= 5000 {
+			serving := per100.Scale(record.ServingSizeG / 100)
+			nutrition.PerServing = &serving
+			nutrition.ServingSizeG = record.ServingSizeG
+		}
+		if barcodeRepo != nil {
+			if err := barcodeRepo.Upsert(&model.FoodBarcode{
+				Barcode:   barcode,
+				Name:      name,
+				Brand:     record.Brand,
+				Quantity:  record.Quantity,
+				Nutrition: nutrition,
+			}); err != nil {
+				return err
+			}
+		}
+		imported++
+		if imported%10000 == 0 {
+			log.Printf("imported %d barcodes", imported)
+		}
+		return nil
+	}
+
+	var err error
+	switch *source {
+	case "cfct":
+		nutritionSource = model.NutritionSourceCFCT
+		if *file == "" {
+			log.Fatal("-file is required for cfct")
+		}
+		err = importCFCT(*file, emit)
+	case "usda":
+		nutritionSource = model.NutritionSourceUSDA
+		switch {
+		case *dir != "":
+			err = importUSDACSV(*dir, strings.Split(*usdaTypes, ","), emit)
+		case *file != "":
+			err = importUSDAJSON(*file, emit)
+		default:
+			log.Fatal("-file or -dir is required for usda")
+		}
+	case "off":
+		nutritionSource = model.NutritionSourceOFF
+		if *file == "" {
+			log.Fatal("-file is required for off")
+		}
+		err = importOFF(*file, *country, emitBarcode)
+	default:
+		log.Fatal("-source must be cfct, usda or off")
+	}
+	if err != nil && !errors.Is(err, errImportLimit) {
+		log.Fatalf("import failed after %d foods: %v", imported, err)
+	}
+	log.Printf("%s import done: imported=%d skipped=%d dry_run=%v", nutritionSource, imported, skipped, *dryRun)
+	if repo != nil && nutritionSource != model.NutritionSourceOFF {
+		if counts, err := repo.CountBySource(); err == nil {
+			log.Printf("food table: %v", counts)
+		}
+	}
+}
+
+// openDB 按 config.yaml 的数据库配置连接。
+func openDB() *sql.DB {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+	db, err := sql.Open("postgres", cfg.Database.DSN())
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	return db
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"eatclean/internal/model"
+)
+
+// barcodeRecord Open Food Facts 的一条商品。
+type barcodeRecord struct {
+	Code         string
+	Name         string
+	Brand        string
+	Quantity     string
+	ServingSizeG float64
+	Per100g      model.NutritionFacts
+}
+
+// importOFF 读取 Open Food Facts 数据导出：JSONL（openfoodfacts-products.jsonl）或制表符分隔的 CSV，可为 .gz。
+// country 非空时只保留 countries_tags 含 en:<country> 的商品。
+func importOFF(path string, country string, emit func(barcodeRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	name := strings.ToLower(path)
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	countryTag := ""
+	if country = strings.ToLower(strings.TrimSpace(country)); country != "" {
+		countryTag = "en:" + country
+	}
+	if strings.HasSuffix(name, ".jsonl") || strings.HasSuffix(name, ".json") {
+		return importOFFJSONL(reader, countryTag, emit)
+	}
+	return importOFFCSV(reader, countryTag, emit)
+}
+
+func importOFFJSONL(reader io.Reader, countryTag string, emit func(barcodeRecord) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var product struct {
+			Code            string                 `json:"code"`
+			ProductName     string                 `json:"product_name"`
+			ProductNameZh   string                 `json:"product_name_zh"`
+			Brands          string                 `json:"brands"`
+			Quantity        string                 `json:"quantity"`
+			ServingQuantity interface{}            `json:"serving_quantity"`
+			CountriesTags   []string               `json:"countries_tags"`
+			Nutriments      map[string]interface{} `json:"nutriments"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &product); err != nil {
+			continue
+		}
+		if countryTag != "" && !containsString(product.CountriesTags, countryTag) {
+			continue
+		}
+		record := barcodeRecord{
+			Code:         product.Code,
+			Name:         firstNonEmpty(strings.TrimSpace(product.ProductNameZh), strings.TrimSpace(product.ProductName)),
+			Brand:        firstBrand(product.Brands),
+			Quantity:     strings.TrimSpace(product.Quantity),
+			ServingSizeG: model.NutrientValue(map[string]interface{}{"v": product.ServingQuantity}, "v"),
+			Per100g:      offNutrition(product.Nutriments),
+		}
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func importOFFCSV(reader io.Reader, countryTag string, emit func(barcodeRecord) error) error {
+	r := csv.NewReader(reader)
+	r.Comma = '\t'
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return err
+	}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// 官方导出偶有字段内的换行/引号问题，跳过坏行
+			if _, ok := err.(*csv.ParseError); ok {
+				continue
+			}
+			return err
+		}
+		values := make(map[string]interface{}, len(header))
+		for idx, key := range header {
+			if idx < len(row) && row[idx] != "" {
+				values[key] = row[idx]
+			}
+		}
+		text := func(key string) string {
+			value, _ := values[key].(string)
+			return strings.TrimSpace(value)
+		}
+		if countryTag != "" && !containsString(strings.Split(text("countries_tags"), ","), countryTag) {
+			continue
+		}
+		record := barcodeRecord{
+			Code:         text("code"),
+			Name:         firstNonEmpty(text("product_name_zh"), text("product_name")),
+			Brand:        firstBrand(text("brands")),
+			Quantity:     text("quantity"),
+			ServingSizeG: model.NutrientValue(values, "serving_quantity"),
+			Per100g:      offNutrition(values),
+		}
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+}
+
+// offNutrition OFF 的 *_100g 字段；钠与盐以克为单位，能量缺少 kcal 时由 kJ 换算。
+func offNutrition(values map[string]interface{}) model.NutritionFacts {
+	facts := model.NutritionFacts{
+		EnergyKcal:    model.NutrientValue(values, "energy-kcal_100g"),
+		ProteinG:      model.NutrientValue(values, "proteins_100g"),
+		FatG:          model.NutrientValue(values, "fat_100g"),
+		SaturatedFatG: model.NutrientValue(values, "saturated-fat_100g"),
+		CarbsG:        model.NutrientValue(values, "carbohydrates_100g"),
+		SugarG:        model.NutrientValue(values, "sugars_100g"),
+		FiberG:        model.NutrientValue(values, "fiber_100g"),
+	}
+	if facts.EnergyKcal == 0 {
+		facts.EnergyKcal = roundTenth(model.NutrientValue(values, "energy-kj_100g", "energy_100g") / 4.184)
+	}
+	if sodium := model.NutrientValue(values, "sodium_100g"); sodium > 0 {
+		facts.SodiumMg = roundTenth(sodium * 1000)
+	} else if salt := model.NutrientValue(values, "salt_100g"); salt > 0 {
+		facts.SodiumMg = roundTenth(salt / 2.5 * 1000)
+	}
+	return facts
+}
+
+func firstBrand(brands string) string {
+	if idx := strings.Index(brands, ","); idx >= 0 {
+		brands = brands[:idx]
+	}
+	return strings.TrimSpace(brands)
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if strings.TrimSpace(value) == target {
+			return true
+		}
+	}
+	return false
+}
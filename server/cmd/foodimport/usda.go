@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"eatclean/internal/model"
+)
+
+// USDA 营养素编号（nutrient_nbr），数值均为每 100g。
+// 能量优先 208（kcal），Foundation Foods 缺失时用 958/957（Atwater 系数换算）。
+var usdaEnergyNumbers = []string{"208", "958", "957"}
+
+var usdaNutrientSetters = map[string]func(*model.NutritionFacts, float64){
+	"203": func(f *model.NutritionFacts, v float64) { f.ProteinG = v },
+	"204": func(f *model.NutritionFacts, v float64) { f.FatG = v },
+	"606": func(f *model.NutritionFacts, v float64) { f.SaturatedFatG = v },
+	"205": func(f *model.NutritionFacts, v float64) { f.CarbsG = v },
+	"269": func(f *model.NutritionFacts, v float64) { f.SugarG = v },
+	"291": func(f *model.NutritionFacts, v float64) { f.FiberG = v },
+	"307": func(f *model.NutritionFacts, v float64) { f.SodiumMg = v },
+}
+
+// usdaFacts 按营养素编号累积，能量按 usdaEnergyNumbers 的优先级取值。
+type usdaFacts struct {
+	facts  model.NutritionFacts
+	energy map[string]float64
+}
+
+func (u *usdaFacts) set(number string, amount float64) {
+	if amount <= 0 {
+		return
+	}
+	if setter, ok := usdaNutrientSetters[number]; ok {
+		setter(&u.facts, amount)
+		return
+	}
+	for _, candidate := range usdaEnergyNumbers {
+		if number == candidate {
+			if u.energy == nil {
+				u.energy = map[string]float64{}
+			}
+			u.energy[number] = amount
+		}
+	}
+}
+
+func (u *usdaFacts) result() model.NutritionFacts {
+	facts := u.facts
+	for _, number := range usdaEnergyNumbers {
+		if value, ok := u.energy[number]; ok {
+			facts.EnergyKcal = value
+			break
+		}
+	}
+	return facts
+}
+
+type usdaJSONFood struct {
+	FdcID        int64  `json:"fdcId"`
+	Description  string `json:"description"`
+	FoodCategory *struct {
+		Description string `json:"description"`
+	} `json:"foodCategory"`
+	BrandedFoodCategory string `json:"brandedFoodCategory"`
+	FoodNutrients       []struct {
+		Nutrient struct {
+			Number   string `json:"number"`
+			UnitName string `json:"unitName"`
+		} `json:"nutrient"`
+		Amount float64 `json:"amount"`
+	} `json:"foodNutrients"`
+}
+
+// importUSDAJSON 读取 FoodData Central 的 JSON 下载（{"FoundationFoods": [...]}、{"SRLegacyFoods": [...]} 等，
+// 或直接是数组），逐条解码，Branded Foods 这类大文件不会整体读入内存。
+func importUSDAJSON(path string, emit func(foodRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == json.Delim('{') {
+		// 数据位于顶层对象的第一个字段
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		next, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if next != json.Delim('[') {
+			return fmt.Errorf("field %v is not an array", key)
+		}
+	} else if token != json.Delim('[') {
+		return fmt.Errorf("unexpected token %v", token)
+	}
+
+	for decoder.More() {
+		var food usdaJSONFood
+		if err := decoder.Decode(&food); err != nil {
+			return err
+		}
+		var acc usdaFacts
+		for _, item := range food.FoodNutrients {
+			if strings.EqualFold(item.Nutrient.UnitName, "kj") {
+				continue
+			}
+			acc.set(item.Nutrient.Number, item.Amount)
+		}
+		category := food.BrandedFoodCategory
+		if food.FoodCategory != nil {
+			category = food.FoodCategory.Description
+		}
+		if err := emit(foodRecord{
+			SourceID: strconv.FormatInt(food.FdcID, 10),
+			Name:     food.Description,
+			Category: category,
+			Per100g:  acc.result(),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importUSDACSV 读取 FoodData Central 的 CSV 导出目录：
+// food.csv 按 data_type 过滤，nutrient.csv 把 nutrient_id 映射为编号，food_nutrient.csv 逐行累积。
+func importUSDACSV(dir string, dataTypes []string, emit func(foodRecord) error) error {
+	keepTypes := map[string]bool{}
+	for _, dataType := range dataTypes {
+		if dataType = strings.TrimSpace(dataType); dataType != "" {
+			keepTypes[dataType] = true
+		}
+	}
+
+	type usdaCSVFood struct {
+		name string
+		acc  usdaFacts
+	}
+	foods := map[string]*usdaCSVFood{}
+	var order []string
+	err := readCSV(filepath.Join(dir, "food.csv"), func(row map[string]string) error {
+		if len(keepTypes) > 0 && !keepTypes[row["data_type"]] {
+			return nil
+		}
+		id := row["fdc_id"]
+		foods[id] = &usdaCSVFood{name: row["description"]}
+		order = append(order, id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	numbers := map[string]string{}
+	err = readCSV(filepath.Join(dir, "nutrient.csv"), func(row map[string]string) error {
+		if strings.EqualFold(row["unit_name"], "kj") {
+			return nil
+		}
+		numbers[row["id"]] = strings.TrimSuffix(row["nutrient_nbr"], ".0")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = readCSV(filepath.Join(dir, "food_nutrient.csv"), func(row map[string]string) error {
+		food, ok := foods[row["fdc_id"]]
+		if !ok {
+			return nil
+		}
+		number, ok := numbers[row["nutrient_id"]]
+		if !ok {
+			return nil
+		}
+		amount, err := strconv.ParseFloat(row["amount"], 64)
+		if err != nil {
+			return nil
+		}
+		food.acc.set(number, amount)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range order {
+		food := foods[id]
+		if err := emit(foodRecord{SourceID: id, Name: food.name, Per100g: food.acc.result()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCSV 按表头把每行转换为 map 交给 fn。
+func readCSV(path string, fn func(map[string]string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: read header: %w", filepath.Base(path), err)
+	}
+	columns := append([]string(nil), header...)
+	if len(columns) > 0 {
+		columns[0] = strings.TrimPrefix(columns[0], "\ufeff")
+	}
+	row := make(map[string]string, len(columns))
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		for idx, name := range columns {
+			if idx < len(record) {
+				row[name] = record[idx]
+			} else {
+				row[name] = ""
+			}
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
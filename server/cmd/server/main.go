@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"eatclean/internal/config"
 	"eatclean/internal/handler"
 	"eatclean/internal/middleware"
 	"eatclean/internal/repository"
 	"eatclean/internal/service"
+	"expvar"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
@@ -43,37 +46,111 @@ func main() {
 	chatMessageRepo := repository.NewChatMessageRepository(db)
 	dailyIntakeRepo := repository.NewDailyIntakeRepository(db)
 	dishRepo := repository.NewDishRepository(db)
+	foodRepo := repository.NewFoodRepository(db)
+	foodBarcodeRepo := repository.NewFoodBarcodeRepository(db)
+	customFoodRepo := repository.NewCustomFoodRepository(db)
+	recipeRepo := repository.NewRecipeRepository(db)
+	favoriteRepo := repository.NewFavoriteRepository(db)
+	frequentItemRepo := repository.NewFrequentItemRepository(db)
 	weeklyMenuRepo := repository.NewWeeklyMenuRepository(db)
 	subscriptionRepo := repository.NewSubscriptionRepository(db)
+	foodSearchLogRepo := repository.NewFoodSearchLogRepository(db)
+	imageAssetRepo := repository.NewImageAssetRepository(db)
+	visionCacheRepo := repository.NewVisionCacheRepository(db)
+	imageRetentionRepo := repository.NewImageRetentionRepository(db)
+	aiJobRepo := repository.NewAIJobRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	quotaReservationRepo := repository.NewQuotaReservationRepository(db)
 
 	// 初始化 services
 	authService := service.NewAuthService(userRepo, &cfg.JWT, &cfg.Apple)
 	menuService := service.NewMenuService()
 	settingsService := service.NewSettingsService(settingsRepo)
-	mealRecordService := service.NewMealRecordService(mealRecordRepo)
+	mealRecordService := service.NewMealRecordService(mealRecordRepo, frequentItemRepo)
 	menuScanService := service.NewMenuScanService(menuScanRepo)
-	visionService := service.NewVisionService(&cfg.Qwen)
-	chatAIService := service.NewChatAIService(&cfg.Qwen)
-	ossService := service.NewOssService(&cfg.OSS)
+	aiTransport := service.NewAITransport(&cfg.Qwen)
+	visionService := service.NewVisionService(&cfg.Qwen, aiTransport)
+	chatAIService := service.NewChatAIService(&cfg.Qwen, aiTransport)
+	objectStore, err := service.NewObjectStore(cfg)
+	if err != nil {
+		log.Fatal("Failed to init object storage:", err)
+	}
+	storageService := service.NewStorageService(objectStore, userRepo, imageRetentionRepo, cfg.Storage.InlineImages, cfg.Storage.LegacyPrefixes)
+	imageAssetService := service.NewImageAssetService(imageAssetRepo, storageService, cfg.Images)
+	visionCacheService := service.NewVisionCacheService(visionCacheRepo, cfg.VisionCache)
+	imagePrecheckService := service.NewImagePrecheckService(visionService, cfg.Images.Precheck)
+	quotaService := service.NewQuotaService(quotaReservationRepo, cfg.Quota)
+	quotaService.Start(context.Background())
+	aiJobService := service.NewAIJobService(aiJobRepo, quotaService, cfg.Jobs)
+	idempotencyService := service.NewIdempotencyService(idempotencyRepo, cfg.Idempotency)
+	idempotencyService.Start(context.Background())
+	imageRetentionService := service.NewImageRetentionService(objectStore, imageRetentionRepo, imageAssetRepo, cfg.Images.Retention)
 	chatMessageService := service.NewChatMessageService(chatMessageRepo)
-	dailyIntakeService := service.NewDailyIntakeService(dailyIntakeRepo)
-	dishService := service.NewDishService(dishRepo)
+	dailyIntakeService := service.NewDailyIntakeService(dailyIntakeRepo, mealRecordService)
+	foodService := service.NewFoodService(foodRepo)
+	barcodeService := service.NewBarcodeService(foodBarcodeRepo)
+	customFoodService := service.NewCustomFoodService(customFoodRepo)
+	foodSuggestService := service.NewFoodSuggestService(foodRepo, dishRepo, customFoodRepo)
+	dishService := service.NewDishService(dishRepo, foodService)
+	recipeService := service.NewRecipeService(recipeRepo, foodService, customFoodService, dishService)
+	favoriteService := service.NewFavoriteService(favoriteRepo, recipeService, foodService, customFoodService)
+	quickPickService := service.NewQuickPickService(mealRecordService, favoriteService, settingsService)
+	nutritionTargetService := service.NewNutritionTargetService(settingsService)
 	weeklyMenuService := service.NewWeeklyMenuService(weeklyMenuRepo)
 	subscriptionService := service.NewSubscriptionService(subscriptionRepo)
+	foodSearchLogService := service.NewFoodSearchLogService(foodSearchLogRepo)
+
+	// 加载并校验提示词模板，之后按间隔检测文件变更自动重载
+	promptRegistry := service.NewPromptRegistry(cfg.Prompts)
+	promptReport, err := promptRegistry.Load()
+	if err != nil {
+		log.Fatal("Failed to load prompt templates:", err)
+	}
+	for _, item := range promptReport {
+		if item.Error != "" {
+			log.Printf("Prompt %s/%s not loaded: %s", item.Name, item.Variant, item.Error)
+		}
+	}
+	go promptRegistry.Watch(context.Background(), time.Duration(cfg.Prompts.ReloadIntervalSeconds)*time.Second)
+	// 孤立图片清理（images.retention.enabled）
+	imageRetentionService.Start(context.Background())
+	promptContextBuilder := service.NewPromptContextBuilder(
+		promptRegistry,
+		settingsService,
+		mealRecordService,
+		chatMessageService,
+		menuScanService,
+		dailyIntakeService,
+		foodSearchLogService,
+	)
 
 	// 初始化 handlers
-	authHandler := handler.NewAuthHandler(authService, settingsService, subscriptionService)
-	menuHandler := handler.NewMenuHandler(menuService, menuScanService, visionService, ossService, settingsService, dishService, mealRecordService, dailyIntakeService, subscriptionService)
+	authHandler := handler.NewAuthHandler(authService, settingsService, subscriptionService, storageService)
+	menuHandler := handler.NewMenuHandler(menuService, menuScanService, visionService, imageAssetService, visionCacheService, imagePrecheckService, aiJobService, dishService, subscriptionService, quotaService, promptContextBuilder)
 	settingsHandler := handler.NewSettingsHandler(settingsService)
-	mealRecordHandler := handler.NewMealRecordHandler(mealRecordService, visionService, ossService, settingsService, dishService, dailyIntakeService, subscriptionService)
-	ossHandler := handler.NewOssHandler(ossService, &cfg.OSS)
-	chatHandler := handler.NewChatMessageHandler(chatMessageService, ossService)
-	chatCompleteHandler := handler.NewChatCompleteHandler(chatAIService, settingsService, chatMessageService, ossService, mealRecordService, dailyIntakeService, subscriptionService)
-	dailyIntakeHandler := handler.NewDailyIntakeHandler(dailyIntakeService)
-	discoverHandler := handler.NewDiscoverHandler(chatAIService, settingsService, dishService, mealRecordService, weeklyMenuService, dailyIntakeService, subscriptionService)
+	nutritionTargetHandler := handler.NewNutritionTargetHandler(nutritionTargetService)
+	mealRecordHandler := handler.NewMealRecordHandler(mealRecordService, visionService, storageService, imageAssetService, visionCacheService, imagePrecheckService, aiJobService, dishService, subscriptionService, quotaService, promptContextBuilder, quickPickService)
+	storageHandler := handler.NewStorageHandler(storageService, &cfg.OSS)
+	uploadHandler := handler.NewUploadHandler(imageAssetService)
+	chatHandler := handler.NewChatMessageHandler(chatMessageService, storageService, imageAssetService)
+	chatCompleteHandler := handler.NewChatCompleteHandler(chatAIService, chatMessageService, storageService, imageAssetService, subscriptionService, quotaService, promptContextBuilder)
+	dailyIntakeHandler := handler.NewDailyIntakeHandler(dailyIntakeService, settingsService)
+	discoverHandler := handler.NewDiscoverHandler(chatAIService, dishService, weeklyMenuService, subscriptionService, promptContextBuilder, aiJobService)
 	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionService, &cfg.Apple)
 	usageHandler := handler.NewUsageHandler(menuScanService, mealRecordService, chatMessageService, subscriptionService)
-	foodHandler := handler.NewFoodHandler(dishRepo, chatAIService)
+	foodHandler := handler.NewFoodHandler(dishRepo, foodService, barcodeService, customFoodService, foodSuggestService, chatAIService, foodSearchLogService)
+	foodLabelHandler := handler.NewFoodLabelHandler(visionService, imageAssetService, imagePrecheckService, customFoodService)
+	recipeHandler := handler.NewRecipeHandler(recipeService, imageAssetService, mealRecordService)
+	favoriteHandler := handler.NewFavoriteHandler(favoriteService)
+	promptAdminHandler := handler.NewPromptAdminHandler(promptRegistry)
+	imageAdminHandler := handler.NewImageAdminHandler(imageRetentionService)
+	jobHandler := handler.NewJobHandler(aiJobService)
+
+	// 异步 AI 任务：入队后由 jobs.workers 个 worker 执行，与 HTTP 并发分开
+	aiJobService.Register(service.AIJobMenuScan, menuHandler.RunScanJob)
+	aiJobService.Register(service.AIJobMealPhoto, mealRecordHandler.RunPhotoJob)
+	aiJobService.Register(service.AIJobWeeklyMenu, discoverHandler.RunWeeklyMenuJob)
+	aiJobService.Start(context.Background())
 
 	// 创建 Echo 实例
 	e := echo.New()
@@ -88,6 +165,12 @@ func main() {
 		return c.JSON(200, map[string]string{"status": "ok"})
 	})
 
+	// 本地存储的签名上传/下载（storage.backend=local），签名 URL 自带鉴权
+	if objectStore.Backend() == service.StorageBackendLocal {
+		e.GET(service.LocalFilesPath+"*", storageHandler.ServeLocal)
+		e.PUT(service.LocalFilesPath+"*", storageHandler.UploadLocal)
+	}
+
 	// API 路由
 	api := e.Group("/eatclean/api/v1")
 
@@ -99,12 +182,15 @@ func main() {
 	// 需要认证的路由
 	protected := api.Group("")
 	protected.Use(middleware.JWTAuth(authService))
+	// 在额度校验之前：重试直接重放首次响应，不重复计费
+	protected.Use(middleware.Idempotency(idempotencyService, "/meals/photo", "/meals/copy", "/menu/scan", "/subscription/verify"))
 
 	quotaGuard := middleware.UsageQuotaGuard(
 		menuScanService,
 		mealRecordService,
 		chatMessageService,
 		subscriptionService,
+		quotaService,
 	)
 
 	metered := protected.Group("")
@@ -116,14 +202,31 @@ func main() {
 	metered.POST("/menu/scan", menuHandler.ScanImages)
 	protected.POST("/user/settings", settingsHandler.Upsert)
 	protected.GET("/user/settings", settingsHandler.Get)
+	protected.GET("/nutrition/targets", nutritionTargetHandler.Recommend)
+	protected.POST("/nutrition/targets", nutritionTargetHandler.Recommend)
 	protected.POST("/meals", mealRecordHandler.Create)
 	metered.POST("/meals/photo", mealRecordHandler.CreateFromPhoto)
 	metered.POST("/meals/analyze", mealRecordHandler.AnalyzeFromPhoto)
 	metered.POST("/ingredients/scan", mealRecordHandler.ScanIngredients)
 	protected.GET("/meals", mealRecordHandler.List)
+	protected.PATCH("/meals/:id/items/:index", mealRecordHandler.UpdateItemPortion)
+	protected.GET("/meals/quick-picks", mealRecordHandler.QuickPicks)
+	protected.POST("/meals/copy", mealRecordHandler.Copy)
+	protected.GET("/favorites", favoriteHandler.List)
+	protected.POST("/favorites", favoriteHandler.Add)
+	protected.DELETE("/favorites/:id", favoriteHandler.Delete)
+	protected.GET("/recipes", recipeHandler.List)
+	protected.POST("/recipes", recipeHandler.Create)
+	protected.POST("/recipes/import", recipeHandler.Import)
+	protected.GET("/recipes/:id", recipeHandler.Get)
+	protected.PUT("/recipes/:id", recipeHandler.Update)
+	protected.DELETE("/recipes/:id", recipeHandler.Delete)
+	protected.POST("/recipes/:id/log", recipeHandler.Log)
 	protected.POST("/intake/daily", dailyIntakeHandler.UpsertDailyIntake)
-	metered.GET("/oss/sts", ossHandler.GetSTS)
-	metered.POST("/oss/sign", ossHandler.SignURLs)
+	protected.GET("/intake/daily", dailyIntakeHandler.GetDailyIntake)
+	metered.GET("/oss/sts", storageHandler.GetSTS)
+	metered.POST("/oss/sign", storageHandler.SignURLs)
+	metered.POST("/uploads/images", uploadHandler.UploadImages)
 	metered.POST("/chat/messages", chatHandler.Create)
 	protected.GET("/chat/messages", chatHandler.List)
 	metered.POST("/chat/complete", chatCompleteHandler.Complete)
@@ -132,9 +235,28 @@ func main() {
 	metered.POST("/discover/weekly/generate", discoverHandler.GenerateWeeklyMenus)
 	metered.POST("/discover/weekly/save", discoverHandler.SaveWeeklyMenus)
 	metered.POST("/food/search", foodHandler.Search)
+	metered.POST("/food/barcode", foodHandler.Barcode)
+	protected.GET("/food/suggest", foodHandler.Suggest)
+	protected.POST("/food/barcode/correction", foodHandler.CorrectBarcode)
+	metered.POST("/food/label/scan", foodLabelHandler.Scan)
+	protected.GET("/food/custom", foodHandler.ListCustom)
+	protected.POST("/food/custom", foodHandler.SaveCustom)
+	protected.DELETE("/food/custom/:id", foodHandler.DeleteCustom)
 	protected.POST("/subscription/verify", subscriptionHandler.Verify)
 	protected.POST("/subscription/restore", subscriptionHandler.Restore)
 	protected.POST("/usage/check", usageHandler.Check)
+	protected.GET("/jobs/:id", jobHandler.Get)
+	protected.GET("/jobs/:id/events", jobHandler.Events)
+
+	// 管理接口（X-Admin-Token）
+	admin := api.Group("/admin")
+	admin.Use(middleware.AdminAuth(cfg.Admin.Token))
+	admin.GET("/prompts", promptAdminHandler.List)
+	admin.POST("/prompts/reload", promptAdminHandler.Reload)
+	admin.GET("/images/retention", imageAdminHandler.Retention)
+	admin.POST("/images/retention/run", imageAdminHandler.RunRetention)
+	// 运行指标（AI 结构化输出解析成功/修复/失败次数等），含进程信息，仅管理端可见
+	admin.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
 
 	// 启动服务器
 	addr := fmt.Sprintf(":%s", cfg.Server.Port)
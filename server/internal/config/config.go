@@ -16,7 +16,18 @@ type Config struct {
 	Apple    AppleConfig    `yaml:"apple"`
 	Qwen     QwenConfig     `yaml:"qwen"`
 	OSS      OSSConfig      `yaml:"oss"`
-	Prompts  PromptConfig   `yaml:"prompts"`
+	Storage  StorageConfig  `yaml:"storage"`
+	Images   ImageConfig    `yaml:"images"`
+	// VisionCache 视觉识别结果缓存
+	VisionCache VisionCacheConfig `yaml:"vision_cache"`
+	// Jobs 异步 AI 任务队列
+	Jobs AIJobConfig `yaml:"jobs"`
+	// Idempotency Idempotency-Key 请求去重
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	// Quota 额度预留与单用户并发上限
+	Quota   QuotaConfig  `yaml:"quota"`
+	Prompts PromptConfig `yaml:"prompts"`
+	Admin   AdminConfig  `yaml:"admin"`
 }
 
 type ServerConfig struct {
@@ -47,9 +58,40 @@ type AppleConfig struct {
 }
 
 type QwenConfig struct {
-	APIKey  string `yaml:"api_key"`
-	BaseURL string `yaml:"base_url"`
-	Model   string `yaml:"model"`
+	APIKey  string                   `yaml:"api_key"`
+	BaseURL string                   `yaml:"base_url"`
+	Model   string                   `yaml:"model"`
+	Routes  map[string]AIRouteConfig `yaml:"routes"`
+	// Transport 所有 AI 调用共享的重试、熔断与并发限制
+	Transport AITransportConfig `yaml:"transport"`
+}
+
+type AITransportConfig struct {
+	// MaxRetries 单个模型遇到 429/5xx/网络错误时的重试次数，未配置时为 2，0 或负数关闭重试
+	MaxRetries  *int `yaml:"max_retries"`
+	RetryBaseMs int  `yaml:"retry_base_ms"`
+	RetryMaxMs  int  `yaml:"retry_max_ms"`
+	// MaxConcurrency 全局同时进行的 AI 请求上限
+	MaxConcurrency int `yaml:"max_concurrency"`
+	// QueueTimeoutMs 等待并发名额的最长时间，超时返回繁忙
+	QueueTimeoutMs int `yaml:"queue_timeout_ms"`
+	// BreakerFailures 路由连续失败多少次后熔断
+	BreakerFailures        int `yaml:"breaker_failures"`
+	BreakerCooldownSeconds int `yaml:"breaker_cooldown_seconds"`
+}
+
+// AIRouteConfig 单个 AI 功能的模型路由：主模型失败/超时后按 fallbacks 顺序重试。
+type AIRouteConfig struct {
+	Model       string   `yaml:"model"`
+	Fallbacks   []string `yaml:"fallbacks"`
+	Temperature *float64 `yaml:"temperature"`
+	MaxTokens   int      `yaml:"max_tokens"`
+	// JSONMode 结构化输出时请求 response_format=json_object（需模型支持）
+	JSONMode bool `yaml:"json_mode"`
+	// TimeoutSeconds 单次请求超时（每次重试/每个模型分别计算）
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+	// MaxConcurrency 该路由同时进行的请求上限，0 表示只受全局限制
+	MaxConcurrency int `yaml:"max_concurrency"`
 }
 
 type OSSConfig struct {
@@ -63,6 +105,131 @@ type OSSConfig struct {
 	StsEndpoint     string `yaml:"sts_endpoint"`
 }
 
+// StorageConfig 图片存储后端：oss（阿里云，使用上面的 oss 配置）、s3（S3/MinIO 兼容）、local（本地磁盘，由本服务签名与提供下载）。
+type StorageConfig struct {
+	Backend string `yaml:"backend"`
+	// InlineImages 传给视觉模型的方式：auto（local 内联 base64，其余传签名 URL）、always、never
+	InlineImages string `yaml:"inline_images"`
+	// LegacyPrefixes 按用户目录（users/）存放之前上传的对象名前缀，"*" 表示 users/ 以外的全部对象；
+	// 这些对象只有被该用户自己的记录引用时才视为其所有
+	LegacyPrefixes []string           `yaml:"legacy_prefixes"`
+	S3             S3StorageConfig    `yaml:"s3"`
+	Local          LocalStorageConfig `yaml:"local"`
+}
+
+type S3StorageConfig struct {
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	// PathStyle 使用 endpoint/bucket/key 形式（MinIO 通常需要开启）
+	PathStyle bool `yaml:"path_style"`
+}
+
+type LocalStorageConfig struct {
+	Root string `yaml:"root"`
+	// BaseURL 生成签名 URL 的外部访问地址，默认 http://localhost:{port}
+	BaseURL string `yaml:"base_url"`
+	// Secret 签名密钥，为空时使用 jwt.secret
+	Secret      string `yaml:"secret"`
+	MaxUploadMB int    `yaml:"max_upload_mb"`
+}
+
+// ImageConfig 图片入库处理：校验、去除 EXIF、纠正方向并生成模型尺寸与缩略图。
+type ImageConfig struct {
+	MaxUploadMB int `yaml:"max_upload_mb"`
+	// MaxMegapixels 解码前按尺寸拒绝超大图片，防止解压炸弹；解码后按 RGBA 每像素 4 字节占用内存
+	MaxMegapixels int `yaml:"max_megapixels"`
+	// ModelMaxSide 传给视觉模型的版本最长边（像素）
+	ModelMaxSide int `yaml:"model_max_side"`
+	ThumbMaxSide int `yaml:"thumb_max_side"`
+	JPEGQuality  int `yaml:"jpeg_quality"`
+	// Precheck 调用视觉识别前的图片质量与相关性预检
+	Precheck ImagePrecheckConfig `yaml:"precheck"`
+	// Retention 存储桶中无引用图片的清理与按分类保留期
+	Retention ImageRetentionConfig `yaml:"retention"`
+}
+
+// ImageRetentionConfig 定期扫描 users/ 下的对象：超过宽限期仍无任何记录引用的删除；
+// 配置了保留天数的分类到期后即使仍被引用也删除。
+type ImageRetentionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// DryRun 定时任务只生成报告不删除
+	DryRun        bool `yaml:"dry_run"`
+	IntervalHours int  `yaml:"interval_hours"`
+	// GraceHours 上传后多久仍未被引用才视为孤立（客户端先上传后提交记录）
+	GraceHours int `yaml:"grace_hours"`
+	// MaxDeletes 单次最多删除的对象数，防止引用查询异常时误删整个桶
+	MaxDeletes int    `yaml:"max_deletes"`
+	Prefix     string `yaml:"prefix"`
+	// Categories 分类 → 保留天数，0 或未配置表示保留到引用记录删除为止
+	Categories map[string]int `yaml:"categories"`
+}
+
+// ImagePrecheckConfig 预检不合格的图片不调用识别、不消耗额度。
+type ImagePrecheckConfig struct {
+	// Mode reject（全部图片不合格时拒绝，部分不合格时提示）、warn（只提示）、off（关闭）
+	Mode string `yaml:"mode"`
+	// MinSharpness 拉普拉斯方差下限，低于视为模糊
+	MinSharpness float64 `yaml:"min_sharpness"`
+	// MinBrightness/MaxBrightness 平均亮度（0-255）范围，超出视为过暗/过曝
+	MinBrightness float64 `yaml:"min_brightness"`
+	MaxBrightness float64 `yaml:"max_brightness"`
+	// RelevanceCheck 额外调用轻量视觉模型（qwen.routes.image_precheck）判断是否为食物/菜单/配料表
+	RelevanceCheck bool `yaml:"relevance_check"`
+	// MinConfidence 模型判断为不相关的置信度达到该值才算不合格
+	MinConfidence float64 `yaml:"min_confidence"`
+}
+
+// VisionCacheConfig 相同/近似图片、相同提示词版本与用户上下文的识别结果在窗口期内直接复用。
+type VisionCacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// WindowHours 缓存有效期（小时），未配置默认 72
+	WindowHours int `yaml:"window_hours"`
+	// MaxHashDistance 近似图片允许的感知哈希汉明距离，未配置默认 6，负数只匹配完全相同的图片
+	MaxHashDistance int `yaml:"max_hash_distance"`
+	// ChargeOnHit 命中缓存时是否计入当日额度
+	ChargeOnHit bool `yaml:"charge_on_hit"`
+}
+
+// AIJobConfig 异步 AI 任务（ai_job 表）：worker 并发与 HTTP 并发分开配置。
+type AIJobConfig struct {
+	// Workers 本实例同时执行的任务数，负数表示本实例不执行任务（只入队）
+	Workers        int `yaml:"workers"`
+	PollIntervalMs int `yaml:"poll_interval_ms"`
+	// MaxAttempts 含首次执行的最大尝试次数
+	MaxAttempts int `yaml:"max_attempts"`
+	// RetryBaseSeconds/RetryMaxSeconds 失败后按指数退避重新排队
+	RetryBaseSeconds int `yaml:"retry_base_seconds"`
+	RetryMaxSeconds  int `yaml:"retry_max_seconds"`
+	// TimeoutSeconds 单次执行超时
+	TimeoutSeconds int `yaml:"timeout_seconds"`
+	// StaleSeconds 执行中的任务超过该时间未完成（实例宕机）时重新领取
+	StaleSeconds int `yaml:"stale_seconds"`
+	// RetentionDays 已结束任务的保留天数
+	RetentionDays int `yaml:"retention_days"`
+}
+
+// IdempotencyConfig 带 Idempotency-Key 的重复请求在 TTL 内重放首次响应。
+type IdempotencyConfig struct {
+	TTLHours int `yaml:"ttl_hours"`
+	// InFlightTimeoutSeconds 处理中的记录超过该时间（实例宕机）视为放弃，允许重新执行
+	InFlightTimeoutSeconds int `yaml:"in_flight_timeout_seconds"`
+	// MaxResponseKB 超过该大小的响应不保存，重试时重新执行
+	MaxResponseKB int `yaml:"max_response_kb"`
+	// MaxRequestKB 带 Idempotency-Key 的请求体上限，超过返回 413
+	MaxRequestKB int `yaml:"max_request_kb"`
+}
+
+// QuotaConfig 调用 AI 前原子预留额度，成功后确认、失败后释放。
+type QuotaConfig struct {
+	// ReservationTTLMinutes 未确认的预留超过该时间自动失效（实例宕机），需覆盖异步任务的重试时间
+	ReservationTTLMinutes int `yaml:"reservation_ttl_minutes"`
+	// MaxInFlight 单个用户同时进行的计费 AI 请求数（含排队中的异步任务）
+	MaxInFlight int `yaml:"max_in_flight"`
+}
+
 type PromptConfig struct {
 	MenuScanPath        string `yaml:"menu_scan_path"`
 	FoodScanPath        string `yaml:"food_scan_path"`
@@ -70,6 +237,22 @@ type PromptConfig struct {
 	DiscoverPlanPath    string `yaml:"discover_plan_path"`
 	DiscoverReplacePath string `yaml:"discover_replace_path"`
 	ChatPromptPath      string `yaml:"chat_prompt_path"`
+	// ReloadIntervalSeconds 轮询模板文件变更的间隔（秒），未配置默认 30，负数关闭自动重载
+	ReloadIntervalSeconds int `yaml:"reload_interval_seconds"`
+	// Variants 按模板名配置 A/B 变体，未命中任何变体的用户使用默认模板
+	Variants map[string][]PromptVariantConfig `yaml:"variants"`
+}
+
+type PromptVariantConfig struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	// Weight 分配到该变体的用户百分比（1-100），同一模板各变体合计不超过 100，否则启动失败
+	Weight int `yaml:"weight"`
+}
+
+type AdminConfig struct {
+	// Token 管理接口使用的 X-Admin-Token，为空时管理接口不可用
+	Token string `yaml:"token"`
 }
 
 var (
@@ -156,12 +339,127 @@ func applyDefaults(cfg *Config) {
 	if cfg.Apple.PrivateKeyPath == "" {
 		cfg.Apple.PrivateKeyPath = "p8/AuthKey_979F55DL33.p8"
 	}
+	if cfg.Storage.Backend == "" {
+		cfg.Storage.Backend = "oss"
+	}
+	if cfg.Storage.InlineImages == "" {
+		cfg.Storage.InlineImages = "auto"
+	}
+	if cfg.Storage.S3.Region == "" {
+		cfg.Storage.S3.Region = "us-east-1"
+	}
+	if cfg.Storage.Local.Root == "" {
+		cfg.Storage.Local.Root = "data/uploads"
+	}
+	if cfg.Storage.Local.BaseURL == "" {
+		cfg.Storage.Local.BaseURL = "http://localhost:" + cfg.Server.Port
+	}
+	if cfg.Storage.Local.Secret == "" {
+		cfg.Storage.Local.Secret = cfg.JWT.Secret
+	}
+	if cfg.Storage.Local.MaxUploadMB <= 0 {
+		cfg.Storage.Local.MaxUploadMB = 10
+	}
+	if cfg.Images.MaxUploadMB <= 0 {
+		cfg.Images.MaxUploadMB = 10
+	}
+	if cfg.Images.MaxMegapixels <= 0 {
+		cfg.Images.MaxMegapixels = 25
+	}
+	if cfg.Images.ModelMaxSide <= 0 {
+		cfg.Images.ModelMaxSide = 1280
+	}
+	if cfg.Images.ThumbMaxSide <= 0 {
+		cfg.Images.ThumbMaxSide = 320
+	}
+	if cfg.Images.JPEGQuality <= 0 || cfg.Images.JPEGQuality > 100 {
+		cfg.Images.JPEGQuality = 85
+	}
+	if cfg.Images.Precheck.Mode == "" {
+		cfg.Images.Precheck.Mode = "reject"
+	}
+	if cfg.Images.Precheck.MinSharpness <= 0 {
+		cfg.Images.Precheck.MinSharpness = 30
+	}
+	if cfg.Images.Precheck.MinBrightness <= 0 {
+		cfg.Images.Precheck.MinBrightness = 35
+	}
+	if cfg.Images.Precheck.MaxBrightness <= 0 {
+		cfg.Images.Precheck.MaxBrightness = 235
+	}
+	if cfg.Images.Precheck.MinConfidence <= 0 {
+		cfg.Images.Precheck.MinConfidence = 0.7
+	}
+	if cfg.Images.Retention.IntervalHours <= 0 {
+		cfg.Images.Retention.IntervalHours = 24
+	}
+	if cfg.Images.Retention.GraceHours <= 0 {
+		cfg.Images.Retention.GraceHours = 24
+	}
+	if cfg.Images.Retention.MaxDeletes <= 0 {
+		cfg.Images.Retention.MaxDeletes = 1000
+	}
+	if cfg.Images.Retention.Prefix == "" {
+		cfg.Images.Retention.Prefix = "users/"
+	}
+	if cfg.Images.Retention.Categories == nil {
+		cfg.Images.Retention.Categories = map[string]int{"menu": 90}
+	}
+	if cfg.VisionCache.WindowHours <= 0 {
+		cfg.VisionCache.WindowHours = 72
+	}
+	if cfg.VisionCache.MaxHashDistance == 0 {
+		cfg.VisionCache.MaxHashDistance = 6
+	}
+	if cfg.Jobs.Workers == 0 {
+		cfg.Jobs.Workers = 4
+	}
+	if cfg.Jobs.PollIntervalMs <= 0 {
+		cfg.Jobs.PollIntervalMs = 1000
+	}
+	if cfg.Jobs.MaxAttempts <= 0 {
+		cfg.Jobs.MaxAttempts = 3
+	}
+	if cfg.Jobs.RetryBaseSeconds <= 0 {
+		cfg.Jobs.RetryBaseSeconds = 5
+	}
+	if cfg.Jobs.RetryMaxSeconds <= 0 {
+		cfg.Jobs.RetryMaxSeconds = 120
+	}
+	if cfg.Jobs.TimeoutSeconds <= 0 {
+		cfg.Jobs.TimeoutSeconds = 300
+	}
+	if cfg.Jobs.StaleSeconds <= 0 {
+		cfg.Jobs.StaleSeconds = 600
+	}
+	if cfg.Jobs.RetentionDays <= 0 {
+		cfg.Jobs.RetentionDays = 7
+	}
+	if cfg.Idempotency.TTLHours <= 0 {
+		cfg.Idempotency.TTLHours = 24
+	}
+	if cfg.Idempotency.InFlightTimeoutSeconds <= 0 {
+		cfg.Idempotency.InFlightTimeoutSeconds = 300
+	}
+	if cfg.Idempotency.MaxResponseKB <= 0 {
+		cfg.Idempotency.MaxResponseKB = 1024
+	}
+	if cfg.Idempotency.MaxRequestKB <= 0 {
+		cfg.Idempotency.MaxRequestKB = 1024
+	}
+	if cfg.Quota.ReservationTTLMinutes <= 0 {
+		cfg.Quota.ReservationTTLMinutes = 30
+	}
+	if cfg.Quota.MaxInFlight <= 0 {
+		cfg.Quota.MaxInFlight = 3
+	}
 	if cfg.Qwen.BaseURL == "" {
 		cfg.Qwen.BaseURL = "https://dashscope.aliyuncs.com/compatible-mode/v1"
 	}
 	if cfg.Qwen.Model == "" {
 		cfg.Qwen.Model = "qwen-vl-plus"
 	}
+	applyAIRouteDefaults(&cfg.Qwen)
 	if cfg.OSS.Region == "" {
 		cfg.OSS.Region = "cn-beijing"
 	}
@@ -189,4 +487,68 @@ func applyDefaults(cfg *Config) {
 	if cfg.Prompts.ChatPromptPath == "" {
 		cfg.Prompts.ChatPromptPath = "prompt/chat_prompt.txt"
 	}
+	if cfg.Prompts.ReloadIntervalSeconds == 0 {
+		cfg.Prompts.ReloadIntervalSeconds = 30
+	}
+}
+
+// 纯文本场景默认走文本模型，视觉场景沿用 qwen.model。
+var defaultTextRoutes = []string{"chat", "discover_plan", "discover_replace", "food_search"}
+var defaultVisionRoutes = []string{"menu_scan", "food_scan", "ingredient_scan", "label_scan", "image_precheck"}
+
+func applyAIRouteDefaults(cfg *QwenConfig) {
+	if cfg.Routes == nil {
+		cfg.Routes = make(map[string]AIRouteConfig)
+	}
+	for _, name := range defaultTextRoutes {
+		route := cfg.Routes[name]
+		if route.Model == "" {
+			route.Model = "qwen-plus"
+			if len(route.Fallbacks) == 0 {
+				route.Fallbacks = []string{"qwen-turbo"}
+			}
+		}
+		if route.Temperature == nil {
+			temperature := 0.6
+			route.Temperature = &temperature
+		}
+		if route.TimeoutSeconds <= 0 {
+			route.TimeoutSeconds = 30
+		}
+		cfg.Routes[name] = route
+	}
+	for _, name := range defaultVisionRoutes {
+		route := cfg.Routes[name]
+		if route.Model == "" {
+			route.Model = cfg.Model
+		}
+		if route.TimeoutSeconds <= 0 {
+			route.TimeoutSeconds = 60
+		}
+		cfg.Routes[name] = route
+	}
+
+	transport := &cfg.Transport
+	if transport.MaxRetries == nil {
+		retries := 2
+		transport.MaxRetries = &retries
+	}
+	if transport.RetryBaseMs <= 0 {
+		transport.RetryBaseMs = 300
+	}
+	if transport.RetryMaxMs <= 0 {
+		transport.RetryMaxMs = 3000
+	}
+	if transport.MaxConcurrency <= 0 {
+		transport.MaxConcurrency = 32
+	}
+	if transport.QueueTimeoutMs <= 0 {
+		transport.QueueTimeoutMs = 3000
+	}
+	if transport.BreakerFailures <= 0 {
+		transport.BreakerFailures = 5
+	}
+	if transport.BreakerCooldownSeconds <= 0 {
+		transport.BreakerCooldownSeconds = 30
+	}
 }
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ImageAdminHandler struct {
+	retention *service.ImageRetentionService
+}
+
+func NewImageAdminHandler(retention *service.ImageRetentionService) *ImageAdminHandler {
+	return &ImageAdminHandler{retention: retention}
+}
+
+// Retention 当前清理配置与最近一次报告
+// GET /api/v1/admin/images/retention
+func (h *ImageAdminHandler) Retention(c echo.Context) error {
+	return response.Success(c, map[string]interface{}{
+		"config":      h.retention.Config(),
+		"last_report": h.retention.LastReport(),
+	})
+}
+
+// RunRetention 立即执行一次清理，默认 dry-run 只返回报告
+// POST /api/v1/admin/images/retention/run {"dry_run": false}
+func (h *ImageAdminHandler) RunRetention(c echo.Context) error {
+	var req struct {
+		DryRun *bool `json:"dry_run"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	dryRun := req.DryRun == nil || *req.DryRun
+	report, err := h.retention.Run(c.Request().Context(), dryRun)
+	if errors.Is(err, service.ErrImageRetentionRunning) {
+		return response.Error(c, http.StatusConflict, "image retention already running")
+	}
+	if errors.Is(err, service.ErrStorageNotConfigured) {
+		return response.InternalError(c, "image retention not configured")
+	}
+	if err != nil {
+		c.Logger().Errorf("image retention failed: %v", err)
+		return response.ErrorWithData(c, http.StatusInternalServerError, "image retention failed", report)
+	}
+	return response.Success(c, report)
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+type PromptAdminHandler struct {
+	prompts *service.PromptRegistry
+}
+
+func NewPromptAdminHandler(prompts *service.PromptRegistry) *PromptAdminHandler {
+	return &PromptAdminHandler{prompts: prompts}
+}
+
+// List 当前加载的模板版本与校验结果
+// GET /api/v1/admin/prompts
+func (h *PromptAdminHandler) List(c echo.Context) error {
+	templates, report := h.prompts.Status()
+	return response.Success(c, map[string]interface{}{
+		"templates":  templates,
+		"validation": report,
+	})
+}
+
+// Reload 立即重新加载模板文件，校验失败的模板保留旧版本
+// POST /api/v1/admin/prompts/reload
+func (h *PromptAdminHandler) Reload(c echo.Context) error {
+	report := h.prompts.Reload()
+	templates, _ := h.prompts.Status()
+	return response.Success(c, map[string]interface{}{
+		"templates":  templates,
+		"validation": report,
+	})
+}
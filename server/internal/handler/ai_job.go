@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"eatclean/internal/model"
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxJobWaitSeconds GET /jobs/:id 长轮询的最长等待时间。
+const maxJobWaitSeconds = 30
+
+// maxJobStreamSeconds GET /jobs/:id/events 单次连接的最长时间。
+const maxJobStreamSeconds = 300
+
+var (
+	// errResultSave AI 处理成功但保存记录失败，重试时可复用识别缓存。
+	errResultSave = errors.New("result save failed")
+	// errUserNotFound 写入记录时用户已不存在。
+	errUserNotFound = errors.New("user not found")
+)
+
+// visionJobPayload 入队的识别任务：图片入库、质量预检、提示词渲染与额度检查已在请求中完成。
+type visionJobPayload struct {
+	ImageAssetIDs  []int64                `json:"image_asset_ids"`
+	SystemPrompt   string                 `json:"system_prompt"`
+	PromptVersion  string                 `json:"prompt_version"`
+	CacheKey       service.VisionCacheKey `json:"cache_key"`
+	Note           string                 `json:"note"`
+	RestaurantHint string                 `json:"restaurant_hint,omitempty"`
+	Warnings       []service.ImageIssue   `json:"warnings,omitempty"`
+	// ClientTime 请求中的客户端时间，异步执行时仍按提交时的“当天”对照营养素限量
+	ClientTime time.Time `json:"client_time"`
+}
+
+type JobHandler struct {
+	jobs *service.AIJobService
+}
+
+func NewJobHandler(jobs *service.AIJobService) *JobHandler {
+	return &JobHandler{jobs: jobs}
+}
+
+// Get 查询异步任务状态与结果，wait 为长轮询秒数（最多 30 秒），任务结束时立即返回
+// GET /api/v1/jobs/:id?wait=20
+func (h *JobHandler) Get(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return response.BadRequest(c, "invalid job id")
+	}
+	wait, _ := strconv.Atoi(c.QueryParam("wait"))
+	if wait > maxJobWaitSeconds {
+		wait = maxJobWaitSeconds
+	}
+	job, err := h.jobs.Wait(c.Request().Context(), userID, id, time.Duration(wait)*time.Second)
+	if errors.Is(err, service.ErrAIJobUnsupported) {
+		return response.InternalError(c, "job queue not configured")
+	}
+	if err != nil {
+		c.Logger().Errorf("job load failed: %v", err)
+		return response.InternalError(c, "failed to load job")
+	}
+	if job == nil {
+		return response.Error(c, http.StatusNotFound, "job not found")
+	}
+	return response.Success(c, job)
+}
+
+// Events 以 Server-Sent Events 推送任务状态（event: job，data 与 GET /jobs/:id 的 data 相同），
+// 任务结束后发送最终状态并关闭；超过 maxJobStreamSeconds 仍未结束时关闭连接，客户端重新连接即可
+// GET /api/v1/jobs/:id/events
+func (h *JobHandler) Events(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return response.BadRequest(c, "invalid job id")
+	}
+	if !h.jobs.IsEnabled() {
+		return response.InternalError(c, "job queue not configured")
+	}
+	ctx, cancel := context.WithTimeout(c.Request().Context(), maxJobStreamSeconds*time.Second)
+	defer cancel()
+	streaming := false
+	job, err := h.jobs.Watch(ctx, userID, id, func(job *model.AIJob) error {
+		if !streaming {
+			header := c.Response().Header()
+			header.Set(echo.HeaderContentType, "text/event-stream")
+			header.Set(echo.HeaderCacheControl, "no-cache")
+			header.Set("X-Accel-Buffering", "no")
+			c.Response().WriteHeader(http.StatusOK)
+			streaming = true
+		}
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(c.Response(), "event: job\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		c.Response().Flush()
+		return nil
+	})
+	if streaming {
+		if err != nil {
+			c.Logger().Warnf("job stream ended: %v", err)
+		}
+		return nil
+	}
+	if err != nil {
+		c.Logger().Errorf("job load failed: %v", err)
+		return response.InternalError(c, "failed to load job")
+	}
+	if job == nil {
+		return response.Error(c, http.StatusNotFound, "job not found")
+	}
+	return nil
+}
+
+// wantsAsync 客户端通过 ?async=1 或 Prefer: respond-async 请求异步处理。
+func wantsAsync(c echo.Context) bool {
+	if value, err := strconv.ParseBool(c.QueryParam("async")); err == nil && value {
+		return true
+	}
+	return strings.Contains(strings.ToLower(c.Request().Header.Get("Prefer")), "respond-async")
+}
+
+// respondJobAccepted 返回 202 与任务 ID，客户端轮询 GET /jobs/:id。
+func respondJobAccepted(c echo.Context, job *model.AIJob) error {
+	return response.Accepted(c, map[string]interface{}{
+		"job_id":     job.ID,
+		"kind":       job.Kind,
+		"status":     job.Status,
+		"created_at": job.CreatedAt,
+	})
+}
+
+// respondVisionRunError 同步执行识别步骤失败时的响应，与入队前的错误映射一致。
+func respondVisionRunError(c echo.Context, err error, invalidMessage string, failedMessage string, saveMessage string) error {
+	var precheckErr *service.ImagePrecheckError
+	switch {
+	case errors.As(err, &precheckErr):
+		return respondImageError(c, err)
+	case errors.Is(err, errUserNotFound):
+		return response.Unauthorized(c, "user not found, please re-login")
+	case errors.Is(err, errResultSave):
+		c.Logger().Errorf("%s: %v", saveMessage, err)
+		return response.InternalError(c, saveMessage)
+	}
+	return respondAIError(c, err, invalidMessage, failedMessage)
+}
+
+// asJobError 不可重试的错误转换为任务错误码，其余错误由任务队列退避重试。
+func asJobError(err error) error {
+	var precheckErr *service.ImagePrecheckError
+	switch {
+	case errors.As(err, &precheckErr):
+		return service.NewAIJobError(precheckErr.Code, precheckErr.Message())
+	case errors.Is(err, errUserNotFound):
+		return service.NewAIJobError("user_not_found", "用户不存在，请重新登录")
+	case errors.Is(err, service.ErrImageAssetNotFound), errors.Is(err, service.ErrObjectNotFound):
+		return service.NewAIJobError("image_not_found", "图片不存在，请重新上传")
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"eatclean/internal/model"
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// respondAIError 区分熔断/繁忙（503）、模型输出校验失败（502，可重试）与调用失败（500）。
+func respondAIError(c echo.Context, err error, invalidMessage string, failedMessage string) error {
+	if service.IsAIUnavailable(err) {
+		c.Logger().Warnf("ai unavailable: %v", err)
+		return respondAIUnavailable(c)
+	}
+	var outputErr *service.AIOutputError
+	if errors.As(err, &outputErr) {
+		c.Logger().Errorf("ai output invalid: %v", outputErr)
+		return response.Error(c, http.StatusBadGateway, invalidMessage)
+	}
+	c.Logger().Errorf("ai call failed: %v", err)
+	return response.InternalError(c, failedMessage)
+}
+
+// respondAIUnavailable AI 熔断或并发已满时返回 503，提示客户端稍后重试。
+func respondAIUnavailable(c echo.Context) error {
+	c.Response().Header().Set("Retry-After", "30")
+	return response.Error(c, http.StatusServiceUnavailable, "AI 服务繁忙，请稍后再试")
+}
+
+// aiDishesToMaps 把结构化菜品转换为客户端使用的卡片 map，并补齐默认字段。
+func aiDishesToMaps(dishes []service.AIDish) []map[string]interface{} {
+	var raw []map[string]interface{}
+	payload, err := json.Marshal(dishes)
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil
+	}
+	return normalizeAIDishes(raw)
+}
+
+func aiHighlightsToMaps(highlights []service.AINutritionHighlight) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(highlights))
+	for _, item := range highlights {
+		if item.Name == "" {
+			continue
+		}
+		out = append(out, map[string]interface{}{
+			"name":  item.Name,
+			"value": item.Value,
+			"unit":  item.Unit,
+		})
+	}
+	return out
+}
+
+// aiMealsToMaps 把结构化餐食转换为发现页卡片 map，并补齐 id/默认做法等字段。
+func aiMealsToMaps(meals []service.AIDiscoverMeal) []map[string]interface{} {
+	var raw []interface{}
+	payload, err := json.Marshal(meals)
+	if err != nil {
+		return nil
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil
+	}
+	return parseDiscoverList(raw)
+}
+
+// tagPromptVersion 在每张 AI 生成的卡片上记录所用模板版本，随卡片一起持久化。
+func tagPromptVersion(items []map[string]interface{}, version string) []map[string]interface{} {
+	if version == "" {
+		return items
+	}
+	for _, item := range items {
+		item["prompt_version"] = version
+	}
+	return items
+}
+
+// excludedDishScore 含忌口的菜品分数上限，对应“避免”。
+const excludedDishScore = 40
+
+// flagExcludedDishes 按用户忌口标记识别出的菜品：写入 excluded_hits、不推荐并降到“避免”，返回确定性风险提示。
+func flagExcludedDishes(dishes []map[string]interface{}, restrictions *service.DietRestrictions) []string {
+	if restrictions.IsEmpty() {
+		return nil
+	}
+	var alerts []string
+	for _, dish := range dishes {
+		name := readString(dish["name"])
+		hits := restrictions.Check(name, readStringList(dish["components"], dish["ingredients"]))
+		if len(hits) == 0 {
+			continue
+		}
+		score := clampInt(readInt(dish["score"], excludedDishScore), 0, excludedDishScore)
+		dish["excluded_hits"] = hits
+		dish["recommended"] = false
+		dish["score"] = score
+		dish["scoreLabel"] = scoreLabelFor(score)
+		dish["scoreColor"] = scoreColorFor(score, false)
+		dish["reason"] = strings.TrimSpace(fmt.Sprintf("含忌口：%s。%s", strings.Join(hits, "、"), readString(dish["reason"])))
+		alerts = append(alerts, service.DietRiskAlert(name, hits))
+	}
+	return alerts
+}
+
+// flagNutrientDishes 标记会超出每日营养素限量的菜品：写入 nutrient_excess 并改为不推荐，返回确定性风险提示。
+// 分数保持模型给出的值，缺少钠、糖等数值的菜品不标记。
+func flagNutrientDishes(dishes []map[string]interface{}, budget *service.NutrientBudget) []string {
+	if budget == nil {
+		return nil
+	}
+	var alerts []string
+	for _, dish := range dishes {
+		facts, ok := model.NutritionFromMap(dish, "").Serving()
+		if !ok {
+			continue
+		}
+		excess := budget.Check(facts)
+		if len(excess) == 0 {
+			continue
+		}
+		labels := make([]string, 0, len(excess))
+		for _, item := range excess {
+			labels = append(labels, item.Label)
+		}
+		name := readString(dish["name"])
+		dish["nutrient_excess"] = excess
+		dish["recommended"] = false
+		dish["scoreColor"] = scoreColorFor(readInt(dish["score"], 0), false)
+		dish["reason"] = strings.TrimSpace(fmt.Sprintf("超出每日限量：%s。%s", strings.Join(labels, "、"), readString(dish["reason"])))
+		alerts = append(alerts, service.NutrientRiskAlert(name, excess))
+	}
+	return alerts
+}
+
+// flagExcludedMeals 在命中忌口的发现页餐食上写入 excluded_hits，返回命中的数量。
+func flagExcludedMeals(meals []map[string]interface{}, restrictions *service.DietRestrictions) int {
+	if restrictions.IsEmpty() {
+		return 0
+	}
+	flagged := 0
+	for _, meal := range meals {
+		title := readStringOr(meal["title"], readString(meal["name"]))
+		if hits := restrictions.Check(title, readStringList(meal["ingredients"], meal["components"])); len(hits) > 0 {
+			meal["excluded_hits"] = hits
+			flagged++
+		}
+	}
+	return flagged
+}
+
+// filterExcludedMeals 移除命中忌口的发现页餐食；全部命中时保留并标记，避免返回空列表。
+func filterExcludedMeals(meals []map[string]interface{}, restrictions *service.DietRestrictions) []map[string]interface{} {
+	if flagExcludedMeals(meals, restrictions) == 0 {
+		return meals
+	}
+	kept := make([]map[string]interface{}, 0, len(meals))
+	for _, meal := range meals {
+		if _, excluded := meal["excluded_hits"]; !excluded {
+			kept = append(kept, meal)
+		}
+	}
+	if len(kept) == 0 {
+		return meals
+	}
+	return kept
+}
@@ -16,17 +16,20 @@ type AuthHandler struct {
 	authService     *service.AuthService
 	settingsService *service.SettingsService
 	subscriptionSvc *service.SubscriptionService
+	storage         *service.StorageService
 }
 
 func NewAuthHandler(
 	authService *service.AuthService,
 	settingsService *service.SettingsService,
 	subscriptionSvc *service.SubscriptionService,
+	storage *service.StorageService,
 ) *AuthHandler {
 	return &AuthHandler{
 		authService:     authService,
 		settingsService: settingsService,
 		subscriptionSvc: subscriptionSvc,
+		storage:         storage,
 	}
 }
 
@@ -240,6 +243,9 @@ func (h *AuthHandler) UpdateAvatar(c echo.Context) error {
 	if !strings.Contains(body.URL, "/avatar/") {
 		return response.BadRequest(c, "avatar url must be stored under /avatar/ path")
 	}
+	if _, err := h.storage.CheckOwnership(userID, strings.TrimSpace(body.URL)); err != nil {
+		return respondStorageSignError(c, err)
+	}
 	if h.subscriptionSvc != nil {
 		active, _ := h.subscriptionSvc.IsUserActive(userID)
 		if !active {
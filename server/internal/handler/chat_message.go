@@ -12,12 +12,13 @@ import (
 )
 
 type ChatMessageHandler struct {
-	service    *service.ChatMessageService
-	ossService *service.OssService
+	service *service.ChatMessageService
+	storage *service.StorageService
+	images  *service.ImageAssetService
 }
 
-func NewChatMessageHandler(service *service.ChatMessageService, ossService *service.OssService) *ChatMessageHandler {
-	return &ChatMessageHandler{service: service, ossService: ossService}
+func NewChatMessageHandler(service *service.ChatMessageService, storage *service.StorageService, images *service.ImageAssetService) *ChatMessageHandler {
+	return &ChatMessageHandler{service: service, storage: storage, images: images}
 }
 
 // Create 创建聊天消息
@@ -37,24 +38,38 @@ func (h *ChatMessageHandler) Create(c echo.Context) error {
 		role = "user"
 	}
 	text := strings.TrimSpace(req.Text)
-	if text == "" && len(req.ImageUrls) == 0 {
+	if text == "" && len(req.ImageUrls) == 0 && len(req.ImageAssetIDs) == 0 {
 		return response.BadRequest(c, "message content is empty")
 	}
 
+	var imageKeys []string
+	var imageAssetIDs []int64
+	if len(req.ImageUrls) > 0 || len(req.ImageAssetIDs) > 0 {
+		assets, err := h.images.Assets(c.Request().Context(), userID, "chat", req.ImageAssetIDs, req.ImageUrls)
+		if err != nil {
+			return respondImageError(c, err)
+		}
+		for _, asset := range assets {
+			imageAssetIDs = append(imageAssetIDs, asset.ID)
+			imageKeys = append(imageKeys, asset.ModelKey)
+		}
+	}
+
 	message := &model.ChatMessage{
-		UserID:    userID,
-		Role:      role,
-		Text:      text,
-		ImageUrls: mustMarshalJSON(req.ImageUrls),
-		CreatedAt: time.Now(),
+		UserID:        userID,
+		Role:          role,
+		Text:          text,
+		ImageUrls:     mustMarshalJSON(imageKeys),
+		ImageAssetIDs: imageAssetIDs,
+		CreatedAt:     time.Now(),
 	}
 	if err := h.service.Create(message); err != nil {
 		return response.InternalError(c, "failed to create chat message")
 	}
 
 	var signedUrls []string
-	if len(req.ImageUrls) > 0 && h.ossService != nil {
-		signed, err := h.ossService.SignURLs(req.ImageUrls, 15*time.Minute)
+	if len(imageKeys) > 0 && h.storage != nil {
+		signed, err := h.storage.SignURLs(userID, imageKeys, 15*time.Minute)
 		if err == nil {
 			signedUrls = signed
 		}
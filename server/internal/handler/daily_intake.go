@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"eatclean/internal/model"
 	"eatclean/internal/service"
 	"eatclean/pkg/response"
 	"strings"
@@ -10,14 +11,15 @@ import (
 )
 
 type DailyIntakeHandler struct {
-	service *service.DailyIntakeService
+	service  *service.DailyIntakeService
+	settings *service.SettingsService
 }
 
-func NewDailyIntakeHandler(service *service.DailyIntakeService) *DailyIntakeHandler {
-	return &DailyIntakeHandler{service: service}
+func NewDailyIntakeHandler(service *service.DailyIntakeService, settings *service.SettingsService) *DailyIntakeHandler {
+	return &DailyIntakeHandler{service: service, settings: settings}
 }
 
-// UpsertDailyIntake 同步每日能量摄入
+// UpsertDailyIntake 同步每日能量摄入；钠、糖等微量营养素可选，未上报的项按当天就餐记录合计
 // POST /api/v1/intake/daily
 func (h *DailyIntakeHandler) UpsertDailyIntake(c echo.Context) error {
 	userID, ok := c.Get("user_id").(int64)
@@ -25,26 +27,68 @@ func (h *DailyIntakeHandler) UpsertDailyIntake(c echo.Context) error {
 		return response.Unauthorized(c, "invalid user context")
 	}
 	var req struct {
-		Date     string `json:"date"`
-		Calories int    `json:"calories"`
-		Protein  int    `json:"protein"`
-		Carbs    int    `json:"carbs"`
-		Fat      int    `json:"fat"`
+		Date          string   `json:"date"`
+		Calories      int      `json:"calories"`
+		Protein       int      `json:"protein"`
+		Carbs         int      `json:"carbs"`
+		Fat           int      `json:"fat"`
+		SodiumMg      *float64 `json:"sodium_mg"`
+		SugarG        *float64 `json:"sugar_g"`
+		AddedSugarG   *float64 `json:"added_sugar_g"`
+		FiberG        *float64 `json:"fiber_g"`
+		SaturatedFatG *float64 `json:"saturated_fat_g"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return response.BadRequest(c, "invalid request body")
 	}
-	date := strings.TrimSpace(req.Date)
-	if date == "" {
-		date = time.Now().Format("2006-01-02")
-	}
-	if _, err := time.Parse("2006-01-02", date); err != nil {
+	day, ok := parseIntakeDate(req.Date)
+	if !ok {
 		return response.BadRequest(c, "invalid date format")
 	}
+	date := day.Format("2006-01-02")
 	if h.service == nil {
 		return response.InternalError(c, "daily intake service not configured")
 	}
-	if err := h.service.Upsert(userID, date, req.Calories, req.Protein, req.Carbs, req.Fat); err != nil {
+	record := &model.DailyIntake{
+		UserID:   userID,
+		Day:      day,
+		Calories: req.Calories,
+		Protein:  req.Protein,
+		Carbs:    req.Carbs,
+		Fat:      req.Fat,
+	}
+	micros := []*float64{req.SodiumMg, req.SugarG, req.AddedSugarG, req.FiberG, req.SaturatedFatG}
+	reported, complete := false, true
+	for _, value := range micros {
+		if value == nil {
+			complete = false
+			continue
+		}
+		if *value < 0 {
+			return response.BadRequest(c, "nutrient values must not be negative")
+		}
+		reported = true
+	}
+	// 逐项合并：上报的项用客户端数值，未上报的项用当天就餐记录合计
+	var totals model.NutritionFacts
+	if !complete {
+		if meals, err := h.service.MealTotals(userID, day); err != nil {
+			c.Logger().Warnf("daily intake meal totals failed: %v", err)
+		} else {
+			totals = meals
+			reported = true
+		}
+	}
+	if reported {
+		record.SetMicronutrients(model.NutritionFacts{
+			SodiumMg:      floatOr(req.SodiumMg, totals.SodiumMg),
+			SugarG:        floatOr(req.SugarG, totals.SugarG),
+			AddedSugarG:   floatOr(req.AddedSugarG, totals.AddedSugarG),
+			FiberG:        floatOr(req.FiberG, totals.FiberG),
+			SaturatedFatG: floatOr(req.SaturatedFatG, totals.SaturatedFatG),
+		})
+	}
+	if err := h.service.Upsert(record); err != nil {
 		if isForeignKeyViolation(err) {
 			return response.Unauthorized(c, "user not found, please re-login")
 		}
@@ -52,9 +96,77 @@ func (h *DailyIntakeHandler) UpsertDailyIntake(c echo.Context) error {
 		return response.InternalError(c, "failed to save daily intake")
 	}
 	return response.Success(c, map[string]interface{}{
-		"user_id":  userID,
-		"date":     date,
-		"synced":   true,
-		"calories": req.Calories,
+		"user_id":   userID,
+		"date":      date,
+		"synced":    true,
+		"calories":  req.Calories,
+		"nutrients": record.Micronutrients(),
 	})
 }
+
+// GetDailyIntake 当天已同步的摄入，以及钠、糖、膳食纤维、饱和脂肪与每日限量（settings.nutrient_limits）的对比
+// GET /api/v1/intake/daily?date=2006-01-02
+func (h *DailyIntakeHandler) GetDailyIntake(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	day, ok := parseIntakeDate(c.QueryParam("date"))
+	if !ok {
+		return response.BadRequest(c, "invalid date format")
+	}
+	date := day.Format("2006-01-02")
+	if h.service == nil {
+		return response.InternalError(c, "daily intake service not configured")
+	}
+	record, err := h.service.GetByDate(userID, date)
+	if err != nil {
+		c.Logger().Errorf("daily intake query failed: %v", err)
+		return response.InternalError(c, "failed to load daily intake")
+	}
+	nutrients, err := h.service.Micronutrients(userID, day, record)
+	if err != nil {
+		c.Logger().Errorf("daily intake nutrients failed: %v", err)
+		return response.InternalError(c, "failed to load daily intake")
+	}
+	settings, err := h.settings.Values(userID)
+	if err != nil {
+		c.Logger().Errorf("daily intake settings failed: %v", err)
+		return response.InternalError(c, "failed to load settings")
+	}
+	result := map[string]interface{}{
+		"user_id":         userID,
+		"date":            date,
+		"synced":          record != nil,
+		"calories":        0,
+		"protein":         0,
+		"carbs":           0,
+		"fat":             0,
+		"nutrients":       nutrients,
+		"nutrient_limits": service.NutrientStatuses(service.NutrientLimitsFromSettings(settings), nutrients),
+	}
+	if record != nil {
+		result["calories"] = record.Calories
+		result["protein"] = record.Protein
+		result["carbs"] = record.Carbs
+		result["fat"] = record.Fat
+	}
+	return response.Success(c, result)
+}
+
+// parseIntakeDate 解析 YYYY-MM-DD，为空时取今天。
+func parseIntakeDate(raw string) (time.Time, bool) {
+	date := strings.TrimSpace(raw)
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	return day, err == nil
+}
+
+func floatOr(value *float64, fallback float64) float64 {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
@@ -2,11 +2,13 @@ package handler
 
 import (
 	"context"
+	"eatclean/internal/model"
 	"eatclean/internal/service"
 	"eatclean/pkg/response"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
 	"time"
@@ -15,32 +17,29 @@ import (
 )
 
 type DiscoverHandler struct {
-	aiService       *service.ChatAIService
-	settingsService *service.SettingsService
-	dishService     *service.DishService
-	mealService     *service.MealRecordService
-	weeklyMenu      *service.WeeklyMenuService
-	dailyService    *service.DailyIntakeService
-	subscriptions   *service.SubscriptionService
+	aiService     *service.ChatAIService
+	dishService   *service.DishService
+	weeklyMenu    *service.WeeklyMenuService
+	subscriptions *service.SubscriptionService
+	promptContext *service.PromptContextBuilder
+	jobs          *service.AIJobService
 }
 
 func NewDiscoverHandler(
 	aiService *service.ChatAIService,
-	settingsService *service.SettingsService,
 	dishService *service.DishService,
-	mealService *service.MealRecordService,
 	weeklyMenu *service.WeeklyMenuService,
-	dailyService *service.DailyIntakeService,
 	subscriptions *service.SubscriptionService,
+	promptContext *service.PromptContextBuilder,
+	jobs *service.AIJobService,
 ) *DiscoverHandler {
 	return &DiscoverHandler{
-		aiService:       aiService,
-		settingsService: settingsService,
-		dishService:     dishService,
-		mealService:     mealService,
-		weeklyMenu:      weeklyMenu,
-		dailyService:    dailyService,
-		subscriptions:   subscriptions,
+		aiService:     aiService,
+		dishService:   dishService,
+		weeklyMenu:    weeklyMenu,
+		subscriptions: subscriptions,
+		promptContext: promptContext,
+		jobs:          jobs,
 	}
 }
 
@@ -79,12 +78,11 @@ func (h *DiscoverHandler) Recommendations(c echo.Context) error {
 			isSubscriber = true
 		}
 	}
+	// 默认菜单与缓存菜单在返回时按当前忌口处理，设置变更后无需重新生成
+	restrictions := h.promptContext.DietRestrictions(userID)
 	if !isSubscriber {
 		planMeals, recommendations := defaultWeeklyMenus(weekday)
-		return response.Success(c, map[string]interface{}{
-			"plan_meals":      planMeals,
-			"recommendations": recommendations,
-		})
+		return respondDiscoverMenus(c, planMeals, recommendations, restrictions)
 	}
 	weekStart := weekStartForDate(clientTime)
 	targetDate := weekStart.AddDate(0, 0, weekday-1)
@@ -93,10 +91,7 @@ func (h *DiscoverHandler) Recommendations(c echo.Context) error {
 			planMeals := decodeDiscoverMeals(cached.PlanMeals)
 			recommendations := decodeDiscoverMeals(cached.Recommendations)
 			if len(planMeals) > 0 || len(recommendations) > 0 {
-				return response.Success(c, map[string]interface{}{
-					"plan_meals":      planMeals,
-					"recommendations": recommendations,
-				})
+				return respondDiscoverMenus(c, planMeals, recommendations, restrictions)
 			}
 		}
 	}
@@ -106,6 +101,9 @@ func (h *DiscoverHandler) Recommendations(c echo.Context) error {
 			"recommendations": []interface{}{},
 		})
 	}
+	if !h.aiService.RouteAvailable(service.AIRouteDiscoverPlan) {
+		return respondDefaultWeeklyMenus(c, weekday, restrictions)
+	}
 	planMeals, recommendations, err := h.generateDiscoverMenus(
 		c.Request().Context(),
 		userID,
@@ -115,13 +113,16 @@ func (h *DiscoverHandler) Recommendations(c echo.Context) error {
 		clientTime,
 	)
 	if err != nil {
+		if service.IsAIUnavailable(err) {
+			return respondDefaultWeeklyMenus(c, weekday, restrictions)
+		}
 		c.Logger().Errorf("discover recommendations failed: %v", err)
 		return response.InternalError(c, "failed to generate recommendations")
 	}
 	if h.dishService != nil {
 		for _, meal := range append(append([]map[string]interface{}{}, planMeals...), recommendations...) {
 			meal["name"] = readStringOr(meal["name"], readString(meal["title"]))
-			_ = h.dishService.UpsertFromMap(meal)
+			_ = h.dishService.UpsertFromMap(meal, model.NutritionSourceAIPlan)
 		}
 	}
 	if h.weeklyMenu != nil && h.weeklyMenu.IsEnabled() {
@@ -154,47 +155,18 @@ func (h *DiscoverHandler) Replace(c echo.Context) error {
 		delete(req, "client_time")
 	}
 
-	recentMealSummary := ""
-	if h.mealService != nil {
-		if records, err := h.mealService.ListByUser(userID, 20); err == nil {
-			recentMealSummary = service.SummarizeMealRecords(records, 3)
-		}
-	}
-	if strings.TrimSpace(recentMealSummary) == "" {
-		recentMealSummary = "暂无"
-	}
+	payload, _ := json.Marshal(req)
 	systemPrompt := ""
-
-	if h.settingsService != nil {
-		if raw, err := h.settingsService.Get(userID); err == nil && len(raw) > 0 {
-			var settings map[string]interface{}
-			if err := json.Unmarshal(raw, &settings); err == nil {
-				if template, err := service.LoadDiscoverReplacePromptTemplate(); err == nil {
-					isTraining, isCheat := computeDayFlagsForDate(settings, clientTime)
-					dayType := dayTypeLabel(isTraining, isCheat)
-					timeOfDay := timeOfDayLabel(clientTime)
-					intake := buildIntakeContext(h.dailyService, userID, clientTime, settings)
-					settings["userId"] = userID
-					systemPrompt = service.BuildSystemPrompt(template, settings, map[string]string{
-						"menu_scanned":        "false",
-						"food_photo_taken":    "false",
-						"recent_chat_summary": "暂无",
-						"recent_meal_summary": recentMealSummary,
-						"current_time":        formatPromptTime(clientTime),
-						"time_of_day":         timeOfDay,
-						"day_type":            dayType,
-						"is_training_day":     formatYesNo(isTraining),
-						"is_cheat_day":        formatYesNo(isCheat),
-						"calories_consumed":   intake.CaloriesConsumed,
-						"macro_consumed":      intake.MacroConsumed,
-						"calorie_remaining":   intake.CalorieRemaining,
-					})
-				}
-			}
-		}
+	promptVersion := ""
+	pctx := h.promptContext.Build(userID, service.PromptContextOptions{ClientTime: clientTime})
+	if rendered, err := h.promptContext.Render(service.PromptDiscoverReplace, pctx, map[string]string{
+		"current_meal_json": string(payload),
+	}); err == nil {
+		systemPrompt = rendered.Text
+		promptVersion = rendered.Version
+	} else {
+		c.Logger().Errorf("discover replace prompt render failed: %v", err)
 	}
-
-	payload, _ := json.Marshal(req)
 	prompt := fmt.Sprintf(`你需要为用户提供更健康的“替换餐食”方案。
 请参考以下餐食卡片 JSON，给出 2-3 个更健康的替换方案。
 必须输出 JSON（不要输出额外文本）：
@@ -219,17 +191,17 @@ func (h *DiscoverHandler) Replace(c echo.Context) error {
 - 如果信息不足，请合理生成可执行的菜品名称与食材搭配，保持真实可做。
 参考卡片：%s`, string(payload))
 
-	reply, err := h.aiService.Chat(c.Request().Context(), systemPrompt, "", nil, prompt, nil)
-	if err != nil {
-		c.Logger().Errorf("discover replace failed: %v", err)
-		return response.InternalError(c, "failed to generate replacements")
+	var replacements service.AIReplacementMeals
+	if _, err := h.aiService.ChatStructured(c.Request().Context(), service.AIRouteDiscoverReplace, systemPrompt, prompt, &replacements); err != nil {
+		return respondAIError(c, err, "替换方案生成结果无效，请重试", "failed to generate replacements")
 	}
 
-	meals := parseDiscoverMeals(reply)
+	meals := tagPromptVersion(aiMealsToMaps(replacements.Meals), promptVersion)
+	meals = filterExcludedMeals(meals, service.DietRestrictionsFromSettings(pctx.Settings))
 	if h.dishService != nil {
 		for _, meal := range meals {
 			meal["name"] = readStringOr(meal["name"], readString(meal["title"]))
-			_ = h.dishService.UpsertFromMap(meal)
+			_ = h.dishService.UpsertFromMap(meal, model.NutritionSourceAIPlan)
 		}
 	}
 
@@ -263,41 +235,116 @@ func (h *DiscoverHandler) GenerateWeeklyMenus(c echo.Context) error {
 	if mode == "" {
 		mode = "weekly"
 	}
+	job := weeklyMenuJobPayload{
+		ClientTime: clientTime.Format(time.RFC3339Nano),
+		PlanMode:   mode,
+		Weekday:    req.Weekday,
+	}
+
+	// 异步模式：7 天循环放入任务队列，客户端轮询 GET /jobs/:id
+	if wantsAsync(c) && h.jobs.Supports(service.AIJobWeeklyMenu) {
+		// 预留的额度随任务转交，任务结束时确认或释放
+		hold := quotaHoldFrom(c)
+		queued, err := h.jobs.Enqueue(userID, service.AIJobWeeklyMenu, job, hold.IDs()...)
+		if err != nil {
+			c.Logger().Errorf("weekly menu enqueue failed: %v", err)
+			return response.InternalError(c, "failed to enqueue weekly menu")
+		}
+		hold.Detach()
+		return respondJobAccepted(c, queued)
+	}
 
+	result, err := h.generateWeeklyMenus(c.Request().Context(), userID, job, time.Time{})
+	if err != nil {
+		if service.IsAIUnavailable(err) {
+			return respondAIUnavailable(c)
+		}
+		if errors.Is(err, errResultSave) {
+			c.Logger().Errorf("manual weekly menu save failed: %v", err)
+			return response.InternalError(c, "failed to save weekly menu")
+		}
+		c.Logger().Errorf("manual weekly menu generate failed: %v", err)
+		return response.InternalError(c, "failed to generate weekly menu")
+	}
+	return response.Success(c, result)
+}
+
+// weeklyMenuJobPayload 入队的周菜单生成任务。
+type weeklyMenuJobPayload struct {
+	ClientTime string `json:"client_time"`
+	PlanMode   string `json:"plan_mode"`
+	Weekday    int    `json:"weekday"`
+}
+
+// RunWeeklyMenuJob 执行排队的周菜单生成；重试时跳过本任务已生成的日期
+func (h *DiscoverHandler) RunWeeklyMenuJob(ctx context.Context, job *model.AIJob) (interface{}, error) {
+	if h.aiService == nil || !h.aiService.IsEnabled() {
+		return nil, service.NewAIJobError("ai_unconfigured", "AI 服务未配置")
+	}
+	if h.weeklyMenu == nil || !h.weeklyMenu.IsEnabled() {
+		return nil, service.NewAIJobError("weekly_menu_unavailable", "周菜单存储不可用")
+	}
+	var payload weeklyMenuJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, service.NewAIJobError("invalid_payload", "任务参数无效")
+	}
+	var resumeAfter time.Time
+	if job.Attempts > 1 {
+		resumeAfter = job.CreatedAt
+	}
+	return h.generateWeeklyMenus(ctx, job.UserID, payload, resumeAfter)
+}
+
+// generateWeeklyMenus 逐天生成并保存菜单，返回第一天的结果；
+// resumeAfter 非零时跳过在该时间之后已更新的日期（任务重试时避免重复调用模型）。
+func (h *DiscoverHandler) generateWeeklyMenus(
+	ctx context.Context,
+	userID int64,
+	job weeklyMenuJobPayload,
+	resumeAfter time.Time,
+) (map[string]interface{}, error) {
+	clientTime := parseClientTime(job.ClientTime)
 	weekStart := weekStartForDate(clientTime)
 	startDay := 1
 	endDay := 7
-	if req.Weekday >= 1 && req.Weekday <= 7 {
-		startDay = req.Weekday
-		endDay = req.Weekday
+	if job.Weekday >= 1 && job.Weekday <= 7 {
+		startDay = job.Weekday
+		endDay = job.Weekday
 	}
 
 	var respPlan []map[string]interface{}
 	var respRecs []map[string]interface{}
 
 	for weekday := startDay; weekday <= endDay; weekday++ {
+		if !resumeAfter.IsZero() {
+			if existing, err := h.weeklyMenu.Get(userID, weekStart, weekday); err == nil && existing != nil && existing.UpdatedAt.After(resumeAfter) {
+				if weekday == startDay {
+					respPlan = decodeDiscoverMeals(existing.PlanMeals)
+					respRecs = decodeDiscoverMeals(existing.Recommendations)
+				}
+				continue
+			}
+		}
 		targetDate := weekStart.AddDate(0, 0, weekday-1)
 		planMeals, recommendations, err := h.generateDiscoverMenus(
-			c.Request().Context(),
+			ctx,
 			userID,
-			mode,
+			job.PlanMode,
 			weekday,
 			targetDate,
 			clientTime,
 		)
 		if err != nil {
-			c.Logger().Errorf("manual weekly menu generate failed (day %d): %v", weekday, err)
-			return response.InternalError(c, "failed to generate weekly menu")
+			return nil, fmt.Errorf("day %d: %w", weekday, err)
 		}
 		if h.dishService != nil {
 			for _, meal := range append(append([]map[string]interface{}{}, planMeals...), recommendations...) {
 				meal["name"] = readStringOr(meal["name"], readString(meal["title"]))
-				_ = h.dishService.UpsertFromMap(meal)
+				_ = h.dishService.UpsertFromMap(meal, model.NutritionSourceAIPlan)
 			}
 		}
 		if err := h.weeklyMenu.Upsert(userID, weekStart, weekday, planMeals, recommendations); err != nil {
-			c.Logger().Errorf("manual weekly menu upsert failed (day %d): %v", weekday, err)
-			return response.InternalError(c, "failed to save weekly menu")
+			return nil, fmt.Errorf("%w (day %d): %v", errResultSave, weekday, err)
 		}
 		if weekday == startDay {
 			respPlan = planMeals
@@ -305,12 +352,12 @@ func (h *DiscoverHandler) GenerateWeeklyMenus(c echo.Context) error {
 		}
 	}
 
-	return response.Success(c, map[string]interface{}{
+	return map[string]interface{}{
 		"week_start":      weekStart.Format("2006-01-02"),
 		"weekday":         startDay,
 		"plan_meals":      respPlan,
 		"recommendations": respRecs,
-	})
+	}, nil
 }
 
 // SaveWeeklyMenus 保存本周菜单（用于客户端替换后同步）
@@ -372,7 +419,7 @@ func (h *DiscoverHandler) SaveWeeklyMenus(c echo.Context) error {
 	if h.dishService != nil {
 		for _, meal := range list {
 			meal["name"] = readStringOr(meal["name"], readString(meal["title"]))
-			_ = h.dishService.UpsertFromMap(meal)
+			_ = h.dishService.UpsertFromMap(meal, model.NutritionSourceAIPlan)
 		}
 	}
 
@@ -393,18 +440,16 @@ func (h *DiscoverHandler) generateDiscoverMenus(
 		return nil, nil, errors.New("ai service is not configured")
 	}
 
-	recentMealSummary := ""
-	if h.mealService != nil {
-		if records, err := h.mealService.ListByUser(userID, 20); err == nil {
-			recentMealSummary = service.SummarizeMealRecords(records, 3)
-		}
-	}
-	if strings.TrimSpace(recentMealSummary) == "" {
-		recentMealSummary = "暂无"
+	systemPrompt := ""
+	promptVersion := ""
+	pctx := h.promptContext.Build(userID, service.PromptContextOptions{ClientTime: clientTime, TargetDate: targetDate})
+	if rendered, err := h.promptContext.Render(service.PromptDiscoverPlan, pctx, nil); err == nil {
+		systemPrompt = rendered.Text
+		promptVersion = rendered.Version
+	} else {
+		log.Printf("discover plan prompt render failed (user %d): %v", userID, err)
 	}
 
-	systemPrompt := h.buildDiscoverSystemPrompt(userID, targetDate, clientTime, recentMealSummary)
-
 	prompt := fmt.Sprintf(`你正在为“元气食光”的发现页生成真实可执行的健康餐食推荐。
 要求输出 JSON（不要输出额外文本）：
 {
@@ -444,99 +489,19 @@ func (h *DiscoverHandler) generateDiscoverMenus(
 - 输出必须是严格 JSON，不要输出解释文字或代码块。
 计划模式：%s。今天是周%d。请根据用户设置、训练/放纵日状态调整营养结构。`, mode, weekday)
 
-	reply, err := h.aiService.Chat(ctx, systemPrompt, "", nil, prompt, nil)
-	if err != nil {
+	var plan service.AIDiscoverPlan
+	if _, err := h.aiService.ChatStructured(ctx, service.AIRouteDiscoverPlan, systemPrompt, prompt, &plan); err != nil {
 		return nil, nil, err
 	}
-
-	planMeals, recommendations := parseDiscoverPayload(reply)
+	planMeals := tagPromptVersion(aiMealsToMaps(plan.PlanMeals), promptVersion)
+	recommendations := tagPromptVersion(aiMealsToMaps(plan.Recommendations), promptVersion)
+	// 计划餐保留每个时段只做标记，推荐餐直接移除命中忌口的
+	restrictions := service.DietRestrictionsFromSettings(pctx.Settings)
+	flagExcludedMeals(planMeals, restrictions)
+	recommendations = filterExcludedMeals(recommendations, restrictions)
 	return planMeals, recommendations, nil
 }
 
-func (h *DiscoverHandler) buildDiscoverSystemPrompt(
-	userID int64,
-	targetDate time.Time,
-	clientTime time.Time,
-	recentMealSummary string,
-) string {
-	if h.settingsService == nil {
-		return ""
-	}
-	raw, err := h.settingsService.Get(userID)
-	if err != nil || len(raw) == 0 {
-		return ""
-	}
-	var settings map[string]interface{}
-	if err := json.Unmarshal(raw, &settings); err != nil {
-		return ""
-	}
-	template, err := service.LoadDiscoverPlanPromptTemplate()
-	if err != nil {
-		return ""
-	}
-	isTraining, isCheat := computeDayFlagsForDate(settings, targetDate)
-	dayType := dayTypeLabel(isTraining, isCheat)
-	timeOfDay := timeOfDayLabel(clientTime)
-	intake := buildIntakeContext(h.dailyService, userID, clientTime, settings)
-	return service.BuildSystemPrompt(template, settings, map[string]string{
-		"menu_scanned":        "false",
-		"food_photo_taken":    "false",
-		"recent_chat_summary": "暂无",
-		"recent_meal_summary": recentMealSummary,
-		"current_time":        formatPromptTime(clientTime),
-		"time_of_day":         timeOfDay,
-		"day_type":            dayType,
-		"is_training_day":     formatYesNo(isTraining),
-		"is_cheat_day":        formatYesNo(isCheat),
-		"calories_consumed":   intake.CaloriesConsumed,
-		"macro_consumed":      intake.MacroConsumed,
-		"calorie_remaining":   intake.CalorieRemaining,
-	})
-}
-
-func parseDiscoverPayload(raw string) ([]map[string]interface{}, []map[string]interface{}) {
-	payload := extractJSONPayload(raw)
-	if payload == "" {
-		return nil, nil
-	}
-	var decoded map[string]interface{}
-	if err := json.Unmarshal([]byte(payload), &decoded); err == nil {
-		planMeals := parseDiscoverList(decoded["plan_meals"])
-		recommendations := parseDiscoverList(decoded["recommendations"])
-		if len(recommendations) == 0 {
-			recommendations = parseDiscoverList(decoded["meals"])
-		}
-		return planMeals, recommendations
-	}
-	var list []interface{}
-	if err := json.Unmarshal([]byte(payload), &list); err == nil {
-		return nil, parseDiscoverList(list)
-	}
-	return nil, nil
-}
-
-func parseDiscoverMeals(raw string) []map[string]interface{} {
-	payload := extractJSONPayload(raw)
-	if payload == "" {
-		return nil
-	}
-	var decoded map[string]interface{}
-	if err := json.Unmarshal([]byte(payload), &decoded); err == nil {
-		if meals := parseDiscoverList(decoded["meals"]); len(meals) > 0 {
-			return meals
-		}
-		if meals := parseDiscoverList(decoded["recommendations"]); len(meals) > 0 {
-			return meals
-		}
-		return parseDiscoverList(decoded["plan_meals"])
-	}
-	var list []interface{}
-	if err := json.Unmarshal([]byte(payload), &list); err == nil {
-		return parseDiscoverList(list)
-	}
-	return nil
-}
-
 func parseDiscoverList(raw interface{}) []map[string]interface{} {
 	items, ok := raw.([]interface{})
 	if !ok || len(items) == 0 {
@@ -707,6 +672,26 @@ func currentWeekday() int {
 	return weekday
 }
 
+// respondDefaultWeeklyMenus AI 不可用时返回内置菜单，不写入周菜单缓存。
+func respondDefaultWeeklyMenus(c echo.Context, weekday int, restrictions *service.DietRestrictions) error {
+	planMeals, recommendations := defaultWeeklyMenus(weekday)
+	flagExcludedMeals(planMeals, restrictions)
+	return response.Success(c, map[string]interface{}{
+		"plan_meals":      planMeals,
+		"recommendations": filterExcludedMeals(recommendations, restrictions),
+		"degraded":        true,
+	})
+}
+
+// respondDiscoverMenus 计划餐按忌口标记，推荐餐移除命中忌口的。
+func respondDiscoverMenus(c echo.Context, planMeals, recommendations []map[string]interface{}, restrictions *service.DietRestrictions) error {
+	flagExcludedMeals(planMeals, restrictions)
+	return response.Success(c, map[string]interface{}{
+		"plan_meals":      planMeals,
+		"recommendations": filterExcludedMeals(recommendations, restrictions),
+	})
+}
+
 func defaultWeeklyMenus(weekday int) ([]map[string]interface{}, []map[string]interface{}) {
 	dayNames := []string{"周一", "周二", "周三", "周四", "周五", "周六", "周日"}
 	dayLabel := dayNames[(weekday-1+7)%7]
@@ -815,39 +800,7 @@ func decodeDiscoverMeals(raw json.RawMessage) []map[string]interface{} {
 }
 
 func computeDayFlagsForDate(settings map[string]interface{}, targetDate time.Time) (bool, bool) {
-	weekday := weekdayFromDate(targetDate)
-	dayOfMonth := targetDate.Day()
-
-	trainingDays := readIntSlice(settings["monthly_training_days"])
-	cheatDays := readIntSlice(settings["monthly_cheat_days"])
-	if len(trainingDays) > 0 || len(cheatDays) > 0 {
-		isTraining := containsInt(trainingDays, dayOfMonth)
-		isCheat := containsInt(cheatDays, dayOfMonth)
-		if isCheat {
-			isTraining = false
-		}
-		return isTraining, isCheat
-	}
-
-	weeklyTrainingList := readIntSlice(settings["weekly_training_days_list"])
-	weeklyCheatList := readIntSlice(settings["weekly_cheat_days_list"])
-	if len(weeklyTrainingList) > 0 || len(weeklyCheatList) > 0 {
-		isTraining := containsInt(weeklyTrainingList, weekday)
-		isCheat := containsInt(weeklyCheatList, weekday)
-		if isCheat {
-			isTraining = false
-		}
-		return isTraining, isCheat
-	}
-
-	weeklyTraining := clampInt(readInt(settings["weekly_training_days"], 0), 0, 7)
-	cheatFrequency := clampInt(readInt(settings["cheat_frequency"], 0), 0, 7)
-	isTraining := weeklyTraining > 0 && weekday <= weeklyTraining
-	isCheat := cheatFrequency > 0 && weekday > 7-cheatFrequency
-	if isCheat {
-		isTraining = false
-	}
-	return isTraining, isCheat
+	return service.ComputeDayFlags(settings, targetDate)
 }
 
 func formatYesNo(value bool) string {
@@ -897,12 +850,3 @@ func readIntSlice(value interface{}) []int {
 	}
 	return nil
 }
-
-func containsInt(list []int, value int) bool {
-	for _, item := range list {
-		if item == value {
-			return true
-		}
-	}
-	return false
-}
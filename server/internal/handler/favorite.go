@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"eatclean/internal/model"
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+type FavoriteHandler struct {
+	favorites *service.FavoriteService
+}
+
+func NewFavoriteHandler(favorites *service.FavoriteService) *FavoriteHandler {
+	return &FavoriteHandler{favorites: favorites}
+}
+
+// List 收藏列表
+// GET /api/v1/favorites
+func (h *FavoriteHandler) List(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	favorites, err := h.favorites.List(userID)
+	if err != nil {
+		c.Logger().Errorf("favorite list failed: %v", err)
+		return response.InternalError(c, "failed to load favorites")
+	}
+	if favorites == nil {
+		favorites = make([]model.FavoriteItem, 0)
+	}
+	return response.Success(c, favorites)
+}
+
+// Add 收藏菜品（dish，附带条目）、食物（food，按名称）或菜谱（recipe，ref_id）
+// POST /api/v1/favorites
+func (h *FavoriteHandler) Add(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	var req struct {
+		Kind  string                 `json:"kind"`
+		RefID int64                  `json:"ref_id"`
+		Name  string                 `json:"name"`
+		Item  map[string]interface{} `json:"item"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	favorite := &model.FavoriteItem{
+		UserID: userID,
+		Kind:   strings.TrimSpace(req.Kind),
+		RefID:  req.RefID,
+		Name:   req.Name,
+		Item:   req.Item,
+	}
+	if err := h.favorites.Add(favorite); err != nil {
+		switch {
+		case errors.Is(err, service.ErrFavoriteInvalid), errors.Is(err, service.ErrRecipeInvalid), errors.Is(err, service.ErrPortionBase):
+			return response.BadRequest(c, err.Error())
+		case errors.Is(err, service.ErrRecipeNotFound):
+			return response.Error(c, http.StatusNotFound, "recipe not found")
+		case isForeignKeyViolation(err):
+			return response.Unauthorized(c, "user not found, please re-login")
+		}
+		c.Logger().Errorf("favorite add failed: %v", err)
+		return response.InternalError(c, "failed to save favorite")
+	}
+	return response.Success(c, favorite)
+}
+
+// Delete 取消收藏
+// DELETE /api/v1/favorites/:id
+func (h *FavoriteHandler) Delete(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return response.BadRequest(c, "invalid id")
+	}
+	deleted, err := h.favorites.Delete(userID, id)
+	if err != nil {
+		c.Logger().Errorf("favorite delete failed: %v", err)
+		return response.InternalError(c, "failed to delete favorite")
+	}
+	if !deleted {
+		return response.Error(c, http.StatusNotFound, "favorite not found")
+	}
+	return response.Success(c, map[string]interface{}{"deleted": true})
+}
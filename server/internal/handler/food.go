@@ -2,7 +2,6 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"strconv"
 	"strings"
@@ -16,12 +15,17 @@ import (
 )
 
 type FoodHandler struct {
-	dishes *repository.DishRepository
-	ai     *service.ChatAIService
+	dishes   *repository.DishRepository
+	foods    *service.FoodService
+	barcodes *service.BarcodeService
+	customs  *service.CustomFoodService
+	suggest  *service.FoodSuggestService
+	ai       *service.ChatAIService
+	searches *service.FoodSearchLogService
 }
 
-func NewFoodHandler(dishes *repository.DishRepository, ai *service.ChatAIService) *FoodHandler {
-	return &FoodHandler{dishes: dishes, ai: ai}
+func NewFoodHandler(dishes *repository.DishRepository, foods *service.FoodService, barcodes *service.BarcodeService, customs *service.CustomFoodService, suggest *service.FoodSuggestService, ai *service.ChatAIService, searches *service.FoodSearchLogService) *FoodHandler {
+	return &FoodHandler{dishes: dishes, foods: foods, barcodes: barcodes, customs: customs, suggest: suggest, ai: ai, searches: searches}
 }
 
 type foodSearchRequest struct {
@@ -35,6 +39,10 @@ type foodSearchResult struct {
 	Fat      float64 `json:"fat_g_per100g"`
 	Carbs    float64 `json:"carbs_g_per100g"`
 	Advice   string  `json:"advice"`
+	// Source 营养数据来源：cfct / usda_fdc 为食物成分表，其余为 AI 估算
+	Source string `json:"source,omitempty"`
+	// Degraded AI 不可用时返回的相近缓存数据
+	Degraded bool `json:"degraded,omitempty"`
 }
 
 func (h *FoodHandler) Search(c echo.Context) error {
@@ -47,9 +55,22 @@ func (h *FoodHandler) Search(c echo.Context) error {
 		return response.BadRequest(c, "query is required")
 	}
 	raw := strings.TrimSpace(req.Query)
-	norm := normalizeFoodName(raw)
+	norm := model.NormalizeFoodName(raw)
+	if err := h.searches.Record(userID, raw); err != nil {
+		c.Logger().Warnf("food search log failed: %v", err)
+	}
 
-	// 1) 缓存命中直接返回
+	// 1) 用户自建食物优先，其次是食物成分表与 AI 估算的缓存
+	if custom, err := h.customs.Lookup(userID, raw); err != nil {
+		c.Logger().Warnf("custom food lookup failed: %v", err)
+	} else if custom != nil {
+		return response.Success(c, customFoodResult(*custom))
+	}
+	if food, err := h.foods.Lookup(raw); err != nil {
+		c.Logger().Warnf("food table lookup failed: %v", err)
+	} else if food != nil {
+		return response.Success(c, foodTableResult(*food))
+	}
 	if h.dishes != nil {
 		if cached, err := h.dishes.FindOne(norm); err == nil && cached != nil {
 			return response.Success(c, toFoodResult(*cached))
@@ -59,10 +80,16 @@ func (h *FoodHandler) Search(c echo.Context) error {
 	if h.ai == nil || !h.ai.IsEnabled() {
 		return response.InternalError(c, "ai service not available")
 	}
+	if !h.ai.RouteAvailable(service.AIRouteFoodSearch) {
+		return h.respondDegraded(c, userID, raw)
+	}
 
 	// 2) 调用模型
 	info, err := h.generateFoodInfo(c.Request().Context(), raw)
 	if err != nil {
+		if service.IsAIUnavailable(err) {
+			return h.respondDegraded(c, userID, raw)
+		}
 		c.Logger().Errorf("food search ai failed: %v", err)
 		return response.InternalError(c, "获取食物信息失败，请稍后再试")
 	}
@@ -72,64 +99,116 @@ func (h *FoodHandler) Search(c echo.Context) error {
 		_ = h.dishes.Upsert(&model.Dish{
 			Name:           info.Name,
 			NormalizedName: norm,
-			NutritionEstimate: mustJSON(map[string]interface{}{
-				"calories_kcal_per100g": info.Calories,
-				"protein_g_per100g":     info.Protein,
-				"fat_g_per100g":         info.Fat,
-				"carbs_g_per100g":       info.Carbs,
-				"advice":                info.Advice,
-			}),
+			Nutrition: &model.Nutrition{
+				Per100g: &model.NutritionFacts{
+					EnergyKcal: info.Calories,
+					ProteinG:   info.Protein,
+					FatG:       info.Fat,
+					CarbsG:     info.Carbs,
+				},
+				Confidence: model.DefaultNutritionConfidence(model.NutritionSourceAISearch),
+				Source:     model.NutritionSourceAISearch,
+			},
+			Advice: info.Advice,
 		})
 	}
 
+	info.Source = model.NutritionSourceAISearch
 	return response.Success(c, info)
 }
 
-func toFoodResult(d model.Dish) *foodSearchResult {
-	var parsed map[string]interface{}
-	calories := 0.0
-	protein := 0.0
-	fat := 0.0
-	carbs := 0.0
-	advice := ""
-	if len(d.NutritionEstimate) > 0 {
-		_ = json.Unmarshal(d.NutritionEstimate, &parsed)
-		if parsed != nil {
-			calories = toFloat(parsed["calories_kcal_per100g"])
-			protein = toFloat(parsed["protein_g_per100g"])
-			fat = toFloat(parsed["fat_g_per100g"])
-			carbs = toFloat(parsed["carbs_g_per100g"])
-			if v, ok := parsed["advice"].(string); ok {
-				advice = v
-			}
+// respondDegraded AI 熔断/繁忙时返回模糊搜索得分最高且有每 100g 数据的条目，没有则 503。
+func (h *FoodHandler) respondDegraded(c echo.Context, userID int64, query string) error {
+	suggestions, err := h.suggest.Suggest(userID, query, 5)
+	if err != nil {
+		c.Logger().Warnf("food suggest failed: %v", err)
+	}
+	for _, item := range suggestions {
+		if item.Per100g == nil {
+			continue
 		}
+		return response.Success(c, &foodSearchResult{
+			Name:     item.Name,
+			Calories: item.Per100g.EnergyKcal,
+			Protein:  item.Per100g.ProteinG,
+			Fat:      item.Per100g.FatG,
+			Carbs:    item.Per100g.CarbsG,
+			Source:   item.Source,
+			Degraded: true,
+		})
+	}
+	return respondAIUnavailable(c)
+}
+
+// Suggest 输入联想：自建食物、食物成分表与已缓存菜品的模糊匹配，支持拼音与首字母
+// GET /api/v1/food/suggest?q=xhs&limit=10
+func (h *FoodHandler) Suggest(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	limit := 10
+	if raw := c.QueryParam("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 && parsed <= 20 {
+			limit = parsed
+		}
+	}
+	suggestions, err := h.suggest.Suggest(userID, c.QueryParam("q"), limit)
+	if err != nil {
+		c.Logger().Errorf("food suggest failed: %v", err)
+		return response.InternalError(c, "food suggest failed")
 	}
+	if suggestions == nil {
+		suggestions = make([]service.FoodSuggestion, 0)
+	}
+	return response.Success(c, suggestions)
+}
+
+// toFoodResult 每 100g 数据；缓存中只有每份数据且份量未知时营养为 0。
+func toFoodResult(d model.Dish) *foodSearchResult {
+	per100, _ := d.Nutrition.Per100()
 	return &foodSearchResult{
 		Name:     d.Name,
-		Calories: calories,
-		Protein:  protein,
-		Fat:      fat,
-		Carbs:    carbs,
-		Advice:   advice,
+		Calories: per100.EnergyKcal,
+		Protein:  per100.ProteinG,
+		Fat:      per100.FatG,
+		Carbs:    per100.CarbsG,
+		Advice:   d.Advice,
+		Source:   nutritionSourceOf(d.Nutrition),
+	}
+}
+
+// foodTableResult 成分表条目，数据即为每 100g 可食部。
+func foodTableResult(f model.Food) *foodSearchResult {
+	per100, _ := f.Nutrition.Per100()
+	return &foodSearchResult{
+		Name:     f.Name,
+		Calories: per100.EnergyKcal,
+		Protein:  per100.ProteinG,
+		Fat:      per100.FatG,
+		Carbs:    per100.CarbsG,
+		Source:   f.Source,
+	}
+}
+
+// customFoodResult 自建食物；只有每份数据且份量未知时营养为 0。
+func customFoodResult(f model.CustomFood) *foodSearchResult {
+	per100, _ := f.Nutrition.Per100()
+	return &foodSearchResult{
+		Name:     f.Name,
+		Calories: per100.EnergyKcal,
+		Protein:  per100.ProteinG,
+		Fat:      per100.FatG,
+		Carbs:    per100.CarbsG,
+		Source:   nutritionSourceOf(f.Nutrition),
 	}
 }
 
-func toFloat(v interface{}) float64 {
-	switch val := v.(type) {
-	case float64:
-		return val
-	case float32:
-		return float64(val)
-	case int:
-		return float64(val)
-	case int64:
-		return float64(val)
-	case string:
-		f, _ := strconv.ParseFloat(val, 64)
-		return f
-	default:
-		return 0
+func nutritionSourceOf(n *model.Nutrition) string {
+	if n == nil {
+		return ""
 	}
+	return n.Source
 }
 
 func (h *FoodHandler) generateFoodInfo(ctx context.Context, name string) (*foodSearchResult, error) {
@@ -144,32 +223,24 @@ other: 其他营养成分(数字)
 desc: 食物的详细介绍，包括不限于种类、来源地等
 advice: 1-2 句饮食建议
 `
-	reply, err := h.ai.Chat(
-		ctx,
-		prompt,
-		"",
-		nil,
-		"食物："+name,
-		nil,
-	)
-	if err != nil {
+	var info service.AIFoodInfo
+	if _, err := h.ai.ChatStructured(ctx, service.AIRouteFoodSearch, prompt, "食物："+name, &info); err != nil {
 		return nil, err
 	}
-	var parsed foodSearchResult
-	if err := json.Unmarshal([]byte(reply), &parsed); err != nil {
-		return nil, err
+	parsed := foodSearchResult{
+		Name:     strings.TrimSpace(firstNonEmpty(info.Name, name)),
+		Calories: float64(info.Calories),
+		Protein:  float64(info.Protein),
+		Fat:      float64(info.Fat),
+		Carbs:    float64(info.Carbs),
+		Advice:   strings.TrimSpace(info.Advice),
 	}
-	parsed.Name = strings.TrimSpace(firstNonEmpty(parsed.Name, name))
 	if parsed.Name == "" {
 		return nil, errors.New("empty name from ai")
 	}
 	return &parsed, nil
 }
 
-func normalizeFoodName(name string) string {
-	return strings.ToLower(strings.TrimSpace(name))
-}
-
 func firstNonEmpty(values ...string) string {
 	for _, v := range values {
 		if strings.TrimSpace(v) != "" {
@@ -178,8 +249,3 @@ func firstNonEmpty(values ...string) string {
 	}
 	return ""
 }
-
-func mustJSON(v interface{}) []byte {
-	data, _ := json.Marshal(v)
-	return data
-}
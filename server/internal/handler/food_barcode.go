@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"eatclean/internal/model"
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+type barcodeRequest struct {
+	Barcode string `json:"barcode"`
+	// Name/Brand 条码未收录时用于 AI 估算
+	Name  string `json:"name"`
+	Brand string `json:"brand"`
+}
+
+type barcodeResult struct {
+	Barcode   string           `json:"barcode"`
+	Name      string           `json:"name"`
+	Brand     string           `json:"brand,omitempty"`
+	Quantity  string           `json:"quantity,omitempty"`
+	Nutrition *model.Nutrition `json:"nutrition"`
+	Source    string           `json:"source"`
+	// Estimated 营养数据为 AI 按商品名估算
+	Estimated bool `json:"estimated"`
+	// Corrected 使用了当前用户提交的修正
+	Corrected bool `json:"corrected"`
+}
+
+type barcodeCorrectionRequest struct {
+	Barcode      string                `json:"barcode"`
+	Name         string                `json:"name"`
+	Brand        string                `json:"brand"`
+	ServingSizeG float64               `json:"serving_size_g"`
+	Per100g      *model.NutritionFacts `json:"per_100g"`
+	PerServing   *model.NutritionFacts `json:"per_serving"`
+}
+
+// Barcode 条码查询
+// POST /api/v1/food/barcode
+func (h *FoodHandler) Barcode(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	var req barcodeRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	barcode, err := service.NormalizeBarcode(req.Barcode)
+	if err != nil {
+		return response.BadRequest(c, "条码无效")
+	}
+
+	found, err := h.barcodes.Lookup(userID, barcode)
+	if err != nil {
+		c.Logger().Errorf("barcode lookup failed: %v", err)
+		return response.InternalError(c, "barcode lookup failed")
+	}
+	if found != nil {
+		return response.Success(c, toBarcodeResult(found.Item, found.Corrected))
+	}
+
+	// 未收录：有商品名时按名称估算每 100g 营养
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return response.Error(c, http.StatusNotFound, "未收录该条码，请输入商品名称")
+	}
+	if h.ai == nil || !h.ai.IsEnabled() || !h.ai.RouteAvailable(service.AIRouteFoodSearch) {
+		return respondAIUnavailable(c)
+	}
+	query := name
+	if brand := strings.TrimSpace(req.Brand); brand != "" && !strings.Contains(name, brand) {
+		query = brand + " " + name
+	}
+	info, err := h.generateFoodInfo(c.Request().Context(), "包装食品 "+query)
+	if err != nil {
+		if service.IsAIUnavailable(err) {
+			return respondAIUnavailable(c)
+		}
+		c.Logger().Errorf("barcode ai estimate failed: %v", err)
+		return response.InternalError(c, "获取食物信息失败，请稍后再试")
+	}
+	item := &model.FoodBarcode{
+		Barcode: barcode,
+		Name:    name,
+		Brand:   strings.TrimSpace(req.Brand),
+		Nutrition: &model.Nutrition{
+			Per100g: &model.NutritionFacts{
+				EnergyKcal: info.Calories,
+				ProteinG:   info.Protein,
+				FatG:       info.Fat,
+				CarbsG:     info.Carbs,
+			},
+			Confidence: model.DefaultNutritionConfidence(model.NutritionSourceAISearch),
+			Source:     model.NutritionSourceAISearch,
+		},
+	}
+	if err := h.barcodes.SaveEstimate(userID, item); err != nil {
+		c.Logger().Warnf("barcode estimate save failed: %v", err)
+	}
+	return response.Success(c, toBarcodeResult(item, false))
+}
+
+// CorrectBarcode 提交条码修正，之后该用户扫描同一条码时使用修正后的数据
+// POST /api/v1/food/barcode/correction
+func (h *FoodHandler) CorrectBarcode(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	var req barcodeCorrectionRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	barcode, err := service.NormalizeBarcode(req.Barcode)
+	if err != nil {
+		return response.BadRequest(c, "条码无效")
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return response.BadRequest(c, "name is required")
+	}
+	nutrition := &model.Nutrition{
+		Per100g:      req.Per100g,
+		PerServing:   req.PerServing,
+		ServingSizeG: req.ServingSizeG,
+		Confidence:   model.DefaultNutritionConfidence(model.NutritionSourceUser),
+		Source:       model.NutritionSourceUser,
+	}
+	if err := validateUserNutrition(nutrition); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+	correction := &model.FoodBarcodeCorrection{
+		Barcode:   barcode,
+		UserID:    userID,
+		Name:      name,
+		Brand:     strings.TrimSpace(req.Brand),
+		Nutrition: nutrition,
+	}
+	if err := h.barcodes.SaveCorrection(correction); err != nil {
+		c.Logger().Errorf("barcode correction save failed: %v", err)
+		return response.InternalError(c, "failed to save correction")
+	}
+	return response.Success(c, toBarcodeResult(&model.FoodBarcode{
+		Barcode:   barcode,
+		Name:      correction.Name,
+		Brand:     correction.Brand,
+		Nutrition: correction.Nutrition,
+		CreatedAt: correction.CreatedAt,
+		UpdatedAt: correction.UpdatedAt,
+	}, true))
+}
+
+// validateUserNutrition 用户录入的营养数据：至少给出一种基准，数值在合理范围内（全部为 0 的零热量食品也可以录入）。
+func validateUserNutrition(n *model.Nutrition) error {
+	if n.Per100g == nil && n.PerServing == nil {
+		return errors.New("per_100g or per_serving is required")
+	}
+	if n.ServingSizeG < 0 || n.ServingSizeG > 5000 {
+		return errors.New("serving_size_g out of range")
+	}
+	if n.Per100g != nil {
+		if err := validateFacts(*n.Per100g, 900, 100); err != nil {
+			return errors.New("per_100g: " + err.Error())
+		}
+	}
+	if n.PerServing != nil {
+		if err := validateFacts(*n.PerServing, 5000, 1000); err != nil {
+			return errors.New("per_serving: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func validateFacts(f model.NutritionFacts, maxKcal float64, maxGrams float64) error {
+	// 水、茶、无糖饮料等的能量可以为 0
+	if f.EnergyKcal < 0 || f.EnergyKcal > maxKcal {
+		return errors.New("energy_kcal out of range")
+	}
+	for _, value := range []float64{f.ProteinG, f.FatG, f.SaturatedFatG, f.CarbsG, f.SugarG, f.AddedSugarG, f.FiberG} {
+		if value < 0 || value > maxGrams {
+			return errors.New("nutrient grams out of range")
+		}
+	}
+	if f.SaturatedFatG > f.FatG && f.FatG > 0 {
+		return errors.New("saturated_fat_g exceeds fat_g")
+	}
+	if f.SugarG > f.CarbsG && f.CarbsG > 0 {
+		return errors.New("sugar_g exceeds carbs_g")
+	}
+	if f.AddedSugarG > f.SugarG && f.SugarG > 0 {
+		return errors.New("added_sugar_g exceeds sugar_g")
+	}
+	if f.SodiumMg < 0 || f.SodiumMg > maxGrams*1000 {
+		return errors.New("sodium_mg out of range")
+	}
+	return nil
+}
+
+func toBarcodeResult(item *model.FoodBarcode, corrected bool) *barcodeResult {
+	source := ""
+	if item.Nutrition != nil {
+		source = item.Nutrition.Source
+	}
+	return &barcodeResult{
+		Barcode:   item.Barcode,
+		Name:      item.Name,
+		Brand:     item.Brand,
+		Quantity:  item.Quantity,
+		Nutrition: item.Nutrition.Complete(),
+		Source:    source,
+		Estimated: source == model.NutritionSourceAISearch,
+		Corrected: corrected,
+	}
+}
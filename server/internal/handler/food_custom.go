@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"eatclean/internal/model"
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+type customFoodRequest struct {
+	Name         string                `json:"name"`
+	Brand        string                `json:"brand"`
+	Barcode      string                `json:"barcode"`
+	ServingSizeG float64               `json:"serving_size_g"`
+	Per100g      *model.NutritionFacts `json:"per_100g"`
+	PerServing   *model.NutritionFacts `json:"per_serving"`
+	// Source 默认 user；客户端核对营养成分表识别结果后保存时传 label
+	Source     string             `json:"source"`
+	NRVPercent map[string]float64 `json:"nrv_percent"`
+}
+
+// ListCustom 自建食物列表
+// GET /api/v1/food/custom
+func (h *FoodHandler) ListCustom(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	foods, err := h.customs.List(userID)
+	if err != nil {
+		c.Logger().Errorf("custom food list failed: %v", err)
+		return response.InternalError(c, "failed to load custom foods")
+	}
+	if foods == nil {
+		foods = make([]model.CustomFood, 0)
+	}
+	for idx := range foods {
+		foods[idx].Nutrition = foods[idx].Nutrition.Complete()
+	}
+	return response.Success(c, foods)
+}
+
+// SaveCustom 新建或按名称覆盖自建食物
+// POST /api/v1/food/custom
+func (h *FoodHandler) SaveCustom(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	var req customFoodRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return response.BadRequest(c, "name is required")
+	}
+	barcode := ""
+	if strings.TrimSpace(req.Barcode) != "" {
+		normalized, err := service.NormalizeBarcode(req.Barcode)
+		if err != nil {
+			return response.BadRequest(c, "条码无效")
+		}
+		barcode = normalized
+	}
+	source := model.NutritionSourceUser
+	if req.Source == model.NutritionSourceLabel {
+		source = model.NutritionSourceLabel
+	}
+	nutrition := &model.Nutrition{
+		Per100g:      req.Per100g,
+		PerServing:   req.PerServing,
+		ServingSizeG: req.ServingSizeG,
+		Confidence:   model.DefaultNutritionConfidence(source),
+		Source:       source,
+	}
+	if err := validateUserNutrition(nutrition); err != nil {
+		return response.BadRequest(c, err.Error())
+	}
+	food := &model.CustomFood{
+		UserID:     userID,
+		Name:       name,
+		Brand:      strings.TrimSpace(req.Brand),
+		Barcode:    barcode,
+		Nutrition:  nutrition.Complete(),
+		NRVPercent: req.NRVPercent,
+	}
+	if err := h.customs.Save(food); err != nil {
+		c.Logger().Errorf("custom food save failed: %v", err)
+		return response.InternalError(c, "failed to save custom food")
+	}
+	return response.Success(c, food)
+}
+
+// DeleteCustom 删除自建食物
+// DELETE /api/v1/food/custom/:id
+func (h *FoodHandler) DeleteCustom(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return response.BadRequest(c, "invalid id")
+	}
+	deleted, err := h.customs.Delete(userID, id)
+	if err != nil {
+		c.Logger().Errorf("custom food delete failed: %v", err)
+		return response.InternalError(c, "failed to delete custom food")
+	}
+	if !deleted {
+		return response.Error(c, http.StatusNotFound, "custom food not found")
+	}
+	return response.Success(c, map[string]interface{}{"deleted": true})
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"eatclean/internal/model"
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+// FoodLabelHandler 包装营养成分表识别。
+type FoodLabelHandler struct {
+	vision   *service.VisionService
+	images   *service.ImageAssetService
+	precheck *service.ImagePrecheckService
+	customs  *service.CustomFoodService
+}
+
+func NewFoodLabelHandler(vision *service.VisionService, images *service.ImageAssetService, precheck *service.ImagePrecheckService, customs *service.CustomFoodService) *FoodLabelHandler {
+	return &FoodLabelHandler{vision: vision, images: images, precheck: precheck, customs: customs}
+}
+
+type foodLabelScanRequest struct {
+	ImageUrls     []string `json:"image_urls"`
+	ImageAssetIDs []int64  `json:"image_asset_ids"`
+	// Save 识别结果一致时保存为自建食物，名称缺省取包装上的商品名
+	Save    bool   `json:"save"`
+	Name    string `json:"name"`
+	Brand   string `json:"brand"`
+	Barcode string `json:"barcode"`
+}
+
+// Scan 识别营养成分表，可选保存为自建食物
+// POST /api/v1/food/label/scan
+func (h *FoodLabelHandler) Scan(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	var req foodLabelScanRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	if len(req.ImageUrls) == 0 && len(req.ImageAssetIDs) == 0 {
+		return response.BadRequest(c, "image_urls or image_asset_ids are required")
+	}
+	barcode := ""
+	if strings.TrimSpace(req.Barcode) != "" {
+		normalized, err := service.NormalizeBarcode(req.Barcode)
+		if err != nil {
+			return response.BadRequest(c, "条码无效")
+		}
+		barcode = normalized
+	}
+	if h.vision == nil || !h.vision.IsEnabled() {
+		return response.InternalError(c, "vision service is not configured")
+	}
+	if !h.vision.RouteAvailable(service.AIRouteLabelScan) {
+		return respondAIUnavailable(c)
+	}
+
+	images, err := h.images.Resolve(c.Request().Context(), userID, "ingredient", req.ImageAssetIDs, req.ImageUrls)
+	if err != nil {
+		return respondImageError(c, err)
+	}
+	warnings, err := h.precheck.CheckQuality(service.AIRouteLabelScan, images)
+	if err != nil {
+		return respondImageError(c, err)
+	}
+	relevance, err := h.precheck.CheckRelevance(c.Request().Context(), service.AIRouteLabelScan, images)
+	if err != nil {
+		return respondImageError(c, err)
+	}
+	warnings = append(warnings, relevance...)
+
+	label, rawText, err := h.vision.AnalyzeNutritionLabelFromURLs(c.Request().Context(), images.ModelURLs)
+	if err != nil {
+		return respondAIError(c, err, "营养成分表识别结果无效，请重拍", "nutrition label analyze failed")
+	}
+	result, err := service.ParseNutritionLabel(label)
+	if err != nil {
+		return response.Error(c, http.StatusUnprocessableEntity, "未识别到营养成分表中的能量，请重拍")
+	}
+
+	payload := map[string]interface{}{
+		"label":           result,
+		"raw":             strings.TrimSpace(rawText),
+		"image_asset_ids": images.AssetIDs,
+		"image_warnings":  warnings,
+		"saved":           false,
+	}
+	if !req.Save {
+		return response.Success(c, payload)
+	}
+	// 数值不一致时不自动保存，用户核对后可通过 POST /food/custom 保存
+	name := firstNonEmpty(strings.TrimSpace(req.Name), result.ProductName)
+	switch {
+	case !result.Check.Consistent:
+		payload["save_error"] = "能量与三大营养素不一致，请核对后手动保存"
+	case strings.TrimSpace(name) == "":
+		payload["save_error"] = "请填写食物名称"
+	default:
+		food := &model.CustomFood{
+			UserID:     userID,
+			Name:       name,
+			Brand:      strings.TrimSpace(req.Brand),
+			Barcode:    barcode,
+			Nutrition:  result.Nutrition,
+			NRVPercent: result.NRVPercent,
+		}
+		if err := h.customs.Save(food); err != nil {
+			c.Logger().Errorf("custom food save failed: %v", err)
+			payload["save_error"] = "保存失败，请稍后再试"
+		} else {
+			payload["saved"] = true
+			payload["custom_food"] = food
+		}
+	}
+	return response.Success(c, payload)
+}
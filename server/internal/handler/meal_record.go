@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"context"
 	"eatclean/internal/model"
 	"eatclean/internal/service"
 	"eatclean/pkg/response"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -17,24 +19,34 @@ import (
 )
 
 type MealRecordHandler struct {
-	service         *service.MealRecordService
-	visionService   *service.VisionService
-	ossService      *service.OssService
-	settingsService *service.SettingsService
-	dishService     *service.DishService
-	dailyService    *service.DailyIntakeService
-	subscriptions   *service.SubscriptionService
+	service       *service.MealRecordService
+	visionService *service.VisionService
+	storage       *service.StorageService
+	images        *service.ImageAssetService
+	visionCache   *service.VisionCacheService
+	precheck      *service.ImagePrecheckService
+	jobs          *service.AIJobService
+	dishService   *service.DishService
+	subscriptions *service.SubscriptionService
+	quota         *service.QuotaService
+	promptContext *service.PromptContextBuilder
+	quickPicks    *service.QuickPickService
 }
 
-func NewMealRecordHandler(service *service.MealRecordService, visionService *service.VisionService, ossService *service.OssService, settingsService *service.SettingsService, dishService *service.DishService, dailyService *service.DailyIntakeService, subscriptions *service.SubscriptionService) *MealRecordHandler {
+func NewMealRecordHandler(service *service.MealRecordService, visionService *service.VisionService, storage *service.StorageService, images *service.ImageAssetService, visionCache *service.VisionCacheService, precheck *service.ImagePrecheckService, jobs *service.AIJobService, dishService *service.DishService, subscriptions *service.SubscriptionService, quota *service.QuotaService, promptContext *service.PromptContextBuilder, quickPicks *service.QuickPickService) *MealRecordHandler {
 	return &MealRecordHandler{
-		service:         service,
-		visionService:   visionService,
-		ossService:      ossService,
-		settingsService: settingsService,
-		dishService:     dishService,
-		dailyService:    dailyService,
-		subscriptions:   subscriptions,
+		service:       service,
+		visionService: visionService,
+		storage:       storage,
+		images:        images,
+		visionCache:   visionCache,
+		precheck:      precheck,
+		jobs:          jobs,
+		dishService:   dishService,
+		subscriptions: subscriptions,
+		quota:         quota,
+		promptContext: promptContext,
+		quickPicks:    quickPicks,
 	}
 }
 
@@ -59,22 +71,44 @@ func (h *MealRecordHandler) Create(c echo.Context) error {
 	if len(items) == 0 {
 		items = json.RawMessage("[]")
 	}
-	imageUrls := mustMarshalJSON(req.ImageUrls)
+	imageUrls := req.ImageUrls
+	var imageAssetIDs []int64
+	if len(req.ImageAssetIDs) == 0 && len(imageUrls) > 0 {
+		// 直接保存的地址会作为旧对象的归属依据，必须先确认属于当前用户
+		if err := h.storage.CheckOwnershipAll(userID, imageUrls); err != nil {
+			return respondStorageSignError(c, err)
+		}
+	}
+	if len(req.ImageAssetIDs) > 0 {
+		assets, err := h.images.Assets(c.Request().Context(), userID, "food", req.ImageAssetIDs, nil)
+		if err != nil {
+			return respondImageError(c, err)
+		}
+		imageUrls = nil
+		for _, asset := range assets {
+			imageAssetIDs = append(imageAssetIDs, asset.ID)
+			imageUrls = append(imageUrls, asset.ModelKey)
+		}
+	}
 	recordedAt := time.Now()
 	if req.RecordedAt != nil {
 		recordedAt = *req.RecordedAt
 	}
 
 	record := &model.MealRecord{
-		UserID:     userID,
-		Source:     req.Source,
-		Items:      items,
-		ImageUrls:  imageUrls,
-		Ratings:    req.Ratings,
-		Meta:       req.Meta,
-		RecordedAt: recordedAt,
+		UserID:        userID,
+		Source:        req.Source,
+		Items:         items,
+		ImageUrls:     mustMarshalJSON(imageUrls),
+		ImageAssetIDs: imageAssetIDs,
+		Ratings:       req.Ratings,
+		Meta:          req.Meta,
+		RecordedAt:    recordedAt,
 	}
 	if err := h.service.Create(record); err != nil {
+		if errors.Is(err, service.ErrPortionUnit) || errors.Is(err, service.ErrPortionQuantity) {
+			return response.BadRequest(c, err.Error())
+		}
 		if isForeignKeyViolation(err) {
 			return response.Unauthorized(c, "user not found, please re-login")
 		}
@@ -93,172 +127,164 @@ func (h *MealRecordHandler) CreateFromPhoto(c echo.Context) error {
 		return response.Unauthorized(c, "invalid user context")
 	}
 	var req struct {
-		ImageUrls  []string `json:"image_urls"`
-		ClientTime string   `json:"client_time"`
-		Note       string   `json:"note"`
+		ImageUrls     []string `json:"image_urls"`
+		ImageAssetIDs []int64  `json:"image_asset_ids"`
+		ClientTime    string   `json:"client_time"`
+		Note          string   `json:"note"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return response.BadRequest(c, "invalid request body")
 	}
-	if len(req.ImageUrls) == 0 {
-		return response.BadRequest(c, "image_urls are required")
+	if len(req.ImageUrls) == 0 && len(req.ImageAssetIDs) == 0 {
+		return response.BadRequest(c, "image_urls or image_asset_ids are required")
 	}
 	clientTime := parseClientTime(req.ClientTime)
 	note := strings.TrimSpace(req.Note)
 	if note == "" {
 		note = "无"
 	}
-	if err := h.enforceMealPhotoQuota(c, userID, clientTime); err != nil {
-		return err
+	if h.visionService == nil || !h.visionService.IsEnabled() {
+		return response.InternalError(c, "vision service is not configured")
 	}
 
-	recognizedText := ""
-	var dishNames []string
-	var aiSummary string
-	signedUrls := req.ImageUrls
-	if h.ossService != nil {
-		if signed, err := h.ossService.SignURLs(req.ImageUrls, 15*time.Minute); err == nil {
-			signedUrls = signed
-		} else {
-			c.Logger().Errorf("oss signing failed: %v", err)
-			return response.InternalError(c, "oss signing failed")
-		}
+	images, err := h.images.Resolve(c.Request().Context(), userID, "food", req.ImageAssetIDs, req.ImageUrls)
+	if err != nil {
+		return respondImageError(c, err)
+	}
+	warnings, err := h.precheck.CheckQuality(service.AIRouteFoodScan, images)
+	if err != nil {
+		return respondImageError(c, err)
+	}
+	systemPrompt := ""
+	promptVersion := ""
+	pctx := h.promptContext.Build(userID, service.PromptContextOptions{ClientTime: clientTime})
+	if rendered, err := h.promptContext.Render(service.PromptFoodScan, pctx, service.FoodPhotoPromptExtras(note, clientTime)); err == nil {
+		systemPrompt = rendered.Text
+		promptVersion = rendered.Version
+	} else {
+		c.Logger().Errorf("food scan prompt render failed: %v", err)
 	}
-	if h.visionService != nil && h.visionService.IsEnabled() {
-		recentMealSummary := ""
-		if h.service != nil {
-			if records, err := h.service.ListByUser(userID, 20); err == nil {
-				recentMealSummary = service.SummarizeMealRecords(records, 3)
-			}
-		}
-		if strings.TrimSpace(recentMealSummary) == "" {
-			recentMealSummary = "暂无"
-		}
-		systemPrompt := ""
-		if h.settingsService != nil {
-			if raw, err := h.settingsService.Get(userID); err == nil && len(raw) > 0 {
-				var settings map[string]interface{}
-				if err := json.Unmarshal(raw, &settings); err == nil {
-					if template, err := service.LoadFoodScanPromptTemplate(); err == nil {
-						isTraining, isCheat := computeDayFlagsForDate(settings, clientTime)
-						dayType := dayTypeLabel(isTraining, isCheat)
-						timeOfDay := timeOfDayLabel(clientTime)
-						intake := buildIntakeContext(h.dailyService, userID, clientTime, settings)
-						systemPrompt = service.BuildSystemPrompt(template, settings, map[string]string{
-							"food_photo_taken":    "true",
-							"menu_scanned":        "false",
-							"recent_chat_summary": "暂无",
-							"recent_meal_summary": recentMealSummary,
-							"current_time":        formatPromptTime(clientTime),
-							"time_of_day":         timeOfDay,
-							"day_type":            dayType,
-							"is_training_day":     formatYesNo(isTraining),
-							"is_cheat_day":        formatYesNo(isCheat),
-							"calories_consumed":   intake.CaloriesConsumed,
-							"macro_consumed":      intake.MacroConsumed,
-							"calorie_remaining":   intake.CalorieRemaining,
-							"food_photo_note":     note,
-						})
-					}
-				}
-			}
-		}
 
-		text, err := h.visionService.AnalyzeFoodFromURLs(c.Request().Context(), signedUrls, systemPrompt)
-		if err != nil {
-			c.Logger().Errorf("food recognition failed: %v", err)
-			return response.InternalError(c, "food recognition failed")
+	cacheKey := service.NewVisionCacheKey(service.AIRouteFoodScan, images, promptVersion, pctx.Fingerprint(note))
+	analysis, rawText, cacheHit := h.visionCache.Lookup(userID, cacheKey)
+	quotaExempt := cacheHit && !h.visionCache.ChargeOnHit()
+	if !quotaExempt {
+		if err := h.enforceMealPhotoQuota(c, userID, clientTime); err != nil || c.Response().Committed {
+			return err
 		}
-		recognizedText = strings.TrimSpace(text)
-		if dishes, summary, _ := parseAIDishes(recognizedText); len(dishes) > 0 {
-			if h.dishService != nil {
-				dishes = h.dishService.HydrateDishMapsPreferFresh(dishes)
-			}
-			aiSummary = summary
-			meta, _ := json.Marshal(map[string]interface{}{
-				"image_count":     len(req.ImageUrls),
-				"image_urls":      req.ImageUrls,
-				"source":          "food_photo",
-				"recognized_text": recognizedText,
-				"ai_summary":      aiSummary,
-				"note":            note,
-			})
-			record := &model.MealRecord{
-				UserID:     userID,
-				Source:     "food",
-				Items:      mustMarshalJSON(dishes),
-				ImageUrls:  mustMarshalJSON(req.ImageUrls),
-				Meta:       meta,
-				RecordedAt: time.Now(),
-			}
-			if err := h.service.Create(record); err != nil {
-				if isForeignKeyViolation(err) {
-					return response.Unauthorized(c, "user not found, please re-login")
-				}
-				c.Logger().Errorf("meal photo record create failed: %v", err)
-				return response.InternalError(c, "failed to create meal record")
-			}
-			return response.Success(c, record)
+	}
+	job := visionJobPayload{
+		ImageAssetIDs: images.AssetIDs,
+		SystemPrompt:  systemPrompt,
+		PromptVersion: promptVersion,
+		CacheKey:      cacheKey,
+		Note:          note,
+		Warnings:      warnings,
+	}
+	// 异步模式：识别放入任务队列，完成后的记录通过 GET /jobs/:id 返回
+	if !cacheHit && wantsAsync(c) && h.jobs.Supports(service.AIJobMealPhoto) {
+		// 预留的额度随任务转交，任务结束时确认或释放
+		hold := quotaHoldFrom(c)
+		queued, err := h.jobs.Enqueue(userID, service.AIJobMealPhoto, job, hold.IDs()...)
+		if err != nil {
+			c.Logger().Errorf("meal photo enqueue failed: %v", err)
+			return response.InternalError(c, "failed to enqueue meal photo")
 		}
+		hold.Detach()
+		return respondJobAccepted(c, queued)
+	}
+	record, err := h.runMealPhoto(c.Request().Context(), userID, images, job, analysis, rawText, cacheHit, quotaExempt)
+	if err != nil {
+		return respondVisionRunError(c, err, "食物识别结果无效，请重试", "food recognition failed", "failed to create meal record")
+	}
+	return response.Success(c, record)
+}
 
-		for _, line := range strings.Split(recognizedText, "\n") {
-			name := strings.TrimSpace(strings.TrimPrefix(line, "-"))
-			if name == "" {
-				continue
-			}
-			dishNames = append(dishNames, name)
-		}
-	} else {
-		return response.InternalError(c, "vision service is not configured")
+// RunPhotoJob 执行排队的食物照片识别任务，重试时优先复用已写入的识别缓存
+func (h *MealRecordHandler) RunPhotoJob(ctx context.Context, job *model.AIJob) (interface{}, error) {
+	if h.visionService == nil || !h.visionService.IsEnabled() {
+		return nil, service.NewAIJobError("vision_unavailable", "识别服务未配置")
 	}
+	var payload visionJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, service.NewAIJobError("invalid_payload", "任务参数无效")
+	}
+	images, err := h.images.Resolve(ctx, job.UserID, "food", payload.ImageAssetIDs, nil)
+	if err != nil {
+		return nil, asJobError(err)
+	}
+	analysis, rawText, cacheHit := h.visionCache.Lookup(job.UserID, payload.CacheKey)
+	record, err := h.runMealPhoto(ctx, job.UserID, images, payload, analysis, rawText, cacheHit, false)
+	if err != nil {
+		return nil, asJobError(err)
+	}
+	return record, nil
+}
 
-	dishes := make([]map[string]interface{}, 0, len(dishNames))
-	for idx, name := range dishNames {
-		dishes = append(dishes, map[string]interface{}{
-			"id":          fmt.Sprintf("photo_%d_%d", time.Now().Unix(), idx),
-			"name":        name,
-			"restaurant":  "照片识别",
-			"score":       72,
-			"scoreLabel":  "待确认",
-			"scoreColor":  "ff13ec5b",
-			"kcal":        0,
-			"protein":     0,
-			"carbs":       0,
-			"fat":         0,
-			"tag":         "识别结果",
-			"recommended": true,
-		})
+// runMealPhoto 相关性预检、调用识别（未命中缓存时）并写入就餐记录，同步请求与任务共用
+func (h *MealRecordHandler) runMealPhoto(
+	ctx context.Context,
+	userID int64,
+	images *service.ResolvedImages,
+	job visionJobPayload,
+	analysis *service.AIDishAnalysis,
+	rawText string,
+	cacheHit bool,
+	quotaExempt bool,
+) (*model.MealRecord, error) {
+	warnings := job.Warnings
+	if !cacheHit {
+		relevance, err := h.precheck.CheckRelevance(ctx, service.AIRouteFoodScan, images)
+		if err != nil {
+			return nil, err
+		}
+		warnings = append(warnings, relevance...)
+		analysis, rawText, err = h.visionService.AnalyzeFoodFromURLs(ctx, images.ModelURLs, job.SystemPrompt)
+		if err != nil {
+			return nil, err
+		}
+		h.visionCache.Store(userID, job.CacheKey, analysis, rawText)
 	}
+	recognizedText := strings.TrimSpace(rawText)
+	dishes := aiDishesToMaps(analysis.Dishes)
+	aiSummary := analysis.SummaryText()
 
 	if h.dishService != nil {
 		dishes = h.dishService.HydrateDishMapsPreferFresh(dishes)
 	}
+	dishes = service.AnnotatePortions(dishes)
+	riskAlerts := service.MergeRiskAlerts(flagExcludedDishes(dishes, h.promptContext.DietRestrictions(userID)), analysis.RiskAlerts)
 
 	meta, _ := json.Marshal(map[string]interface{}{
-		"image_count":     len(req.ImageUrls),
-		"image_urls":      req.ImageUrls,
+		"image_count":     len(images.Keys),
+		"image_urls":      images.Keys,
 		"source":          "food_photo",
 		"recognized_text": recognizedText,
 		"ai_summary":      aiSummary,
-		"note":            note,
+		"risk_alerts":     riskAlerts,
+		"note":            job.Note,
+		"prompt_version":  job.PromptVersion,
+		"cache_hit":       cacheHit,
+		"image_warnings":  warnings,
 	})
 
 	record := &model.MealRecord{
-		UserID:     userID,
-		Source:     "food",
-		Items:      mustMarshalJSON(dishes),
-		ImageUrls:  mustMarshalJSON(req.ImageUrls),
-		Meta:       meta,
-		RecordedAt: time.Now(),
+		UserID:        userID,
+		Source:        "food",
+		Items:         mustMarshalJSON(dishes),
+		ImageUrls:     mustMarshalJSON(images.Keys),
+		ImageAssetIDs: images.AssetIDs,
+		Meta:          meta,
+		QuotaExempt:   quotaExempt,
+		RecordedAt:    time.Now(),
 	}
 	if err := h.service.Create(record); err != nil {
 		if isForeignKeyViolation(err) {
-			return response.Unauthorized(c, "user not found, please re-login")
+			return nil, errUserNotFound
 		}
-		c.Logger().Errorf("meal photo record create failed: %v", err)
-		return response.InternalError(c, "failed to create meal record")
+		return nil, fmt.Errorf("%w: %v", errResultSave, err)
 	}
-	return response.Success(c, record)
+	return record, nil
 }
 
 // AnalyzeFromPhoto 仅分析食物照片，不直接入库
@@ -269,15 +295,16 @@ func (h *MealRecordHandler) AnalyzeFromPhoto(c echo.Context) error {
 		return response.Unauthorized(c, "invalid user context")
 	}
 	var req struct {
-		ImageUrls  []string `json:"image_urls"`
-		ClientTime string   `json:"client_time"`
-		Note       string   `json:"note"`
+		ImageUrls     []string `json:"image_urls"`
+		ImageAssetIDs []int64  `json:"image_asset_ids"`
+		ClientTime    string   `json:"client_time"`
+		Note          string   `json:"note"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return response.BadRequest(c, "invalid request body")
 	}
-	if len(req.ImageUrls) == 0 {
-		return response.BadRequest(c, "image_urls are required")
+	if len(req.ImageUrls) == 0 && len(req.ImageAssetIDs) == 0 {
+		return response.BadRequest(c, "image_urls or image_asset_ids are required")
 	}
 	clientTime := parseClientTime(req.ClientTime)
 	note := strings.TrimSpace(req.Note)
@@ -285,81 +312,67 @@ func (h *MealRecordHandler) AnalyzeFromPhoto(c echo.Context) error {
 		note = "无"
 	}
 
-	signedUrls := req.ImageUrls
-	if h.ossService != nil {
-		if signed, err := h.ossService.SignURLs(req.ImageUrls, 15*time.Minute); err == nil {
-			signedUrls = signed
-		} else {
-			c.Logger().Errorf("oss signing failed: %v", err)
-			return response.InternalError(c, "oss signing failed")
-		}
-	}
 	if h.visionService == nil || !h.visionService.IsEnabled() {
 		return response.InternalError(c, "vision service is not configured")
 	}
+	images, err := h.images.Resolve(c.Request().Context(), userID, "food", req.ImageAssetIDs, req.ImageUrls)
+	if err != nil {
+		return respondImageError(c, err)
+	}
+	warnings, err := h.precheck.CheckQuality(service.AIRouteFoodScan, images)
+	if err != nil {
+		return respondImageError(c, err)
+	}
 
 	systemPrompt := ""
-	if h.settingsService != nil {
-		if raw, err := h.settingsService.Get(userID); err == nil && len(raw) > 0 {
-			var settings map[string]interface{}
-			if err := json.Unmarshal(raw, &settings); err == nil {
-				recentMealSummary := "暂无"
-				if h.service != nil {
-					if records, err := h.service.ListByUser(userID, 20); err == nil {
-						if summary := service.SummarizeMealRecords(records, 5); strings.TrimSpace(summary) != "" {
-							recentMealSummary = summary
-						}
-					}
-				}
-				if template, err := service.LoadFoodScanPromptTemplate(); err == nil {
-					isTraining, isCheat := computeDayFlagsForDate(settings, clientTime)
-					dayType := dayTypeLabel(isTraining, isCheat)
-					timeOfDay := timeOfDayLabel(clientTime)
-					intake := buildIntakeContext(h.dailyService, userID, clientTime, settings)
-					systemPrompt = service.BuildSystemPrompt(template, settings, map[string]string{
-						"food_photo_taken":    "true",
-						"menu_scanned":        "false",
-						"recent_chat_summary": "暂无",
-						"recent_meal_summary": recentMealSummary,
-						"current_time":        formatPromptTime(clientTime),
-						"time_of_day":         timeOfDay,
-						"day_type":            dayType,
-						"is_training_day":     formatYesNo(isTraining),
-						"is_cheat_day":        formatYesNo(isCheat),
-						"calories_consumed":   intake.CaloriesConsumed,
-						"macro_consumed":      intake.MacroConsumed,
-						"calorie_remaining":   intake.CalorieRemaining,
-						"food_photo_note":     note,
-					})
-				}
-			}
-		}
+	promptVersion := ""
+	pctx := h.promptContext.Build(userID, service.PromptContextOptions{ClientTime: clientTime})
+	if rendered, err := h.promptContext.Render(service.PromptFoodScan, pctx, service.FoodPhotoPromptExtras(note, clientTime)); err == nil {
+		systemPrompt = rendered.Text
+		promptVersion = rendered.Version
+	} else {
+		c.Logger().Errorf("food analyze prompt render failed: %v", err)
 	}
 
-	rawText, err := h.visionService.AnalyzeFoodFromURLs(c.Request().Context(), signedUrls, systemPrompt)
-	if err != nil {
-		c.Logger().Errorf("food analyze failed: %v", err)
-		return response.InternalError(c, "food analyze failed")
+	cacheKey := service.NewVisionCacheKey(service.AIRouteFoodScan, images, promptVersion, pctx.Fingerprint(note))
+	analysis, rawText, cacheHit := h.visionCache.Lookup(userID, cacheKey)
+	if !cacheHit {
+		relevance, err := h.precheck.CheckRelevance(c.Request().Context(), service.AIRouteFoodScan, images)
+		if err != nil {
+			return respondImageError(c, err)
+		}
+		warnings = append(warnings, relevance...)
+		analysis, rawText, err = h.visionService.AnalyzeFoodFromURLs(c.Request().Context(), images.ModelURLs, systemPrompt)
+		if err != nil {
+			return respondAIError(c, err, "食物识别结果无效，请重试", "food analyze failed")
+		}
+		h.visionCache.Store(userID, cacheKey, analysis, rawText)
 	}
-	dishes, summary, actions := parseAIDishes(rawText)
+	dishes := aiDishesToMaps(analysis.Dishes)
 	if h.dishService != nil {
 		dishes = h.dishService.HydrateDishMapsPreferFresh(dishes)
 	}
-	ingredientList, riskAlerts, nutritionHighlights, recommendation := parseAIScanDetails(rawText)
+	dishes = service.AnnotatePortions(dishes)
+	riskAlerts := flagExcludedDishes(dishes, service.DietRestrictionsFromSettings(pctx.Settings))
+	actions := []string(analysis.Actions)
 	if len(actions) == 0 {
 		actions = []string{"action=record_meal", "action=discover"}
 	}
 
 	return response.Success(c, map[string]interface{}{
 		"mode":                 "food",
-		"summary":              summary,
+		"summary":              analysis.SummaryText(),
 		"actions":              actions,
 		"items":                dishes,
-		"ingredient_list":      ingredientList,
-		"risk_alerts":          riskAlerts,
-		"nutrition_highlights": nutritionHighlights,
-		"recommendation":       recommendation,
+		"ingredient_list":      []string(analysis.IngredientList),
+		"risk_alerts":          service.MergeRiskAlerts(riskAlerts, analysis.RiskAlerts),
+		"nutrition_highlights": aiHighlightsToMaps(analysis.NutritionHighlights),
+		"recommendation":       analysis.Recommendation,
 		"raw":                  strings.TrimSpace(rawText),
+		"prompt_version":       promptVersion,
+		"image_asset_ids":      images.AssetIDs,
+		"cache_hit":            cacheHit,
+		"image_warnings":       warnings,
 	})
 }
 
@@ -371,15 +384,16 @@ func (h *MealRecordHandler) ScanIngredients(c echo.Context) error {
 		return response.Unauthorized(c, "invalid user context")
 	}
 	var req struct {
-		ImageUrls  []string `json:"image_urls"`
-		ClientTime string   `json:"client_time"`
-		Note       string   `json:"note"`
+		ImageUrls     []string `json:"image_urls"`
+		ImageAssetIDs []int64  `json:"image_asset_ids"`
+		ClientTime    string   `json:"client_time"`
+		Note          string   `json:"note"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return response.BadRequest(c, "invalid request body")
 	}
-	if len(req.ImageUrls) == 0 {
-		return response.BadRequest(c, "image_urls are required")
+	if len(req.ImageUrls) == 0 && len(req.ImageAssetIDs) == 0 {
+		return response.BadRequest(c, "image_urls or image_asset_ids are required")
 	}
 	clientTime := parseClientTime(req.ClientTime)
 	note := strings.TrimSpace(req.Note)
@@ -390,78 +404,69 @@ func (h *MealRecordHandler) ScanIngredients(c echo.Context) error {
 		return response.InternalError(c, "vision service is not configured")
 	}
 
-	signedUrls := req.ImageUrls
-	if h.ossService != nil {
-		if signed, err := h.ossService.SignURLs(req.ImageUrls, 15*time.Minute); err == nil {
-			signedUrls = signed
-		} else {
-			c.Logger().Errorf("oss signing failed: %v", err)
-			return response.InternalError(c, "oss signing failed")
-		}
+	images, err := h.images.Resolve(c.Request().Context(), userID, "ingredient", req.ImageAssetIDs, req.ImageUrls)
+	if err != nil {
+		return respondImageError(c, err)
+	}
+	warnings, err := h.precheck.CheckQuality(service.AIRouteIngredientScan, images)
+	if err != nil {
+		return respondImageError(c, err)
 	}
 
 	systemPrompt := ""
-	if h.settingsService != nil {
-		if raw, err := h.settingsService.Get(userID); err == nil && len(raw) > 0 {
-			var settings map[string]interface{}
-			if err := json.Unmarshal(raw, &settings); err == nil {
-				recentMealSummary := "暂无"
-				if h.service != nil {
-					if records, err := h.service.ListByUser(userID, 20); err == nil {
-						if summary := service.SummarizeMealRecords(records, 5); strings.TrimSpace(summary) != "" {
-							recentMealSummary = summary
-						}
-					}
-				}
-				if template, err := service.LoadIngredientScanPromptTemplate(); err == nil {
-					isTraining, isCheat := computeDayFlagsForDate(settings, clientTime)
-					dayType := dayTypeLabel(isTraining, isCheat)
-					timeOfDay := timeOfDayLabel(clientTime)
-					intake := buildIntakeContext(h.dailyService, userID, clientTime, settings)
-					systemPrompt = service.BuildSystemPrompt(template, settings, map[string]string{
-						"food_photo_taken":      "false",
-						"menu_scanned":          "false",
-						"recent_chat_summary":   "暂无",
-						"recent_meal_summary":   recentMealSummary,
-						"current_time":          formatPromptTime(clientTime),
-						"time_of_day":           timeOfDay,
-						"day_type":              dayType,
-						"is_training_day":       formatYesNo(isTraining),
-						"is_cheat_day":          formatYesNo(isCheat),
-						"calories_consumed":     intake.CaloriesConsumed,
-						"macro_consumed":        intake.MacroConsumed,
-						"calorie_remaining":     intake.CalorieRemaining,
-						"ingredient_photo_note": note,
-					})
-				}
-			}
-		}
+	promptVersion := ""
+	pctx := h.promptContext.Build(userID, service.PromptContextOptions{ClientTime: clientTime})
+	if rendered, err := h.promptContext.Render(service.PromptIngredientScan, pctx, service.IngredientPromptExtras(note)); err == nil {
+		systemPrompt = rendered.Text
+		promptVersion = rendered.Version
+	} else {
+		c.Logger().Errorf("ingredient scan prompt render failed: %v", err)
 	}
 
-	rawText, err := h.visionService.AnalyzeIngredientFromURLs(c.Request().Context(), signedUrls, systemPrompt)
-	if err != nil {
-		c.Logger().Errorf("ingredient analyze failed: %v", err)
-		return response.InternalError(c, "ingredient analyze failed")
+	cacheKey := service.NewVisionCacheKey(service.AIRouteIngredientScan, images, promptVersion, pctx.Fingerprint(note))
+	analysis, rawText, cacheHit := h.visionCache.Lookup(userID, cacheKey)
+	if !cacheHit {
+		relevance, err := h.precheck.CheckRelevance(c.Request().Context(), service.AIRouteIngredientScan, images)
+		if err != nil {
+			return respondImageError(c, err)
+		}
+		warnings = append(warnings, relevance...)
+		analysis, rawText, err = h.visionService.AnalyzeIngredientFromURLs(c.Request().Context(), images.ModelURLs, systemPrompt)
+		if err != nil {
+			return respondAIError(c, err, "配料表识别结果无效，请重试", "ingredient analyze failed")
+		}
+		h.visionCache.Store(userID, cacheKey, analysis, rawText)
 	}
-	dishes, summary, actions := parseAIDishes(rawText)
+	dishes := aiDishesToMaps(analysis.Dishes)
 	if h.dishService != nil {
 		dishes = h.dishService.HydrateDishMapsPreferFresh(dishes)
 	}
-	ingredientList, riskAlerts, nutritionHighlights, recommendation := parseAIScanDetails(rawText)
+	// 配料表整体命中忌口时单独提示，菜品卡片仍按名称与成分逐个标记
+	restrictions := service.DietRestrictionsFromSettings(pctx.Settings)
+	riskAlerts := flagExcludedDishes(dishes, restrictions)
+	if hits := restrictions.Check("", analysis.IngredientList); len(hits) > 0 {
+		riskAlerts = append(riskAlerts, service.DietRiskAlert("配料表", hits))
+	}
+	// 配料表卡片的营养按每 100g 估算，不是一份的量，不对照每日营养素限量
+	actions := []string(analysis.Actions)
 	if len(actions) == 0 {
 		actions = []string{"action=record_meal", "action=setting"}
 	}
 
 	return response.Success(c, map[string]interface{}{
 		"mode":                 "ingredient",
-		"summary":              summary,
+		"summary":              analysis.SummaryText(),
 		"actions":              actions,
 		"items":                dishes,
-		"ingredient_list":      ingredientList,
-		"risk_alerts":          riskAlerts,
-		"nutrition_highlights": nutritionHighlights,
-		"recommendation":       recommendation,
+		"ingredient_list":      []string(analysis.IngredientList),
+		"risk_alerts":          service.MergeRiskAlerts(riskAlerts, analysis.RiskAlerts),
+		"nutrition_highlights": aiHighlightsToMaps(analysis.NutritionHighlights),
+		"recommendation":       analysis.Recommendation,
 		"raw":                  strings.TrimSpace(rawText),
+		"prompt_version":       promptVersion,
+		"image_asset_ids":      images.AssetIDs,
+		"cache_hit":            cacheHit,
+		"image_warnings":       warnings,
 	})
 }
 
@@ -480,14 +485,16 @@ func (h *MealRecordHandler) enforceMealPhotoQuota(
 	}
 	start := startOfDay(clientTime)
 	end := start.Add(24 * time.Hour)
-	count, err := h.service.CountByUserSourceBetween(userID, "food", start, end)
+	err := reserveFreeUse(c, h.quota, userID, service.QuotaKindMealPhoto, func() (int, error) {
+		return h.service.CountByUserSourceBetween(userID, "food", start, end)
+	})
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		return response.Error(c, http.StatusTooManyRequests, "今日餐食记录次数已用完，开通订阅可无限使用")
+	}
 	if err != nil {
 		c.Logger().Errorf("meal photo quota check failed: %v", err)
 		return response.InternalError(c, "usage check failed")
 	}
-	if count >= 1 {
-		return response.Error(c, http.StatusTooManyRequests, "今日餐食记录次数已用完，开通订阅可无限使用")
-	}
 	return nil
 }
 
@@ -517,85 +524,130 @@ func (h *MealRecordHandler) List(c echo.Context) error {
 	return response.Success(c, records)
 }
 
-func parseAIDishes(raw string) ([]map[string]interface{}, string, []string) {
-	payload := extractJSONPayload(raw)
-	if payload == "" {
-		return nil, "", nil
+// UpdateItemPortion 修改记录中单个条目的份量，服务端按比例重算营养
+// PATCH /api/v1/meals/:id/items/:index
+func (h *MealRecordHandler) UpdateItemPortion(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return response.BadRequest(c, "invalid meal record id")
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 {
+		return response.BadRequest(c, "invalid item index")
+	}
+	var req struct {
+		Quantity float64 `json:"quantity"`
+		Unit     string  `json:"unit"`
+		Grams    float64 `json:"grams"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	if req.Unit == "" && req.Grams > 0 {
+		req.Quantity, req.Unit = req.Grams, model.UnitGram
+	}
+	if req.Unit == "" {
+		return response.BadRequest(c, "unit or grams is required")
 	}
 
-	trimmed := strings.TrimSpace(payload)
-	if strings.HasPrefix(trimmed, "[") {
-		var dishes []map[string]interface{}
-		dec := json.NewDecoder(strings.NewReader(trimmed))
-		dec.UseNumber()
-		if err := dec.Decode(&dishes); err != nil {
-			return nil, "", nil
-		}
-		return normalizeAIDishes(dishes), "", nil
+	record, err := h.service.UpdateItemPortion(userID, id, index, req.Quantity, req.Unit)
+	switch {
+	case errors.Is(err, service.ErrMealRecordNotFound), errors.Is(err, service.ErrMealItemNotFound):
+		return response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrPortionUnit), errors.Is(err, service.ErrPortionQuantity), errors.Is(err, service.ErrPortionBase):
+		return response.BadRequest(c, err.Error())
+	case err != nil:
+		c.Logger().Errorf("meal item portion update failed: %v", err)
+		return response.InternalError(c, "failed to update meal item")
 	}
+	return response.Success(c, record)
+}
 
-	var decoded struct {
-		Summary string                   `json:"summary"`
-		Advice  string                   `json:"advice"`
-		Dishes  []map[string]interface{} `json:"dishes"`
-		Actions []interface{}            `json:"actions"`
+// QuickPicks 按时段与训练日/放纵日给出常吃、收藏条目与可复制的历史餐
+// GET /api/v1/meals/quick-picks?client_time=&limit=
+func (h *MealRecordHandler) QuickPicks(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok {
+		return response.Unauthorized(c, "invalid user context")
 	}
-	dec := json.NewDecoder(strings.NewReader(trimmed))
-	dec.UseNumber()
-	if err := dec.Decode(&decoded); err != nil {
-		return nil, "", nil
+	limit := 10
+	if raw := strings.TrimSpace(c.QueryParam("limit")); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+			limit = clampInt(parsed, 1, 30)
+		}
 	}
-	summary := strings.TrimSpace(decoded.Summary)
-	if summary == "" {
-		summary = strings.TrimSpace(decoded.Advice)
+	picks, err := h.quickPicks.Suggest(userID, parseClientTime(c.QueryParam("client_time")), limit)
+	if err != nil {
+		c.Logger().Errorf("meal quick picks failed: %v", err)
+		return response.InternalError(c, "failed to load quick picks")
 	}
-	actions := toStringSlice(decoded.Actions)
-	return normalizeAIDishes(decoded.Dishes), summary, actions
+	return response.Success(c, picks)
 }
 
-func parseAIScanDetails(raw string) ([]string, []string, []map[string]interface{}, string) {
-	payload := extractJSONPayload(raw)
-	if payload == "" {
-		return nil, nil, nil, ""
+// Copy 复制一条历史记录（record_id）或某一天的全部记录（date，yyyy-mm-dd）
+// POST /api/v1/meals/copy
+func (h *MealRecordHandler) Copy(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok {
+		return response.Unauthorized(c, "invalid user context")
 	}
-
-	trimmed := strings.TrimSpace(payload)
-	if !strings.HasPrefix(trimmed, "{") {
-		return nil, nil, nil, ""
+	var req struct {
+		RecordID   int64      `json:"record_id"`
+		Date       string     `json:"date"`
+		TargetDate string     `json:"target_date"`
+		RecordedAt *time.Time `json:"recorded_at"`
+		ClientTime string     `json:"client_time"`
 	}
-
-	var decoded map[string]interface{}
-	dec := json.NewDecoder(strings.NewReader(trimmed))
-	dec.UseNumber()
-	if err := dec.Decode(&decoded); err != nil {
-		return nil, nil, nil, ""
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
 	}
+	clientTime := parseClientTime(req.ClientTime)
 
-	ingredientList := toStringSlice(decoded["ingredient_list"])
-	riskAlerts := toStringSlice(decoded["risk_alerts"])
-	recommendation := readString(decoded["recommendation"])
-
-	highlights := make([]map[string]interface{}, 0)
-	if rawHighlights, ok := decoded["nutrition_highlights"].([]interface{}); ok {
-		for _, item := range rawHighlights {
-			m, ok := item.(map[string]interface{})
-			if !ok {
-				continue
-			}
-			name := strings.TrimSpace(readString(m["name"]))
-			value := strings.TrimSpace(readString(m["value"]))
-			unit := strings.TrimSpace(readString(m["unit"]))
-			if name == "" {
-				continue
+	var records []model.MealRecord
+	var err error
+	switch {
+	case req.RecordID > 0:
+		recordedAt := clientTime
+		if req.RecordedAt != nil {
+			recordedAt = *req.RecordedAt
+		}
+		var record *model.MealRecord
+		if record, err = h.service.Copy(userID, req.RecordID, recordedAt); err == nil {
+			records = []model.MealRecord{*record}
+		}
+	case strings.TrimSpace(req.Date) != "":
+		sourceDay, parseErr := time.ParseInLocation("2006-01-02", strings.TrimSpace(req.Date), clientTime.Location())
+		if parseErr != nil {
+			return response.BadRequest(c, "invalid date")
+		}
+		targetDay := startOfDay(clientTime)
+		if strings.TrimSpace(req.TargetDate) != "" {
+			if targetDay, parseErr = time.ParseInLocation("2006-01-02", strings.TrimSpace(req.TargetDate), clientTime.Location()); parseErr != nil {
+				return response.BadRequest(c, "invalid target_date")
 			}
-			highlights = append(highlights, map[string]interface{}{
-				"name":  name,
-				"value": value,
-				"unit":  unit,
-			})
 		}
+		if targetDay.Equal(sourceDay) {
+			return response.BadRequest(c, "target_date must differ from date")
+		}
+		records, err = h.service.CopyDay(userID, sourceDay, targetDay)
+	default:
+		return response.BadRequest(c, "record_id or date is required")
 	}
-	return ingredientList, riskAlerts, highlights, recommendation
+	if err != nil {
+		if errors.Is(err, service.ErrMealRecordNotFound) {
+			return response.Error(c, http.StatusNotFound, "meal record not found")
+		}
+		if isForeignKeyViolation(err) {
+			return response.Unauthorized(c, "user not found, please re-login")
+		}
+		c.Logger().Errorf("meal copy failed: %v", err)
+		return response.InternalError(c, "failed to copy meal records")
+	}
+	return response.Success(c, records)
 }
 
 func normalizeAIDishes(raw []map[string]interface{}) []map[string]interface{} {
@@ -619,7 +671,7 @@ func normalizeAIDishes(raw []map[string]interface{}) []map[string]interface{} {
 		if scoreColor == "" {
 			scoreColor = scoreColorFor(score, recommended)
 		}
-		normalized = append(normalized, map[string]interface{}{
+		item := map[string]interface{}{
 			"id":          readStringOr(dish["id"], fmt.Sprintf("photo_%d_%d", now, idx)),
 			"name":        name,
 			"restaurant":  readStringOr(dish["restaurant"], "照片识别"),
@@ -634,32 +686,23 @@ func normalizeAIDishes(raw []map[string]interface{}) []map[string]interface{} {
 			"recommended": recommended,
 			"components":  readStringList(dish["components"], dish["ingredients"]),
 			"reason":      readString(dish["reason"]),
-		})
+		}
+		if weight := readInt(dish["weight_g"], 0); weight > 0 {
+			item["weight_g"] = clampInt(weight, 0, 3000)
+		}
+		if portion := readString(dish["portion"]); portion != "" {
+			item["portion"] = portion
+		}
+		for _, limit := range service.DefaultNutrientLimits {
+			if value := model.NutrientValue(dish, limit.Key); value > 0 {
+				item[limit.Key] = math.Round(value*10) / 10
+			}
+		}
+		normalized = append(normalized, item)
 	}
 	return normalized
 }
 
-func extractJSONPayload(raw string) string {
-	raw = strings.TrimSpace(raw)
-	if raw == "" {
-		return ""
-	}
-	if strings.HasPrefix(raw, "{") || strings.HasPrefix(raw, "[") {
-		return raw
-	}
-	start := strings.Index(raw, "{")
-	end := strings.LastIndex(raw, "}")
-	if start >= 0 && end > start {
-		return raw[start : end+1]
-	}
-	start = strings.Index(raw, "[")
-	end = strings.LastIndex(raw, "]")
-	if start >= 0 && end > start {
-		return raw[start : end+1]
-	}
-	return raw
-}
-
 func readString(value interface{}) string {
 	switch v := value.(type) {
 	case string:
@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"context"
 	"eatclean/internal/model"
 	"eatclean/internal/service"
 	"eatclean/pkg/response"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -17,35 +19,41 @@ type MenuHandler struct {
 	menuService     *service.MenuService
 	menuScanService *service.MenuScanService
 	visionService   *service.VisionService
-	ossService      *service.OssService
-	settingsService *service.SettingsService
+	images          *service.ImageAssetService
+	visionCache     *service.VisionCacheService
+	precheck        *service.ImagePrecheckService
+	jobs            *service.AIJobService
 	dishService     *service.DishService
-	mealService     *service.MealRecordService
-	dailyService    *service.DailyIntakeService
 	subscriptions   *service.SubscriptionService
+	quota           *service.QuotaService
+	promptContext   *service.PromptContextBuilder
 }
 
 func NewMenuHandler(
 	menuService *service.MenuService,
 	menuScanService *service.MenuScanService,
 	visionService *service.VisionService,
-	ossService *service.OssService,
-	settingsService *service.SettingsService,
+	images *service.ImageAssetService,
+	visionCache *service.VisionCacheService,
+	precheck *service.ImagePrecheckService,
+	jobs *service.AIJobService,
 	dishService *service.DishService,
-	mealService *service.MealRecordService,
-	dailyService *service.DailyIntakeService,
 	subscriptions *service.SubscriptionService,
+	quota *service.QuotaService,
+	promptContext *service.PromptContextBuilder,
 ) *MenuHandler {
 	return &MenuHandler{
 		menuService:     menuService,
 		menuScanService: menuScanService,
 		visionService:   visionService,
-		ossService:      ossService,
-		settingsService: settingsService,
+		images:          images,
+		visionCache:     visionCache,
+		precheck:        precheck,
+		jobs:            jobs,
 		dishService:     dishService,
-		mealService:     mealService,
-		dailyService:    dailyService,
 		subscriptions:   subscriptions,
+		quota:           quota,
+		promptContext:   promptContext,
 	}
 }
 
@@ -76,113 +84,134 @@ func (h *MenuHandler) ScanImages(c echo.Context) error {
 	if err := c.Bind(req); err != nil {
 		return response.BadRequest(c, "invalid request body")
 	}
-	if len(req.ImageUrls) == 0 {
-		return response.BadRequest(c, "image_urls are required")
+	if len(req.ImageUrls) == 0 && len(req.ImageAssetIDs) == 0 {
+		return response.BadRequest(c, "image_urls or image_asset_ids are required")
 	}
 	clientTime := parseClientTime(req.ClientTime)
 	note := strings.TrimSpace(req.Note)
 	if note == "" {
 		note = "无"
 	}
-	if err := h.enforceMenuScanQuota(c, userID, clientTime); err != nil {
-		return err
+	if h.visionService == nil || !h.visionService.IsEnabled() {
+		return response.InternalError(c, "vision service is not configured")
 	}
 
-	recognizedText := ""
-	var aiSummary string
-	var dishes []map[string]interface{}
-	var actions []string
-	signedUrls := req.ImageUrls
-	if h.ossService != nil {
-		if signed, err := h.ossService.SignURLs(req.ImageUrls, 15*time.Minute); err == nil {
-			signedUrls = signed
-		} else {
-			return response.InternalError(c, "oss signing failed")
-		}
+	images, err := h.images.Resolve(c.Request().Context(), userID, "menu", req.ImageAssetIDs, req.ImageUrls)
+	if err != nil {
+		return respondImageError(c, err)
+	}
+	warnings, err := h.precheck.CheckQuality(service.AIRouteMenuScan, images)
+	if err != nil {
+		return respondImageError(c, err)
+	}
+	systemPrompt := ""
+	promptVersion := ""
+	pctx := h.promptContext.Build(userID, service.PromptContextOptions{ClientTime: clientTime})
+	if rendered, err := h.promptContext.Render(service.PromptMenuScan, pctx, service.MenuScanPromptExtras(note)); err == nil {
+		systemPrompt = rendered.Text
+		promptVersion = rendered.Version
+	} else {
+		c.Logger().Errorf("menu scan prompt render failed: %v", err)
 	}
-	if h.visionService != nil && h.visionService.IsEnabled() {
-		recentMealSummary := ""
-		if h.mealService != nil {
-			if records, err := h.mealService.ListByUser(userID, 20); err == nil {
-				recentMealSummary = service.SummarizeMealRecords(records, 3)
-			}
-		}
-		if strings.TrimSpace(recentMealSummary) == "" {
-			recentMealSummary = "暂无"
-		}
-		systemPrompt := ""
-		if h.settingsService != nil {
-			if raw, err := h.settingsService.Get(userID); err == nil && len(raw) > 0 {
-				var settings map[string]interface{}
-				if err := json.Unmarshal(raw, &settings); err == nil {
-					if template, err := service.LoadMenuScanPromptTemplate(); err == nil {
-						isTraining, isCheat := computeDayFlagsForDate(settings, clientTime)
-						dayType := dayTypeLabel(isTraining, isCheat)
-						timeOfDay := timeOfDayLabel(clientTime)
-						intake := buildIntakeContext(h.dailyService, userID, clientTime, settings)
-						systemPrompt = service.BuildSystemPrompt(template, settings, map[string]string{
-							"food_photo_taken":    "false",
-							"menu_scanned":        "true",
-							"recent_chat_summary": "暂无",
-							"recent_meal_summary": recentMealSummary,
-							"current_time":        formatPromptTime(clientTime),
-							"time_of_day":         timeOfDay,
-							"day_type":            dayType,
-							"is_training_day":     formatYesNo(isTraining),
-							"is_cheat_day":        formatYesNo(isCheat),
-							"calories_consumed":   intake.CaloriesConsumed,
-							"macro_consumed":      intake.MacroConsumed,
-							"calorie_remaining":   intake.CalorieRemaining,
-							"menu_scan_note":      note,
-						})
-					}
-				}
-			}
-		}
 
-		text, err := h.visionService.AnalyzeMenuFromURLs(c.Request().Context(), signedUrls, systemPrompt)
-		if err != nil {
-			return response.InternalError(c, "menu recognition failed")
+	// 相同菜单在窗口期内重复扫描直接返回缓存结果，按配置不计入额度
+	cacheKey := service.NewVisionCacheKey(service.AIRouteMenuScan, images, promptVersion, pctx.Fingerprint(note))
+	analysis, _, cacheHit := h.visionCache.Lookup(userID, cacheKey)
+	quotaExempt := cacheHit && !h.visionCache.ChargeOnHit()
+	if !quotaExempt {
+		if err := h.enforceMenuScanQuota(c, userID, clientTime); err != nil || c.Response().Committed {
+			return err
 		}
-		recognizedText = strings.TrimSpace(text)
-		if parsedDishes, summary, parsedActions := parseAIDishes(recognizedText); len(parsedDishes) > 0 {
-			dishes = parsedDishes
-			aiSummary = summary
-			actions = parsedActions
-			if extracted := extractMenuText(recognizedText); extracted != "" {
-				recognizedText = extracted
-			}
+	}
+	job := visionJobPayload{
+		ImageAssetIDs:  images.AssetIDs,
+		SystemPrompt:   systemPrompt,
+		PromptVersion:  promptVersion,
+		CacheKey:       cacheKey,
+		Note:           note,
+		RestaurantHint: strings.TrimSpace(req.RestaurantHint),
+		Warnings:       warnings,
+		ClientTime:     clientTime,
+	}
+	// 异步模式：识别放入任务队列，客户端轮询 GET /jobs/:id 获取与同步相同的结果
+	if !cacheHit && wantsAsync(c) && h.jobs.Supports(service.AIJobMenuScan) {
+		// 预留的额度随任务转交，任务结束时确认或释放
+		hold := quotaHoldFrom(c)
+		queued, err := h.jobs.Enqueue(userID, service.AIJobMenuScan, job, hold.IDs()...)
+		if err != nil {
+			c.Logger().Errorf("menu scan enqueue failed: %v", err)
+			return response.InternalError(c, "failed to enqueue menu scan")
 		}
-	} else {
-		return response.InternalError(c, "vision service is not configured")
+		hold.Detach()
+		return respondJobAccepted(c, queued)
+	}
+	result, err := h.runMenuScan(c.Request().Context(), userID, images, job, analysis, cacheHit, quotaExempt)
+	if err != nil {
+		return respondVisionRunError(c, err, "菜单识别结果无效，请重试", "menu recognition failed", "failed to save menu scan")
 	}
+	return response.Success(c, result)
+}
 
-	var parsedMenu []byte
-	if len(dishes) == 0 {
-		parsed := h.menuService.ParseMenuText(recognizedText)
-		parsedMenu, _ = json.Marshal(parsed)
-		for idx, item := range parsed.Items {
-			dishes = append(dishes, map[string]interface{}{
-				"id":          fmt.Sprintf("menu_%d_%d", time.Now().Unix(), idx),
-				"name":        item.Name,
-				"restaurant":  "菜单识别",
-				"score":       72,
-				"scoreLabel":  "待评估",
-				"scoreColor":  "ff37f07a",
-				"kcal":        0,
-				"protein":     0,
-				"carbs":       0,
-				"fat":         0,
-				"tag":         "菜单",
-				"recommended": true,
-				"reason":      "",
-			})
+// RunScanJob 执行排队的菜单识别任务，重试时优先复用已写入的识别缓存
+func (h *MenuHandler) RunScanJob(ctx context.Context, job *model.AIJob) (interface{}, error) {
+	if h.visionService == nil || !h.visionService.IsEnabled() {
+		return nil, service.NewAIJobError("vision_unavailable", "识别服务未配置")
+	}
+	var payload visionJobPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return nil, service.NewAIJobError("invalid_payload", "任务参数无效")
+	}
+	images, err := h.images.Resolve(ctx, job.UserID, "menu", payload.ImageAssetIDs, nil)
+	if err != nil {
+		return nil, asJobError(err)
+	}
+	analysis, _, cacheHit := h.visionCache.Lookup(job.UserID, payload.CacheKey)
+	result, err := h.runMenuScan(ctx, job.UserID, images, payload, analysis, cacheHit, false)
+	if err != nil {
+		return nil, asJobError(err)
+	}
+	return result, nil
+}
+
+// runMenuScan 相关性预检、调用识别（未命中缓存时）并保存扫描记录，同步请求与任务共用
+func (h *MenuHandler) runMenuScan(
+	ctx context.Context,
+	userID int64,
+	images *service.ResolvedImages,
+	job visionJobPayload,
+	analysis *service.AIDishAnalysis,
+	cacheHit bool,
+	quotaExempt bool,
+) (map[string]interface{}, error) {
+	warnings := job.Warnings
+	if !cacheHit {
+		relevance, err := h.precheck.CheckRelevance(ctx, service.AIRouteMenuScan, images)
+		if err != nil {
+			return nil, err
 		}
+		warnings = append(warnings, relevance...)
+		var rawText string
+		analysis, rawText, err = h.visionService.AnalyzeMenuFromURLs(ctx, images.ModelURLs, job.SystemPrompt)
+		if err != nil {
+			return nil, err
+		}
+		h.visionCache.Store(userID, job.CacheKey, analysis, rawText)
 	}
+	dishes := aiDishesToMaps(analysis.Dishes)
+	aiSummary := analysis.SummaryText()
+	actions := []string(analysis.Actions)
+	recognizedText := strings.TrimSpace(analysis.RecognizedText)
 
 	if h.dishService != nil {
 		dishes = h.dishService.HydrateDishMapsPreferFresh(dishes)
 	}
+	riskAlerts := flagExcludedDishes(dishes, h.promptContext.DietRestrictions(userID))
+	at := job.ClientTime
+	if at.IsZero() {
+		at = time.Now()
+	}
+	riskAlerts = append(riskAlerts, flagNutrientDishes(dishes, h.promptContext.NutrientBudget(userID, at))...)
+	riskAlerts = service.MergeRiskAlerts(riskAlerts, analysis.RiskAlerts)
 
 	if recognizedText == "" {
 		var names []string
@@ -193,48 +222,58 @@ func (h *MenuHandler) ScanImages(c echo.Context) error {
 		}
 		recognizedText = strings.Join(names, "\n")
 	}
-	parsedMenu, _ = json.Marshal(map[string]interface{}{
-		"items":      dishes,
-		"item_count": len(dishes),
-		"raw_text":   recognizedText,
-		"summary":    aiSummary,
+	parsedMenu, _ := json.Marshal(map[string]interface{}{
+		"items":          dishes,
+		"item_count":     len(dishes),
+		"raw_text":       recognizedText,
+		"summary":        aiSummary,
+		"risk_alerts":    riskAlerts,
+		"prompt_version": job.PromptVersion,
+		"cache_hit":      cacheHit,
+		"image_warnings": warnings,
 	})
 
 	var rawImageURL *string
-	if len(req.ImageUrls) > 0 {
-		rawImageURL = &req.ImageUrls[0]
+	if len(images.Keys) > 0 {
+		rawImageURL = &images.Keys[0]
 	}
 	var restaurantHint *string
-	if strings.TrimSpace(req.RestaurantHint) != "" {
-		value := strings.TrimSpace(req.RestaurantHint)
+	if job.RestaurantHint != "" {
+		value := job.RestaurantHint
 		restaurantHint = &value
 	}
 
 	scan := &model.MenuScan{
 		UserID:         userID,
 		RawImageURL:    rawImageURL,
-		RawImageURLs:   mustMarshalJSON(req.ImageUrls),
+		RawImageURLs:   mustMarshalJSON(images.Keys),
+		ImageAssetIDs:  images.AssetIDs,
 		OCRText:        recognizedText,
 		ParsedMenu:     parsedMenu,
 		RestaurantHint: restaurantHint,
+		QuotaExempt:    quotaExempt,
 	}
 	if h.menuScanService != nil {
 		if err := h.menuScanService.Create(scan); err != nil {
-			return response.InternalError(c, "failed to save menu scan")
+			return nil, fmt.Errorf("%w: %v", errResultSave, err)
 		}
 	}
 	if len(actions) == 0 {
 		actions = []string{"action=discover", "action=record_meal"}
 	}
 
-	return response.Success(c, map[string]interface{}{
+	return map[string]interface{}{
 		"scan_id":         scan.ID,
-		"image_count":     len(req.ImageUrls),
+		"image_count":     len(images.Keys),
+		"image_asset_ids": images.AssetIDs,
 		"recognized_text": recognizedText,
 		"summary":         aiSummary,
 		"actions":         actions,
 		"items":           dishes,
-	})
+		"risk_alerts":     riskAlerts,
+		"cache_hit":       cacheHit,
+		"image_warnings":  warnings,
+	}, nil
 }
 
 func (h *MenuHandler) enforceMenuScanQuota(
@@ -252,28 +291,15 @@ func (h *MenuHandler) enforceMenuScanQuota(
 	}
 	start := startOfDay(clientTime)
 	end := start.Add(24 * time.Hour)
-	count, err := h.menuScanService.CountByUserBetween(userID, start, end)
+	err := reserveFreeUse(c, h.quota, userID, service.QuotaKindMenuScan, func() (int, error) {
+		return h.menuScanService.CountByUserBetween(userID, start, end)
+	})
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		return response.Error(c, http.StatusTooManyRequests, "今日菜单扫描次数已用完，开通订阅可无限使用")
+	}
 	if err != nil {
 		c.Logger().Errorf("menu scan quota check failed: %v", err)
 		return response.InternalError(c, "usage check failed")
 	}
-	if count >= 1 {
-		return response.Error(c, http.StatusTooManyRequests, "今日菜单扫描次数已用完，开通订阅可无限使用")
-	}
 	return nil
 }
-
-func extractMenuText(raw string) string {
-	payload := extractJSONPayload(raw)
-	if payload == "" {
-		return ""
-	}
-	var decoded map[string]interface{}
-	if err := json.Unmarshal([]byte(payload), &decoded); err != nil {
-		return ""
-	}
-	if text, ok := decoded["recognized_text"].(string); ok {
-		return strings.TrimSpace(text)
-	}
-	return ""
-}
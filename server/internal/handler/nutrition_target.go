@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+type NutritionTargetHandler struct {
+	targets *service.NutritionTargetService
+}
+
+func NewNutritionTargetHandler(targets *service.NutritionTargetService) *NutritionTargetHandler {
+	return &NutritionTargetHandler{targets: targets}
+}
+
+// Recommend 推荐的热量与宏量营养素目标及计算说明，并检查设置中已有的目标
+// GET /api/v1/nutrition/targets?client_time=
+// POST /api/v1/nutrition/targets  {"settings": {...}, "client_time": ""}，settings 覆盖已保存的字段（引导页尚未保存时使用）
+func (h *NutritionTargetHandler) Recommend(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	var req struct {
+		Settings   map[string]interface{} `json:"settings"`
+		ClientTime string                 `json:"client_time"`
+	}
+	if c.Request().Method == http.MethodPost {
+		if err := c.Bind(&req); err != nil {
+			return response.BadRequest(c, "invalid request body")
+		}
+	} else {
+		req.ClientTime = c.QueryParam("client_time")
+	}
+	targets, err := h.targets.Recommend(userID, req.Settings, parseClientTime(req.ClientTime))
+	if err != nil {
+		var profileErr *service.TargetProfileError
+		if errors.As(err, &profileErr) {
+			return response.ErrorWithData(c, http.StatusBadRequest, "weight and height are required", map[string]interface{}{
+				"missing": profileErr.Missing,
+			})
+		}
+		c.Logger().Errorf("nutrition targets failed: %v", err)
+		return response.InternalError(c, "failed to compute nutrition targets")
+	}
+	return response.Success(c, targets)
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"eatclean/internal/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// quotaHoldFrom 额度中间件为本次请求创建的预留集合，未经过中间件时为 nil（预留只能等 TTL 过期）。
+func quotaHoldFrom(c echo.Context) *service.QuotaHold {
+	hold, _ := c.Get(service.QuotaHoldContextKey).(*service.QuotaHold)
+	return hold
+}
+
+// reserveFreeUse 免费用户每日一次的功能：在用户锁内统计已用次数并预留本次，
+// 并发请求只有一个能通过。超出时返回 service.ErrQuotaExceeded。
+func reserveFreeUse(c echo.Context, quota *service.QuotaService, userID int64, kind string, used func() (int, error)) error {
+	_, err := quota.Reserve(userID, service.QuotaCheck{
+		Kind:  kind,
+		Units: 1,
+		Limit: 1,
+		Used:  used,
+	}, quotaHoldFrom(c))
+	return err
+}
@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"eatclean/internal/model"
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+type RecipeHandler struct {
+	recipes *service.RecipeService
+	images  *service.ImageAssetService
+	meals   *service.MealRecordService
+}
+
+func NewRecipeHandler(recipes *service.RecipeService, images *service.ImageAssetService, meals *service.MealRecordService) *RecipeHandler {
+	return &RecipeHandler{recipes: recipes, images: images, meals: meals}
+}
+
+type recipeRequest struct {
+	Title        string                   `json:"title"`
+	Servings     float64                  `json:"servings"`
+	Ingredients  []model.RecipeIngredient `json:"ingredients"`
+	Instructions string                   `json:"instructions"`
+	// ImageAssetID 通过 /upload 上传（category=food）的成品照片，0 表示不设置
+	ImageAssetID int64 `json:"image_asset_id"`
+}
+
+// List 菜谱列表
+// GET /api/v1/recipes
+func (h *RecipeHandler) List(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	recipes, err := h.recipes.List(userID)
+	if err != nil {
+		c.Logger().Errorf("recipe list failed: %v", err)
+		return response.InternalError(c, "failed to load recipes")
+	}
+	if recipes == nil {
+		recipes = make([]model.Recipe, 0)
+	}
+	return response.Success(c, recipes)
+}
+
+// Get 菜谱详情
+// GET /api/v1/recipes/:id
+func (h *RecipeHandler) Get(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return response.BadRequest(c, "invalid id")
+	}
+	recipe, err := h.recipes.Get(userID, id)
+	if err != nil {
+		return respondRecipeError(c, err, "recipe load failed")
+	}
+	return response.Success(c, recipe)
+}
+
+// Create 新建菜谱，食材营养由服务端解析计算
+// POST /api/v1/recipes
+func (h *RecipeHandler) Create(c echo.Context) error {
+	return h.save(c, 0)
+}
+
+// Update 修改菜谱并重新计算营养
+// PUT /api/v1/recipes/:id
+func (h *RecipeHandler) Update(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return response.BadRequest(c, "invalid id")
+	}
+	return h.save(c, id)
+}
+
+func (h *RecipeHandler) save(c echo.Context, id int64) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	var req recipeRequest
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	if req.Servings == 0 {
+		req.Servings = 1
+	}
+	recipe := &model.Recipe{
+		ID:           id,
+		UserID:       userID,
+		Title:        req.Title,
+		Servings:     req.Servings,
+		Ingredients:  req.Ingredients,
+		Instructions: req.Instructions,
+	}
+	if req.ImageAssetID > 0 {
+		assets, err := h.images.Assets(c.Request().Context(), userID, "food", []int64{req.ImageAssetID}, nil)
+		if err != nil {
+			return respondImageError(c, err)
+		}
+		recipe.ImageAssetID = assets[0].ID
+		recipe.ImageKey = assets[0].ModelKey
+	}
+	if err := h.recipes.Save(recipe); err != nil {
+		if isForeignKeyViolation(err) {
+			return response.Unauthorized(c, "user not found, please re-login")
+		}
+		return respondRecipeError(c, err, "recipe save failed")
+	}
+	return response.Success(c, recipe)
+}
+
+// Delete 删除菜谱，已记录的就餐条目不受影响
+// DELETE /api/v1/recipes/:id
+func (h *RecipeHandler) Delete(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return response.BadRequest(c, "invalid id")
+	}
+	deleted, err := h.recipes.Delete(userID, id)
+	if err != nil {
+		c.Logger().Errorf("recipe delete failed: %v", err)
+		return response.InternalError(c, "failed to delete recipe")
+	}
+	if !deleted {
+		return response.Error(c, http.StatusNotFound, "recipe not found")
+	}
+	return response.Success(c, map[string]interface{}{"deleted": true})
+}
+
+// Import 把发现页生成的餐食卡片保存为菜谱
+// POST /api/v1/recipes/import
+func (h *RecipeHandler) Import(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	var req struct {
+		Card map[string]interface{} `json:"card"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	if len(req.Card) == 0 {
+		return response.BadRequest(c, "card is required")
+	}
+	recipe, err := h.recipes.ImportDiscoverCard(userID, req.Card)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return response.Unauthorized(c, "user not found, please re-login")
+		}
+		return respondRecipeError(c, err, "recipe import failed")
+	}
+	return response.Success(c, recipe)
+}
+
+// Log 按份数把菜谱记为一次就餐
+// POST /api/v1/recipes/:id/log
+func (h *RecipeHandler) Log(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok || userID == 0 {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return response.BadRequest(c, "invalid id")
+	}
+	var req struct {
+		Servings   float64    `json:"servings"`
+		RecordedAt *time.Time `json:"recorded_at"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	if req.Servings == 0 {
+		req.Servings = 1
+	}
+	recipe, err := h.recipes.Get(userID, id)
+	if err != nil {
+		return respondRecipeError(c, err, "recipe load failed")
+	}
+	item, err := h.recipes.MealItem(recipe, req.Servings)
+	if err != nil {
+		return respondRecipeError(c, err, "recipe meal item failed")
+	}
+	recordedAt := time.Now()
+	if req.RecordedAt != nil {
+		recordedAt = *req.RecordedAt
+	}
+	record := &model.MealRecord{
+		UserID:     userID,
+		Source:     "recipe",
+		Items:      mustMarshalJSON([]map[string]interface{}{item}),
+		ImageUrls:  mustMarshalJSON([]string{}),
+		Meta:       mustMarshalJSON(map[string]interface{}{"recipe_id": recipe.ID, "servings": req.Servings}),
+		RecordedAt: recordedAt,
+	}
+	if recipe.ImageAssetID > 0 {
+		record.ImageAssetIDs = []int64{recipe.ImageAssetID}
+		record.ImageUrls = mustMarshalJSON([]string{recipe.ImageKey})
+	}
+	if err := h.meals.Create(record); err != nil {
+		if isForeignKeyViolation(err) {
+			return response.Unauthorized(c, "user not found, please re-login")
+		}
+		c.Logger().Errorf("recipe log failed: %v", err)
+		return response.InternalError(c, "failed to create meal record")
+	}
+	return response.Success(c, record)
+}
+
+func respondRecipeError(c echo.Context, err error, logMessage string) error {
+	switch {
+	case errors.Is(err, service.ErrRecipeNotFound):
+		return response.Error(c, http.StatusNotFound, "recipe not found")
+	case errors.Is(err, service.ErrRecipeInvalid), errors.Is(err, service.ErrPortionQuantity):
+		return response.BadRequest(c, err.Error())
+	}
+	c.Logger().Errorf("%s: %v", logMessage, err)
+	return response.InternalError(c, "recipe request failed")
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"eatclean/internal/config"
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type StorageHandler struct {
+	service *service.StorageService
+	ossCfg  *config.OSSConfig
+}
+
+func NewStorageHandler(service *service.StorageService, ossCfg *config.OSSConfig) *StorageHandler {
+	return &StorageHandler{service: service, ossCfg: ossCfg}
+}
+
+// maxUploadKeys 单次最多申请的上传对象名数量
+const maxUploadKeys = 9
+
+// GetSTS 获取上传授权，只能写入当前用户目录
+// GET /api/v1/oss/sts?category=food&ext=jpg&ext=png
+// 按 ext（或 count，默认 jpg）返回服务端生成的 object_keys 与对应的预签名上传请求 uploads，客户端必须用这些 key 上传；
+// 后端为 OSS 且配置了 RoleArn 时另外返回 STS 凭证
+func (h *StorageHandler) GetSTS(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	c.Logger().Infof("storage upload grant request received: user_id=%d path=%s", userID, c.Path())
+	if h.service == nil || h.service.Store() == nil {
+		return response.InternalError(c, "storage service not configured")
+	}
+	category := strings.TrimSpace(c.QueryParam("category"))
+	if category == "" {
+		return response.BadRequest(c, "category is required")
+	}
+	exts := c.QueryParams()["ext"]
+	if len(exts) == 0 {
+		count, _ := strconv.Atoi(c.QueryParam("count"))
+		if count <= 0 {
+			count = 1
+		}
+		for i := 0; i < count && i < maxUploadKeys; i++ {
+			exts = append(exts, "jpg")
+		}
+	}
+	if len(exts) > maxUploadKeys {
+		return response.BadRequest(c, "too many upload keys requested")
+	}
+	grant, err := h.service.GrantUpload(c.Request().Context(), userID, category, exts, 15*time.Minute)
+	if errors.Is(err, service.ErrInvalidUploadCategory) {
+		return response.BadRequest(c, "invalid upload category")
+	}
+	if err != nil {
+		c.Logger().Errorf("storage upload grant error: %v", err)
+		return response.InternalError(c, "failed to grant upload")
+	}
+
+	payload := map[string]interface{}{
+		"backend":       grant.Backend,
+		"upload_prefix": grant.UploadPrefix,
+		"object_keys":   grant.Keys,
+		"uploads":       grant.Uploads,
+	}
+	if token := grant.Credentials; token != nil && h.ossCfg != nil {
+		if token.AccessKeyID == "" || token.AccessKeySecret == "" || token.SecurityToken == "" {
+			c.Logger().Errorf("oss sts invalid token: access_key_id_len=%d access_key_secret_len=%d security_token_len=%d",
+				len(token.AccessKeyID), len(token.AccessKeySecret), len(token.SecurityToken))
+			return response.InternalError(c, "invalid oss sts token")
+		}
+		akPrefix := token.AccessKeyID
+		if len(akPrefix) > 12 {
+			akPrefix = akPrefix[:12]
+		}
+		c.Logger().Infof("oss sts issued: ak_prefix=%s***, ak_len=%d, endpoint=%s, bucket=%s",
+			akPrefix, len(token.AccessKeyID), h.ossCfg.Endpoint, h.ossCfg.Bucket)
+
+		endpoint := strings.TrimSpace(h.ossCfg.Endpoint)
+		endpoint = strings.TrimPrefix(endpoint, "https://")
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+
+		payload["access_key_id"] = token.AccessKeyID
+		payload["access_key_secret"] = token.AccessKeySecret
+		payload["security_token"] = token.SecurityToken
+		payload["expiration"] = token.Expiration
+		payload["endpoint"] = endpoint
+		payload["bucket"] = h.ossCfg.Bucket
+		payload["region"] = h.ossCfg.Region
+	}
+	return response.Success(c, payload)
+}
+
+// SignURLs 为图片生成签名 URL
+// POST /api/v1/oss/sign
+func (h *StorageHandler) SignURLs(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	if h.service == nil {
+		return response.InternalError(c, "storage service not configured")
+	}
+	var req struct {
+		Urls       []string `json:"urls"`
+		TTLSeconds int      `json:"ttl_seconds"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return response.BadRequest(c, "invalid request body")
+	}
+	if len(req.Urls) == 0 {
+		return response.BadRequest(c, "urls are required")
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	signed, err := h.service.SignURLs(userID, req.Urls, ttl)
+	if err != nil {
+		return respondStorageSignError(c, err)
+	}
+	return response.Success(c, map[string]interface{}{
+		"signed_urls": signed,
+	})
+}
+
+// ServeLocal 本地存储下载（签名 URL）
+// GET /files/*?expires=&sig=
+func (h *StorageHandler) ServeLocal(c echo.Context) error {
+	store, key, status, message := h.verifyLocal(c, http.MethodGet)
+	if status != 0 {
+		return response.Error(c, status, message)
+	}
+	full, err := store.FilePath(key)
+	if err != nil {
+		return response.BadRequest(c, "invalid object key")
+	}
+	c.Response().Header().Set("Cache-Control", "private, max-age=300")
+	return c.File(full)
+}
+
+// UploadLocal 本地存储直传（预签名 PUT）
+// PUT /files/*?expires=&sig=
+func (h *StorageHandler) UploadLocal(c echo.Context) error {
+	store, key, status, message := h.verifyLocal(c, http.MethodPut)
+	if status != 0 {
+		return response.Error(c, status, message)
+	}
+	if err := store.Write(key, c.Request().Body); err != nil {
+		if errors.Is(err, service.ErrLocalUploadTooLarge) {
+			return response.Error(c, http.StatusRequestEntityTooLarge, "upload too large")
+		}
+		c.Logger().Errorf("local storage write failed: %v", err)
+		return response.InternalError(c, "upload failed")
+	}
+	return response.Success(c, map[string]interface{}{"key": key})
+}
+
+// verifyLocal 校验签名，失败时返回非 0 状态码与提示。
+func (h *StorageHandler) verifyLocal(c echo.Context, method string) (*service.LocalObjectStore, string, int, string) {
+	store, ok := h.service.Store().(*service.LocalObjectStore)
+	if !ok {
+		return nil, "", http.StatusNotFound, "not found"
+	}
+	key, err := url.PathUnescape(c.Param("*"))
+	if err != nil {
+		return nil, "", http.StatusBadRequest, "invalid object key"
+	}
+	if err := store.Verify(method, key, c.QueryParam("expires"), c.QueryParam("sig")); err != nil {
+		return nil, "", http.StatusForbidden, "invalid or expired signature"
+	}
+	return store, key, 0, ""
+}
+
+// respondStorageSignError 图片不属于当前用户时返回 403，其余签名失败返回 500。
+func respondStorageSignError(c echo.Context, err error) error {
+	if errors.Is(err, service.ErrObjectNotOwned) {
+		c.Logger().Warnf("oss sign rejected: %v", err)
+		return response.Error(c, http.StatusForbidden, "image does not belong to current user")
+	}
+	c.Logger().Errorf("oss signing failed: %v", err)
+	return response.InternalError(c, "oss signing failed")
+}
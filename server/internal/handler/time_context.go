@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"eatclean/internal/service"
 	"strings"
 	"time"
 )
@@ -35,27 +36,11 @@ func formatPromptTime(value time.Time) string {
 }
 
 func timeOfDayLabel(value time.Time) string {
-	hour := value.Hour()
-	switch {
-	case hour >= 5 && hour < 11:
-		return "早上"
-	case hour >= 11 && hour < 14:
-		return "中午"
-	case hour >= 14 && hour < 18:
-		return "下午"
-	default:
-		return "晚上"
-	}
+	return service.TimeOfDayLabel(value)
 }
 
 func dayTypeLabel(isTraining, isCheat bool) string {
-	if isTraining {
-		return "训练日"
-	}
-	if isCheat {
-		return "放纵日"
-	}
-	return "正常日"
+	return service.DayTypeLabel(isTraining, isCheat)
 }
 
 func startOfDay(value time.Time) time.Time {
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"eatclean/internal/model"
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type UploadHandler struct {
+	images *service.ImageAssetService
+}
+
+func NewUploadHandler(images *service.ImageAssetService) *UploadHandler {
+	return &UploadHandler{images: images}
+}
+
+// UploadImages 图片入库：校验格式与大小、去除 EXIF、纠正方向、生成模型尺寸版本与缩略图，按内容去重
+// POST /api/v1/uploads/images
+// multipart：category + files（可多个）；或 JSON {"category":"food","image_urls":[...]} 处理已直传的原图
+func (h *UploadHandler) UploadImages(c echo.Context) error {
+	userID, ok := c.Get("user_id").(int64)
+	if !ok {
+		return response.Unauthorized(c, "invalid user context")
+	}
+	if h.images == nil {
+		return response.InternalError(c, "image service not configured")
+	}
+	ctx := c.Request().Context()
+
+	type ingested struct {
+		asset        *model.ImageAsset
+		deduplicated bool
+	}
+	var results []ingested
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return response.BadRequest(c, "invalid multipart form")
+		}
+		category := strings.TrimSpace(c.FormValue("category"))
+		files := form.File["files"]
+		if len(files) == 0 {
+			return response.BadRequest(c, "files are required")
+		}
+		if len(files) > maxUploadKeys {
+			return response.BadRequest(c, "too many files")
+		}
+		for _, header := range files {
+			if header.Size > h.images.MaxBytes() {
+				return respondImageError(c, service.ErrImageTooLarge)
+			}
+			file, err := header.Open()
+			if err != nil {
+				return response.BadRequest(c, "invalid upload file")
+			}
+			data, err := io.ReadAll(io.LimitReader(file, h.images.MaxBytes()+1))
+			file.Close()
+			if err != nil {
+				return response.BadRequest(c, "invalid upload file")
+			}
+			asset, deduplicated, err := h.images.Ingest(ctx, userID, category, data)
+			if err != nil {
+				return respondImageError(c, err)
+			}
+			results = append(results, ingested{asset: asset, deduplicated: deduplicated})
+		}
+	} else {
+		var req struct {
+			Category  string   `json:"category"`
+			ImageUrls []string `json:"image_urls"`
+		}
+		if err := c.Bind(&req); err != nil {
+			return response.BadRequest(c, "invalid request body")
+		}
+		if len(req.ImageUrls) == 0 {
+			return response.BadRequest(c, "image_urls are required")
+		}
+		if len(req.ImageUrls) > maxUploadKeys {
+			return response.BadRequest(c, "too many images")
+		}
+		for _, raw := range req.ImageUrls {
+			asset, deduplicated, err := h.images.IngestObject(ctx, userID, req.Category, raw)
+			if err != nil {
+				return respondImageError(c, err)
+			}
+			results = append(results, ingested{asset: asset, deduplicated: deduplicated})
+		}
+	}
+
+	assets := make([]map[string]interface{}, 0, len(results))
+	for _, result := range results {
+		modelURL, thumbURL, err := h.images.SignAsset(userID, result.asset, time.Hour)
+		if err != nil {
+			return respondImageError(c, err)
+		}
+		assets = append(assets, map[string]interface{}{
+			"id":            result.asset.ID,
+			"content_hash":  result.asset.ContentHash,
+			"category":      result.asset.Category,
+			"width":         result.asset.Width,
+			"height":        result.asset.Height,
+			"model_key":     result.asset.ModelKey,
+			"model_url":     modelURL,
+			"thumbnail_key": result.asset.ThumbKey,
+			"thumbnail_url": thumbURL,
+			"quality":       result.asset.Quality,
+			"deduplicated":  result.deduplicated,
+		})
+	}
+	return response.Success(c, map[string]interface{}{"assets": assets})
+}
+
+// respondImageError 图片入库/解析错误映射为 HTTP 状态码
+func respondImageError(c echo.Context, err error) error {
+	var precheckErr *service.ImagePrecheckError
+	if errors.As(err, &precheckErr) {
+		return response.ErrorWithData(c, http.StatusUnprocessableEntity, precheckErr.Message(), map[string]interface{}{
+			"error_code": precheckErr.Code,
+			"issues":     precheckErr.Issues,
+		})
+	}
+	switch {
+	case errors.Is(err, service.ErrUnsupportedImage):
+		return response.Error(c, http.StatusUnsupportedMediaType, "unsupported image format")
+	case errors.Is(err, service.ErrImageTooLarge):
+		return response.Error(c, http.StatusRequestEntityTooLarge, "image too large")
+	case errors.Is(err, service.ErrInvalidUploadCategory):
+		return response.BadRequest(c, "invalid upload category")
+	case errors.Is(err, service.ErrImageAssetNotFound):
+		return response.BadRequest(c, "image asset not found")
+	case errors.Is(err, service.ErrObjectNotFound):
+		return response.BadRequest(c, "image not found")
+	}
+	return respondStorageSignError(c, err)
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"eatclean/internal/model"
 	"eatclean/internal/service"
 	"log"
 	"time"
@@ -81,6 +82,10 @@ func (s *WeeklyMenuScheduler) runOnce(now time.Time) {
 				targetDate,
 			)
 			if err != nil {
+				if service.IsAIUnavailable(err) {
+					log.Printf("weekly menu scheduler stopped: %v", err)
+					return
+				}
 				log.Printf("weekly menu generate failed (user %d, day %d): %v", userID, weekday, err)
 				continue
 			}
@@ -96,7 +101,7 @@ func (s *WeeklyMenuScheduler) runOnce(now time.Time) {
 			if s.discover.dishService != nil {
 				for _, meal := range append(append([]map[string]interface{}{}, planMeals...), recommendations...) {
 					meal["name"] = readStringOr(meal["name"], readString(meal["title"]))
-					_ = s.discover.dishService.UpsertFromMap(meal)
+					_ = s.discover.dishService.UpsertFromMap(meal, model.NutritionSourceAIPlan)
 				}
 			}
 		}
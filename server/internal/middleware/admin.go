@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"eatclean/pkg/response"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AdminAuth 校验 X-Admin-Token，未配置 token 时管理接口一律拒绝。
+func AdminAuth(token string) echo.MiddlewareFunc {
+	token = strings.TrimSpace(token)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if token == "" {
+				return response.Error(c, http.StatusForbidden, "admin api disabled")
+			}
+			provided := strings.TrimSpace(c.Request().Header.Get("X-Admin-Token"))
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				return response.Unauthorized(c, "invalid admin token")
+			}
+			return next(c)
+		}
+	}
+}
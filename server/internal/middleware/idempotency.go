@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"eatclean/internal/service"
+	"eatclean/pkg/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+// IdempotencyKeyHeader 客户端为每次用户操作生成的唯一值，重试时原样带上。
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+// Idempotency 对 paths 中的路由按 (用户, 路由, Idempotency-Key) 去重：
+// 重试重放首次响应（Idempotent-Replayed: true），首次请求处理中返回 409，同一个 key 对应不同请求体返回 422。
+// 只保存 2xx 响应；4xx/5xx 与过大的响应不保存，客户端修正请求或升级后可用同一个 key 重试。
+// 请求体超过 MaxRequestBytes 时返回 413。需挂在额度校验之前，重放不再计费。
+func Idempotency(idempotency *service.IdempotencyService, paths ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := strings.TrimSpace(c.Request().Header.Get(IdempotencyKeyHeader))
+			if key == "" || !idempotency.IsEnabled() || !matchesPath(c.Path(), paths) {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return response.BadRequest(c, "Idempotency-Key too long")
+			}
+			userID, ok := c.Get("user_id").(int64)
+			if !ok {
+				return response.Unauthorized(c, "invalid user context")
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, idempotency.MaxRequestBytes()))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					return response.Error(c, http.StatusRequestEntityTooLarge, "request body too large")
+				}
+				return response.BadRequest(c, "invalid request body")
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256(body)
+			requestHash := hex.EncodeToString(sum[:])
+
+			route := c.Request().Method + " " + c.Path()
+			replay, err := idempotency.Begin(userID, route, key, requestHash)
+			switch {
+			case errors.Is(err, service.ErrIdempotencyInFlight):
+				return response.Error(c, http.StatusConflict, "相同请求正在处理中，请稍后重试")
+			case errors.Is(err, service.ErrIdempotencyMismatch):
+				return response.Error(c, http.StatusUnprocessableEntity, "Idempotency-Key 已用于其他请求")
+			case err != nil:
+				// 存储不可用时不阻断请求，只是失去去重保护
+				c.Logger().Errorf("idempotency begin failed: %v", err)
+				return next(c)
+			case replay != nil:
+				c.Response().Header().Set("Idempotent-Replayed", "true")
+				return c.Blob(replay.ResponseStatus, replay.ContentType, replay.ResponseBody)
+			}
+
+			recorder := &idempotencyRecorder{ResponseWriter: c.Response().Writer, limit: idempotency.MaxResponseBytes()}
+			c.Response().Writer = recorder
+			defer func() {
+				c.Response().Writer = recorder.ResponseWriter
+			}()
+
+			handlerErr := next(c)
+			status := c.Response().Status
+			if handlerErr != nil || !c.Response().Committed || status < http.StatusOK || status >= http.StatusMultipleChoices || recorder.overflow {
+				idempotency.Release(userID, route, key)
+				return handlerErr
+			}
+			idempotency.Complete(userID, route, key, status, c.Response().Header().Get(echo.HeaderContentType), recorder.body.Bytes())
+			return nil
+		}
+	}
+}
+
+func matchesPath(path string, paths []string) bool {
+	for _, candidate := range paths {
+		if path == candidate || strings.HasSuffix(path, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// idempotencyRecorder 写出响应的同时保存一份副本，超过 limit 后停止保存。
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (r *idempotencyRecorder) Write(p []byte) (int, error) {
+	if !r.overflow {
+		if r.body.Len()+len(p) > r.limit {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
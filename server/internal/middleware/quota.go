@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -12,13 +13,16 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-// UsageQuotaGuard 按积分校验每日额度，统一返回 429。
+// UsageQuotaGuard 按积分校验每日额度，统一返回 429。不写用量记录的 AI 路由（如营养成分表识别）由确认后的预留计数，
+// 同时进行的 AI 请求受 max_in_flight 限制。
 // cost 估算：扫描/图片类 8 分，AI 对话 5 分，普通写入 2 分。
+// 校验与预留在用户锁内原子完成，并发请求不会同时通过；请求成功后确认预留，失败则释放。
 func UsageQuotaGuard(
 	menuScans *service.MenuScanService,
 	mealRecords *service.MealRecordService,
 	chatMessages *service.ChatMessageService,
 	subscriptions *service.SubscriptionService,
+	quota *service.QuotaService,
 ) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -44,36 +48,58 @@ func UsageQuotaGuard(
 
 			start := startOfDay(time.Now())
 			end := start.Add(24 * time.Hour)
-
-			used := 0
-			if menuScans != nil {
-				if n, err := menuScans.CountByUserBetween(userID, start, end); err == nil {
-					used += n * 8
+			usedPoints := func() (int, error) {
+				used := 0
+				if menuScans != nil {
+					if n, err := menuScans.CountByUserBetween(userID, start, end); err == nil {
+						used += n * 8
+					}
 				}
-			}
-			if mealRecords != nil {
-				if n, err := mealRecords.CountByUserSourceBetween(userID, "food", start, end); err == nil {
-					used += n * 3
+				if mealRecords != nil {
+					if n, err := mealRecords.CountByUserSourceBetween(userID, "food", start, end); err == nil {
+						used += n * 3
+					}
 				}
-			}
-			if chatMessages != nil {
-				if n, err := chatMessages.CountByUserRoleBetween(userID, "user", start, end); err == nil {
-					used += n * 5
+				if chatMessages != nil {
+					if n, err := chatMessages.CountByUserRoleBetween(userID, "user", start, end); err == nil {
+						used += n * 5
+					}
 				}
+				return used, nil
 			}
 
-			if used+cost > planLimit {
+			hold := quota.NewHold()
+			c.Set(service.QuotaHoldContextKey, hold)
+			remaining, err := quota.Reserve(userID, service.QuotaCheck{
+				Kind:          service.QuotaKindPoints,
+				Units:         cost,
+				Limit:         planLimit,
+				Used:          usedPoints,
+				Retain:        retainsQuota(path),
+				Since:         start,
+				LimitInFlight: isAIPath(path),
+			}, hold)
+			switch {
+			case errors.Is(err, service.ErrQuotaTooManyInFlight):
+				return response.Error(
+					c,
+					http.StatusTooManyRequests,
+					"同时进行的 AI 请求过多，请稍后再试",
+				)
+			case errors.Is(err, service.ErrQuotaExceeded):
 				return response.Error(
 					c,
 					http.StatusTooManyRequests,
 					"额度已用完，请订阅后继续使用",
 				)
+			case err != nil:
+				return response.InternalError(c, "额度校验失败")
 			}
-
-			remaining := planLimit - (used + cost)
 			c.Response().Header().Set("X-Quota-Remaining", fmt.Sprintf("%d", remaining))
 
-			return next(c)
+			err = next(c)
+			hold.Finish(err == nil && c.Response().Status < http.StatusBadRequest)
+			return err
 		}
 	}
 }
@@ -85,6 +111,7 @@ func costForPath(path string) int {
 		strings.Contains(path, "/meals/photo"),
 		strings.Contains(path, "/meals/analyze"),
 		strings.Contains(path, "/ingredients/scan"),
+		strings.Contains(path, "/food/label/scan"),
 		strings.Contains(path, "/discover/recommendations"),
 		strings.Contains(path, "/discover/replace"),
 		strings.Contains(path, "/discover/weekly/generate"),
@@ -99,6 +126,26 @@ func costForPath(path string) int {
 	}
 }
 
+// isAIPath 调用大模型的计费路由，受 max_in_flight 限制；菜单文本解析与 OSS 签名不调用模型。
+func isAIPath(path string) bool {
+	return !strings.Contains(path, "/menu/parse") && !strings.Contains(path, "/oss/")
+}
+
+// retainsQuota 不写用量记录的 AI 路由，确认后的预留计入当天额度。
+// /menu/scan、/meals/photo 与对话由落库的记录计数。
+func retainsQuota(path string) bool {
+	switch {
+	case strings.Contains(path, "/meals/analyze"),
+		strings.Contains(path, "/ingredients/scan"),
+		strings.Contains(path, "/food/label/scan"),
+		strings.Contains(path, "/discover/recommendations"),
+		strings.Contains(path, "/discover/replace"),
+		strings.Contains(path, "/discover/weekly/generate"):
+		return true
+	}
+	return false
+}
+
 func dailyLimitForUser(userID int64, subscriptions *service.SubscriptionService) int {
 	// 免费：30 分/天 (~30k tokens)
 	// 月订阅：600 分/天
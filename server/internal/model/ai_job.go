@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AI 任务状态。
+const (
+	AIJobQueued    = "queued"
+	AIJobRunning   = "running"
+	AIJobSucceeded = "succeeded"
+	AIJobFailed    = "failed"
+)
+
+// AIJob 异步 AI 任务。Payload 含渲染好的提示词等内部数据，不返回给客户端。
+type AIJob struct {
+	ID           int64           `json:"id" db:"id"`
+	UserID       int64           `json:"user_id" db:"user_id"`
+	Kind         string          `json:"kind" db:"kind"`
+	Status       string          `json:"status" db:"status"`
+	Payload      json.RawMessage `json:"-" db:"payload"`
+	Result       json.RawMessage `json:"result,omitempty" db:"result"`
+	ErrorCode    string          `json:"error_code,omitempty" db:"error_code"`
+	ErrorMessage string          `json:"error_message,omitempty" db:"error_message"`
+	Attempts     int             `json:"attempts" db:"attempts"`
+	MaxAttempts  int             `json:"max_attempts" db:"max_attempts"`
+	RunAfter     time.Time       `json:"run_after" db:"run_after"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at" db:"updated_at"`
+	FinishedAt   *time.Time      `json:"finished_at,omitempty" db:"finished_at"`
+	// ReservationIDs 提交请求时预留的额度，任务成功后确认、最终失败后释放
+	ReservationIDs []int64 `json:"-" db:"quota_reservation_ids"`
+}
+
+// Finished 任务已成功或最终失败。
+func (j *AIJob) Finished() bool {
+	return j.Status == AIJobSucceeded || j.Status == AIJobFailed
+}
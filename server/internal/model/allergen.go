@@ -0,0 +1,250 @@
+package model
+
+import "strings"
+
+// 过敏原与忌口类别。
+const (
+	AllergenPeanut    = "peanut"
+	AllergenTreeNut   = "tree_nut"
+	AllergenFish      = "fish"
+	AllergenShellfish = "shellfish"
+	AllergenDairy     = "dairy"
+	AllergenEgg       = "egg"
+	AllergenGluten    = "gluten"
+	AllergenSoy       = "soy"
+	AllergenSesame    = "sesame"
+	AllergenMeat      = "meat"
+)
+
+// AllergenCategory 一个过敏原/忌口类别。
+// Aliases 为设置中 excluded_foods 可能的写法；Keywords 出现在菜名或食材中即视为含有；
+// Ignore 为含关键词但不属于该类的词（如“鱼香”“椰奶”），匹配前先移除。
+type AllergenCategory struct {
+	Code     string
+	Label    string
+	Aliases  []string
+	Keywords []string
+	Ignore   []string
+}
+
+// AllergenCategories 常见过敏原（花生、坚果、鱼、甲壳贝类、奶、蛋、麸质、大豆、芝麻）与素食需要排除的肉类。
+var AllergenCategories = []AllergenCategory{
+	{
+		Code:     AllergenPeanut,
+		Label:    "花生",
+		Aliases:  []string{"花生", "peanut", "peanuts", "花生过敏"},
+		Keywords: []string{"花生", "落花生", "沙爹", "沙嗲"},
+	},
+	{
+		Code:     AllergenTreeNut,
+		Label:    "坚果",
+		Aliases:  []string{"坚果", "树坚果", "nut", "nuts", "tree_nut", "tree nuts"},
+		Keywords: []string{"坚果", "杏仁", "核桃", "腰果", "榛子", "开心果", "夏威夷果", "碧根果", "松子", "巴旦木", "胡桃"},
+	},
+	{
+		Code:     AllergenFish,
+		Label:    "鱼类",
+		Aliases:  []string{"海鲜", "水产", "鱼", "鱼类", "fish", "seafood"},
+		Keywords: []string{"鱼", "鳕", "鳗", "鲈", "鲫", "鳜", "鲅", "刺身"},
+		Ignore:   []string{"鱼香", "鲍鱼", "鱿鱼", "墨鱼", "章鱼", "甲鱼", "鳄鱼", "木鱼"},
+	},
+	{
+		Code:    AllergenShellfish,
+		Label:   "甲壳贝类",
+		Aliases: []string{"海鲜", "水产", "虾", "蟹", "虾蟹", "贝类", "甲壳类", "shellfish", "seafood"},
+		Keywords: []string{
+			"海鲜", "虾", "蟹", "贝", "蛤", "蚌", "蚝", "牡蛎", "螺", "蚬", "淡菜", "鲍鱼", "鱿鱼", "墨鱼", "章鱼",
+			"海参", "海胆", "海蜇", "xo酱",
+		},
+		Ignore: []string{"贝果", "贝贝南瓜", "螺丝粉", "螺旋藻", "螺纹面"},
+	},
+	{
+		Code:     AllergenDairy,
+		Label:    "奶制品",
+		Aliases:  []string{"奶制品", "乳制品", "牛奶", "奶", "乳糖", "乳糖不耐", "dairy", "milk", "lactose"},
+		Keywords: []string{"奶", "乳", "芝士", "起司", "奶酪", "黄油", "拿铁", "酸奶"},
+		Ignore:   []string{"椰奶", "豆奶", "杏仁奶", "燕麦奶", "核桃奶", "奶白菜", "豆乳", "腐乳", "乳鸽", "乳猪", "乳瓜"},
+	},
+	{
+		Code:     AllergenEgg,
+		Label:    "鸡蛋",
+		Aliases:  []string{"鸡蛋", "蛋", "蛋类", "egg", "eggs"},
+		Keywords: []string{"蛋", "蛋黄酱", "沙拉酱", "天妇罗"},
+		Ignore:   []string{"高蛋白", "蛋白质", "蛋白粉", "乳清蛋白", "植物蛋白", "大豆蛋白", "豌豆蛋白", "蛋白棒"},
+	},
+	{
+		Code:    AllergenGluten,
+		Label:   "麸质",
+		Aliases: []string{"麸质", "小麦", "面筋", "面食", "gluten", "wheat"},
+		Keywords: []string{
+			"面", "小麦", "大麦", "黑麦", "麸", "馒头", "包子", "饺子", "馄饨", "油条", "烧麦", "烧卖", "花卷", "饼",
+			"吐司", "三明治", "汉堡", "披萨", "春卷", "锅贴", "生煎", "蛋糕", "凉皮", "天妇罗", "啤酒",
+		},
+		Ignore: []string{"米饼"},
+	},
+	{
+		Code:     AllergenSoy,
+		Label:    "大豆",
+		Aliases:  []string{"大豆", "黄豆", "豆制品", "soy", "soybean"},
+		Keywords: []string{"大豆", "黄豆", "豆腐", "豆浆", "豆奶", "豆乳", "豆干", "腐竹", "豆皮", "千张", "毛豆", "纳豆", "味噌", "豆花", "素鸡", "素肉", "腐乳", "豆豉"},
+		Ignore:   []string{"杏仁豆腐", "日本豆腐", "鸡蛋豆腐"},
+	},
+	{
+		Code:     AllergenSesame,
+		Label:    "芝麻",
+		Aliases:  []string{"芝麻", "sesame"},
+		Keywords: []string{"芝麻", "麻酱", "香油", "麻油", "热干面"},
+	},
+	{
+		Code:    AllergenMeat,
+		Label:   "肉类",
+		Aliases: []string{"肉", "肉类", "红肉", "meat"},
+		Keywords: []string{
+			"肉", "鸡", "鸭", "鹅", "猪", "牛", "羊", "兔", "鸽", "排骨", "培根", "火腿", "香肠", "腊肠", "腊味",
+			"里脊", "肥牛", "骨汤",
+		},
+		Ignore: []string{
+			"素肉", "肉桂", "肉豆蔻", "果肉", "椰肉", "蟹肉", "虾肉", "贝肉", "鱼肉", "素鸡", "鸡蛋", "鸭蛋", "鹅蛋", "鸽子蛋", "鸡毛菜", "鸡腿菇", "鸡枞", "鸡头米",
+			"牛奶", "牛乳", "牛油果", "牛肝菌", "牛蒡", "牛轧糖", "蜗牛", "羊奶", "羊肚菌", "羊羹", "鸡精", "鸡尾酒", "鸭梨",
+		},
+	},
+}
+
+// dishAllergens 名称本身看不出配料的常见中式菜品，按通常做法补充的类别。
+var dishAllergens = map[string][]string{
+	"宫保":   {AllergenPeanut},
+	"担担面":  {AllergenPeanut, AllergenSesame},
+	"夫妻肺片": {AllergenPeanut, AllergenSesame},
+	"口水鸡":  {AllergenPeanut, AllergenSesame},
+	"酸辣粉":  {AllergenPeanut, AllergenSoy},
+	"棒棒鸡":  {AllergenPeanut, AllergenSesame},
+	"凉面":   {AllergenPeanut, AllergenSesame},
+	"怪味":   {AllergenPeanut, AllergenSesame},
+	"麻婆豆腐": {AllergenMeat},
+	"酸辣汤":  {AllergenEgg, AllergenSoy},
+	"炒饭":   {AllergenEgg},
+	"木须":   {AllergenEgg},
+	"日本豆腐": {AllergenEgg},
+	"鸡蛋豆腐": {AllergenEgg},
+	"蚝油":   {AllergenShellfish},
+	"佛跳墙":  {AllergenShellfish, AllergenMeat},
+	"寿司":   {AllergenFish},
+	"凯撒":   {AllergenDairy, AllergenEgg, AllergenFish},
+	"提拉米苏": {AllergenDairy, AllergenEgg, AllergenGluten},
+	"奶茶":   {AllergenDairy},
+	"泡芙":   {AllergenDairy, AllergenEgg, AllergenGluten},
+	"饼干":   {AllergenDairy, AllergenGluten},
+	"青酱":   {AllergenTreeNut, AllergenDairy},
+}
+
+// normalizedAllergenCategories 关键词与排除词按 NormalizeFoodName 规范化后的类别，与菜名使用同一套规则。
+var normalizedAllergenCategories = func() []AllergenCategory {
+	out := make([]AllergenCategory, len(AllergenCategories))
+	for idx, category := range AllergenCategories {
+		category.Keywords = normalizeFoodNames(category.Keywords)
+		category.Ignore = normalizeFoodNames(category.Ignore)
+		out[idx] = category
+	}
+	return out
+}()
+
+var normalizedDishAllergens = func() map[string][]string {
+	out := make(map[string][]string, len(dishAllergens))
+	for name, codes := range dishAllergens {
+		out[NormalizeFoodName(name)] = codes
+	}
+	return out
+}()
+
+// AllergenCategoryByCode 未知类别返回 nil。
+func AllergenCategoryByCode(code string) *AllergenCategory {
+	for idx := range AllergenCategories {
+		if AllergenCategories[idx].Code == code {
+			return &AllergenCategories[idx]
+		}
+	}
+	return nil
+}
+
+// AllergenCodesForTerm 忌口写法对应的类别，如“海鲜”对应鱼类与甲壳贝类；
+// 没有完全相同的写法时，按包含的两字以上别名归类（如“对海鲜过敏”），仍无法归类时返回空。
+func AllergenCodesForTerm(term string) []string {
+	key := strings.ToLower(strings.TrimSpace(term))
+	if key == "" {
+		return nil
+	}
+	if codes := allergenCodesMatching(func(alias string) bool { return key == alias }); len(codes) > 0 {
+		return codes
+	}
+	return allergenCodesMatching(func(alias string) bool {
+		return len([]rune(alias)) >= 2 && strings.Contains(key, alias)
+	})
+}
+
+func allergenCodesMatching(match func(alias string) bool) []string {
+	var codes []string
+	for _, category := range AllergenCategories {
+		for _, alias := range category.Aliases {
+			if match(alias) {
+				codes = append(codes, category.Code)
+				break
+			}
+		}
+	}
+	return codes
+}
+
+// DetectAllergens 按菜名与食材判断含有的类别，按 AllergenCategories 的顺序返回。
+// 关键词匹配前移除该类的排除词；常见菜名另按 dishAllergens 补充。
+func DetectAllergens(name string, components []string) []string {
+	texts := make([]string, 0, len(components)+1)
+	for _, text := range append([]string{name}, components...) {
+		if normalized := NormalizeFoodName(text); normalized != "" {
+			texts = append(texts, normalized)
+		}
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+	found := map[string]bool{}
+	for _, text := range texts {
+		for dish, codes := range normalizedDishAllergens {
+			if strings.Contains(text, dish) {
+				for _, code := range codes {
+					found[code] = true
+				}
+			}
+		}
+	}
+	var codes []string
+	for _, category := range normalizedAllergenCategories {
+		if found[category.Code] || containsAllergenKeyword(texts, category) {
+			codes = append(codes, category.Code)
+		}
+	}
+	return codes
+}
+
+func containsAllergenKeyword(texts []string, category AllergenCategory) bool {
+	for _, text := range texts {
+		for _, ignore := range category.Ignore {
+			text = strings.ReplaceAll(text, ignore, "|")
+		}
+		for _, keyword := range category.Keywords {
+			if strings.Contains(text, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func normalizeFoodNames(names []string) []string {
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		if normalized := NormalizeFoodName(name); normalized != "" {
+			out = append(out, normalized)
+		}
+	}
+	return out
+}
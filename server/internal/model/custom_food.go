@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// CustomFood 用户自建食物（营养成分表识别或手动录入），只对本人可见，名称按 model.NormalizeFoodName 去重。
+type CustomFood struct {
+	ID             int64      `json:"id"`
+	UserID         int64      `json:"user_id"`
+	Name           string     `json:"name"`
+	NormalizedName string     `json:"-"`
+	Brand          string     `json:"brand,omitempty"`
+	Barcode        string     `json:"barcode,omitempty"`
+	Nutrition      *Nutrition `json:"nutrition"`
+	// NRVPercent 标签上的营养素参考值百分比（按标签基准），key 为 energy/protein/fat/carbs/sodium 等
+	NRVPercent map[string]float64 `json:"nrv_percent,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+}
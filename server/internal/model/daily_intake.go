@@ -3,11 +3,37 @@ package model
 import "time"
 
 type DailyIntake struct {
-	UserID    int64     `json:"user_id" db:"user_id"`
-	Day       time.Time `json:"day" db:"day"`
-	Calories  int       `json:"calories" db:"calories"`
-	Protein   int       `json:"protein" db:"protein"`
-	Carbs     int       `json:"carbs" db:"carbs"`
-	Fat       int       `json:"fat" db:"fat"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	UserID   int64     `json:"user_id" db:"user_id"`
+	Day      time.Time `json:"day" db:"day"`
+	Calories int       `json:"calories" db:"calories"`
+	Protein  int       `json:"protein" db:"protein"`
+	Carbs    int       `json:"carbs" db:"carbs"`
+	Fat      int       `json:"fat" db:"fat"`
+	// 微量营养素合计，客户端未上报时按当天就餐记录计算
+	SodiumMg      float64   `json:"sodium_mg" db:"sodium_mg"`
+	SugarG        float64   `json:"sugar_g" db:"sugar_g"`
+	AddedSugarG   float64   `json:"added_sugar_g" db:"added_sugar_g"`
+	FiberG        float64   `json:"fiber_g" db:"fiber_g"`
+	SaturatedFatG float64   `json:"saturated_fat_g" db:"saturated_fat_g"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Micronutrients 当天的钠、糖、膳食纤维与饱和脂肪合计。
+func (d *DailyIntake) Micronutrients() NutritionFacts {
+	return NutritionFacts{
+		SodiumMg:      d.SodiumMg,
+		SugarG:        d.SugarG,
+		AddedSugarG:   d.AddedSugarG,
+		FiberG:        d.FiberG,
+		SaturatedFatG: d.SaturatedFatG,
+	}
+}
+
+// SetMicronutrients 用 facts 中的微量营养素覆盖当前值。
+func (d *DailyIntake) SetMicronutrients(facts NutritionFacts) {
+	d.SodiumMg = facts.SodiumMg
+	d.SugarG = facts.SugarG
+	d.AddedSugarG = facts.AddedSugarG
+	d.FiberG = facts.FiberG
+	d.SaturatedFatG = facts.SaturatedFatG
 }
@@ -1,16 +1,17 @@
 package model
 
 import (
-	"encoding/json"
 	"time"
 )
 
 type Dish struct {
-	ID                int64           `json:"id"`
-	Name              string          `json:"name"`
-	NormalizedName    string          `json:"normalized_name"`
-	Category          *string         `json:"category,omitempty"`
-	NutritionEstimate json.RawMessage `json:"nutrition_estimate,omitempty"`
-	ImageUrls         []string        `json:"image_urls,omitempty"`
-	CreatedAt         time.Time       `json:"created_at"`
+	ID             int64   `json:"id"`
+	Name           string  `json:"name"`
+	NormalizedName string  `json:"normalized_name"`
+	Category       *string `json:"category,omitempty"`
+	// Nutrition 存于 nutrition_estimate 列
+	Nutrition *Nutrition `json:"nutrition,omitempty"`
+	Advice    string     `json:"advice,omitempty"`
+	ImageUrls []string   `json:"image_urls,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
 }
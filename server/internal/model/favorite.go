@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// 收藏类型。
+const (
+	FavoriteKindDish   = "dish"   // 识别/记录过的菜品，使用收藏时的条目快照
+	FavoriteKindFood   = "food"   // 自建食物或食物成分表条目
+	FavoriteKindRecipe = "recipe" // 用户菜谱，使用时按菜谱当前营养生成条目
+)
+
+// FavoriteItem 用户收藏，同一类型下按规范化名称去重。Item 为可直接写入 meal_record.items 的条目。
+type FavoriteItem struct {
+	ID             int64                  `json:"id"`
+	UserID         int64                  `json:"user_id"`
+	Kind           string                 `json:"kind"`
+	RefID          int64                  `json:"ref_id,omitempty"`
+	Name           string                 `json:"name"`
+	NormalizedName string                 `json:"-"`
+	Item           map[string]interface{} `json:"item,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+// FrequentItem 按用餐时段统计的常吃条目，由写入就餐记录时自动维护。
+type FrequentItem struct {
+	UserID         int64                  `json:"-"`
+	MealSlot       string                 `json:"meal_slot"`
+	Name           string                 `json:"name"`
+	NormalizedName string                 `json:"-"`
+	Item           map[string]interface{} `json:"item"`
+	Count          int                    `json:"count"`
+	LastLoggedAt   time.Time              `json:"last_logged_at"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Food 食物成分表条目，营养数据为每 100g 可食部。
+type Food struct {
+	ID             int64      `json:"id"`
+	Source         string     `json:"source"`
+	SourceID       string     `json:"source_id"`
+	Name           string     `json:"name"`
+	NormalizedName string     `json:"normalized_name"`
+	Aliases        []string   `json:"aliases,omitempty"`
+	Category       string     `json:"category,omitempty"`
+	Nutrition      *Nutrition `json:"nutrition"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
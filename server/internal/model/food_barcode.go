@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// FoodBarcode 包装食品条码（统一为 GTIN-13/GTIN-8/GTIN-14），数据来自 Open Food Facts 或按商品名的 AI 估算。
+type FoodBarcode struct {
+	Barcode   string     `json:"barcode"`
+	Name      string     `json:"name"`
+	Brand     string     `json:"brand,omitempty"`
+	Quantity  string     `json:"quantity,omitempty"`
+	Nutrition *Nutrition `json:"nutrition"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// FoodBarcodeCorrection 用户对条码数据的修正，按 (条码, 用户) 保存最新一次。
+type FoodBarcodeCorrection struct {
+	Barcode   string     `json:"barcode"`
+	UserID    int64      `json:"user_id"`
+	Name      string     `json:"name"`
+	Brand     string     `json:"brand,omitempty"`
+	Nutrition *Nutrition `json:"nutrition"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
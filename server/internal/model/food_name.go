@@ -0,0 +1,128 @@
+package model
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// foodSynonyms 常见食物别名到规范名称，规范化时替换，使“西红柿炒鸡蛋”与“番茄炒蛋”得到同一键。
+// 只收录含义完全相同的词，避免替换后与其他食物混淆。
+var foodSynonyms = map[string][]string{
+	"番茄":  {"西红柿", "洋柿子"},
+	"土豆":  {"马铃薯", "洋芋"},
+	"红薯":  {"地瓜", "番薯", "山芋", "红苕"},
+	"玉米":  {"苞米", "苞谷", "包谷", "玉蜀黍"},
+	"卷心菜": {"圆白菜", "包菜", "洋白菜", "莲花白"},
+	"西兰花": {"西蓝花", "青花菜", "绿菜花"},
+	"花菜":  {"花椰菜"},
+	"香菜":  {"芫荽"},
+	"空心菜": {"通菜", "蕹菜"},
+	"茄子":  {"矮瓜"},
+	"黄瓜":  {"青瓜"},
+	"南瓜":  {"倭瓜"},
+	"秋葵":  {"羊角豆"},
+	"豆芽":  {"豆芽菜"},
+	"木耳":  {"黑木耳"},
+	"猕猴桃": {"奇异果"},
+	"樱桃":  {"车厘子"},
+	"草莓":  {"士多啤梨"},
+	"牛油果": {"鳄梨"},
+	"三文鱼": {"鲑鱼"},
+	"金枪鱼": {"吞拿鱼"},
+	"鸡胸肉": {"鸡胸脯肉", "鸡脯肉"},
+	"五花肉": {"三层肉"},
+	"馄饨":  {"云吞", "抄手"},
+	"饺子":  {"水饺"},
+	"粥":   {"稀饭"},
+	"米饭":  {"白米饭"},
+	"汉堡":  {"汉堡包"},
+	"薯条":  {"炸薯条"},
+	"酸奶":  {"酸牛奶"},
+	"豆花":  {"豆腐脑"},
+	"炒蛋":  {"炒鸡蛋"},
+	"蒸蛋":  {"蒸鸡蛋", "鸡蛋羹"},
+	"煎蛋":  {"煎鸡蛋"},
+	"水煮蛋": {"水煮鸡蛋", "煮鸡蛋", "白煮蛋"},
+}
+
+var foodNameCleaner = regexp.MustCompile(`[\s\p{P}\p{S}]+`)
+
+// foodSynonymReplacer 别名按长度降序，较长的别名优先匹配。
+var foodSynonymReplacer = func() *strings.Replacer {
+	type pair struct{ alias, canonical string }
+	pairs := make([]pair, 0, len(foodSynonyms)*2)
+	for canonical, aliases := range foodSynonyms {
+		for _, alias := range aliases {
+			pairs = append(pairs, pair{alias, canonical})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if len(pairs[i].alias) != len(pairs[j].alias) {
+			return len(pairs[i].alias) > len(pairs[j].alias)
+		}
+		return pairs[i].alias < pairs[j].alias
+	})
+	args := make([]string, 0, len(pairs)*2)
+	for _, p := range pairs {
+		args = append(args, p.alias, p.canonical)
+	}
+	return strings.NewReplacer(args...)
+}()
+
+// NormalizeFoodName 菜品、食物成分表、自建食物共用的名称键：全角转半角、小写、去空白与标点、别名替换为规范名称。
+// 对结果再次调用结果不变。
+func NormalizeFoodName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			return ' '
+		case r >= '！' && r <= '～':
+			return r - 0xfee0
+		}
+		return r
+	}, name)
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return ""
+	}
+	name = foodNameCleaner.ReplaceAllString(name, "")
+	return foodSynonymReplacer.Replace(name)
+}
+
+// FoodNamePinyin 规范化名称的全拼与首字母（均为小写、无分隔）；字母数字原样保留。
+// 含字表外的汉字（如“鲮”“馕”）时返回空键，避免跳过后与其他名称的拼音误匹配。
+func FoodNamePinyin(normalized string) (string, string) {
+	var full, initials strings.Builder
+	for _, r := range normalized {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			full.WriteRune(r)
+			initials.WriteRune(r)
+			continue
+		}
+		py, ok := pinyinOf[r]
+		if !ok {
+			if unicode.Is(unicode.Han, r) {
+				return "", ""
+			}
+			continue
+		}
+		full.WriteString(py)
+		initials.WriteByte(py[0])
+	}
+	return full.String(), initials.String()
+}
+
+// IsPinyinQuery 输入只含字母数字时按拼音或首字母匹配。
+func IsPinyinQuery(normalized string) bool {
+	if normalized == "" {
+		return false
+	}
+	for _, r := range normalized {
+		if !((r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
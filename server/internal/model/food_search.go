@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+type FoodSearchLog struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    int64     `json:"user_id" db:"user_id"`
+	Query     string    `json:"query" db:"query"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Idempotency-Key 记录状态。
+const (
+	IdempotencyInFlight  = "in_flight"
+	IdempotencyCompleted = "completed"
+)
+
+// IdempotencyRecord 按 (用户, 路由, Idempotency-Key) 保存的首次响应。
+type IdempotencyRecord struct {
+	UserID         int64     `json:"user_id" db:"user_id"`
+	Route          string    `json:"route" db:"route"`
+	Key            string    `json:"key" db:"idem_key"`
+	RequestHash    string    `json:"request_hash" db:"request_hash"`
+	Status         string    `json:"status" db:"status"`
+	ResponseStatus int       `json:"response_status" db:"response_status"`
+	ContentType    string    `json:"content_type" db:"content_type"`
+	ResponseBody   []byte    `json:"-" db:"response_body"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at" db:"expires_at"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// ImageAsset 入库处理后的图片：原图不保留，只存去除 EXIF 的模型尺寸版本与缩略图。
+type ImageAsset struct {
+	ID          int64  `json:"id" db:"id"`
+	UserID      int64  `json:"user_id" db:"user_id"`
+	ContentHash string `json:"content_hash" db:"content_hash"`
+	Category    string `json:"category" db:"category"`
+	SourceMime  string `json:"source_mime" db:"source_mime"`
+	SourceBytes int64  `json:"source_bytes" db:"source_bytes"`
+	Width       int    `json:"width" db:"width"`
+	Height      int    `json:"height" db:"height"`
+	ModelKey    string `json:"model_key" db:"model_key"`
+	ModelWidth  int    `json:"model_width" db:"model_width"`
+	ModelHeight int    `json:"model_height" db:"model_height"`
+	ThumbKey    string `json:"thumb_key" db:"thumb_key"`
+	// PHash 模型版本的 64 位差值哈希，用于识别近似重复图片；0 表示未计算
+	PHash int64 `json:"phash,omitempty" db:"phash"`
+	// Quality 入库时计算的清晰度与曝光指标，旧记录为空
+	Quality   *ImageQuality `json:"quality,omitempty" db:"quality"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
+
+// ImageQuality 图片质量指标，基于缩小到最长边 512 的灰度图计算。
+type ImageQuality struct {
+	// Sharpness 拉普拉斯方差，越小越模糊
+	Sharpness float64 `json:"sharpness"`
+	// Brightness 平均亮度（0-255）
+	Brightness float64 `json:"brightness"`
+	// DarkRatio/BrightRatio 接近纯黑/纯白的像素占比
+	DarkRatio   float64 `json:"dark_ratio"`
+	BrightRatio float64 `json:"bright_ratio"`
+}
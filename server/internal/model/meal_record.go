@@ -6,22 +6,27 @@ import (
 )
 
 type MealRecord struct {
-	ID         int64           `json:"id" db:"id"`
-	UserID     int64           `json:"user_id" db:"user_id"`
-	Source     string          `json:"source" db:"source"`
-	Items      json.RawMessage `json:"items" db:"items"`
-	ImageUrls  json.RawMessage `json:"image_urls,omitempty" db:"image_urls"`
-	Ratings    json.RawMessage `json:"ratings,omitempty" db:"ratings"`
-	Meta       json.RawMessage `json:"meta,omitempty" db:"meta"`
-	RecordedAt time.Time       `json:"recorded_at" db:"recorded_at"`
-	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+	ID        int64           `json:"id" db:"id"`
+	UserID    int64           `json:"user_id" db:"user_id"`
+	Source    string          `json:"source" db:"source"`
+	Items     json.RawMessage `json:"items" db:"items"`
+	ImageUrls json.RawMessage `json:"image_urls,omitempty" db:"image_urls"`
+	// ImageAssetIDs 引用的 image_asset，image_urls 中对应为模型尺寸版本的对象 key
+	ImageAssetIDs []int64         `json:"image_asset_ids,omitempty" db:"image_asset_ids"`
+	Ratings       json.RawMessage `json:"ratings,omitempty" db:"ratings"`
+	Meta          json.RawMessage `json:"meta,omitempty" db:"meta"`
+	// QuotaExempt 命中识别缓存且不计费，不计入每日额度
+	QuotaExempt bool      `json:"quota_exempt,omitempty" db:"quota_exempt"`
+	RecordedAt  time.Time `json:"recorded_at" db:"recorded_at"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
 type MealRecordCreateRequest struct {
-	Source     string          `json:"source"`
-	Items      json.RawMessage `json:"items"`
-	ImageUrls  []string        `json:"image_urls,omitempty"`
-	Ratings    json.RawMessage `json:"ratings,omitempty"`
-	Meta       json.RawMessage `json:"meta,omitempty"`
-	RecordedAt *time.Time      `json:"recorded_at,omitempty"`
+	Source        string          `json:"source"`
+	Items         json.RawMessage `json:"items"`
+	ImageUrls     []string        `json:"image_urls,omitempty"`
+	ImageAssetIDs []int64         `json:"image_asset_ids,omitempty"`
+	Ratings       json.RawMessage `json:"ratings,omitempty"`
+	Meta          json.RawMessage `json:"meta,omitempty"`
+	RecordedAt    *time.Time      `json:"recorded_at,omitempty"`
 }
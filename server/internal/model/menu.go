@@ -6,6 +6,7 @@ type MenuParseRequest struct {
 
 type MenuScanRequest struct {
 	ImageUrls      []string `json:"image_urls"`
+	ImageAssetIDs  []int64  `json:"image_asset_ids,omitempty"`
 	RestaurantHint string   `json:"restaurant_hint,omitempty"`
 	ClientTime     string   `json:"client_time,omitempty"`
 	Note           string   `json:"note,omitempty"`
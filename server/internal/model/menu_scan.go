@@ -10,8 +10,11 @@ type MenuScan struct {
 	UserID         int64           `json:"user_id" db:"user_id"`
 	RawImageURL    *string         `json:"raw_image_url,omitempty" db:"raw_image_url"`
 	RawImageURLs   json.RawMessage `json:"raw_image_urls,omitempty" db:"raw_image_urls"`
+	ImageAssetIDs  []int64         `json:"image_asset_ids,omitempty" db:"image_asset_ids"`
 	OCRText        string          `json:"ocr_text" db:"ocr_text"`
 	ParsedMenu     json.RawMessage `json:"parsed_menu" db:"parsed_menu"`
 	RestaurantHint *string         `json:"restaurant_hint,omitempty" db:"restaurant_hint"`
-	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	// QuotaExempt 命中识别缓存且不计费，不计入每日额度
+	QuotaExempt bool      `json:"quota_exempt,omitempty" db:"quota_exempt"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
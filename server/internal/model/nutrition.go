@@ -0,0 +1,301 @@
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 营养数据来源。
+const (
+	NutritionSourceAIVision = "ai_vision" // 菜单/食物照片识别估算
+	NutritionSourceAISearch = "ai_search" // 食物搜索由模型生成
+	NutritionSourceAIPlan   = "ai_plan"   // 发现页/周菜单生成
+	NutritionSourceUser     = "user"      // 用户录入或修正
+	NutritionSourceLegacy   = "legacy"    // 旧数据迁移，来源未知
+	NutritionSourceCFCT     = "cfct"      // 中国食物成分表
+	NutritionSourceUSDA     = "usda_fdc"  // USDA FoodData Central
+	NutritionSourceOFF      = "off"       // Open Food Facts 包装食品标签
+	NutritionSourceLabel    = "label"     // 拍照识别的包装营养成分表
+	NutritionSourceRecipe   = "recipe"    // 用户菜谱按食材合计
+)
+
+// 各来源的默认可信度（0-1），数据本身给出时以数据为准。
+var defaultNutritionConfidence = map[string]float64{
+	NutritionSourceAIVision: 0.5,
+	NutritionSourceAISearch: 0.6,
+	NutritionSourceAIPlan:   0.5,
+	NutritionSourceUser:     0.8,
+	NutritionSourceLegacy:   0.4,
+	NutritionSourceCFCT:     0.95,
+	NutritionSourceUSDA:     0.95,
+	NutritionSourceOFF:      0.85,
+	NutritionSourceLabel:    0.9,
+	NutritionSourceRecipe:   0.8,
+}
+
+// IsAuthoritativeNutrition 来自公开食物成分表，优先于 AI 估算。
+func IsAuthoritativeNutrition(source string) bool {
+	return source == NutritionSourceCFCT || source == NutritionSourceUSDA
+}
+
+// DefaultNutritionConfidence 来源的默认可信度，未知来源为 0。
+func DefaultNutritionConfidence(source string) float64 {
+	return defaultNutritionConfidence[source]
+}
+
+// NutritionFacts 一个计量基准下的营养素，未知按 0 处理。
+type NutritionFacts struct {
+	EnergyKcal    float64 `json:"energy_kcal"`
+	ProteinG      float64 `json:"protein_g"`
+	FatG          float64 `json:"fat_g"`
+	SaturatedFatG float64 `json:"saturated_fat_g,omitempty"`
+	CarbsG        float64 `json:"carbs_g"`
+	SugarG        float64 `json:"sugar_g,omitempty"`
+	AddedSugarG   float64 `json:"added_sugar_g,omitempty"`
+	FiberG        float64 `json:"fiber_g,omitempty"`
+	SodiumMg      float64 `json:"sodium_mg,omitempty"`
+}
+
+// IsZero 所有营养素均未知。
+func (f NutritionFacts) IsZero() bool {
+	return f == NutritionFacts{}
+}
+
+// Scale 按比例换算，保留一位小数。
+func (f NutritionFacts) Scale(factor float64) NutritionFacts {
+	return NutritionFacts{
+		EnergyKcal:    roundNutrient(f.EnergyKcal * factor),
+		ProteinG:      roundNutrient(f.ProteinG * factor),
+		FatG:          roundNutrient(f.FatG * factor),
+		SaturatedFatG: roundNutrient(f.SaturatedFatG * factor),
+		CarbsG:        roundNutrient(f.CarbsG * factor),
+		SugarG:        roundNutrient(f.SugarG * factor),
+		AddedSugarG:   roundNutrient(f.AddedSugarG * factor),
+		FiberG:        roundNutrient(f.FiberG * factor),
+		SodiumMg:      roundNutrient(f.SodiumMg * factor),
+	}
+}
+
+// Add 累加，用于合计多份食物。
+func (f NutritionFacts) Add(other NutritionFacts) NutritionFacts {
+	return NutritionFacts{
+		EnergyKcal:    roundNutrient(f.EnergyKcal + other.EnergyKcal),
+		ProteinG:      roundNutrient(f.ProteinG + other.ProteinG),
+		FatG:          roundNutrient(f.FatG + other.FatG),
+		SaturatedFatG: roundNutrient(f.SaturatedFatG + other.SaturatedFatG),
+		CarbsG:        roundNutrient(f.CarbsG + other.CarbsG),
+		SugarG:        roundNutrient(f.SugarG + other.SugarG),
+		AddedSugarG:   roundNutrient(f.AddedSugarG + other.AddedSugarG),
+		FiberG:        roundNutrient(f.FiberG + other.FiberG),
+		SodiumMg:      roundNutrient(f.SodiumMg + other.SodiumMg),
+	}
+}
+
+// Nutrition 菜品/食物的营养数据。Per100g 与 PerServing 至少有一个；
+// 两者只有其一时可通过 ServingSizeG 互相换算。
+type Nutrition struct {
+	Per100g      *NutritionFacts `json:"per_100g,omitempty"`
+	PerServing   *NutritionFacts `json:"per_serving,omitempty"`
+	ServingSizeG float64         `json:"serving_size_g,omitempty"`
+	// Confidence 数据可信度 0-1
+	Confidence float64 `json:"confidence"`
+	Source     string  `json:"source"`
+}
+
+// IsEmpty 没有任何营养数值。
+func (n *Nutrition) IsEmpty() bool {
+	if n == nil {
+		return true
+	}
+	return (n.Per100g == nil || n.Per100g.IsZero()) && (n.PerServing == nil || n.PerServing.IsZero())
+}
+
+// Serving 每份营养；只有每 100g 数据时按份量换算，无法得出时 ok 为 false。
+func (n *Nutrition) Serving() (NutritionFacts, bool) {
+	if n == nil {
+		return NutritionFacts{}, false
+	}
+	if n.PerServing != nil && !n.PerServing.IsZero() {
+		return *n.PerServing, true
+	}
+	if n.Per100g != nil && n.ServingSizeG > 0 {
+		return n.Per100g.Scale(n.ServingSizeG / 100), true
+	}
+	return NutritionFacts{}, false
+}
+
+// Per100 每 100g 营养；只有每份数据时按份量换算，无法得出时 ok 为 false。
+func (n *Nutrition) Per100() (NutritionFacts, bool) {
+	if n == nil {
+		return NutritionFacts{}, false
+	}
+	if n.Per100g != nil && !n.Per100g.IsZero() {
+		return *n.Per100g, true
+	}
+	if n.PerServing != nil && n.ServingSizeG > 0 {
+		return n.PerServing.Scale(100 / n.ServingSizeG), true
+	}
+	return NutritionFacts{}, false
+}
+
+// ForGrams 指定克数的营养，优先按每 100g 换算。
+func (n *Nutrition) ForGrams(grams float64) (NutritionFacts, bool) {
+	per100, ok := n.Per100()
+	if !ok || grams <= 0 {
+		return NutritionFacts{}, false
+	}
+	return per100.Scale(grams / 100), true
+}
+
+// Complete 返回补全两种基准后的副本（能换算时），便于客户端直接展示。
+func (n *Nutrition) Complete() *Nutrition {
+	if n == nil {
+		return nil
+	}
+	out := *n
+	if facts, ok := n.Per100(); ok && out.Per100g == nil {
+		out.Per100g = &facts
+	}
+	if facts, ok := n.Serving(); ok && out.PerServing == nil {
+		out.PerServing = &facts
+	}
+	return &out
+}
+
+// Merge 用 other 中已有的基准覆盖当前数据，other 没有的基准保留。
+func (n *Nutrition) Merge(other *Nutrition) *Nutrition {
+	if n == nil {
+		return other
+	}
+	if other == nil {
+		return n
+	}
+	merged := *n
+	if other.Per100g != nil {
+		merged.Per100g = other.Per100g
+	}
+	if other.PerServing != nil {
+		merged.PerServing = other.PerServing
+	}
+	if other.ServingSizeG > 0 {
+		merged.ServingSizeG = other.ServingSizeG
+	}
+	merged.Confidence = other.Confidence
+	merged.Source = other.Source
+	return &merged
+}
+
+// NutritionFromMap 从 AI 输出或旧版 nutrition_estimate 的 map 中读取营养数据：
+// kcal/calories/protein/carbs/fat 等视为每份，*_per100g 视为每 100g。没有任何数值时返回 nil。
+func NutritionFromMap(values map[string]interface{}, source string) *Nutrition {
+	if values == nil {
+		return nil
+	}
+	n := &Nutrition{Source: source}
+	serving := NutritionFacts{
+		EnergyKcal:    nutritionValue(values, "kcal", "calories", "energy", "energy_kcal"),
+		ProteinG:      nutritionValue(values, "protein", "protein_g"),
+		FatG:          nutritionValue(values, "fat", "fat_g"),
+		SaturatedFatG: nutritionValue(values, "saturated_fat", "saturated_fat_g", "sat_fat"),
+		CarbsG:        nutritionValue(values, "carbs", "carb", "carbohydrates", "carbs_g"),
+		SugarG:        nutritionValue(values, "sugar", "sugar_g", "sugars"),
+		AddedSugarG:   nutritionValue(values, "added_sugar", "added_sugar_g", "added_sugars"),
+		FiberG:        nutritionValue(values, "fiber", "fiber_g", "dietary_fiber"),
+		SodiumMg:      nutritionValue(values, "sodium", "sodium_mg"),
+	}
+	if !serving.IsZero() {
+		n.PerServing = &serving
+	}
+	per100 := NutritionFacts{
+		EnergyKcal:    nutritionValue(values, "calories_kcal_per100g", "energy_kcal_per100g"),
+		ProteinG:      nutritionValue(values, "protein_g_per100g"),
+		FatG:          nutritionValue(values, "fat_g_per100g"),
+		SaturatedFatG: nutritionValue(values, "saturated_fat_g_per100g"),
+		CarbsG:        nutritionValue(values, "carbs_g_per100g"),
+		SugarG:        nutritionValue(values, "sugar_g_per100g"),
+		AddedSugarG:   nutritionValue(values, "added_sugar_g_per100g"),
+		FiberG:        nutritionValue(values, "fiber_g_per100g"),
+		SodiumMg:      nutritionValue(values, "sodium_mg_per100g"),
+	}
+	if !per100.IsZero() {
+		n.Per100g = &per100
+	}
+	n.ServingSizeG = nutritionValue(values, "serving_size_g", "weight_g", "grams")
+	n.Confidence = nutritionValue(values, "confidence")
+	if n.Confidence <= 0 || n.Confidence > 1 {
+		n.Confidence = DefaultNutritionConfidence(source)
+	}
+	if n.IsEmpty() {
+		return nil
+	}
+	return n
+}
+
+// ParseNutrition 读取 nutrition_estimate 列：新格式直接解析，旧格式（整份 AI 菜品 map）按 NutritionFromMap 转换。
+func ParseNutrition(raw []byte) (*Nutrition, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, err
+	}
+	if !IsTypedNutrition(values) {
+		return NutritionFromMap(values, NutritionSourceLegacy), nil
+	}
+	var n Nutrition
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return nil, err
+	}
+	if n.IsEmpty() {
+		return nil, nil
+	}
+	return &n, nil
+}
+
+// IsTypedNutrition 是否已是 Nutrition 格式。
+func IsTypedNutrition(values map[string]interface{}) bool {
+	_, per100 := values["per_100g"]
+	_, serving := values["per_serving"]
+	return per100 || serving
+}
+
+var nutritionNumberPattern = regexp.MustCompile(`-?\d+(?:\.\d+)?`)
+
+// NutrientValue 按 keys 顺序取第一个大于 0 的数值，兼容数字与 "450kcal" 形式的字符串。
+func NutrientValue(values map[string]interface{}, keys ...string) float64 {
+	return nutritionValue(values, keys...)
+}
+
+func nutritionValue(values map[string]interface{}, keys ...string) float64 {
+	for _, key := range keys {
+		raw, ok := values[key]
+		if !ok {
+			continue
+		}
+		var value float64
+		switch v := raw.(type) {
+		case float64:
+			value = v
+		case int:
+			value = float64(v)
+		case int64:
+			value = float64(v)
+		case json.Number:
+			value, _ = v.Float64()
+		case string:
+			value, _ = strconv.ParseFloat(nutritionNumberPattern.FindString(strings.TrimSpace(v)), 64)
+		}
+		if value > 0 && !math.IsInf(value, 0) {
+			return value
+		}
+	}
+	return 0
+}
+
+func roundNutrient(value float64) float64 {
+	return math.Round(value*10) / 10
+}
@@ -0,0 +1,349 @@
+package model
+
+import "strings"
+
+// pinyinTable 食物名称常用汉字的拼音（不带声调），多音字取食物语境中的读音。
+const pinyinTable = `
+a 阿啊
+ai 艾爱
+an 安鹌氨
+ao 奥澳熬
+ba 八巴芭吧把霸拔粑鲅
+bai 白百柏摆
+ban 板半拌瓣斑版
+bang 棒帮蚌膀
+bao 包宝保饱爆煲鲍豹抱堡
+bei 北贝杯焙被背
+ben 本
+bi 比碧荸鼻笔必壁
+bian 扁边便鞭变煸
+biao 标膘
+bie 鳖别
+bin 槟滨
+bing 冰饼病并兵
+bo 菠波博拨钵脖薄
+bu 布不补卜部步
+cai 菜彩财
+can 蚕餐残
+cang 仓苍
+cao 草糙槽
+cha 茶叉插查
+chai 柴
+chan 蝉馋缠产
+chang 长肠常场尝厂鲳
+chao 炒潮朝超巢焯
+che 车
+chen 陈沉辰晨
+cheng 橙成城乘程秤蛏
+chi 吃池尺翅赤齿匙豉
+chong 虫冲重
+chou 臭抽稠
+chu 出初除厨楚储
+chuan 川串穿船传
+chun 春纯醇唇莼
+ci 次刺瓷茨慈糍
+cong 葱从丛
+cu 醋粗簇
+cuan 汆
+cui 脆翠崔
+cun 村寸
+cuo 搓错
+da 大打达答
+dai 带代袋黛
+dan 蛋单丹淡担胆弹旦
+dang 当党
+dao 刀岛稻道倒捣
+de 德得的
+deng 灯登等
+di 地底滴帝第笛
+dian 点甸电店滇
+diao 吊雕鲷
+die 碟蝶叠
+ding 丁钉顶鼎定
+dong 冬东洞冻动
+dou 豆斗兜抖
+du 肚杜独毒都督渡
+duan 段短断
+dui 对堆
+dun 炖墩盾顿蹲
+duo 多朵剁
+e 鹅额饿鄂
+er 二耳儿尔
+fa 发法
+fan 饭番翻凡范帆反
+fang 方芳房坊防放
+fei 肥飞非菲翡啡肺
+fen 粉分份芬
+feng 风蜂凤峰丰锋
+fo 佛
+fu 腐福扶伏芙浮富附麸府釜斧覆夫付腹脯
+gai 盖
+gan 干甘肝柑橄赶感
+gang 港岗钢
+gao 糕高膏
+ge 鸽葛格各哥歌阁割隔蛤
+gen 根跟
+geng 羹梗耕
+gong 宫公工贡功
+gou 狗枸钩沟
+gu 骨谷菇鼓古姑固股故
+gua 瓜刮挂
+guai 怪拐
+guan 罐管关馆灌冠
+guang 光广
+gui 桂鳜贵龟规鲑
+gun 滚棍
+guo 果锅国裹过郭粿
+ha 哈
+hai 海孩
+han 汉寒韩含汗
+hang 杭
+hao 蚝好豪号蒿
+he 荷核和盒河合禾褐
+hei 黑
+hong 红烘洪虹
+hou 猴厚后候
+hu 胡糊湖虎葫壶互护
+hua 花滑华化画
+huai 槐怀淮
+huan 欢环
+huang 黄皇凰
+hui 烩茴灰回会徽汇
+hun 馄荤浑
+huo 火藿活货霍
+ji 鸡鲫及吉基姬荠积几季即脊寄纪急集稷
+jia 家加夹佳嘉甲价假
+jian 煎尖剑碱见件茧坚健间简腱
+jiang 姜酱江豇浆将
+jiao 饺椒角胶焦交脚搅叫茭蕉浇
+jie 芥节结街洁姐
+jin 金筋紧锦津斤进近
+jing 京精晶井净经荆
+jiu 酒韭九久旧
+ju 菊橘局桔句巨居聚举焗
+juan 卷
+jue 蕨决
+jun 菌君
+ka 咖卡
+kai 开凯
+kang 康抗
+kao 烤靠考
+ke 可科壳克客稞
+kou 口扣寇蔻
+ku 苦库酷
+kuai 块快筷
+kuan 宽
+kui 葵魁
+kun 昆
+la 辣腊拉蜡
+lai 莱来
+lan 蓝兰榄烂篮
+lang 榔郎浪
+lao 老捞烙酪醪
+le 乐
+lei 蕾雷肋类
+leng 冷
+li 梨荔栗李里鲤利力蜊粒厘黎丽理蛎藜喱
+lian 莲连链脸鲢
+liang 凉梁粱两量亮
+liao 料辽
+lie 烈裂
+lin 林淋鳞
+ling 菱灵零岭铃苓
+liu 榴六柳流溜留刘熘
+long 龙笼隆
+lou 楼
+lu 卤芦鹿路陆露鲁炉鲈
+lv 绿驴
+luan 卵
+lun 轮
+luo 萝螺落骆罗
+ma 麻马妈码蚂
+mai 麦卖买脉
+man 馒鳗蔓满曼
+mang 芒忙
+mao 毛猫帽茅冒
+mei 梅美煤莓眉每
+men 焖门闷
+meng 蒙萌檬
+mi 米蜜迷秘密猕
+mian 面棉免
+miao 苗秒妙
+min 民闽
+ming 明名
+mo 蘑磨末膜沫墨摸馍魔
+mu 木母牧目
+na 拿纳那
+nai 奶乃
+nan 南难腩
+nao 脑
+nen 嫩
+ni 泥尼腻
+nian 年粘黏鲶
+niang 酿
+niao 鸟
+ning 柠宁
+niu 牛纽扭
+nong 浓农
+nuo 糯
+nv 女
+ou 藕欧
+pa 扒爬杷
+pai 排派拍
+pan 盘攀
+pang 胖螃
+pao 泡炮跑
+pei 培配
+pen 盆喷
+peng 蓬棚朋膨
+pi 皮啤枇琵劈披
+pian 片
+piao 漂飘
+pin 品拼
+ping 苹平瓶
+po 婆破泼坡
+pu 葡蒲铺普浦朴扑
+qi 七其奇芪起气漆齐汽骑杞淇妻
+qian 千芡钱前浅谦签
+qiang 枪墙强炝
+qiao 荞巧桥翘
+qie 茄切
+qin 芹琴秦亲勤
+qing 青清晴庆轻
+qiu 秋球丘
+qu 曲去趣区
+quan 全泉拳圈
+que 雀
+qun 裙群
+ran 燃然
+re 热
+ren 人仁
+rong 茸蓉容绒
+rou 肉揉
+ru 乳如儒
+ruan 软
+rui 瑞蕊
+run 润
+ruo 弱若
+sa 萨撒洒
+sai 赛
+san 三伞散
+sang 桑
+sao 臊扫
+se 色涩
+sha 沙砂鲨杀纱
+shai 晒筛
+shan 山扇杉善鳝珊
+shang 上商
+shao 烧勺少芍韶
+she 蛇舌社
+shen 参深神申身
+sheng 生圣胜盛笙
+shi 石柿食时十狮师湿诗实士市式事
+shou 手寿首兽瘦
+shu 薯蔬熟鼠黍叔书树数属
+shuan 涮
+shuang 双霜爽
+shui 水
+shun 顺
+si 丝四司斯寺蛳撕
+song 松宋送
+su 素酥苏粟速
+suan 蒜酸算
+sui 碎穗岁
+sun 笋孙
+suo 蓑所锁
+ta 塔他挞
+tai 台太泰苔
+tan 炭滩坛谈
+tang 汤糖塘唐堂烫
+tao 桃陶淘套萄
+te 特
+teng 藤腾
+ti 蹄提梯
+tian 甜田天填
+tiao 条调跳
+tie 铁贴
+ting 亭挺
+tong 桐通铜筒同童桶茼
+tou 头透
+tu 土兔吐涂突
+tuan 团
+tui 腿
+tun 吞豚屯饨
+tuo 托驼
+wa 蛙娃瓦挖
+wan 丸碗湾晚豌万
+wang 王旺网
+wei 味威维薇尾喂卫煨
+wen 温文纹
+weng 蕹
+wo 窝莴蜗我
+wu 五乌午雾无梧
+xi 西稀溪锡喜细息悉
+xia 虾夏霞下
+xian 鲜咸馅仙线苋现蚬
+xiang 香乡湘相象箱
+xiao 小肖笑消晓宵削
+xie 蟹薤鞋
+xin 心新芯辛
+xing 杏星兴
+xiong 熊胸雄
+xiu 秀
+xu 絮须徐
+xue 雪鳕血学
+xun 熏荀蕈
+ya 鸭芽牙压丫
+yan 盐燕烟岩腌颜艳眼
+yang 羊杨洋阳养扬
+yao 腰瑶摇药
+ye 椰叶夜野
+yi 一薏意宜伊怡蚁
+yin 银印饮
+ying 樱鹰营
+you 油柚鱿有友
+yu 鱼玉芋榆渔雨语域
+yuan 圆元原园源
+yue 月粤越
+yun 云芸
+za 杂
+zai 仔
+zao 枣早灶糟
+zha 炸榨扎渣闸
+zhai 斋
+zhan 沾
+zhang 章樟掌
+zhao 照
+zhe 蔗浙折
+zhen 珍针榛真胗
+zheng 蒸正
+zhi 芝汁脂枝纸之治
+zhong 中种
+zhou 粥州周肘
+zhu 猪竹煮珠烛
+zhua 爪抓
+zhuan 砖
+zhuang 装撞
+zi 紫子籽孜
+zong 棕粽
+zui 醉
+zun 鳟
+zuo 作
+`
+
+// pinyinOf 由 pinyinTable 生成的汉字到拼音映射。
+var pinyinOf = func() map[rune]string {
+	m := make(map[rune]string, 1024)
+	for _, line := range strings.Split(pinyinTable, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		for _, r := range fields[1] {
+			if _, ok := m[r]; !ok {
+				m[r] = fields[0]
+			}
+		}
+	}
+	return m
+}()
@@ -0,0 +1,155 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 份量单位。
+const (
+	UnitGram       = "g"
+	UnitMilliliter = "ml"
+	UnitBowl       = "碗"
+	UnitPiece      = "个"
+	UnitSlice      = "片"
+	UnitServing    = "份"
+	UnitSpoon      = "勺"
+)
+
+// PortionUnits 客户端可选的单位，按常用程度排列。
+var PortionUnits = []string{UnitGram, UnitBowl, UnitPiece, UnitSlice, UnitServing, UnitSpoon, UnitMilliliter}
+
+// unitAliases 输入中的单位写法到标准单位。
+var unitAliases = map[string]string{
+	"g": UnitGram, "克": UnitGram, "gram": UnitGram, "grams": UnitGram,
+	"ml": UnitMilliliter, "毫升": UnitMilliliter,
+	"碗": UnitBowl, "bowl": UnitBowl,
+	"个": UnitPiece, "只": UnitPiece, "颗": UnitPiece, "枚": UnitPiece, "根": UnitPiece, "piece": UnitPiece,
+	"片": UnitSlice, "slice": UnitSlice,
+	"份": UnitServing, "盘": UnitServing, "serving": UnitServing,
+	"勺": UnitSpoon, "汤匙": UnitSpoon, "汤勺": UnitSpoon, "tbsp": UnitSpoon,
+}
+
+// defaultUnitGrams 没有食物专属数据时每单位的克数；ml 按密度 1 计，份 优先使用菜品自身份量。
+var defaultUnitGrams = map[string]float64{
+	UnitGram:       1,
+	UnitMilliliter: 1,
+	UnitBowl:       200,
+	UnitPiece:      100,
+	UnitSlice:      30,
+	UnitServing:    250,
+	UnitSpoon:      10,
+}
+
+// foodUnitGrams 常见食物每单位的克数（可食部），key 为 NormalizeFoodName 后的名称；ml 为密度（g/ml）。
+var foodUnitGrams = map[string]map[string]float64{
+	"米饭":   {UnitBowl: 150},
+	"粥":    {UnitBowl: 250},
+	"小米粥":  {UnitBowl: 250},
+	"面条":   {UnitBowl: 300},
+	"汤":    {UnitBowl: 250},
+	"馄饨":   {UnitBowl: 300, UnitPiece: 15},
+	"饺子":   {UnitPiece: 20},
+	"鸡蛋":   {UnitPiece: 50},
+	"水煮蛋":  {UnitPiece: 50},
+	"茶叶蛋":  {UnitPiece: 50},
+	"煎蛋":   {UnitPiece: 55},
+	"馒头":   {UnitPiece: 100},
+	"包子":   {UnitPiece: 80},
+	"花卷":   {UnitPiece: 80},
+	"油条":   {UnitPiece: 70},
+	"烧饼":   {UnitPiece: 90},
+	"苹果":   {UnitPiece: 180},
+	"香蕉":   {UnitPiece: 120},
+	"橙子":   {UnitPiece: 180},
+	"梨":    {UnitPiece: 200},
+	"猕猴桃":  {UnitPiece: 80},
+	"番茄":   {UnitPiece: 150},
+	"土豆":   {UnitPiece: 150},
+	"红薯":   {UnitPiece: 200},
+	"玉米":   {UnitPiece: 150},
+	"面包":   {UnitSlice: 35},
+	"吐司":   {UnitSlice: 35},
+	"全麦面包": {UnitSlice: 35},
+	"芝士":   {UnitSlice: 20},
+	"奶酪":   {UnitSlice: 20},
+	"火腿":   {UnitSlice: 15},
+	"培根":   {UnitSlice: 15},
+	"饼干":   {UnitSlice: 8},
+	"牛奶":   {UnitMilliliter: 1.03},
+	"酸奶":   {UnitMilliliter: 1.05},
+	"豆浆":   {UnitMilliliter: 1.02},
+	"食用油":  {UnitSpoon: 10, UnitMilliliter: 0.92},
+	"花生油":  {UnitSpoon: 10, UnitMilliliter: 0.92},
+	"橄榄油":  {UnitSpoon: 10, UnitMilliliter: 0.92},
+	"蜂蜜":   {UnitSpoon: 20, UnitMilliliter: 1.4},
+	"白糖":   {UnitSpoon: 12},
+	"酱油":   {UnitSpoon: 15, UnitMilliliter: 1.15},
+	"花生酱":  {UnitSpoon: 16},
+}
+
+// NormalizeUnit 标准单位，无法识别时返回 false。
+func NormalizeUnit(raw string) (string, bool) {
+	unit, ok := unitAliases[strings.ToLower(strings.TrimSpace(raw))]
+	return unit, ok
+}
+
+// UnitGrams 食物每单位的克数：份 优先取 servingSizeG，其次是常见食物数据与通用默认值。
+func UnitGrams(normalizedName string, unit string, servingSizeG float64) float64 {
+	if unit == UnitServing && servingSizeG > 0 {
+		return servingSizeG
+	}
+	if grams, ok := foodUnitGrams[normalizedName][unit]; ok {
+		return grams
+	}
+	return defaultUnitGrams[unit]
+}
+
+// HasFoodUnit 常见食物数据中是否收录了该单位（g/ml/份 总是可用）。
+func HasFoodUnit(normalizedName string, unit string) bool {
+	_, ok := foodUnitGrams[normalizedName][unit]
+	return ok
+}
+
+var chineseNumbers = map[string]float64{
+	"半": 0.5, "一": 1, "两": 2, "二": 2, "三": 3, "四": 4, "五": 5, "六": 6, "七": 7, "八": 8, "九": 9, "十": 10,
+}
+
+// ParsePortion 解析“1碗”“半碗”“两个”“150g”“1.5 份”等份量描述。
+func ParsePortion(text string) (float64, string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, "", false
+	}
+	idx := 0
+	for idx < len(text) && (text[idx] == '.' || (text[idx] >= '0' && text[idx] <= '9')) {
+		idx++
+	}
+	var quantity float64
+	rest := text[idx:]
+	if idx > 0 {
+		parsed, err := strconv.ParseFloat(text[:idx], 64)
+		if err != nil {
+			return 0, "", false
+		}
+		quantity = parsed
+	} else {
+		for word, value := range chineseNumbers {
+			if strings.HasPrefix(text, word) {
+				quantity = value
+				rest = strings.TrimPrefix(text, word)
+				break
+			}
+		}
+		// “一碗半”
+		if strings.HasSuffix(rest, "半") && quantity > 0 {
+			rest = strings.TrimSuffix(rest, "半")
+			quantity += 0.5
+		}
+	}
+	unit, ok := NormalizeUnit(rest)
+	if !ok || quantity <= 0 {
+		return 0, "", false
+	}
+	return quantity, unit, true
+}
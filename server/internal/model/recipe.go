@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// 菜谱来源。
+const (
+	RecipeSourceManual   = "manual"   // 用户录入
+	RecipeSourceDiscover = "discover" // 从发现页餐食卡片导入
+)
+
+// RecipeIngredient 菜谱食材。Grams 由 Quantity 与 Unit 换算；Resolved 为 false 表示未在食物数据中找到，
+// 不计入营养合计。
+type RecipeIngredient struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity,omitempty"`
+	Unit     string  `json:"unit,omitempty"`
+	Grams    float64 `json:"grams,omitempty"`
+	Resolved bool    `json:"resolved"`
+	// Source 营养数据来源：custom（自建食物）或 NutritionSource*
+	Source string `json:"source,omitempty"`
+	// Nutrition 该用量的营养
+	Nutrition *NutritionFacts `json:"nutrition,omitempty"`
+}
+
+// Recipe 用户菜谱，Nutrition 为每份营养（PerServing），ServingSizeG 为每份克数（食材克数均已知时）。
+type Recipe struct {
+	ID           int64              `json:"id"`
+	UserID       int64              `json:"user_id"`
+	Title        string             `json:"title"`
+	Servings     float64            `json:"servings"`
+	Ingredients  []RecipeIngredient `json:"ingredients"`
+	Instructions string             `json:"instructions,omitempty"`
+	Nutrition    *Nutrition         `json:"nutrition,omitempty"`
+	ImageAssetID int64              `json:"image_asset_id,omitempty"`
+	ImageKey     string             `json:"image_key,omitempty"`
+	Source       string             `json:"source"`
+	// SourceRef 导入来源的卡片 id
+	SourceRef string    `json:"source_ref,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
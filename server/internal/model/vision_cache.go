@@ -0,0 +1,20 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// VisionCacheEntry 视觉识别结果缓存，按 (用户, 路由, 图片集合哈希, 提示词版本, 上下文指纹) 唯一。
+type VisionCacheEntry struct {
+	ID                 int64           `json:"id" db:"id"`
+	UserID             int64           `json:"user_id" db:"user_id"`
+	Route              string          `json:"route" db:"route"`
+	ImageKey           string          `json:"image_key" db:"image_key"`
+	ImagePHashes       []int64         `json:"image_phashes,omitempty" db:"image_phashes"`
+	PromptVersion      string          `json:"prompt_version" db:"prompt_version"`
+	ContextFingerprint string          `json:"context_fingerprint" db:"context_fingerprint"`
+	Result             json.RawMessage `json:"result" db:"result"`
+	HitCount           int             `json:"hit_count" db:"hit_count"`
+	CreatedAt          time.Time       `json:"created_at" db:"created_at"`
+}
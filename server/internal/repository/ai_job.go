@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type AIJobRepository struct {
+	db *sql.DB
+}
+
+func NewAIJobRepository(db *sql.DB) *AIJobRepository {
+	return &AIJobRepository{db: db}
+}
+
+func (r *AIJobRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS ai_job (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			kind VARCHAR(40) NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'queued',
+			payload JSONB NOT NULL,
+			result JSONB,
+			error_code VARCHAR(60),
+			error_message TEXT,
+			attempts INT NOT NULL DEFAULT 0,
+			max_attempts INT NOT NULL DEFAULT 3,
+			run_after TIMESTAMP NOT NULL DEFAULT NOW(),
+			locked_at TIMESTAMP,
+			locked_by TEXT,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW(),
+			finished_at TIMESTAMP,
+			quota_reservation_ids BIGINT[]
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`ALTER TABLE ai_job ADD COLUMN IF NOT EXISTS quota_reservation_ids BIGINT[]`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_ai_job_pending
+		ON ai_job(run_after, id) WHERE status IN ('queued', 'running')
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`CREATE INDEX IF NOT EXISTS idx_ai_job_user_time ON ai_job(user_id, created_at DESC)`)
+	return err
+}
+
+const aiJobColumns = `id, user_id, kind, status, payload, result, error_code, error_message,
+	attempts, max_attempts, run_after, created_at, updated_at, finished_at, quota_reservation_ids`
+
+func (r *AIJobRepository) Create(job *model.AIJob) error {
+	return r.db.QueryRow(`
+		INSERT INTO ai_job (user_id, kind, status, payload, max_attempts, quota_reservation_ids)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, run_after, created_at, updated_at
+	`, job.UserID, job.Kind, job.Status, job.Payload, job.MaxAttempts, pq.Array(job.ReservationIDs)).
+		Scan(&job.ID, &job.RunAfter, &job.CreatedAt, &job.UpdatedAt)
+}
+
+// FindByID 只返回属于该用户的任务，不存在时返回 nil。
+func (r *AIJobRepository) FindByID(userID int64, id int64) (*model.AIJob, error) {
+	row := r.db.QueryRow(`SELECT `+aiJobColumns+` FROM ai_job WHERE id = $1 AND user_id = $2`, id, userID)
+	job, err := scanAIJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// Claim 领取一个到期的任务：排队中的，或执行超过 stale 仍未结束（实例宕机）且尚有尝试次数的。
+// FOR UPDATE SKIP LOCKED 保证多个 worker/实例不会领取同一任务。没有任务时返回 nil。
+func (r *AIJobRepository) Claim(kinds []string, workerID string, stale time.Duration) (*model.AIJob, error) {
+	row := r.db.QueryRow(`
+		UPDATE ai_job SET status = 'running', attempts = attempts + 1,
+			locked_at = NOW(), locked_by = $2, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM ai_job
+			WHERE kind = ANY($1)
+			  AND ((status = 'queued' AND run_after <= NOW())
+			    OR (status = 'running' AND locked_at < NOW() - $3 * INTERVAL '1 second' AND attempts < max_attempts))
+			ORDER BY run_after, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING `+aiJobColumns,
+		pq.Array(kinds), workerID, int64(stale/time.Second))
+	job, err := scanAIJob(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return job, err
+}
+
+// Complete 保存结果；只更新仍由该 worker 持有的任务，避免被重新领取后重复写入。
+func (r *AIJobRepository) Complete(id int64, workerID string, result []byte) (bool, error) {
+	res, err := r.db.Exec(`
+		UPDATE ai_job SET status = 'succeeded', result = $3, error_code = NULL, error_message = NULL,
+			locked_at = NULL, updated_at = NOW(), finished_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, id, workerID, result)
+	return rowsChanged(res, err)
+}
+
+// Retry 记录本次失败并在 delay 之后重新排队。
+func (r *AIJobRepository) Retry(id int64, workerID string, delay time.Duration, code string, message string) (bool, error) {
+	res, err := r.db.Exec(`
+		UPDATE ai_job SET status = 'queued', run_after = NOW() + $3 * INTERVAL '1 second', error_code = $4, error_message = $5,
+			locked_at = NULL, locked_by = NULL, updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, id, workerID, int64(delay/time.Second), code, message)
+	return rowsChanged(res, err)
+}
+
+// Fail 最终失败，不再重试。
+func (r *AIJobRepository) Fail(id int64, workerID string, code string, message string) (bool, error) {
+	res, err := r.db.Exec(`
+		UPDATE ai_job SET status = 'failed', error_code = $3, error_message = $4,
+			locked_at = NULL, updated_at = NOW(), finished_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+	`, id, workerID, code, message)
+	return rowsChanged(res, err)
+}
+
+// FailStale 执行超过 stale 仍未结束且已用完尝试次数的任务（如每次都导致 worker 崩溃）标记为最终失败，返回这些任务。
+func (r *AIJobRepository) FailStale(stale time.Duration, code string, message string) ([]model.AIJob, error) {
+	rows, err := r.db.Query(`
+		UPDATE ai_job SET status = 'failed', error_code = $2, error_message = $3,
+			locked_at = NULL, updated_at = NOW(), finished_at = NOW()
+		WHERE status = 'running' AND locked_at < NOW() - $1 * INTERVAL '1 second' AND attempts >= max_attempts
+		RETURNING `+aiJobColumns,
+		int64(stale/time.Second), code, message)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []model.AIJob
+	for rows.Next() {
+		job, err := scanAIJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+// DeleteFinishedOlderThan 清理结束超过 days 天的历史任务。
+func (r *AIJobRepository) DeleteFinishedOlderThan(days int) (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM ai_job WHERE finished_at IS NOT NULL AND finished_at < NOW() - $1 * INTERVAL '1 day'`, days)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanAIJob(row rowScanner) (*model.AIJob, error) {
+	var job model.AIJob
+	var result []byte
+	var errorCode, errorMessage sql.NullString
+	var finishedAt sql.NullTime
+	err := row.Scan(
+		&job.ID, &job.UserID, &job.Kind, &job.Status, &job.Payload, &result, &errorCode, &errorMessage,
+		&job.Attempts, &job.MaxAttempts, &job.RunAfter, &job.CreatedAt, &job.UpdatedAt, &finishedAt,
+		pq.Array(&job.ReservationIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > 0 {
+		job.Result = result
+	}
+	job.ErrorCode = errorCode.String
+	job.ErrorMessage = errorMessage.String
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+func rowsChanged(res sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"eatclean/internal/model"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type ChatMessageRepository struct {
@@ -14,10 +16,20 @@ func NewChatMessageRepository(db *sql.DB) *ChatMessageRepository {
 	return &ChatMessageRepository{db: db}
 }
 
+// EnsureTable 补齐 chat_message 表后续新增的列，基础表结构见 DB/db.md。
+func (r *ChatMessageRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	// 入库图片（image_asset）的 ID，与 image_urls 一一对应
+	_, err := r.db.Exec(`ALTER TABLE chat_message ADD COLUMN IF NOT EXISTS image_asset_ids BIGINT[]`)
+	return err
+}
+
 func (r *ChatMessageRepository) Create(message *model.ChatMessage) error {
 	query := `
-		INSERT INTO chat_message (user_id, role, text, image_urls)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO chat_message (user_id, role, text, image_urls, image_asset_ids)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at
 	`
 	imageUrls := normalizeJSON(message.ImageUrls, "[]")
@@ -27,12 +39,13 @@ func (r *ChatMessageRepository) Create(message *model.ChatMessage) error {
 		message.Role,
 		message.Text,
 		imageUrls,
+		nullableInt64Array(message.ImageAssetIDs),
 	).Scan(&message.ID, &message.CreatedAt)
 }
 
 func (r *ChatMessageRepository) ListByUser(userID int64, limit int) ([]model.ChatMessage, error) {
 	query := `
-		SELECT id, user_id, role, text, image_urls, created_at
+		SELECT id, user_id, role, text, image_urls, image_asset_ids, created_at
 		FROM chat_message
 		WHERE user_id = $1
 		ORDER BY id DESC
@@ -53,6 +66,7 @@ func (r *ChatMessageRepository) ListByUser(userID int64, limit int) ([]model.Cha
 			&msg.Role,
 			&msg.Text,
 			&msg.ImageUrls,
+			(*pq.Int64Array)(&msg.ImageAssetIDs),
 			&msg.CreatedAt,
 		); err != nil {
 			return nil, err
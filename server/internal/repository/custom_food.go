@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"encoding/json"
+)
+
+type CustomFoodRepository struct {
+	db *sql.DB
+}
+
+func NewCustomFoodRepository(db *sql.DB) *CustomFoodRepository {
+	return &CustomFoodRepository{db: db}
+}
+
+func (r *CustomFoodRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS custom_food (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			normalized_name TEXT NOT NULL,
+			brand TEXT,
+			barcode VARCHAR(14),
+			nutrition JSONB NOT NULL,
+			nrv_percent JSONB,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW(),
+			UNIQUE (user_id, normalized_name)
+		)
+	`)
+	return err
+}
+
+const customFoodColumns = `id, user_id, name, normalized_name, brand, barcode, nutrition, nrv_percent, created_at, updated_at`
+
+// Upsert 同一用户同名食物覆盖为最新数据。
+func (r *CustomFoodRepository) Upsert(food *model.CustomFood) error {
+	nutrition, err := json.Marshal(food.Nutrition)
+	if err != nil {
+		return err
+	}
+	var nrv []byte
+	if len(food.NRVPercent) > 0 {
+		if nrv, err = json.Marshal(food.NRVPercent); err != nil {
+			return err
+		}
+	}
+	return r.db.QueryRow(`
+		INSERT INTO custom_food (user_id, name, normalized_name, brand, barcode, nutrition, nrv_percent)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7)
+		ON CONFLICT (user_id, normalized_name) DO UPDATE SET
+			name = EXCLUDED.name,
+			brand = EXCLUDED.brand,
+			barcode = EXCLUDED.barcode,
+			nutrition = EXCLUDED.nutrition,
+			nrv_percent = EXCLUDED.nrv_percent,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`, food.UserID, food.Name, food.NormalizedName, food.Brand, food.Barcode, nutrition, nrv).
+		Scan(&food.ID, &food.CreatedAt, &food.UpdatedAt)
+}
+
+// FindByNormalizedName 不存在时返回 nil。
+func (r *CustomFoodRepository) FindByNormalizedName(userID int64, name string) (*model.CustomFood, error) {
+	row := r.db.QueryRow(`
+		SELECT `+customFoodColumns+`
+		FROM custom_food WHERE user_id = $1 AND normalized_name = $2
+	`, userID, name)
+	food, err := scanCustomFood(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return food, err
+}
+
+// ListByUser 最近更新的在前。
+func (r *CustomFoodRepository) ListByUser(userID int64, limit int) ([]model.CustomFood, error) {
+	rows, err := r.db.Query(`
+		SELECT `+customFoodColumns+`
+		FROM custom_food WHERE user_id = $1
+		ORDER BY updated_at DESC, id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var foods []model.CustomFood
+	for rows.Next() {
+		food, err := scanCustomFood(rows)
+		if err != nil {
+			return nil, err
+		}
+		foods = append(foods, *food)
+	}
+	return foods, rows.Err()
+}
+
+// Delete 只能删除自己的食物，返回是否删除成功。
+func (r *CustomFoodRepository) Delete(userID int64, id int64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM custom_food WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func scanCustomFood(row rowScanner) (*model.CustomFood, error) {
+	var food model.CustomFood
+	var brand, barcode sql.NullString
+	var nutrition, nrv []byte
+	if err := row.Scan(
+		&food.ID, &food.UserID, &food.Name, &food.NormalizedName, &brand, &barcode,
+		&nutrition, &nrv, &food.CreatedAt, &food.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	parsed, err := model.ParseNutrition(nutrition)
+	if err != nil {
+		return nil, err
+	}
+	food.Brand = brand.String
+	food.Barcode = barcode.String
+	food.Nutrition = parsed
+	if len(nrv) > 0 {
+		if err := json.Unmarshal(nrv, &food.NRVPercent); err != nil {
+			return nil, err
+		}
+	}
+	return &food, nil
+}
@@ -32,22 +32,47 @@ func (r *DailyIntakeRepository) EnsureTable() error {
 	if err != nil {
 		return err
 	}
+	for _, column := range []string{"sodium_mg", "sugar_g", "added_sugar_g", "fiber_g", "saturated_fat_g"} {
+		if _, err := r.db.Exec(`ALTER TABLE daily_intake ADD COLUMN IF NOT EXISTS ` + column + ` NUMERIC(8,1) NOT NULL DEFAULT 0`); err != nil {
+			return err
+		}
+	}
 	_, err = r.db.Exec(`CREATE INDEX IF NOT EXISTS idx_daily_intake_user_day ON daily_intake(user_id, day)`)
 	return err
 }
 
-func (r *DailyIntakeRepository) Upsert(userID int64, day string, calories, protein, carbs, fat int) error {
+func (r *DailyIntakeRepository) Upsert(record *model.DailyIntake) error {
 	query := `
-		INSERT INTO daily_intake (user_id, day, calories, protein, carbs, fat)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO daily_intake (
+			user_id, day, calories, protein, carbs, fat,
+			sodium_mg, sugar_g, added_sugar_g, fiber_g, saturated_fat_g
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		ON CONFLICT (user_id, day)
 		DO UPDATE SET calories = EXCLUDED.calories,
 			protein = EXCLUDED.protein,
 			carbs = EXCLUDED.carbs,
 			fat = EXCLUDED.fat,
+			sodium_mg = EXCLUDED.sodium_mg,
+			sugar_g = EXCLUDED.sugar_g,
+			added_sugar_g = EXCLUDED.added_sugar_g,
+			fiber_g = EXCLUDED.fiber_g,
+			saturated_fat_g = EXCLUDED.saturated_fat_g,
 			updated_at = NOW()
 	`
-	_, err := r.db.Exec(query, userID, day, calories, protein, carbs, fat)
+	_, err := r.db.Exec(query,
+		record.UserID,
+		record.Day.Format("2006-01-02"),
+		record.Calories,
+		record.Protein,
+		record.Carbs,
+		record.Fat,
+		record.SodiumMg,
+		record.SugarG,
+		record.AddedSugarG,
+		record.FiberG,
+		record.SaturatedFatG,
+	)
 	return err
 }
 
@@ -57,7 +82,8 @@ func (r *DailyIntakeRepository) GetByDate(userID int64, day string) (*model.Dail
 	}
 	record := &model.DailyIntake{}
 	err := r.db.QueryRow(`
-		SELECT user_id, day, calories, protein, carbs, fat, updated_at
+		SELECT user_id, day, calories, protein, carbs, fat,
+			sodium_mg, sugar_g, added_sugar_g, fiber_g, saturated_fat_g, updated_at
 		FROM daily_intake
 		WHERE user_id = $1 AND day = $2
 	`, userID, day).Scan(
@@ -67,6 +93,11 @@ func (r *DailyIntakeRepository) GetByDate(userID int64, day string) (*model.Dail
 		&record.Protein,
 		&record.Carbs,
 		&record.Fat,
+		&record.SodiumMg,
+		&record.SugarG,
+		&record.AddedSugarG,
+		&record.FiberG,
+		&record.SaturatedFatG,
 		&record.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -9,7 +9,8 @@ import (
 )
 
 type DishRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	trigram trigramProbe
 }
 
 func NewDishRepository(db *sql.DB) *DishRepository {
@@ -28,8 +29,81 @@ func (r *DishRepository) EnsureTable() error {
 			created_at TIMESTAMP DEFAULT NOW()
 		)
 	`
-	_, err := r.db.Exec(query)
-	return err
+	if _, err := r.db.Exec(query); err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(`ALTER TABLE dish ADD COLUMN IF NOT EXISTS advice TEXT`); err != nil {
+		return err
+	}
+	return ensureNameSearch(r.db, "dish", &r.trigram)
+}
+
+// NormalizeNames 按 model.NormalizeFoodName 重算 normalized_name 并补齐拼音列，可重复执行，
+// 由 foodimport -normalize-dishes 调用。
+// 重算后与已有行同名的旧行保留原键（仍可通过模糊搜索找到），返回更新的行数。
+func (r *DishRepository) NormalizeNames() (int, error) {
+	return normalizeStoredNames(r.db, "dish", true)
+}
+
+// NormalizeNutrition 把旧格式的 nutrition_estimate（整份 AI 菜品 map）转换为 model.Nutrition，
+// 旧数据中的 advice 移到 advice 列；无法得到营养数值的置为 NULL。返回转换的行数，可重复执行，
+// 由 foodimport -normalize-dishes 调用。
+func (r *DishRepository) NormalizeNutrition() (int, error) {
+	rows, err := r.db.Query(`
+		SELECT id, nutrition_estimate
+		FROM dish
+		WHERE nutrition_estimate IS NOT NULL
+		  AND NOT (nutrition_estimate ? 'per_100g' OR nutrition_estimate ? 'per_serving')
+	`)
+	if err != nil {
+		return 0, err
+	}
+	type legacyRow struct {
+		id        int64
+		nutrition *model.Nutrition
+		advice    string
+	}
+	var pending []legacyRow
+	for rows.Next() {
+		var id int64
+		var raw []byte
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal(raw, &values); err != nil {
+			continue
+		}
+		advice, _ := values["advice"].(string)
+		source := model.NutritionSourceLegacy
+		if _, ok := values["calories_kcal_per100g"]; ok {
+			source = model.NutritionSourceAISearch
+		}
+		pending = append(pending, legacyRow{id: id, nutrition: model.NutritionFromMap(values, source), advice: advice})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, row := range pending {
+		var nutrition interface{}
+		if row.nutrition != nil {
+			data, err := json.Marshal(row.nutrition)
+			if err != nil {
+				return 0, err
+			}
+			nutrition = data
+		}
+		if _, err := r.db.Exec(`
+			UPDATE dish SET nutrition_estimate = $2, advice = COALESCE(advice, NULLIF($3, ''))
+			WHERE id = $1
+		`, row.id, nutrition, row.advice); err != nil {
+			return 0, err
+		}
+	}
+	return len(pending), nil
 }
 
 func (r *DishRepository) FindByNormalizedNames(names []string) (map[string]model.Dish, error) {
@@ -38,7 +112,7 @@ func (r *DishRepository) FindByNormalizedNames(names []string) (map[string]model
 		return result, nil
 	}
 	query := `
-		SELECT id, name, normalized_name, category, nutrition_estimate, image_urls, created_at
+		SELECT id, name, normalized_name, category, nutrition_estimate, advice, image_urls, created_at
 		FROM dish
 		WHERE normalized_name = ANY($1)
 	`
@@ -51,7 +125,8 @@ func (r *DishRepository) FindByNormalizedNames(names []string) (map[string]model
 	for rows.Next() {
 		var dish model.Dish
 		var category sql.NullString
-		var nutrition json.RawMessage
+		var nutrition []byte
+		var advice sql.NullString
 		var imageUrls []string
 		if err := rows.Scan(
 			&dish.ID,
@@ -59,6 +134,7 @@ func (r *DishRepository) FindByNormalizedNames(names []string) (map[string]model
 			&dish.NormalizedName,
 			&category,
 			&nutrition,
+			&advice,
 			pq.Array(&imageUrls),
 			&dish.CreatedAt,
 		); err != nil {
@@ -68,9 +144,12 @@ func (r *DishRepository) FindByNormalizedNames(names []string) (map[string]model
 			value := category.String
 			dish.Category = &value
 		}
-		if len(nutrition) > 0 {
-			dish.NutritionEstimate = nutrition
+		parsed, err := model.ParseNutrition(nutrition)
+		if err != nil {
+			return nil, err
 		}
+		dish.Nutrition = parsed
+		dish.Advice = advice.String
 		dish.ImageUrls = imageUrls
 		result[dish.NormalizedName] = dish
 	}
@@ -91,14 +170,56 @@ func (r *DishRepository) FindOne(normalized string) (*model.Dish, error) {
 	return nil, nil
 }
 
+// SearchFuzzy 模糊匹配的已缓存菜品候选（三元组相似度、包含、拼音/首字母前缀），按相似度粗排；
+// 最终排序由 service 层统一打分。
+func (r *DishRepository) SearchFuzzy(query string, pinyinKey string, limit int) ([]model.Dish, error) {
+	match, order := fuzzyNameSearch(r.trigram.available(r.db))
+	rows, err := r.db.Query(`
+		SELECT normalized_name
+		FROM dish
+		WHERE `+match+`
+		ORDER BY `+order+`, id
+		LIMIT $3
+	`, query, pinyinKey, limit)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	found, err := r.FindByNormalizedNames(names)
+	if err != nil {
+		return nil, err
+	}
+	dishes := make([]model.Dish, 0, len(names))
+	for _, name := range names {
+		if dish, ok := found[name]; ok {
+			dishes = append(dishes, dish)
+		}
+	}
+	return dishes, nil
+}
+
+// Upsert 按 normalized_name 写入；营养数据按基准合并，新数据没有的基准（如每 100g）保留原值。
 func (r *DishRepository) Upsert(dish *model.Dish) error {
 	query := `
-		INSERT INTO dish (name, normalized_name, category, nutrition_estimate, image_urls)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO dish (name, normalized_name, category, nutrition_estimate, advice, image_urls, name_pinyin, name_initials)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
 		ON CONFLICT (normalized_name) DO UPDATE SET
 			name = EXCLUDED.name,
 			category = COALESCE(EXCLUDED.category, dish.category),
-			nutrition_estimate = COALESCE(EXCLUDED.nutrition_estimate, dish.nutrition_estimate),
+			nutrition_estimate = COALESCE(COALESCE(dish.nutrition_estimate, '{}'::jsonb) || EXCLUDED.nutrition_estimate, dish.nutrition_estimate),
+			advice = COALESCE(EXCLUDED.advice, dish.advice),
 			image_urls = COALESCE(EXCLUDED.image_urls, dish.image_urls)
 		RETURNING id, created_at
 	`
@@ -107,16 +228,24 @@ func (r *DishRepository) Upsert(dish *model.Dish) error {
 		category = *dish.Category
 	}
 	var nutrition interface{}
-	if len(dish.NutritionEstimate) > 0 {
-		nutrition = json.RawMessage(dish.NutritionEstimate)
+	if !dish.Nutrition.IsEmpty() {
+		data, err := json.Marshal(dish.Nutrition)
+		if err != nil {
+			return err
+		}
+		nutrition = data
 	}
 	imageUrls := pq.Array(dish.ImageUrls)
+	pinyin, initials := model.FoodNamePinyin(dish.NormalizedName)
 	return r.db.QueryRow(
 		query,
 		dish.Name,
 		dish.NormalizedName,
 		category,
 		nutrition,
+		dish.Advice,
 		imageUrls,
+		pinyin,
+		initials,
 	).Scan(&dish.ID, &dish.CreatedAt)
 }
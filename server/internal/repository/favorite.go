@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"encoding/json"
+)
+
+type FavoriteRepository struct {
+	db *sql.DB
+}
+
+func NewFavoriteRepository(db *sql.DB) *FavoriteRepository {
+	return &FavoriteRepository{db: db}
+}
+
+func (r *FavoriteRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS favorite_item (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			kind VARCHAR(20) NOT NULL,
+			ref_id BIGINT,
+			name TEXT NOT NULL,
+			normalized_name TEXT NOT NULL,
+			item JSONB,
+			created_at TIMESTAMP DEFAULT NOW(),
+			UNIQUE (user_id, kind, normalized_name)
+		)
+	`)
+	return err
+}
+
+const favoriteColumns = `id, user_id, kind, ref_id, name, normalized_name, item, created_at`
+
+// Upsert 同类型同名收藏覆盖为最新快照。
+func (r *FavoriteRepository) Upsert(favorite *model.FavoriteItem) error {
+	var item []byte
+	if len(favorite.Item) > 0 {
+		payload, err := json.Marshal(favorite.Item)
+		if err != nil {
+			return err
+		}
+		item = payload
+	}
+	return r.db.QueryRow(`
+		INSERT INTO favorite_item (user_id, kind, ref_id, name, normalized_name, item)
+		VALUES ($1, $2, NULLIF($3::BIGINT, 0), $4, $5, $6)
+		ON CONFLICT (user_id, kind, normalized_name) DO UPDATE SET
+			ref_id = EXCLUDED.ref_id,
+			name = EXCLUDED.name,
+			item = EXCLUDED.item
+		RETURNING id, created_at
+	`, favorite.UserID, favorite.Kind, favorite.RefID, favorite.Name, favorite.NormalizedName, item).
+		Scan(&favorite.ID, &favorite.CreatedAt)
+}
+
+// ListByUser 最近收藏的在前。
+func (r *FavoriteRepository) ListByUser(userID int64, limit int) ([]model.FavoriteItem, error) {
+	rows, err := r.db.Query(`
+		SELECT `+favoriteColumns+`
+		FROM favorite_item WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var favorites []model.FavoriteItem
+	for rows.Next() {
+		var favorite model.FavoriteItem
+		var refID sql.NullInt64
+		var item []byte
+		if err := rows.Scan(
+			&favorite.ID, &favorite.UserID, &favorite.Kind, &refID, &favorite.Name,
+			&favorite.NormalizedName, &item, &favorite.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		favorite.RefID = refID.Int64
+		if len(item) > 0 {
+			if err := json.Unmarshal(item, &favorite.Item); err != nil {
+				return nil, err
+			}
+		}
+		favorites = append(favorites, favorite)
+	}
+	return favorites, rows.Err()
+}
+
+// Delete 只能删除自己的收藏，返回是否删除成功。
+func (r *FavoriteRepository) Delete(userID int64, id int64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM favorite_item WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+// FoodRepository 食物成分表（中国食物成分表、USDA FDC 等公开数据），与 AI 估算的 dish 缓存分开存放。
+type FoodRepository struct {
+	db      *sql.DB
+	trigram trigramProbe
+}
+
+func NewFoodRepository(db *sql.DB) *FoodRepository {
+	return &FoodRepository{db: db}
+}
+
+func (r *FoodRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS food (
+			id BIGSERIAL PRIMARY KEY,
+			source VARCHAR(20) NOT NULL,
+			source_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			normalized_name TEXT NOT NULL,
+			aliases TEXT[],
+			category TEXT,
+			nutrition JSONB NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW(),
+			UNIQUE (source, source_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`CREATE INDEX IF NOT EXISTS idx_food_normalized ON food(normalized_name)`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`CREATE INDEX IF NOT EXISTS idx_food_aliases ON food USING GIN(aliases)`)
+	if err != nil {
+		return err
+	}
+	if err := ensureNameSearch(r.db, "food", &r.trigram); err != nil {
+		return err
+	}
+	_, err = r.NormalizeNames()
+	return err
+}
+
+// NormalizeNames 按 model.NormalizeFoodName 重算 normalized_name 并补齐拼音列，可重复执行；别名在重新导入时重算。
+func (r *FoodRepository) NormalizeNames() (int, error) {
+	return normalizeStoredNames(r.db, "food", false)
+}
+
+const foodColumns = `id, source, source_id, name, normalized_name, aliases, category, nutrition, created_at, updated_at`
+
+// Upsert 按 (source, source_id) 写入，重复导入同一数据集时更新。
+func (r *FoodRepository) Upsert(food *model.Food) error {
+	nutrition, err := json.Marshal(food.Nutrition)
+	if err != nil {
+		return err
+	}
+	pinyin, initials := model.FoodNamePinyin(food.NormalizedName)
+	return r.db.QueryRow(`
+		INSERT INTO food (source, source_id, name, normalized_name, aliases, category, nutrition, name_pinyin, name_initials)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9)
+		ON CONFLICT (source, source_id) DO UPDATE SET
+			name = EXCLUDED.name,
+			normalized_name = EXCLUDED.normalized_name,
+			name_pinyin = EXCLUDED.name_pinyin,
+			name_initials = EXCLUDED.name_initials,
+			aliases = EXCLUDED.aliases,
+			category = EXCLUDED.category,
+			nutrition = EXCLUDED.nutrition,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`, food.Source, food.SourceID, food.Name, food.NormalizedName, pq.Array(food.Aliases), food.Category, nutrition, pinyin, initials).
+		Scan(&food.ID, &food.CreatedAt, &food.UpdatedAt)
+}
+
+// FindByNormalizedNames 名称或别名完全匹配的条目，同名多条时按 sources 顺序取第一个来源。
+func (r *FoodRepository) FindByNormalizedNames(names []string, sources []string) (map[string]model.Food, error) {
+	result := make(map[string]model.Food)
+	if len(names) == 0 || len(sources) == 0 {
+		return result, nil
+	}
+	rows, err := r.db.Query(`
+		SELECT `+foodColumns+`
+		FROM food
+		WHERE (normalized_name = ANY($1::text[]) OR aliases && $1::text[]) AND source = ANY($2::text[])
+		ORDER BY array_position($2::text[], source::text), id
+	`, pq.Array(names), pq.Array(sources))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	for rows.Next() {
+		food, err := scanFood(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys := append([]string{food.NormalizedName}, food.Aliases...)
+		for _, key := range keys {
+			if _, ok := result[key]; !ok && wanted[key] {
+				result[key] = *food
+			}
+		}
+	}
+	return result, rows.Err()
+}
+
+// SearchFuzzy 模糊匹配的条目候选（三元组相似度、包含、别名、拼音/首字母前缀），按相似度与 sources 顺序粗排。
+func (r *FoodRepository) SearchFuzzy(query string, pinyinKey string, sources []string, limit int) ([]model.Food, error) {
+	match, order := fuzzyNameSearch(r.trigram.available(r.db))
+	rows, err := r.db.Query(`
+		SELECT `+foodColumns+`
+		FROM food
+		WHERE (`+match+` OR $1 = ANY(aliases)) AND source = ANY($3::text[])
+		ORDER BY `+order+`, array_position($3::text[], source::text), id
+		LIMIT $4
+	`, query, pinyinKey, pq.Array(sources), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var foods []model.Food
+	for rows.Next() {
+		food, err := scanFood(rows)
+		if err != nil {
+			return nil, err
+		}
+		foods = append(foods, *food)
+	}
+	return foods, rows.Err()
+}
+
+// CountBySource 各来源的条目数，导入后核对用。
+func (r *FoodRepository) CountBySource() (map[string]int, error) {
+	rows, err := r.db.Query(`SELECT source, COUNT(*) FROM food GROUP BY source`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[string]int{}
+	for rows.Next() {
+		var source string
+		var count int
+		if err := rows.Scan(&source, &count); err != nil {
+			return nil, err
+		}
+		counts[source] = count
+	}
+	return counts, rows.Err()
+}
+
+func scanFood(row rowScanner) (*model.Food, error) {
+	var food model.Food
+	var aliases []string
+	var category sql.NullString
+	var nutrition []byte
+	if err := row.Scan(
+		&food.ID, &food.Source, &food.SourceID, &food.Name, &food.NormalizedName,
+		pq.Array(&aliases), &category, &nutrition, &food.CreatedAt, &food.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	parsed, err := model.ParseNutrition(nutrition)
+	if err != nil {
+		return nil, err
+	}
+	food.Aliases = aliases
+	food.Category = category.String
+	food.Nutrition = parsed
+	return &food, nil
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"encoding/json"
+)
+
+type FoodBarcodeRepository struct {
+	db *sql.DB
+}
+
+func NewFoodBarcodeRepository(db *sql.DB) *FoodBarcodeRepository {
+	return &FoodBarcodeRepository{db: db}
+}
+
+func (r *FoodBarcodeRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS food_barcode (
+			barcode VARCHAR(14) PRIMARY KEY,
+			name TEXT NOT NULL,
+			brand TEXT,
+			quantity TEXT,
+			nutrition JSONB NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS food_barcode_correction (
+			barcode VARCHAR(14) NOT NULL,
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			brand TEXT,
+			nutrition JSONB NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW(),
+			PRIMARY KEY (barcode, user_id)
+		)
+	`)
+	return err
+}
+
+// Upsert 写入条码数据。AI 估算不会覆盖已有的 Open Food Facts 数据，导入的数据总是覆盖 AI 估算。
+func (r *FoodBarcodeRepository) Upsert(item *model.FoodBarcode) error {
+	nutrition, err := json.Marshal(item.Nutrition)
+	if err != nil {
+		return err
+	}
+	source := ""
+	if item.Nutrition != nil {
+		source = item.Nutrition.Source
+	}
+	err = r.db.QueryRow(`
+		INSERT INTO food_barcode (barcode, name, brand, quantity, nutrition)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		ON CONFLICT (barcode) DO UPDATE SET
+			name = EXCLUDED.name,
+			brand = EXCLUDED.brand,
+			quantity = EXCLUDED.quantity,
+			nutrition = EXCLUDED.nutrition,
+			updated_at = NOW()
+		WHERE $6::text = 'off' OR food_barcode.nutrition->>'source' <> 'off'
+		RETURNING created_at, updated_at
+	`, item.Barcode, item.Name, item.Brand, item.Quantity, nutrition, source).Scan(&item.CreatedAt, &item.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
+
+// Find 不存在时返回 nil。
+func (r *FoodBarcodeRepository) Find(barcode string) (*model.FoodBarcode, error) {
+	var item model.FoodBarcode
+	var brand, quantity sql.NullString
+	var nutrition []byte
+	err := r.db.QueryRow(`
+		SELECT barcode, name, brand, quantity, nutrition, created_at, updated_at
+		FROM food_barcode WHERE barcode = $1
+	`, barcode).Scan(&item.Barcode, &item.Name, &brand, &quantity, &nutrition, &item.CreatedAt, &item.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	item.Brand = brand.String
+	item.Quantity = quantity.String
+	item.Nutrition, err = model.ParseNutrition(nutrition)
+	return &item, err
+}
+
+// SaveCorrection 同一用户对同一条码只保留最新的修正。
+func (r *FoodBarcodeRepository) SaveCorrection(item *model.FoodBarcodeCorrection) error {
+	nutrition, err := json.Marshal(item.Nutrition)
+	if err != nil {
+		return err
+	}
+	return r.db.QueryRow(`
+		INSERT INTO food_barcode_correction (barcode, user_id, name, brand, nutrition)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		ON CONFLICT (barcode, user_id) DO UPDATE SET
+			name = EXCLUDED.name,
+			brand = EXCLUDED.brand,
+			nutrition = EXCLUDED.nutrition,
+			updated_at = NOW()
+		RETURNING created_at, updated_at
+	`, item.Barcode, item.UserID, item.Name, item.Brand, nutrition).Scan(&item.CreatedAt, &item.UpdatedAt)
+}
+
+// FindCorrection 用户自己的修正，不存在时返回 nil。
+func (r *FoodBarcodeRepository) FindCorrection(userID int64, barcode string) (*model.FoodBarcodeCorrection, error) {
+	var item model.FoodBarcodeCorrection
+	var brand sql.NullString
+	var nutrition []byte
+	err := r.db.QueryRow(`
+		SELECT barcode, user_id, name, brand, nutrition, created_at, updated_at
+		FROM food_barcode_correction WHERE barcode = $1 AND user_id = $2
+	`, barcode, userID).Scan(&item.Barcode, &item.UserID, &item.Name, &brand, &nutrition, &item.CreatedAt, &item.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	item.Brand = brand.String
+	item.Nutrition, err = model.ParseNutrition(nutrition)
+	return &item, err
+}
+
+// CountCorrections 条码被多少用户修正过，数据质量参考。
+func (r *FoodBarcodeRepository) CountCorrections(barcode string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM food_barcode_correction WHERE barcode = $1`, barcode).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"log"
+	"sync"
+)
+
+// dish 与 food 共用的名称模糊匹配：$1 为规范化名称，$2 为拼音键（拼音输入或中文输入的全拼，可为空）。
+// pg_trgm 对中文的三元组依赖数据库使用 UTF-8 区域设置（如 zh_CN.UTF-8 / en_US.UTF-8），C 区域只能靠包含与拼音匹配。
+const (
+	fuzzyNameMatch = `(normalized_name % $1 OR normalized_name LIKE '%' || $1 || '%'
+		OR ($2 <> '' AND (name_pinyin LIKE $2 || '%' OR name_initials LIKE $2 || '%')))`
+	fuzzyNameOrder = `similarity(normalized_name, $1) DESC, length(normalized_name)`
+	// 未启用 pg_trgm 时只用包含与拼音匹配，包含输入且位置靠前的排在前面
+	plainNameMatch = `(normalized_name LIKE '%' || $1 || '%'
+		OR ($2 <> '' AND (name_pinyin LIKE $2 || '%' OR name_initials LIKE $2 || '%')))`
+	plainNameOrder = `strpos(normalized_name, $1) = 0, strpos(normalized_name, $1), length(normalized_name)`
+)
+
+// fuzzyNameSearch trigram 可用时的匹配条件与粗排，否则退化为包含与拼音匹配。
+func fuzzyNameSearch(trigram bool) (string, string) {
+	if trigram {
+		return fuzzyNameMatch, fuzzyNameOrder
+	}
+	return plainNameMatch, plainNameOrder
+}
+
+// trigramProbe 缓存 pg_trgm 是否已安装。服务启动不执行 EnsureTable（表结构按 DB/db.md 迁移），
+// 首次搜索时检测；查询失败时本次按不可用处理，下次重试。
+type trigramProbe struct {
+	mu        sync.Mutex
+	checked   bool
+	installed bool
+}
+
+func (p *trigramProbe) available(db *sql.DB) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.checked {
+		var installed bool
+		if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')`).Scan(&installed); err != nil {
+			return false
+		}
+		if !installed {
+			log.Printf("pg_trgm is not installed, name search falls back to LIKE and pinyin")
+		}
+		p.checked, p.installed = true, installed
+	}
+	return p.installed
+}
+
+func (p *trigramProbe) set(installed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checked, p.installed = true, installed
+}
+
+// ensureTrigram 扩展应由有权限的账号按 DB/db.md 预先创建；未创建时尝试创建，没有权限则返回 false。
+func ensureTrigram(db *sql.DB, probe *trigramProbe) bool {
+	if probe.available(db) {
+		return true
+	}
+	if _, err := db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`); err != nil {
+		log.Printf("create extension pg_trgm failed: %v", err)
+		return false
+	}
+	probe.set(true)
+	return true
+}
+
+// ensureNameSearch 为 table 增加拼音列与前缀索引，pg_trgm 可用时再建三元组索引。table 只能是内部常量。
+func ensureNameSearch(db *sql.DB, table string, probe *trigramProbe) error {
+	trigram := ensureTrigram(db, probe)
+	statements := []string{
+		`ALTER TABLE ` + table + ` ADD COLUMN IF NOT EXISTS name_pinyin TEXT`,
+		`ALTER TABLE ` + table + ` ADD COLUMN IF NOT EXISTS name_initials TEXT`,
+		`CREATE INDEX IF NOT EXISTS idx_` + table + `_name_pinyin ON ` + table + ` (name_pinyin text_pattern_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_` + table + `_name_initials ON ` + table + ` (name_initials text_pattern_ops)`,
+	}
+	if trigram {
+		statements = append(statements, `CREATE INDEX IF NOT EXISTS idx_`+table+`_name_trgm ON `+table+` USING GIN (normalized_name gin_trgm_ops)`)
+	}
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeStoredNames 重算 normalized_name 与拼音列。unique 为 true 时（dish）新键已被占用的行只补拼音。
+func normalizeStoredNames(db *sql.DB, table string, unique bool) (int, error) {
+	rows, err := db.Query(`SELECT id, normalized_name, COALESCE(name_pinyin, '') FROM ` + table)
+	if err != nil {
+		return 0, err
+	}
+	type pendingName struct {
+		id         int64
+		normalized string
+	}
+	var pending []pendingName
+	for rows.Next() {
+		var id int64
+		var normalized, pinyin string
+		if err := rows.Scan(&id, &normalized, &pinyin); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		next := model.NormalizeFoodName(normalized)
+		if nextPinyin, _ := model.FoodNamePinyin(next); next != normalized || nextPinyin != pinyin {
+			pending = append(pending, pendingName{id: id, normalized: normalized})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, row := range pending {
+		next := model.NormalizeFoodName(row.normalized)
+		if next == "" {
+			continue
+		}
+		pinyin, initials := model.FoodNamePinyin(next)
+		query := `UPDATE ` + table + ` SET normalized_name = $2, name_pinyin = $3, name_initials = $4 WHERE id = $1`
+		if unique {
+			query += ` AND NOT EXISTS (SELECT 1 FROM ` + table + ` WHERE normalized_name = $2 AND id <> $1)`
+		}
+		result, err := db.Exec(query, row.id, next, pinyin, initials)
+		if err != nil {
+			return updated, err
+		}
+		if affected, _ := result.RowsAffected(); affected > 0 {
+			updated++
+			continue
+		}
+		pinyin, initials = model.FoodNamePinyin(row.normalized)
+		if _, err := db.Exec(`UPDATE `+table+` SET name_pinyin = $2, name_initials = $3 WHERE id = $1`, row.id, pinyin, initials); err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
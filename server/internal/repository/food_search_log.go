@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+)
+
+type FoodSearchLogRepository struct {
+	db *sql.DB
+}
+
+func NewFoodSearchLogRepository(db *sql.DB) *FoodSearchLogRepository {
+	return &FoodSearchLogRepository{db: db}
+}
+
+func (r *FoodSearchLogRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS food_search_log (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			query TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW()
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`CREATE INDEX IF NOT EXISTS idx_food_search_log_user_time ON food_search_log(user_id, created_at DESC)`)
+	return err
+}
+
+func (r *FoodSearchLogRepository) Create(entry *model.FoodSearchLog) error {
+	query := `
+		INSERT INTO food_search_log (user_id, query)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`
+	return r.db.QueryRow(query, entry.UserID, entry.Query).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// ListRecent 返回最近的搜索记录（同一关键词只保留最新一次）。
+func (r *FoodSearchLogRepository) ListRecent(userID int64, limit int) ([]model.FoodSearchLog, error) {
+	query := `
+		SELECT id, user_id, query, created_at
+		FROM (
+			SELECT DISTINCT ON (query) id, user_id, query, created_at
+			FROM food_search_log
+			WHERE user_id = $1
+			ORDER BY query, created_at DESC
+		) latest
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.FoodSearchLog
+	for rows.Next() {
+		var entry model.FoodSearchLog
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.Query, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
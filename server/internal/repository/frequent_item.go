@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"encoding/json"
+	"time"
+)
+
+type FrequentItemRepository struct {
+	db *sql.DB
+}
+
+func NewFrequentItemRepository(db *sql.DB) *FrequentItemRepository {
+	return &FrequentItemRepository{db: db}
+}
+
+func (r *FrequentItemRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS frequent_item (
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			meal_slot VARCHAR(10) NOT NULL,
+			normalized_name TEXT NOT NULL,
+			name TEXT NOT NULL,
+			item JSONB NOT NULL,
+			count INT NOT NULL DEFAULT 0,
+			last_logged_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (user_id, meal_slot, normalized_name)
+		)
+	`)
+	return err
+}
+
+// Increment 每个条目计数加一；条目快照与名称取最近一次记录。
+func (r *FrequentItemRepository) Increment(userID int64, slot string, items []model.FrequentItem, loggedAt time.Time) error {
+	if len(items) == 0 {
+		return nil
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, item := range items {
+		payload, err := json.Marshal(item.Item)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO frequent_item (user_id, meal_slot, normalized_name, name, item, count, last_logged_at)
+			VALUES ($1, $2, $3, $4, $5, 1, $6)
+			ON CONFLICT (user_id, meal_slot, normalized_name) DO UPDATE SET
+				count = frequent_item.count + 1,
+				name = CASE WHEN EXCLUDED.last_logged_at >= frequent_item.last_logged_at THEN EXCLUDED.name ELSE frequent_item.name END,
+				item = CASE WHEN EXCLUDED.last_logged_at >= frequent_item.last_logged_at THEN EXCLUDED.item ELSE frequent_item.item END,
+				last_logged_at = GREATEST(frequent_item.last_logged_at, EXCLUDED.last_logged_at)
+		`, userID, slot, item.NormalizedName, item.Name, payload, loggedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListByUser 所有时段的常吃条目，按次数降序。
+func (r *FrequentItemRepository) ListByUser(userID int64, limit int) ([]model.FrequentItem, error) {
+	rows, err := r.db.Query(`
+		SELECT user_id, meal_slot, normalized_name, name, item, count, last_logged_at
+		FROM frequent_item WHERE user_id = $1
+		ORDER BY count DESC, last_logged_at DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []model.FrequentItem
+	for rows.Next() {
+		var item model.FrequentItem
+		var payload []byte
+		if err := rows.Scan(
+			&item.UserID, &item.MealSlot, &item.NormalizedName, &item.Name, &payload, &item.Count, &item.LastLoggedAt,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &item.Item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// HasAny 用户是否已有统计数据，没有时由就餐记录回填。
+func (r *FrequentItemRepository) HasAny(userID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM frequent_item WHERE user_id = $1)`, userID).Scan(&exists)
+	return exists, err
+}
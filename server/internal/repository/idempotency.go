@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"time"
+)
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+func (r *IdempotencyRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS idempotency_key (
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			route TEXT NOT NULL,
+			idem_key VARCHAR(255) NOT NULL,
+			request_hash CHAR(64) NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'in_flight',
+			response_status INT,
+			content_type TEXT,
+			response_body BYTEA,
+			created_at TIMESTAMP DEFAULT NOW(),
+			expires_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (user_id, route, idem_key)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`CREATE INDEX IF NOT EXISTS idx_idempotency_key_expires ON idempotency_key(expires_at)`)
+	return err
+}
+
+// Acquire 登记一次执行。已过期或处理超时被放弃的记录会被覆盖；
+// 存在有效记录时 acquired 为 false 并返回该记录（并发删除导致读不到时 existing 为 nil）。
+func (r *IdempotencyRepository) Acquire(
+	userID int64,
+	route string,
+	key string,
+	requestHash string,
+	ttl time.Duration,
+	inFlightTimeout time.Duration,
+) (bool, *model.IdempotencyRecord, error) {
+	var inserted int
+	err := r.db.QueryRow(`
+		INSERT INTO idempotency_key (user_id, route, idem_key, request_hash, status, expires_at)
+		VALUES ($1, $2, $3, $4, 'in_flight', NOW() + $5 * INTERVAL '1 second')
+		ON CONFLICT (user_id, route, idem_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, status = 'in_flight',
+			response_status = NULL, content_type = NULL, response_body = NULL,
+			created_at = NOW(), expires_at = EXCLUDED.expires_at
+		WHERE idempotency_key.expires_at < NOW()
+		   OR (idempotency_key.status = 'in_flight' AND idempotency_key.created_at < NOW() - $6 * INTERVAL '1 second')
+		RETURNING 1
+	`, userID, route, key, requestHash, int64(ttl/time.Second), int64(inFlightTimeout/time.Second)).Scan(&inserted)
+	if err == nil {
+		return true, nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, nil, err
+	}
+	existing, err := r.find(userID, route, key)
+	return false, existing, err
+}
+
+func (r *IdempotencyRepository) find(userID int64, route string, key string) (*model.IdempotencyRecord, error) {
+	var record model.IdempotencyRecord
+	var responseStatus sql.NullInt64
+	var contentType sql.NullString
+	err := r.db.QueryRow(`
+		SELECT user_id, route, idem_key, request_hash, status, response_status, content_type, response_body, created_at, expires_at
+		FROM idempotency_key
+		WHERE user_id = $1 AND route = $2 AND idem_key = $3
+	`, userID, route, key).Scan(
+		&record.UserID, &record.Route, &record.Key, &record.RequestHash, &record.Status,
+		&responseStatus, &contentType, &record.ResponseBody, &record.CreatedAt, &record.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record.ResponseStatus = int(responseStatus.Int64)
+	record.ContentType = contentType.String
+	return &record, nil
+}
+
+// Complete 保存首次响应，之后的重试直接重放。
+func (r *IdempotencyRepository) Complete(userID int64, route string, key string, status int, contentType string, body []byte) error {
+	_, err := r.db.Exec(`
+		UPDATE idempotency_key
+		SET status = 'completed', response_status = $4, content_type = $5, response_body = $6
+		WHERE user_id = $1 AND route = $2 AND idem_key = $3 AND status = 'in_flight'
+	`, userID, route, key, status, contentType, body)
+	return err
+}
+
+// Release 删除处理中的记录（服务端错误或响应过大），允许客户端用同一个 key 重试。
+func (r *IdempotencyRepository) Release(userID int64, route string, key string) error {
+	_, err := r.db.Exec(`
+		DELETE FROM idempotency_key
+		WHERE user_id = $1 AND route = $2 AND idem_key = $3 AND status = 'in_flight'
+	`, userID, route, key)
+	return err
+}
+
+func (r *IdempotencyRepository) DeleteExpired() (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM idempotency_key WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+type ImageAssetRepository struct {
+	db *sql.DB
+}
+
+func NewImageAssetRepository(db *sql.DB) *ImageAssetRepository {
+	return &ImageAssetRepository{db: db}
+}
+
+func (r *ImageAssetRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS image_asset (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			content_hash CHAR(64) NOT NULL,
+			category VARCHAR(20) NOT NULL,
+			source_mime VARCHAR(50),
+			source_bytes BIGINT,
+			width INT,
+			height INT,
+			model_key TEXT NOT NULL,
+			model_width INT,
+			model_height INT,
+			thumb_key TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT NOW(),
+			UNIQUE (user_id, content_hash)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`CREATE INDEX IF NOT EXISTS idx_image_asset_model_key ON image_asset(model_key)`)
+	if err != nil {
+		return err
+	}
+	if _, err := r.db.Exec(`ALTER TABLE image_asset ADD COLUMN IF NOT EXISTS phash BIGINT`); err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`ALTER TABLE image_asset ADD COLUMN IF NOT EXISTS quality JSONB`)
+	return err
+}
+
+const imageAssetColumns = `id, user_id, content_hash, category, source_mime, source_bytes, width, height,
+	model_key, model_width, model_height, thumb_key, phash, quality, created_at`
+
+// Create 写入图片记录；同一用户相同内容已存在时返回已有记录，created 为 false。
+func (r *ImageAssetRepository) Create(asset *model.ImageAsset) (bool, error) {
+	query := `
+		INSERT INTO image_asset (user_id, content_hash, category, source_mime, source_bytes, width, height,
+			model_key, model_width, model_height, thumb_key, phash, quality)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (user_id, content_hash) DO NOTHING
+		RETURNING id, created_at
+	`
+	var quality interface{}
+	if asset.Quality != nil {
+		payload, err := json.Marshal(asset.Quality)
+		if err != nil {
+			return false, err
+		}
+		quality = string(payload)
+	}
+	err := r.db.QueryRow(query,
+		asset.UserID, asset.ContentHash, asset.Category, asset.SourceMime, asset.SourceBytes,
+		asset.Width, asset.Height, asset.ModelKey, asset.ModelWidth, asset.ModelHeight, asset.ThumbKey, asset.PHash, quality,
+	).Scan(&asset.ID, &asset.CreatedAt)
+	if err == sql.ErrNoRows {
+		existing, findErr := r.FindByHash(asset.UserID, asset.ContentHash)
+		if findErr != nil {
+			return false, findErr
+		}
+		if existing != nil {
+			*asset = *existing
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *ImageAssetRepository) FindByHash(userID int64, contentHash string) (*model.ImageAsset, error) {
+	row := r.db.QueryRow(`SELECT `+imageAssetColumns+` FROM image_asset WHERE user_id = $1 AND content_hash = $2`, userID, contentHash)
+	return scanImageAsset(row)
+}
+
+// FindByModelKey 按模型版本对象名查找，用于识别客户端传回的已入库图片。
+func (r *ImageAssetRepository) FindByModelKey(userID int64, key string) (*model.ImageAsset, error) {
+	row := r.db.QueryRow(`SELECT `+imageAssetColumns+` FROM image_asset WHERE user_id = $1 AND (model_key = $2 OR thumb_key = $2)`, userID, key)
+	return scanImageAsset(row)
+}
+
+// ListByIDs 只返回属于该用户的记录，顺序与 ids 无关。
+func (r *ImageAssetRepository) ListByIDs(userID int64, ids []int64) ([]model.ImageAsset, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := r.db.Query(`SELECT `+imageAssetColumns+` FROM image_asset WHERE user_id = $1 AND id = ANY($2)`, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assets []model.ImageAsset
+	for rows.Next() {
+		asset, err := scanImageAsset(rows)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, *asset)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// ThumbKeys 返回模型版本对象名到缩略图对象名的映射，清理时缩略图随模型版本一起保留或删除。
+func (r *ImageAssetRepository) ThumbKeys() (map[string]string, error) {
+	rows, err := r.db.Query(`SELECT model_key, thumb_key FROM image_asset`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := map[string]string{}
+	for rows.Next() {
+		var modelKey, thumbKey string
+		if err := rows.Scan(&modelKey, &thumbKey); err != nil {
+			return nil, err
+		}
+		keys[modelKey] = thumbKey
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// DeleteByKeys 删除模型版本或缩略图已被清理的图片记录，避免去重命中已不存在的对象。
+func (r *ImageAssetRepository) DeleteByKeys(keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	result, err := r.db.Exec(`DELETE FROM image_asset WHERE model_key = ANY($1) OR thumb_key = ANY($1)`, pq.Array(keys))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// rowScanner 兼容 *sql.Row 与 *sql.Rows。
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanImageAsset(row rowScanner) (*model.ImageAsset, error) {
+	var asset model.ImageAsset
+	var sourceMime sql.NullString
+	var sourceBytes sql.NullInt64
+	var width, height, modelWidth, modelHeight, phash sql.NullInt64
+	var quality []byte
+	err := row.Scan(
+		&asset.ID, &asset.UserID, &asset.ContentHash, &asset.Category, &sourceMime, &sourceBytes,
+		&width, &height, &asset.ModelKey, &modelWidth, &modelHeight, &asset.ThumbKey, &phash, &quality, &asset.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	asset.SourceMime = sourceMime.String
+	asset.SourceBytes = sourceBytes.Int64
+	asset.Width = int(width.Int64)
+	asset.Height = int(height.Int64)
+	asset.ModelWidth = int(modelWidth.Int64)
+	asset.ModelHeight = int(modelHeight.Int64)
+	asset.PHash = phash.Int64
+	if len(quality) > 0 {
+		var parsed model.ImageQuality
+		if err := json.Unmarshal(quality, &parsed); err == nil {
+			asset.Quality = &parsed
+		}
+	}
+	return &asset, nil
+}
+
+// nullableInt64Array 空切片写入 NULL，旧记录与无图片记录保持一致。
+func nullableInt64Array(ids []int64) interface{} {
+	if len(ids) == 0 {
+		return nil
+	}
+	return pq.Array(ids)
+}
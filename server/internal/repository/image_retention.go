@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+)
+
+// ImageRetentionRepository 汇总所有引用图片的字段，供孤立图片清理使用。
+type ImageRetentionRepository struct {
+	db *sql.DB
+}
+
+func NewImageRetentionRepository(db *sql.DB) *ImageRetentionRepository {
+	return &ImageRetentionRepository{db: db}
+}
+
+// referencedImageURLsQuery 各表中引用的图片 URL 或对象名（旧记录可能是完整 URL）。
+const referencedImageURLsQuery = `
+	SELECT jsonb_array_elements_text(image_urls) FROM meal_record WHERE jsonb_typeof(image_urls) = 'array'
+	UNION
+	SELECT jsonb_array_elements_text(raw_image_urls) FROM menu_scan WHERE jsonb_typeof(raw_image_urls) = 'array'
+	UNION
+	SELECT raw_image_url FROM menu_scan WHERE raw_image_url IS NOT NULL AND raw_image_url <> ''
+	UNION
+	SELECT jsonb_array_elements_text(image_urls) FROM chat_message WHERE jsonb_typeof(image_urls) = 'array'
+	UNION
+	SELECT avatar_url FROM app_user WHERE avatar_url IS NOT NULL AND avatar_url <> ''
+	UNION
+	SELECT unnest(image_urls) FROM dish WHERE image_urls IS NOT NULL
+	UNION
+	SELECT a.model_key FROM image_asset a WHERE a.id IN (
+		SELECT unnest(image_asset_ids) FROM meal_record
+		UNION SELECT unnest(image_asset_ids) FROM menu_scan
+		UNION SELECT unnest(image_asset_ids) FROM chat_message
+		UNION SELECT image_asset_id FROM recipe WHERE image_asset_id IS NOT NULL
+	)
+`
+
+// userImageReferenceQuery 该用户自己的记录中是否引用了对象 $2（引用可能是对象名或带签名参数的完整 URL）。
+// 这些列由客户端提交的地址写入，写入时都已校验归属，不能引用其他用户的对象。
+const userImageReferenceQuery = `
+	SELECT EXISTS (
+		SELECT 1 FROM (
+			SELECT jsonb_array_elements_text(image_urls) AS ref FROM meal_record WHERE user_id = $1 AND jsonb_typeof(image_urls) = 'array'
+			UNION ALL
+			SELECT jsonb_array_elements_text(raw_image_urls) FROM menu_scan WHERE user_id = $1 AND jsonb_typeof(raw_image_urls) = 'array'
+			UNION ALL
+			SELECT raw_image_url FROM menu_scan WHERE user_id = $1 AND raw_image_url IS NOT NULL
+			UNION ALL
+			SELECT jsonb_array_elements_text(image_urls) FROM chat_message WHERE user_id = $1 AND jsonb_typeof(image_urls) = 'array'
+			UNION ALL
+			SELECT avatar_url FROM app_user WHERE id = $1 AND avatar_url IS NOT NULL
+		) refs
+		WHERE split_part(ref, '?', 1) = $2
+		   OR right(split_part(ref, '?', 1), length($2) + 1) = '/' || $2
+	)
+`
+
+// UserReferencesImage 用户自己的就餐、菜单、对话记录或头像是否引用了 objectKey。
+func (r *ImageRetentionRepository) UserReferencesImage(userID int64, objectKey string) (bool, error) {
+	if r.db == nil {
+		return false, sql.ErrConnDone
+	}
+	var referenced bool
+	err := r.db.QueryRow(userImageReferenceQuery, userID, objectKey).Scan(&referenced)
+	return referenced, err
+}
+
+// ReferencedImageURLs 返回去重后的全部图片引用。
+func (r *ImageRetentionRepository) ReferencedImageURLs() ([]string, error) {
+	if r.db == nil {
+		return nil, sql.ErrConnDone
+	}
+	rows, err := r.db.Query(referencedImageURLsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var value sql.NullString
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		if value.Valid && value.String != "" {
+			urls = append(urls, value.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
@@ -3,7 +3,10 @@ package repository
 import (
 	"database/sql"
 	"eatclean/internal/model"
+	"encoding/json"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type MealRecordRepository struct {
@@ -14,31 +17,71 @@ func NewMealRecordRepository(db *sql.DB) *MealRecordRepository {
 	return &MealRecordRepository{db: db}
 }
 
+// EnsureTable 补齐 meal_record 表后续新增的列，基础表结构见 DB/db.md。
+func (r *MealRecordRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	// 入库图片（image_asset）的 ID，与 image_urls 一一对应
+	if _, err := r.db.Exec(`ALTER TABLE meal_record ADD COLUMN IF NOT EXISTS image_asset_ids BIGINT[]`); err != nil {
+		return err
+	}
+	// 命中识别缓存且不计费的记录不计入每日额度
+	_, err := r.db.Exec(`ALTER TABLE meal_record ADD COLUMN IF NOT EXISTS quota_exempt BOOLEAN NOT NULL DEFAULT FALSE`)
+	return err
+}
+
+// rowQuerier *sql.DB 与 *sql.Tx 共用的单行查询。
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 func (r *MealRecordRepository) Create(record *model.MealRecord) error {
+	return insertMealRecord(r.db, record)
+}
+
+// CreateAll 在一个事务内写入多条记录，任一条失败时全部回滚。
+func (r *MealRecordRepository) CreateAll(records []*model.MealRecord) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, record := range records {
+		if err := insertMealRecord(tx, record); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func insertMealRecord(q rowQuerier, record *model.MealRecord) error {
 	query := `
-		INSERT INTO meal_record (user_id, source, items, image_urls, ratings, meta, recorded_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO meal_record (user_id, source, items, image_urls, image_asset_ids, ratings, meta, quota_exempt, recorded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id, created_at
 	`
 	itemsJSON := normalizeJSON(record.Items, "[]")
 	imageUrlsJSON := normalizeJSON(record.ImageUrls, "[]")
 	ratingsJSON := normalizeJSON(record.Ratings, "null")
 	metaJSON := normalizeJSON(record.Meta, "null")
-	return r.db.QueryRow(
+	return q.QueryRow(
 		query,
 		record.UserID,
 		record.Source,
 		itemsJSON,
 		imageUrlsJSON,
+		nullableInt64Array(record.ImageAssetIDs),
 		ratingsJSON,
 		metaJSON,
+		record.QuotaExempt,
 		record.RecordedAt,
 	).Scan(&record.ID, &record.CreatedAt)
 }
 
 func (r *MealRecordRepository) ListByUser(userID int64, limit int) ([]model.MealRecord, error) {
 	query := `
-		SELECT id, user_id, source, items, image_urls, ratings, meta, recorded_at, created_at
+		SELECT id, user_id, source, items, image_urls, image_asset_ids, ratings, meta, recorded_at, created_at
 		FROM meal_record
 		WHERE user_id = $1
 		ORDER BY recorded_at DESC, id DESC
@@ -59,6 +102,7 @@ func (r *MealRecordRepository) ListByUser(userID int64, limit int) ([]model.Meal
 			&record.Source,
 			&record.Items,
 			&record.ImageUrls,
+			(*pq.Int64Array)(&record.ImageAssetIDs),
 			&record.Ratings,
 			&record.Meta,
 			&record.RecordedAt,
@@ -88,7 +132,116 @@ func (r *MealRecordRepository) CountByUserSourceBetween(
 		  AND source = $2
 		  AND recorded_at >= $3
 		  AND recorded_at < $4
+		  AND NOT quota_exempt
 	`
 	err := r.db.QueryRow(query, userID, source, start, end).Scan(&count)
 	return count, err
 }
+
+// FindByID 用户自己的就餐记录，不存在时返回 nil。
+func (r *MealRecordRepository) FindByID(userID int64, id int64) (*model.MealRecord, error) {
+	return findMealRecord(r.db, userID, id, false)
+}
+
+// findMealRecord forUpdate 为 true 时加行锁（SELECT ... FOR UPDATE），需在事务内调用。
+func findMealRecord(q rowQuerier, userID int64, id int64, forUpdate bool) (*model.MealRecord, error) {
+	query := `
+		SELECT id, user_id, source, items, image_urls, image_asset_ids, ratings, meta, quota_exempt, recorded_at, created_at
+		FROM meal_record
+		WHERE id = $1 AND user_id = $2
+	`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	var record model.MealRecord
+	err := q.QueryRow(query, id, userID).Scan(
+		&record.ID,
+		&record.UserID,
+		&record.Source,
+		&record.Items,
+		&record.ImageUrls,
+		(*pq.Int64Array)(&record.ImageAssetIDs),
+		&record.Ratings,
+		&record.Meta,
+		&record.QuotaExempt,
+		&record.RecordedAt,
+		&record.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// UpdateItemsLocked 在事务内锁定记录后由 update 根据当前菜品列表计算新列表并写回，
+// 同一记录的并发修改串行执行，不会互相覆盖。记录不存在或不属于该用户时返回 nil；update 返回错误时不写入。
+func (r *MealRecordRepository) UpdateItemsLocked(userID int64, id int64, update func(items json.RawMessage) (json.RawMessage, error)) (*model.MealRecord, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	record, err := findMealRecord(tx, userID, id, true)
+	if err != nil || record == nil {
+		return nil, err
+	}
+	items, err := update(record.Items)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(
+		`UPDATE meal_record SET items = $3 WHERE id = $1 AND user_id = $2`,
+		id, userID, normalizeJSON(items, "[]"),
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	record.Items = items
+	return record, nil
+}
+
+// ListByUserBetween recorded_at 在 [start, end) 内的记录，按时间升序。
+func (r *MealRecordRepository) ListByUserBetween(userID int64, start time.Time, end time.Time) ([]model.MealRecord, error) {
+	query := `
+		SELECT id, user_id, source, items, image_urls, image_asset_ids, ratings, meta, recorded_at, created_at
+		FROM meal_record
+		WHERE user_id = $1
+		  AND recorded_at >= $2
+		  AND recorded_at < $3
+		ORDER BY recorded_at, id
+	`
+	rows, err := r.db.Query(query, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []model.MealRecord
+	for rows.Next() {
+		var record model.MealRecord
+		if err := rows.Scan(
+			&record.ID,
+			&record.UserID,
+			&record.Source,
+			&record.Items,
+			&record.ImageUrls,
+			(*pq.Int64Array)(&record.ImageAssetIDs),
+			&record.Ratings,
+			&record.Meta,
+			&record.RecordedAt,
+			&record.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -14,10 +14,24 @@ func NewMenuScanRepository(db *sql.DB) *MenuScanRepository {
 	return &MenuScanRepository{db: db}
 }
 
+// EnsureTable 补齐 menu_scan 表后续新增的列，基础表结构见 DB/db.md。
+func (r *MenuScanRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	// 入库图片（image_asset）的 ID，与 image_urls 一一对应
+	if _, err := r.db.Exec(`ALTER TABLE menu_scan ADD COLUMN IF NOT EXISTS image_asset_ids BIGINT[]`); err != nil {
+		return err
+	}
+	// 命中识别缓存且不计费的记录不计入每日额度
+	_, err := r.db.Exec(`ALTER TABLE menu_scan ADD COLUMN IF NOT EXISTS quota_exempt BOOLEAN NOT NULL DEFAULT FALSE`)
+	return err
+}
+
 func (r *MenuScanRepository) Create(scan *model.MenuScan) error {
 	query := `
-		INSERT INTO menu_scan (user_id, raw_image_url, raw_image_urls, ocr_text, parsed_menu, restaurant_hint)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO menu_scan (user_id, raw_image_url, raw_image_urls, image_asset_ids, ocr_text, parsed_menu, restaurant_hint, quota_exempt)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, created_at
 	`
 	rawImageURLs := normalizeJSON(scan.RawImageURLs, "[]")
@@ -27,9 +41,11 @@ func (r *MenuScanRepository) Create(scan *model.MenuScan) error {
 		scan.UserID,
 		scan.RawImageURL,
 		rawImageURLs,
+		nullableInt64Array(scan.ImageAssetIDs),
 		scan.OCRText,
 		parsedMenu,
 		scan.RestaurantHint,
+		scan.QuotaExempt,
 	).Scan(&scan.ID, &scan.CreatedAt)
 }
 
@@ -45,7 +61,38 @@ func (r *MenuScanRepository) CountByUserBetween(
 		WHERE user_id = $1
 		  AND created_at >= $2
 		  AND created_at < $3
+		  AND NOT quota_exempt
 	`
 	err := r.db.QueryRow(query, userID, start, end).Scan(&count)
 	return count, err
 }
+
+func (r *MenuScanRepository) ListByUser(userID int64, limit int) ([]model.MenuScan, error) {
+	query := `
+		SELECT id, user_id, ocr_text, restaurant_hint, created_at
+		FROM menu_scan
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scans []model.MenuScan
+	for rows.Next() {
+		var scan model.MenuScan
+		var ocrText sql.NullString
+		if err := rows.Scan(&scan.ID, &scan.UserID, &ocrText, &scan.RestaurantHint, &scan.CreatedAt); err != nil {
+			return nil, err
+		}
+		scan.OCRText = ocrText.String
+		scans = append(scans, scan)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return scans, nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type QuotaReservationRepository struct {
+	db *sql.DB
+}
+
+func NewQuotaReservationRepository(db *sql.DB) *QuotaReservationRepository {
+	return &QuotaReservationRepository{db: db}
+}
+
+func (r *QuotaReservationRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS quota_reservation (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			kind VARCHAR(20) NOT NULL,
+			units INT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'reserved',
+			created_at TIMESTAMP DEFAULT NOW(),
+			expires_at TIMESTAMP NOT NULL,
+			settled_at TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`ALTER TABLE quota_reservation ADD COLUMN IF NOT EXISTS retained BOOLEAN NOT NULL DEFAULT FALSE`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`ALTER TABLE quota_reservation ADD COLUMN IF NOT EXISTS limit_in_flight BOOLEAN NOT NULL DEFAULT FALSE`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_quota_reservation_active
+		ON quota_reservation(user_id, kind) WHERE status = 'reserved'
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_quota_reservation_retained
+		ON quota_reservation(user_id, kind, created_at) WHERE retained
+	`)
+	return err
+}
+
+// WithUserLock 在事务内持有用户级 advisory lock 执行 fn，同一用户的检查与预留串行执行。
+func (r *QuotaReservationRepository) WithUserLock(userID int64, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, userID); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Active 未确认且未过期、受并发上限约束（limit_in_flight）的预留份数，以及占用的单位合计：
+// 未确认的预留加上 since 之后已确认的 retained 预留。
+func (r *QuotaReservationRepository) Active(tx *sql.Tx, userID int64, kind string, since time.Time) (int, int, error) {
+	var count, units int
+	err := tx.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'reserved' AND limit_in_flight),
+			COALESCE(SUM(units), 0)
+		FROM quota_reservation
+		WHERE user_id = $1 AND kind = $2
+		  AND (
+			(status = 'reserved' AND expires_at > NOW())
+			OR (status = 'committed' AND retained AND created_at >= $3)
+		  )
+	`, userID, kind, since).Scan(&count, &units)
+	return count, units, err
+}
+
+// Insert retained 为 true 时预留确认后继续占用额度（对应请求不写用量记录）；
+// limitInFlight 为 true 时未确认期间计入并发上限（AI 请求）。
+func (r *QuotaReservationRepository) Insert(tx *sql.Tx, userID int64, kind string, units int, ttl time.Duration, retained bool, limitInFlight bool) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`
+		INSERT INTO quota_reservation (user_id, kind, units, expires_at, retained, limit_in_flight)
+		VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second', $5, $6)
+		RETURNING id
+	`, userID, kind, units, int64(ttl/time.Second), retained, limitInFlight).Scan(&id)
+	return id, err
+}
+
+// Settle 把仍处于预留状态的记录标记为 committed 或 released，重复调用无副作用。
+func (r *QuotaReservationRepository) Settle(ids []int64, status string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.db.Exec(`
+		UPDATE quota_reservation SET status = $2, settled_at = NOW()
+		WHERE id = ANY($1) AND status = 'reserved'
+	`, pq.Array(ids), status)
+	return err
+}
+
+// DeleteOlderThan 清理 days 天前的预留记录。
+func (r *QuotaReservationRepository) DeleteOlderThan(days int) (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM quota_reservation WHERE created_at < NOW() - $1 * INTERVAL '1 day'`, days)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"encoding/json"
+)
+
+type RecipeRepository struct {
+	db *sql.DB
+}
+
+func NewRecipeRepository(db *sql.DB) *RecipeRepository {
+	return &RecipeRepository{db: db}
+}
+
+func (r *RecipeRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS recipe (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			title TEXT NOT NULL,
+			servings NUMERIC(6,2) NOT NULL DEFAULT 1,
+			ingredients JSONB NOT NULL DEFAULT '[]',
+			instructions TEXT,
+			nutrition JSONB,
+			image_asset_id BIGINT REFERENCES image_asset(id) ON DELETE SET NULL,
+			image_key TEXT,
+			source VARCHAR(20) NOT NULL DEFAULT 'manual',
+			source_ref TEXT,
+			created_at TIMESTAMP DEFAULT NOW(),
+			updated_at TIMESTAMP DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_recipe_user_time ON recipe(user_id, updated_at DESC)`,
+	}
+	for _, statement := range statements {
+		if _, err := r.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const recipeColumns = `id, user_id, title, servings, ingredients, instructions, nutrition, image_asset_id, image_key, source, source_ref, created_at, updated_at`
+
+func (r *RecipeRepository) Create(recipe *model.Recipe) error {
+	ingredients, nutrition, err := marshalRecipe(recipe)
+	if err != nil {
+		return err
+	}
+	return r.db.QueryRow(`
+		INSERT INTO recipe (user_id, title, servings, ingredients, instructions, nutrition, image_asset_id, image_key, source, source_ref)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7::BIGINT, 0), NULLIF($8, ''), $9, NULLIF($10, ''))
+		RETURNING id, created_at, updated_at
+	`, recipe.UserID, recipe.Title, recipe.Servings, ingredients, recipe.Instructions, nutrition,
+		recipe.ImageAssetID, recipe.ImageKey, recipe.Source, recipe.SourceRef).
+		Scan(&recipe.ID, &recipe.CreatedAt, &recipe.UpdatedAt)
+}
+
+// Update 覆盖可编辑字段，菜谱不属于该用户时返回 false。
+func (r *RecipeRepository) Update(recipe *model.Recipe) (bool, error) {
+	ingredients, nutrition, err := marshalRecipe(recipe)
+	if err != nil {
+		return false, err
+	}
+	err = r.db.QueryRow(`
+		UPDATE recipe SET
+			title = $3,
+			servings = $4,
+			ingredients = $5,
+			instructions = NULLIF($6, ''),
+			nutrition = $7,
+			image_asset_id = NULLIF($8::BIGINT, 0),
+			image_key = NULLIF($9, ''),
+			updated_at = NOW()
+		WHERE id = $1 AND user_id = $2
+		RETURNING source, COALESCE(source_ref, ''), created_at, updated_at
+	`, recipe.ID, recipe.UserID, recipe.Title, recipe.Servings, ingredients, recipe.Instructions, nutrition,
+		recipe.ImageAssetID, recipe.ImageKey).
+		Scan(&recipe.Source, &recipe.SourceRef, &recipe.CreatedAt, &recipe.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// FindByID 不存在或不属于该用户时返回 nil。
+func (r *RecipeRepository) FindByID(userID int64, id int64) (*model.Recipe, error) {
+	row := r.db.QueryRow(`SELECT `+recipeColumns+` FROM recipe WHERE id = $1 AND user_id = $2`, id, userID)
+	recipe, err := scanRecipe(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return recipe, err
+}
+
+// ListByUser 最近更新的在前。
+func (r *RecipeRepository) ListByUser(userID int64, limit int) ([]model.Recipe, error) {
+	rows, err := r.db.Query(`
+		SELECT `+recipeColumns+`
+		FROM recipe WHERE user_id = $1
+		ORDER BY updated_at DESC, id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recipes []model.Recipe
+	for rows.Next() {
+		recipe, err := scanRecipe(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, *recipe)
+	}
+	return recipes, rows.Err()
+}
+
+// Delete 只能删除自己的菜谱，返回是否删除成功。
+func (r *RecipeRepository) Delete(userID int64, id int64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM recipe WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func marshalRecipe(recipe *model.Recipe) ([]byte, []byte, error) {
+	ingredients := recipe.Ingredients
+	if ingredients == nil {
+		ingredients = []model.RecipeIngredient{}
+	}
+	ingredientsJSON, err := json.Marshal(ingredients)
+	if err != nil {
+		return nil, nil, err
+	}
+	var nutritionJSON []byte
+	if recipe.Nutrition != nil {
+		if nutritionJSON, err = json.Marshal(recipe.Nutrition); err != nil {
+			return nil, nil, err
+		}
+	}
+	return ingredientsJSON, nutritionJSON, nil
+}
+
+func scanRecipe(row rowScanner) (*model.Recipe, error) {
+	var recipe model.Recipe
+	var instructions, imageKey, sourceRef sql.NullString
+	var imageAssetID sql.NullInt64
+	var ingredients, nutrition []byte
+	if err := row.Scan(
+		&recipe.ID, &recipe.UserID, &recipe.Title, &recipe.Servings, &ingredients, &instructions,
+		&nutrition, &imageAssetID, &imageKey, &recipe.Source, &sourceRef, &recipe.CreatedAt, &recipe.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(ingredients, &recipe.Ingredients); err != nil {
+		return nil, err
+	}
+	parsed, err := model.ParseNutrition(nutrition)
+	if err != nil {
+		return nil, err
+	}
+	recipe.Nutrition = parsed
+	recipe.Instructions = instructions.String
+	recipe.ImageAssetID = imageAssetID.Int64
+	recipe.ImageKey = imageKey.String
+	recipe.SourceRef = sourceRef.String
+	return &recipe, nil
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"database/sql"
+	"eatclean/internal/model"
+	"time"
 )
 
 type SettingsRepository struct {
@@ -53,3 +55,21 @@ func (r *SettingsRepository) Get(userID int64) ([]byte, error) {
 	}
 	return settings, nil
 }
+
+// GetRecord 返回设置及其最后更新时间，不存在时返回 nil。
+func (r *SettingsRepository) GetRecord(userID int64) (*model.UserSettings, error) {
+	query := `SELECT settings, updated_at FROM user_settings WHERE user_id = $1`
+	record := &model.UserSettings{UserID: userID}
+	var updatedAt sql.NullTime
+	err := r.db.QueryRow(query, userID).Scan(&record.Settings, &updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if updatedAt.Valid {
+		record.UpdatedAt = updatedAt.Time.Format(time.RFC3339)
+	}
+	return record, nil
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"database/sql"
+	"eatclean/internal/model"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type VisionCacheRepository struct {
+	db *sql.DB
+}
+
+func NewVisionCacheRepository(db *sql.DB) *VisionCacheRepository {
+	return &VisionCacheRepository{db: db}
+}
+
+func (r *VisionCacheRepository) EnsureTable() error {
+	if r.db == nil {
+		return sql.ErrConnDone
+	}
+	_, err := r.db.Exec(`
+		CREATE TABLE IF NOT EXISTS vision_cache (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT REFERENCES app_user(id) ON DELETE CASCADE,
+			route VARCHAR(40) NOT NULL,
+			image_key CHAR(64) NOT NULL,
+			image_phashes BIGINT[],
+			prompt_version TEXT NOT NULL,
+			context_fingerprint VARCHAR(64) NOT NULL,
+			result JSONB NOT NULL,
+			hit_count INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP DEFAULT NOW(),
+			last_hit_at TIMESTAMP,
+			UNIQUE (user_id, route, image_key, prompt_version, context_fingerprint)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_vision_cache_lookup
+		ON vision_cache(user_id, route, prompt_version, context_fingerprint, created_at DESC)
+	`)
+	return err
+}
+
+const visionCacheColumns = `id, user_id, route, image_key, image_phashes, prompt_version, context_fingerprint, result, hit_count, created_at`
+
+// FindExact 查找窗口期内图片集合完全相同的缓存。
+func (r *VisionCacheRepository) FindExact(
+	userID int64,
+	route string,
+	imageKey string,
+	promptVersion string,
+	fingerprint string,
+	since time.Time,
+) (*model.VisionCacheEntry, error) {
+	row := r.db.QueryRow(`
+		SELECT `+visionCacheColumns+`
+		FROM vision_cache
+		WHERE user_id = $1 AND route = $2 AND image_key = $3
+		  AND prompt_version = $4 AND context_fingerprint = $5
+		  AND created_at >= $6
+	`, userID, route, imageKey, promptVersion, fingerprint, since)
+	entry, err := scanVisionCacheEntry(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return entry, err
+}
+
+// ListCandidates 返回窗口期内图片数量相同的缓存，供调用方按感知哈希比较近似图片。
+func (r *VisionCacheRepository) ListCandidates(
+	userID int64,
+	route string,
+	promptVersion string,
+	fingerprint string,
+	imageCount int,
+	since time.Time,
+	limit int,
+) ([]model.VisionCacheEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT `+visionCacheColumns+`
+		FROM vision_cache
+		WHERE user_id = $1 AND route = $2 AND prompt_version = $3 AND context_fingerprint = $4
+		  AND cardinality(image_phashes) = $5
+		  AND created_at >= $6
+		ORDER BY created_at DESC
+		LIMIT $7
+	`, userID, route, promptVersion, fingerprint, imageCount, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.VisionCacheEntry
+	for rows.Next() {
+		entry, err := scanVisionCacheEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Upsert 写入缓存；同一键已存在时覆盖结果并重新开始计时。
+func (r *VisionCacheRepository) Upsert(entry *model.VisionCacheEntry) error {
+	query := `
+		INSERT INTO vision_cache (user_id, route, image_key, image_phashes, prompt_version, context_fingerprint, result)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, route, image_key, prompt_version, context_fingerprint)
+		DO UPDATE SET
+			image_phashes = EXCLUDED.image_phashes,
+			result = EXCLUDED.result,
+			hit_count = 0,
+			created_at = NOW(),
+			last_hit_at = NULL
+		RETURNING id, created_at
+	`
+	return r.db.QueryRow(
+		query,
+		entry.UserID,
+		entry.Route,
+		entry.ImageKey,
+		pq.Array(entry.ImagePHashes),
+		entry.PromptVersion,
+		entry.ContextFingerprint,
+		normalizeJSON(entry.Result, "{}"),
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+func (r *VisionCacheRepository) MarkHit(id int64) error {
+	_, err := r.db.Exec(`UPDATE vision_cache SET hit_count = hit_count + 1, last_hit_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// DeleteBefore 清理过期缓存，返回删除条数。
+func (r *VisionCacheRepository) DeleteBefore(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM vision_cache WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanVisionCacheEntry(row rowScanner) (*model.VisionCacheEntry, error) {
+	var entry model.VisionCacheEntry
+	err := row.Scan(
+		&entry.ID,
+		&entry.UserID,
+		&entry.Route,
+		&entry.ImageKey,
+		(*pq.Int64Array)(&entry.ImagePHashes),
+		&entry.PromptVersion,
+		&entry.ContextFingerprint,
+		&entry.Result,
+		&entry.HitCount,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
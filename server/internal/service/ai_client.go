@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"eatclean/internal/config"
+)
+
+// AI 路由名称，对应 config.yaml 中 qwen.routes 的 key。
+const (
+	AIRouteChat            = "chat"
+	AIRouteDiscoverPlan    = "discover_plan"
+	AIRouteDiscoverReplace = "discover_replace"
+	AIRouteFoodSearch      = "food_search"
+	AIRouteMenuScan        = "menu_scan"
+	AIRouteFoodScan        = "food_scan"
+	AIRouteIngredientScan  = "ingredient_scan"
+	AIRouteLabelScan       = "label_scan"
+	AIRouteImagePrecheck   = "image_precheck"
+)
+
+// aiClient 封装 DashScope 兼容接口的 chat/completions 调用，
+// 按路由选择模型与参数，主模型不可用（429/5xx/超时/网络错误/模型不存在）时依次尝试 fallbacks。
+// 超时、重试、熔断与并发限制由共享的 AITransport 负责。
+type aiClient struct {
+	apiKey       string
+	baseURL      string
+	defaultModel string
+	routes       map[string]config.AIRouteConfig
+	transport    *AITransport
+}
+
+func newAIClient(cfg *config.QwenConfig, transport *AITransport) *aiClient {
+	if cfg == nil {
+		return nil
+	}
+	return &aiClient{
+		apiKey:       cfg.APIKey,
+		baseURL:      strings.TrimRight(cfg.BaseURL, "/"),
+		defaultModel: cfg.Model,
+		routes:       cfg.Routes,
+		transport:    transport,
+	}
+}
+
+// routeAvailable 路由未熔断时返回 true。
+func (c *aiClient) routeAvailable(route string) bool {
+	return c != nil && c.transport.RouteAvailable(route)
+}
+
+func (c *aiClient) isEnabled() bool {
+	return c != nil && c.apiKey != "" && c.baseURL != "" && c.defaultModel != ""
+}
+
+// modelsFor 返回路由的模型调用顺序：主模型在前，fallbacks 依次在后；
+// 未配置的路由使用 qwen.model。
+func (c *aiClient) modelsFor(route string) []string {
+	routeCfg := c.routes[route]
+	if strings.TrimSpace(routeCfg.Model) == "" {
+		if strings.TrimSpace(c.defaultModel) == "" {
+			return nil
+		}
+		return []string{strings.TrimSpace(c.defaultModel)}
+	}
+	candidates := append([]string{routeCfg.Model}, routeCfg.Fallbacks...)
+	models := make([]string, 0, len(candidates))
+	seen := make(map[string]struct{}, len(candidates))
+	for _, model := range candidates {
+		model = strings.TrimSpace(model)
+		if model == "" {
+			continue
+		}
+		if _, ok := seen[model]; ok {
+			continue
+		}
+		seen[model] = struct{}{}
+		models = append(models, model)
+	}
+	return models
+}
+
+// aiRequestOptions 单次调用的附加参数。
+type aiRequestOptions struct {
+	// jsonMode 请求 response_format=json_object，仅在路由开启 json_mode 时生效
+	jsonMode bool
+}
+
+func (c *aiClient) complete(ctx context.Context, route string, messages []map[string]interface{}) (string, error) {
+	return c.completeWithOptions(ctx, route, messages, aiRequestOptions{})
+}
+
+func (c *aiClient) completeWithOptions(ctx context.Context, route string, messages []map[string]interface{}, opts aiRequestOptions) (string, error) {
+	if !c.isEnabled() {
+		return "", errors.New("ai service not configured")
+	}
+	models := c.modelsFor(route)
+	if len(models) == 0 {
+		return "", fmt.Errorf("no model configured for route %s", route)
+	}
+	return c.transport.Call(ctx, route, func(ctx context.Context) (string, error) {
+		return c.completeWithModels(ctx, route, models, messages, opts)
+	})
+}
+
+func (c *aiClient) completeWithModels(ctx context.Context, route string, models []string, messages []map[string]interface{}, opts aiRequestOptions) (string, error) {
+	var lastErr error
+	for idx, model := range models {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		reply, err := c.completeWithModel(ctx, route, model, messages, opts)
+		if err == nil {
+			if idx > 0 {
+				log.Printf("ai route %s served by fallback model %s", route, model)
+			}
+			return reply, nil
+		}
+		lastErr = err
+		log.Printf("ai route %s model %s failed: %v", route, model, err)
+		// 请求本身的问题（鉴权、参数、内容审核、结果无效）换模型也不会成功
+		if !fallbackAIError(err) {
+			return "", err
+		}
+	}
+	return "", lastErr
+}
+
+func (c *aiClient) completeWithModel(ctx context.Context, route string, model string, messages []map[string]interface{}, opts aiRequestOptions) (string, error) {
+	routeCfg := c.routes[route]
+	reqBody := map[string]interface{}{
+		"model":    model,
+		"messages": messages,
+	}
+	if routeCfg.Temperature != nil {
+		reqBody["temperature"] = *routeCfg.Temperature
+	}
+	if routeCfg.MaxTokens > 0 {
+		reqBody["max_tokens"] = routeCfg.MaxTokens
+	}
+	if opts.jsonMode && routeCfg.JSONMode {
+		reqBody["response_format"] = map[string]string{"type": "json_object"}
+	}
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	newRequest := func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(
+			ctx,
+			http.MethodPost,
+			fmt.Sprintf("%s/chat/completions", c.baseURL),
+			bytes.NewReader(payload),
+		)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	}
+	return c.transport.Do(ctx, route, newRequest, decodeAIResponse)
+}
+
+// decodeAIResponse 解析 chat/completions 响应，返回第一条回复文本。
+func decodeAIResponse(resp *http.Response) (string, error) {
+	var decoded struct {
+		Choices []struct {
+			Message struct {
+				Content interface{} `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return "", &aiStatusError{status: resp.StatusCode}
+		}
+		return "", err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		statusErr := &aiStatusError{status: resp.StatusCode}
+		if decoded.Error != nil {
+			statusErr.message = decoded.Error.Message
+		}
+		return "", statusErr
+	}
+	if decoded.Error != nil {
+		return "", errors.New(decoded.Error.Message)
+	}
+	if len(decoded.Choices) == 0 {
+		return "", errors.New("empty response")
+	}
+
+	return parseAIContent(decoded.Choices[0].Message.Content)
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"eatclean/internal/config"
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
+
+// 异步 AI 任务类型。
+const (
+	AIJobMenuScan   = "menu_scan"
+	AIJobMealPhoto  = "meal_photo"
+	AIJobWeeklyMenu = "weekly_menu"
+)
+
+// ErrAIJobUnsupported 任务队列不可用或该类型未注册执行函数。
+var ErrAIJobUnsupported = errors.New("ai job kind not supported")
+
+// aiJobMetrics 任务统计：<kind>.enqueued / .succeeded / .retried / .failed / .lost
+var aiJobMetrics = expvar.NewMap("ai_job")
+
+// AIJobHandler 执行任务并返回写入 result 的结果。
+type AIJobHandler func(ctx context.Context, job *model.AIJob) (interface{}, error)
+
+// AIJobError 不可重试的失败（如图片预检不通过），Code/Message 原样返回给客户端。
+type AIJobError struct {
+	Code    string
+	Message string
+}
+
+func (e *AIJobError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func NewAIJobError(code string, message string) *AIJobError {
+	return &AIJobError{Code: code, Message: message}
+}
+
+// AIJobService Postgres 任务队列：HTTP 请求完成校验与额度检查后入队，worker 领取执行并保存结果。
+// 失败按指数退避重试，超过 max_attempts 后标记失败；实例宕机遗留的任务超过 stale_seconds 后被重新领取，
+// 已用完尝试次数的则标记失败。客户端通过 GET /jobs/:id 长轮询或 /jobs/:id/events 推送获取结果。
+type AIJobService struct {
+	repo       *repository.AIJobRepository
+	quota      *QuotaService
+	cfg        config.AIJobConfig
+	instanceID string
+
+	mu       sync.RWMutex
+	handlers map[string]AIJobHandler
+}
+
+func NewAIJobService(repo *repository.AIJobRepository, quota *QuotaService, cfg config.AIJobConfig) *AIJobService {
+	host, _ := os.Hostname()
+	return &AIJobService{
+		repo:       repo,
+		quota:      quota,
+		cfg:        cfg,
+		instanceID: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		handlers:   map[string]AIJobHandler{},
+	}
+}
+
+func (s *AIJobService) IsEnabled() bool {
+	return s != nil && s.repo != nil
+}
+
+// Register 注册任务执行函数，需在 Start 之前调用。
+func (s *AIJobService) Register(kind string, handler AIJobHandler) {
+	if s == nil || handler == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[kind] = handler
+}
+
+// Supports 该类型能否异步执行，不支持时调用方应按同步流程处理。
+func (s *AIJobService) Supports(kind string) bool {
+	if !s.IsEnabled() {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.handlers[kind] != nil
+}
+
+// Enqueue 写入任务，payload 序列化为 JSON。reservationIDs 为请求已预留的额度，由任务结束时确认或释放。
+func (s *AIJobService) Enqueue(userID int64, kind string, payload interface{}, reservationIDs ...int64) (*model.AIJob, error) {
+	if !s.Supports(kind) {
+		return nil, ErrAIJobUnsupported
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	job := &model.AIJob{
+		UserID:         userID,
+		Kind:           kind,
+		Status:         model.AIJobQueued,
+		Payload:        data,
+		MaxAttempts:    s.cfg.MaxAttempts,
+		ReservationIDs: reservationIDs,
+	}
+	if err := s.repo.Create(job); err != nil {
+		return nil, err
+	}
+	aiJobMetrics.Add(kind+".enqueued", 1)
+	return job, nil
+}
+
+// Get 查询用户自己的任务，不存在时返回 nil。
+func (s *AIJobService) Get(userID int64, id int64) (*model.AIJob, error) {
+	if !s.IsEnabled() {
+		return nil, ErrAIJobUnsupported
+	}
+	return s.repo.FindByID(userID, id)
+}
+
+// Wait 长轮询：任务结束、超时或请求取消时返回最新状态。
+func (s *AIJobService) Wait(ctx context.Context, userID int64, id int64, timeout time.Duration) (*model.AIJob, error) {
+	job, err := s.Get(userID, id)
+	if err != nil || job == nil || job.Finished() || timeout <= 0 {
+		return job, err
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(s.pollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return job, nil
+		case <-deadline.C:
+			return job, nil
+		case <-ticker.C:
+			latest, err := s.Get(userID, id)
+			if err != nil {
+				return job, err
+			}
+			if latest == nil {
+				return job, nil
+			}
+			job = latest
+			if job.Finished() {
+				return job, nil
+			}
+		}
+	}
+}
+
+// Watch 推送任务状态：先回调当前状态，之后状态或尝试次数变化时回调，任务结束、ctx 取消或 notify 出错时返回。
+// 任务不存在时返回 nil 且不回调。
+func (s *AIJobService) Watch(ctx context.Context, userID int64, id int64, notify func(*model.AIJob) error) (*model.AIJob, error) {
+	job, err := s.Get(userID, id)
+	if err != nil || job == nil {
+		return nil, err
+	}
+	if err := notify(job); err != nil || job.Finished() {
+		return job, err
+	}
+	ticker := time.NewTicker(s.pollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return job, nil
+		case <-ticker.C:
+			latest, err := s.Get(userID, id)
+			if err != nil {
+				return job, err
+			}
+			if latest == nil {
+				return job, nil
+			}
+			if latest.Status == job.Status && latest.Attempts == job.Attempts {
+				continue
+			}
+			job = latest
+			if err := notify(job); err != nil || job.Finished() {
+				return job, err
+			}
+		}
+	}
+}
+
+// Start 启动 workers 个执行协程、超时任务回收与历史任务清理；workers 为负数时本实例只入队。
+func (s *AIJobService) Start(ctx context.Context) {
+	if !s.IsEnabled() || s.cfg.Workers <= 0 {
+		return
+	}
+	kinds := s.kinds()
+	if len(kinds) == 0 {
+		return
+	}
+	for i := 0; i < s.cfg.Workers; i++ {
+		go s.work(ctx, fmt.Sprintf("%s-%d", s.instanceID, i), kinds)
+	}
+	go s.reapStale(ctx)
+	go s.cleanup(ctx)
+}
+
+func (s *AIJobService) kinds() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	kinds := make([]string, 0, len(s.handlers))
+	for kind := range s.handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func (s *AIJobService) work(ctx context.Context, workerID string, kinds []string) {
+	for ctx.Err() == nil {
+		processed, err := s.runOnce(ctx, workerID, kinds)
+		if err != nil {
+			log.Printf("ai job worker %s: %v", workerID, err)
+		}
+		if processed && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.pollInterval()):
+		}
+	}
+}
+
+// runOnce 领取并执行一个任务，没有到期任务时 processed 为 false。
+func (s *AIJobService) runOnce(ctx context.Context, workerID string, kinds []string) (bool, error) {
+	job, err := s.repo.Claim(kinds, workerID, s.staleAfter())
+	if err != nil || job == nil {
+		return false, err
+	}
+	s.mu.RLock()
+	handler := s.handlers[job.Kind]
+	s.mu.RUnlock()
+
+	result, runErr := s.execute(ctx, handler, job)
+	if runErr == nil {
+		payload, err := json.Marshal(result)
+		if err != nil {
+			runErr = err
+		} else {
+			owned, err := s.repo.Complete(job.ID, workerID, payload)
+			if err != nil {
+				return true, err
+			}
+			if !owned {
+				// 执行超时被其他 worker 重新领取，以对方的结果为准
+				aiJobMetrics.Add(job.Kind+".lost", 1)
+				return true, nil
+			}
+			aiJobMetrics.Add(job.Kind+".succeeded", 1)
+			s.quota.Commit(job.ReservationIDs)
+			return true, nil
+		}
+	}
+
+	code, message, retryable := aiJobFailure(runErr)
+	log.Printf("ai job %d (%s) attempt %d/%d failed: %v", job.ID, job.Kind, job.Attempts, job.MaxAttempts, runErr)
+	if retryable && job.Attempts < job.MaxAttempts {
+		if _, err := s.repo.Retry(job.ID, workerID, s.backoff(job.Attempts), code, message); err != nil {
+			return true, err
+		}
+		aiJobMetrics.Add(job.Kind+".retried", 1)
+		return true, nil
+	}
+	owned, err := s.repo.Fail(job.ID, workerID, code, message)
+	if err != nil {
+		return true, err
+	}
+	if !owned {
+		aiJobMetrics.Add(job.Kind+".lost", 1)
+		return true, nil
+	}
+	aiJobMetrics.Add(job.Kind+".failed", 1)
+	s.quota.Release(job.ReservationIDs)
+	return true, nil
+}
+
+// execute 单次执行带超时，panic 视为可重试失败。
+func (s *AIJobService) execute(ctx context.Context, handler AIJobHandler, job *model.AIJob) (result interface{}, err error) {
+	if handler == nil {
+		return nil, NewAIJobError("unsupported_kind", "任务类型不支持")
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("ai job panic: %v", recovered)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// reapStale 定期把超时且已用完尝试次数的任务标记为失败并释放额度，避免导致 worker 崩溃的任务无限重试。
+func (s *AIJobService) reapStale(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			jobs, err := s.repo.FailStale(s.staleAfter(), "worker_lost", "处理中断，请重新提交")
+			if err != nil {
+				log.Printf("ai job reap failed: %v", err)
+				continue
+			}
+			for _, job := range jobs {
+				log.Printf("ai job %d (%s) abandoned after %d attempts", job.ID, job.Kind, job.Attempts)
+				aiJobMetrics.Add(job.Kind+".failed", 1)
+				s.quota.Release(job.ReservationIDs)
+			}
+		}
+	}
+}
+
+func (s *AIJobService) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.repo.DeleteFinishedOlderThan(s.cfg.RetentionDays); err != nil {
+				log.Printf("ai job cleanup failed: %v", err)
+			}
+		}
+	}
+}
+
+// backoff 第 attempt 次失败后的等待时间：base * 2^(attempt-1)，不超过 retry_max_seconds。
+func (s *AIJobService) backoff(attempt int) time.Duration {
+	delay := time.Duration(s.cfg.RetryBaseSeconds) * time.Second
+	limit := time.Duration(s.cfg.RetryMaxSeconds) * time.Second
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// staleAfter 不短于单次执行超时，避免仍在执行的任务被其他 worker 重复领取。
+func (s *AIJobService) staleAfter() time.Duration {
+	stale := time.Duration(s.cfg.StaleSeconds) * time.Second
+	if minimum := time.Duration(s.cfg.TimeoutSeconds)*time.Second + time.Minute; stale < minimum {
+		stale = minimum
+	}
+	return stale
+}
+
+func (s *AIJobService) pollInterval() time.Duration {
+	if s.cfg.PollIntervalMs <= 0 {
+		return time.Second
+	}
+	return time.Duration(s.cfg.PollIntervalMs) * time.Millisecond
+}
+
+// aiJobFailure 把执行错误转换为客户端可见的错误码，AIJobError 以外的错误均可重试。
+func aiJobFailure(err error) (code string, message string, retryable bool) {
+	var jobErr *AIJobError
+	var outputErr *AIOutputError
+	switch {
+	case errors.As(err, &jobErr):
+		return jobErr.Code, jobErr.Message, false
+	case IsAIUnavailable(err):
+		return "ai_unavailable", "AI 服务繁忙，请稍后再试", true
+	case errors.As(err, &outputErr):
+		return "ai_output_invalid", "识别结果无效，请重试", true
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout", "处理超时，请重试", true
+	}
+	return "failed", "处理失败，请重试", true
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AIOutput 为模型结构化输出的 Go 定义，Validate 返回字段级错误。
+type AIOutput interface {
+	Validate() []AIFieldError
+}
+
+type AIFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e AIFieldError) String() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// AIOutputError 表示模型输出在修复重试后仍未通过校验。
+type AIOutputError struct {
+	Route  string
+	Errors []AIFieldError
+}
+
+func (e *AIOutputError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		parts = append(parts, fieldErr.String())
+	}
+	return fmt.Sprintf("ai output invalid (%s): %s", e.Route, strings.Join(parts, "; "))
+}
+
+// AINumber 兼容模型把数字写成字符串（如 "450kcal"、"12.5 g"）的情况。
+type AINumber float64
+
+var aiNumberPattern = regexp.MustCompile(`-?\d+(?:\.\d+)?`)
+
+func (n *AINumber) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "" || raw == "null" {
+		*n = 0
+		return nil
+	}
+	if strings.HasPrefix(raw, `"`) {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		match := aiNumberPattern.FindString(text)
+		if match == "" {
+			return fmt.Errorf("not a number: %q", text)
+		}
+		raw = match
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("not a number: %s", raw)
+	}
+	*n = AINumber(value)
+	return nil
+}
+
+func (n AINumber) Int() int {
+	return int(math.Round(float64(n)))
+}
+
+// AIStringList 兼容数组与 "a、b、c" 形式的字符串。
+type AIStringList []string
+
+func (l *AIStringList) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "" || raw == "null" {
+		*l = nil
+		return nil
+	}
+	if strings.HasPrefix(raw, "[") {
+		var items []interface{}
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		out := make([]string, 0, len(items))
+		for _, item := range items {
+			if text := strings.TrimSpace(readString(item)); text != "" {
+				out = append(out, text)
+			}
+		}
+		*l = out
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	segments := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == '/' || r == '|'
+	})
+	out := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg = strings.TrimSpace(seg); seg != "" {
+			out = append(out, seg)
+		}
+	}
+	*l = out
+	return nil
+}
+
+// AIDish 菜单/食物/配料表识别返回的单个菜品卡片。
+type AIDish struct {
+	ID          string       `json:"id,omitempty"`
+	Name        string       `json:"name"`
+	Restaurant  string       `json:"restaurant,omitempty"`
+	Score       AINumber     `json:"score"`
+	ScoreLabel  string       `json:"scoreLabel,omitempty"`
+	ScoreColor  string       `json:"scoreColor,omitempty"`
+	Kcal        AINumber     `json:"kcal"`
+	Protein     AINumber     `json:"protein"`
+	Carbs       AINumber     `json:"carbs"`
+	Fat         AINumber     `json:"fat"`
+	WeightG     AINumber     `json:"weight_g,omitempty"`
+	Portion     string       `json:"portion,omitempty"`
+	Tag         string       `json:"tag,omitempty"`
+	Recommended *bool        `json:"recommended,omitempty"`
+	Components  AIStringList `json:"components,omitempty"`
+	Reason      string       `json:"reason,omitempty"`
+	// 每份微量营养素估算，未给出为 0
+	SodiumMg      AINumber `json:"sodium_mg,omitempty"`
+	SugarG        AINumber `json:"sugar_g,omitempty"`
+	AddedSugarG   AINumber `json:"added_sugar_g,omitempty"`
+	FiberG        AINumber `json:"fiber_g,omitempty"`
+	SaturatedFatG AINumber `json:"saturated_fat_g,omitempty"`
+}
+
+func (d AIDish) validate(prefix string) []AIFieldError {
+	var errs []AIFieldError
+	if strings.TrimSpace(d.Name) == "" {
+		errs = append(errs, AIFieldError{Field: prefix + ".name", Message: "不能为空"})
+	}
+	errs = appendRangeError(errs, prefix+".score", float64(d.Score), 0, 100)
+	errs = appendRangeError(errs, prefix+".kcal", float64(d.Kcal), 0, 4000)
+	errs = appendRangeError(errs, prefix+".protein", float64(d.Protein), 0, 400)
+	errs = appendRangeError(errs, prefix+".carbs", float64(d.Carbs), 0, 600)
+	errs = appendRangeError(errs, prefix+".fat", float64(d.Fat), 0, 200)
+	errs = appendRangeError(errs, prefix+".weight_g", float64(d.WeightG), 0, 3000)
+	errs = appendRangeError(errs, prefix+".sodium_mg", float64(d.SodiumMg), 0, 10000)
+	errs = appendRangeError(errs, prefix+".sugar_g", float64(d.SugarG), 0, 300)
+	errs = appendRangeError(errs, prefix+".added_sugar_g", float64(d.AddedSugarG), 0, 300)
+	errs = appendRangeError(errs, prefix+".fiber_g", float64(d.FiberG), 0, 100)
+	errs = appendRangeError(errs, prefix+".saturated_fat_g", float64(d.SaturatedFatG), 0, 200)
+	return errs
+}
+
+type AINutritionHighlight struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Unit  string `json:"unit"`
+}
+
+// UnmarshalJSON 允许 value 为数字。
+func (h *AINutritionHighlight) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name  interface{} `json:"name"`
+		Value interface{} `json:"value"`
+		Unit  interface{} `json:"unit"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	h.Name = strings.TrimSpace(readString(raw.Name))
+	h.Value = strings.TrimSpace(readString(raw.Value))
+	h.Unit = strings.TrimSpace(readString(raw.Unit))
+	return nil
+}
+
+// AIDishAnalysis 菜单扫描、食物照片、配料表分析的统一输出。
+type AIDishAnalysis struct {
+	RecognizedText      string                 `json:"recognized_text,omitempty"`
+	Summary             string                 `json:"summary"`
+	Advice              string                 `json:"advice,omitempty"`
+	Dishes              []AIDish               `json:"dishes"`
+	Actions             AIStringList           `json:"actions,omitempty"`
+	IngredientList      AIStringList           `json:"ingredient_list,omitempty"`
+	RiskAlerts          AIStringList           `json:"risk_alerts,omitempty"`
+	NutritionHighlights []AINutritionHighlight `json:"nutrition_highlights,omitempty"`
+	Recommendation      string                 `json:"recommendation,omitempty"`
+}
+
+func (a *AIDishAnalysis) Validate() []AIFieldError {
+	var errs []AIFieldError
+	if len(a.Dishes) == 0 {
+		errs = append(errs, AIFieldError{Field: "dishes", Message: "至少包含 1 个菜品"})
+	}
+	for idx, dish := range a.Dishes {
+		errs = append(errs, dish.validate(fmt.Sprintf("dishes[%d]", idx))...)
+	}
+	return errs
+}
+
+// SummaryText 兼容模型使用 advice 字段输出建议。
+func (a *AIDishAnalysis) SummaryText() string {
+	if summary := strings.TrimSpace(a.Summary); summary != "" {
+		return summary
+	}
+	return strings.TrimSpace(a.Advice)
+}
+
+// AIDiscoverMeal 发现页/替换餐食的单个餐食卡片。
+type AIDiscoverMeal struct {
+	ID           string       `json:"id,omitempty"`
+	Title        string       `json:"title"`
+	MealType     string       `json:"meal_type"`
+	Calories     AINumber     `json:"calories"`
+	Protein      AINumber     `json:"protein"`
+	Fat          AINumber     `json:"fat"`
+	Carbs        AINumber     `json:"carbs"`
+	Ingredients  AIStringList `json:"ingredients"`
+	Instructions string       `json:"instructions"`
+	Benefits     string       `json:"benefits"`
+	TimeMinutes  AINumber     `json:"time_minutes"`
+}
+
+func (m AIDiscoverMeal) validate(prefix string) []AIFieldError {
+	var errs []AIFieldError
+	if strings.TrimSpace(m.Title) == "" {
+		errs = append(errs, AIFieldError{Field: prefix + ".title", Message: "不能为空"})
+	}
+	if len(m.Ingredients) == 0 {
+		errs = append(errs, AIFieldError{Field: prefix + ".ingredients", Message: "至少包含 1 种食材"})
+	}
+	if strings.TrimSpace(m.Instructions) == "" {
+		errs = append(errs, AIFieldError{Field: prefix + ".instructions", Message: "不能为空"})
+	}
+	if strings.TrimSpace(m.Benefits) == "" {
+		errs = append(errs, AIFieldError{Field: prefix + ".benefits", Message: "不能为空"})
+	}
+	errs = appendRangeError(errs, prefix+".calories", float64(m.Calories), 1, 4000)
+	errs = appendRangeError(errs, prefix+".protein", float64(m.Protein), 0, 400)
+	errs = appendRangeError(errs, prefix+".fat", float64(m.Fat), 0, 200)
+	errs = appendRangeError(errs, prefix+".carbs", float64(m.Carbs), 0, 600)
+	return errs
+}
+
+// AIDiscoverPlan 发现页当日计划输出。
+type AIDiscoverPlan struct {
+	PlanMeals       []AIDiscoverMeal `json:"plan_meals"`
+	Recommendations []AIDiscoverMeal `json:"recommendations"`
+}
+
+func (p *AIDiscoverPlan) Validate() []AIFieldError {
+	var errs []AIFieldError
+	if len(p.PlanMeals) < 3 {
+		errs = append(errs, AIFieldError{Field: "plan_meals", Message: "需要早餐/午餐/晚餐共 3 道"})
+	}
+	if len(p.Recommendations) == 0 {
+		errs = append(errs, AIFieldError{Field: "recommendations", Message: "至少包含 1 道推荐"})
+	}
+	for idx, meal := range p.PlanMeals {
+		errs = append(errs, meal.validate(fmt.Sprintf("plan_meals[%d]", idx))...)
+	}
+	for idx, meal := range p.Recommendations {
+		errs = append(errs, meal.validate(fmt.Sprintf("recommendations[%d]", idx))...)
+	}
+	return errs
+}
+
+// AIReplacementMeals 替换餐食输出。
+type AIReplacementMeals struct {
+	Meals []AIDiscoverMeal `json:"meals"`
+}
+
+func (r *AIReplacementMeals) Validate() []AIFieldError {
+	var errs []AIFieldError
+	if len(r.Meals) == 0 {
+		errs = append(errs, AIFieldError{Field: "meals", Message: "至少包含 1 个替换方案"})
+	}
+	for idx, meal := range r.Meals {
+		errs = append(errs, meal.validate(fmt.Sprintf("meals[%d]", idx))...)
+	}
+	return errs
+}
+
+// AIImageCheck 图片相关性预检结果，images 与输入图片顺序一致。
+type AIImageCheck struct {
+	Images []AIImageCheckItem `json:"images"`
+}
+
+type AIImageCheckItem struct {
+	// Category food / menu / ingredient_label / other
+	Category   string   `json:"category"`
+	Confidence AINumber `json:"confidence"`
+	Reason     string   `json:"reason,omitempty"`
+}
+
+var aiImageCategories = map[string]bool{"food": true, "menu": true, "ingredient_label": true, "other": true}
+
+func (c *AIImageCheck) Validate() []AIFieldError {
+	var errs []AIFieldError
+	if len(c.Images) == 0 {
+		errs = append(errs, AIFieldError{Field: "images", Message: "至少包含 1 张图片"})
+	}
+	for idx, item := range c.Images {
+		if !aiImageCategories[strings.TrimSpace(item.Category)] {
+			errs = append(errs, AIFieldError{Field: fmt.Sprintf("images[%d].category", idx), Message: "取值无效"})
+		}
+		errs = appendRangeError(errs, fmt.Sprintf("images[%d].confidence", idx), float64(item.Confidence), 0, 1)
+	}
+	return errs
+}
+
+// AIFoodInfo 食物搜索的每 100g 营养信息。
+type AIFoodInfo struct {
+	Name     string   `json:"name"`
+	Calories AINumber `json:"calories_kcal_per100g"`
+	Protein  AINumber `json:"protein_g_per100g"`
+	Fat      AINumber `json:"fat_g_per100g"`
+	Carbs    AINumber `json:"carbs_g_per100g"`
+	Desc     string   `json:"desc,omitempty"`
+	Advice   string   `json:"advice"`
+}
+
+func (f *AIFoodInfo) Validate() []AIFieldError {
+	var errs []AIFieldError
+	if strings.TrimSpace(f.Name) == "" {
+		errs = append(errs, AIFieldError{Field: "name", Message: "不能为空"})
+	}
+	errs = appendRangeError(errs, "calories_kcal_per100g", float64(f.Calories), 0, 900)
+	errs = appendRangeError(errs, "protein_g_per100g", float64(f.Protein), 0, 100)
+	errs = appendRangeError(errs, "fat_g_per100g", float64(f.Fat), 0, 100)
+	errs = appendRangeError(errs, "carbs_g_per100g", float64(f.Carbs), 0, 100)
+	if macros := float64(f.Protein + f.Fat + f.Carbs); macros > 100 {
+		errs = append(errs, AIFieldError{Field: "protein_g_per100g", Message: "蛋白质+脂肪+碳水超过 100g"})
+	}
+	return errs
+}
+
+// AINutritionLabel 包装营养成分表的逐行读数，单位换算与一致性校验由 ParseNutritionLabel 完成。
+type AINutritionLabel struct {
+	ProductName string `json:"product_name"`
+	// Basis 数值对应的基准：100g / 100ml / serving
+	Basis       string                 `json:"basis"`
+	ServingSize AINumber               `json:"serving_size"`
+	ServingUnit string                 `json:"serving_unit"`
+	Items       []AINutritionLabelItem `json:"items"`
+}
+
+type AINutritionLabelItem struct {
+	Name       string   `json:"name"`
+	Value      AINumber `json:"value"`
+	Unit       string   `json:"unit"`
+	NRVPercent AINumber `json:"nrv_percent"`
+}
+
+var aiNutritionLabelBases = map[string]bool{"100g": true, "100ml": true, "serving": true}
+
+func (l *AINutritionLabel) Validate() []AIFieldError {
+	var errs []AIFieldError
+	if !aiNutritionLabelBases[strings.TrimSpace(l.Basis)] {
+		errs = append(errs, AIFieldError{Field: "basis", Message: "取值应为 100g/100ml/serving"})
+	}
+	if strings.TrimSpace(l.Basis) == "serving" && l.ServingSize <= 0 {
+		errs = append(errs, AIFieldError{Field: "serving_size", Message: "按每份标示时必须给出每份的克数或毫升数"})
+	}
+	errs = appendRangeError(errs, "serving_size", float64(l.ServingSize), 0, 5000)
+	hasEnergy := false
+	for idx, item := range l.Items {
+		prefix := fmt.Sprintf("items[%d]", idx)
+		if strings.TrimSpace(item.Name) == "" {
+			errs = append(errs, AIFieldError{Field: prefix + ".name", Message: "不能为空"})
+		}
+		if strings.Contains(item.Name, "能量") || strings.Contains(strings.ToLower(item.Name), "energy") {
+			hasEnergy = true
+		}
+		errs = appendRangeError(errs, prefix+".value", float64(item.Value), 0, 100000)
+		errs = appendRangeError(errs, prefix+".nrv_percent", float64(item.NRVPercent), 0, 1000)
+	}
+	if !hasEnergy {
+		errs = append(errs, AIFieldError{Field: "items", Message: "缺少能量一行"})
+	}
+	return errs
+}
+
+func appendRangeError(errs []AIFieldError, field string, value float64, min float64, max float64) []AIFieldError {
+	if value < min || value > max {
+		return append(errs, AIFieldError{
+			Field:   field,
+			Message: fmt.Sprintf("应在 %g-%g 之间，当前为 %g", min, max, value),
+		})
+	}
+	return errs
+}
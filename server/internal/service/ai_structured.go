@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+)
+
+// aiOutputMetrics 按路由统计结构化输出结果，通过 /admin/debug/vars 暴露：
+// <route>.ok / <route>.parse_failure / <route>.repaired / <route>.failed / <route>.call_error
+var aiOutputMetrics = expvar.NewMap("ai_structured_output")
+
+// completeStructured 请求模型输出 JSON 并解码到 out，校验失败时携带字段错误重新提示一次。
+// 返回最终使用的原始回复；修复后仍无效时返回 *AIOutputError。
+func (c *aiClient) completeStructured(ctx context.Context, route string, messages []map[string]interface{}, out AIOutput) (string, error) {
+	opts := aiRequestOptions{jsonMode: true}
+	reply, err := c.completeWithOptions(ctx, route, messages, opts)
+	if err != nil {
+		aiOutputMetrics.Add(route+".call_error", 1)
+		return "", err
+	}
+	fieldErrs := decodeAIOutput(reply, out)
+	if len(fieldErrs) == 0 {
+		aiOutputMetrics.Add(route+".ok", 1)
+		return reply, nil
+	}
+	aiOutputMetrics.Add(route+".parse_failure", 1)
+	log.Printf("ai route %s output invalid, requesting repair: %v", route, fieldErrs)
+
+	repairMessages := make([]map[string]interface{}, 0, len(messages)+2)
+	repairMessages = append(repairMessages, messages...)
+	repairMessages = append(repairMessages,
+		map[string]interface{}{"role": "assistant", "content": reply},
+		map[string]interface{}{"role": "user", "content": buildRepairPrompt(fieldErrs)},
+	)
+	repaired, err := c.completeWithOptions(ctx, route, repairMessages, opts)
+	if err != nil {
+		aiOutputMetrics.Add(route+".failed", 1)
+		return reply, &AIOutputError{Route: route, Errors: fieldErrs}
+	}
+	fieldErrs = decodeAIOutput(repaired, out)
+	if len(fieldErrs) == 0 {
+		aiOutputMetrics.Add(route+".repaired", 1)
+		return repaired, nil
+	}
+	aiOutputMetrics.Add(route+".failed", 1)
+	return repaired, &AIOutputError{Route: route, Errors: fieldErrs}
+}
+
+func buildRepairPrompt(fieldErrs []AIFieldError) string {
+	lines := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		lines = append(lines, "- "+fieldErr.String())
+	}
+	return fmt.Sprintf(`上一次输出未通过校验，问题如下：
+%s
+请按原要求的 JSON 结构重新输出完整结果，修正以上字段，只输出 JSON，不要输出任何解释或 Markdown。`, strings.Join(lines, "\n"))
+}
+
+// decodeAIOutput 从模型回复中提取 JSON 并解码，返回解析或校验错误。
+func decodeAIOutput(raw string, out AIOutput) []AIFieldError {
+	resetAIOutput(out)
+	payload := extractAIJSON(raw)
+	if payload == "" {
+		return []AIFieldError{{Field: "$", Message: "未找到 JSON 内容"}}
+	}
+	if err := json.Unmarshal([]byte(payload), out); err != nil {
+		return []AIFieldError{{Field: "$", Message: "JSON 解析失败: " + err.Error()}}
+	}
+	return out.Validate()
+}
+
+func resetAIOutput(out AIOutput) {
+	value := reflect.ValueOf(out)
+	if value.Kind() == reflect.Ptr && !value.IsNil() {
+		value.Elem().Set(reflect.Zero(value.Elem().Type()))
+	}
+}
+
+// extractAIJSON 去掉代码块与前后说明文字，返回最外层 JSON 对象或数组。
+func extractAIJSON(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	if strings.HasPrefix(raw, "{") || strings.HasPrefix(raw, "[") {
+		return raw
+	}
+	start := strings.Index(raw, "{")
+	end := strings.LastIndex(raw, "}")
+	if start >= 0 && end > start {
+		return raw[start : end+1]
+	}
+	start = strings.Index(raw, "[")
+	end = strings.LastIndex(raw, "]")
+	if start >= 0 && end > start {
+		return raw[start : end+1]
+	}
+	return ""
+}
@@ -0,0 +1,441 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"eatclean/internal/config"
+)
+
+var (
+	// ErrAICircuitOpen 路由连续失败后熔断，冷却结束前直接拒绝。
+	ErrAICircuitOpen = errors.New("ai circuit open")
+	// ErrAIBusy 等待并发名额超时。
+	ErrAIBusy = errors.New("ai concurrency limit reached")
+)
+
+// aiTransportMetrics 通过 /admin/debug/vars 暴露：<route>.retry / .timeout / .busy / .breaker_open / .breaker_reject
+var aiTransportMetrics = expvar.NewMap("ai_transport")
+
+// IsAIUnavailable 判断错误是否为熔断或限流导致，调用方应返回降级结果而非 500。
+func IsAIUnavailable(err error) bool {
+	return errors.Is(err, ErrAICircuitOpen) || errors.Is(err, ErrAIBusy)
+}
+
+// aiStatusError 上游返回的非 2xx 状态。
+type aiStatusError struct {
+	status     int
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *aiStatusError) Error() string {
+	if e.message != "" {
+		return fmt.Sprintf("ai request failed: status %d: %s", e.status, e.message)
+	}
+	return fmt.Sprintf("ai request failed: status %d", e.status)
+}
+
+// AITransport 所有 AI 调用共享的 HTTP 传输层：按路由超时、429/5xx 抖动重试、
+// 路由级熔断与全局/路由并发限制。
+type AITransport struct {
+	client       *http.Client
+	maxRetries   int
+	retryBase    time.Duration
+	retryMax     time.Duration
+	queueTimeout time.Duration
+	routes       map[string]config.AIRouteConfig
+	global       chan struct{}
+
+	breakerFailures int
+	breakerCooldown time.Duration
+
+	mu       sync.Mutex
+	routeSem map[string]chan struct{}
+	breakers map[string]*aiBreaker
+}
+
+func NewAITransport(cfg *config.QwenConfig) *AITransport {
+	if cfg == nil {
+		return nil
+	}
+	t := cfg.Transport
+	maxRetries := 2
+	if t.MaxRetries != nil {
+		maxRetries = *t.MaxRetries
+	}
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	concurrency := t.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = 32
+	}
+	return &AITransport{
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				MaxIdleConns:        concurrency,
+				MaxIdleConnsPerHost: concurrency,
+				MaxConnsPerHost:     concurrency,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+		},
+		maxRetries:      maxRetries,
+		retryBase:       time.Duration(t.RetryBaseMs) * time.Millisecond,
+		retryMax:        time.Duration(t.RetryMaxMs) * time.Millisecond,
+		queueTimeout:    time.Duration(t.QueueTimeoutMs) * time.Millisecond,
+		routes:          cfg.Routes,
+		global:          make(chan struct{}, concurrency),
+		breakerFailures: t.BreakerFailures,
+		breakerCooldown: time.Duration(t.BreakerCooldownSeconds) * time.Second,
+		routeSem:        make(map[string]chan struct{}),
+		breakers:        make(map[string]*aiBreaker),
+	}
+}
+
+// RouteAvailable 熔断打开时返回 false，供功能在调用前直接走降级。
+func (t *AITransport) RouteAvailable(route string) bool {
+	if t == nil {
+		return true
+	}
+	return t.breaker(route).state(time.Now()) != aiBreakerOpen
+}
+
+// Call 在熔断与并发限制下执行一次路由调用，fn 内部可尝试多个模型。
+// 只有上游 5xx、超时与网络错误计入熔断；4xx/429、响应解析失败与调用方取消不计入。
+func (t *AITransport) Call(ctx context.Context, route string, fn func(context.Context) (string, error)) (string, error) {
+	if t == nil {
+		return fn(ctx)
+	}
+	breaker := t.breaker(route)
+	if !breaker.allow(time.Now()) {
+		aiTransportMetrics.Add(route+".breaker_reject", 1)
+		return "", fmt.Errorf("%w: %s", ErrAICircuitOpen, route)
+	}
+	release, err := t.acquire(ctx, route)
+	if err != nil {
+		breaker.cancel()
+		return "", err
+	}
+	defer release()
+
+	reply, err := fn(ctx)
+	switch {
+	case err == nil:
+		breaker.success()
+	case ctx.Err() != nil, !breakerFailure(err):
+		breaker.cancel()
+	default:
+		if breaker.failure(time.Now()) {
+			aiTransportMetrics.Add(route+".breaker_open", 1)
+		}
+	}
+	return reply, err
+}
+
+// Do 发送单个模型请求，遇到 429/5xx/网络错误按指数退避加抖动重试。
+// newRequest 每次尝试都会被调用，以便重新构造请求体。
+func (t *AITransport) Do(ctx context.Context, route string, newRequest func(context.Context) (*http.Request, error), handle func(*http.Response) (string, error)) (string, error) {
+	client := http.DefaultClient
+	maxRetries := 0
+	if t != nil {
+		client = t.client
+		maxRetries = t.maxRetries
+	}
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			wait := t.backoff(attempt, lastErr)
+			aiTransportMetrics.Add(route+".retry", 1)
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		reply, err := t.attempt(ctx, route, client, newRequest, handle)
+		if err == nil {
+			return reply, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || !retryableAIError(err) {
+			return "", err
+		}
+	}
+	return "", lastErr
+}
+
+func (t *AITransport) attempt(ctx context.Context, route string, client *http.Client, newRequest func(context.Context) (*http.Request, error), handle func(*http.Response) (string, error)) (string, error) {
+	attemptCtx := ctx
+	if timeout := t.timeoutFor(route); timeout > 0 {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := newRequest(attemptCtx)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if attemptCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			aiTransportMetrics.Add(route+".timeout", 1)
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", &aiStatusError{
+			status:     resp.StatusCode,
+			message:    string(body),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return handle(resp)
+}
+
+func (t *AITransport) timeoutFor(route string) time.Duration {
+	if t == nil {
+		return 45 * time.Second
+	}
+	if seconds := t.routes[route].TimeoutSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 45 * time.Second
+}
+
+// backoff 全抖动指数退避；上游给出 Retry-After 时取两者较大值。
+func (t *AITransport) backoff(attempt int, lastErr error) time.Duration {
+	base := t.retryBase
+	if base <= 0 {
+		base = 300 * time.Millisecond
+	}
+	ceiling := base << uint(attempt-1)
+	if t.retryMax > 0 && ceiling > t.retryMax {
+		ceiling = t.retryMax
+	}
+	wait := time.Duration(rand.Int63n(int64(ceiling) + 1))
+	var statusErr *aiStatusError
+	if errors.As(lastErr, &statusErr) && statusErr.retryAfter > wait {
+		wait = statusErr.retryAfter
+		if t.retryMax > 0 && wait > t.retryMax {
+			wait = t.retryMax
+		}
+	}
+	return wait
+}
+
+func (t *AITransport) acquire(ctx context.Context, route string) (func(), error) {
+	waitCtx, cancel := context.WithTimeout(ctx, t.queueTimeout)
+	defer cancel()
+	routeSem := t.routeSemaphore(route)
+	if routeSem != nil {
+		select {
+		case routeSem <- struct{}{}:
+		case <-waitCtx.Done():
+			return nil, t.busyError(ctx, route)
+		}
+	}
+	select {
+	case t.global <- struct{}{}:
+	case <-waitCtx.Done():
+		if routeSem != nil {
+			<-routeSem
+		}
+		return nil, t.busyError(ctx, route)
+	}
+	return func() {
+		<-t.global
+		if routeSem != nil {
+			<-routeSem
+		}
+	}, nil
+}
+
+func (t *AITransport) busyError(ctx context.Context, route string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	aiTransportMetrics.Add(route+".busy", 1)
+	return fmt.Errorf("%w: %s", ErrAIBusy, route)
+}
+
+func (t *AITransport) routeSemaphore(route string) chan struct{} {
+	limit := t.routes[route].MaxConcurrency
+	if limit <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sem, ok := t.routeSem[route]
+	if !ok {
+		sem = make(chan struct{}, limit)
+		t.routeSem[route] = sem
+	}
+	return sem
+}
+
+func (t *AITransport) breaker(route string) *aiBreaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b, ok := t.breakers[route]
+	if !ok {
+		b = &aiBreaker{threshold: t.breakerFailures, cooldown: t.breakerCooldown}
+		t.breakers[route] = b
+	}
+	return b
+}
+
+// breakerFailure 说明上游不可用的错误：5xx、超时与网络错误。
+func breakerFailure(err error) bool {
+	var statusErr *aiStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status >= http.StatusInternalServerError
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func retryableAIError(err error) bool {
+	var statusErr *aiStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status == http.StatusTooManyRequests || statusErr.status >= http.StatusInternalServerError
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// fallbackAIError 可以换用备用模型的错误：可重试的错误，或主模型不存在/已下线。
+// 鉴权失败、参数错误、内容审核等 4xx 以及结果解析失败换模型也无济于事，直接返回。
+func fallbackAIError(err error) bool {
+	if retryableAIError(err) {
+		return true
+	}
+	var statusErr *aiStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	if statusErr.status == http.StatusNotFound {
+		return true
+	}
+	message := strings.ToLower(statusErr.message)
+	return strings.Contains(message, "model_not_found") ||
+		strings.Contains(message, "model not found") ||
+		strings.Contains(message, "model not exist") ||
+		strings.Contains(message, "model_not_supported")
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+type aiBreakerState int
+
+const (
+	aiBreakerClosed aiBreakerState = iota
+	aiBreakerOpen
+	aiBreakerHalfOpen
+)
+
+// aiBreaker 连续失败达到阈值后打开；冷却结束后放行一个探测请求（半开），
+// 探测成功则关闭，失败则重新打开。
+type aiBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	open     bool
+	probing  bool
+}
+
+func (b *aiBreaker) state(now time.Time) aiBreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stateLocked(now)
+}
+
+func (b *aiBreaker) stateLocked(now time.Time) aiBreakerState {
+	if !b.open {
+		return aiBreakerClosed
+	}
+	if now.Sub(b.openedAt) >= b.cooldown && !b.probing {
+		return aiBreakerHalfOpen
+	}
+	return aiBreakerOpen
+}
+
+func (b *aiBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.stateLocked(now) {
+	case aiBreakerClosed:
+		return true
+	case aiBreakerHalfOpen:
+		b.probing = true
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *aiBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.open = false
+	b.probing = false
+}
+
+// cancel 请求未真正发出（排队超时/调用方取消）或失败不计入熔断，释放半开探测名额。
+func (b *aiBreaker) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// failure 记录一次失败，返回本次是否触发熔断。
+func (b *aiBreaker) failure(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.probing || (b.threshold > 0 && b.failures >= b.threshold) {
+		wasOpen := b.open && !b.probing
+		b.open = true
+		b.openedAt = now
+		b.probing = false
+		return !wasOpen
+	}
+	return false
+}
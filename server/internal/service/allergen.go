@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"eatclean/internal/model"
+)
+
+// dietPreferenceAllergens diet_preferences 中需要排除食材的偏好，其余偏好（高蛋白、低碳等）不做排除。
+var dietPreferenceAllergens = map[string][]string{
+	"素食":         {model.AllergenMeat, model.AllergenFish, model.AllergenShellfish},
+	"vegetarian": {model.AllergenMeat, model.AllergenFish, model.AllergenShellfish},
+	"纯素":         {model.AllergenMeat, model.AllergenFish, model.AllergenShellfish, model.AllergenDairy, model.AllergenEgg},
+	"vegan":      {model.AllergenMeat, model.AllergenFish, model.AllergenShellfish, model.AllergenDairy, model.AllergenEgg},
+}
+
+// DietRestriction 一条忌口。Label 为设置中的写法；Codes 为对应的过敏原类别，为空时按规范化后的 Term 原词匹配。
+type DietRestriction struct {
+	Label string
+	Codes []string
+	Term  string
+}
+
+// DietRestrictions 用户设置中的全部忌口，nil 表示没有限制。
+type DietRestrictions struct {
+	items []DietRestriction
+}
+
+// DietRestrictionsFromSettings 读取 excluded_foods（过敏原归类，无法归类的按原词匹配）与 diet_preferences 中的素食偏好。
+func DietRestrictionsFromSettings(settings map[string]interface{}) *DietRestrictions {
+	restrictions := &DietRestrictions{}
+	seen := map[string]bool{}
+	add := func(item DietRestriction) {
+		if item.Label == "" || seen[item.Label] {
+			return
+		}
+		seen[item.Label] = true
+		restrictions.items = append(restrictions.items, item)
+	}
+	for _, term := range settingStrings(settings["excluded_foods"]) {
+		if codes := model.AllergenCodesForTerm(term); len(codes) > 0 {
+			add(DietRestriction{Label: term, Codes: codes})
+		} else if normalized := model.NormalizeFoodName(term); normalized != "" {
+			add(DietRestriction{Label: term, Term: normalized})
+		}
+	}
+	for _, preference := range settingStrings(settings["diet_preferences"]) {
+		if codes, ok := dietPreferenceAllergens[strings.ToLower(preference)]; ok {
+			add(DietRestriction{Label: preference, Codes: codes})
+		}
+	}
+	if len(restrictions.items) == 0 {
+		return nil
+	}
+	return restrictions
+}
+
+func (r *DietRestrictions) IsEmpty() bool {
+	return r == nil || len(r.items) == 0
+}
+
+// Check 菜品命中的忌口（设置中的写法），未命中返回空。
+func (r *DietRestrictions) Check(name string, components []string) []string {
+	if r.IsEmpty() {
+		return nil
+	}
+	detected := map[string]bool{}
+	for _, code := range model.DetectAllergens(name, components) {
+		detected[code] = true
+	}
+	texts := make([]string, 0, len(components)+1)
+	for _, text := range append([]string{name}, components...) {
+		texts = append(texts, model.NormalizeFoodName(text))
+	}
+	var hits []string
+	for _, item := range r.items {
+		if restrictionMatches(item, detected, texts) {
+			hits = append(hits, item.Label)
+		}
+	}
+	return hits
+}
+
+func restrictionMatches(item DietRestriction, detected map[string]bool, texts []string) bool {
+	for _, code := range item.Codes {
+		if detected[code] {
+			return true
+		}
+	}
+	if item.Term == "" {
+		return false
+	}
+	for _, text := range texts {
+		if strings.Contains(text, item.Term) {
+			return true
+		}
+	}
+	return false
+}
+
+// DietRiskAlert 命中忌口的确定性风险提示。
+func DietRiskAlert(name string, hits []string) string {
+	return fmt.Sprintf("「%s」可能含有你设置的忌口（%s），请避免或向店家确认", name, strings.Join(hits, "、"))
+}
+
+// MergeRiskAlerts 确定性提示在前，模型给出的提示去重后追加。
+func MergeRiskAlerts(deterministic []string, generated []string) []string {
+	merged := make([]string, 0, len(deterministic)+len(generated))
+	seen := map[string]bool{}
+	for _, alert := range append(append([]string{}, deterministic...), generated...) {
+		alert = strings.TrimSpace(alert)
+		if alert == "" || seen[alert] {
+			continue
+		}
+		seen[alert] = true
+		merged = append(merged, alert)
+	}
+	return merged
+}
+
+// settingStrings 设置中的列表值，兼容数组与逗号/顿号分隔的字符串。
+func settingStrings(value interface{}) []string {
+	segments := strings.FieldsFunc(stringifyValue(value), func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == '/' || r == '|' || r == ';' || r == '；'
+	})
+	out := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg = strings.TrimSpace(seg); seg != "" && seg != "无" {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
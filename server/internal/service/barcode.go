@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
+
+// ErrInvalidBarcode 不是有效的 EAN-8/UPC-A/EAN-13/GTIN-14（位数或校验位错误）。
+var ErrInvalidBarcode = errors.New("invalid barcode")
+
+// NormalizeBarcode 校验条码并统一格式：UPC-A（12 位）补前导 0 为 EAN-13，与 Open Food Facts 的编码一致。
+func NormalizeBarcode(raw string) (string, error) {
+	code := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw))
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return "", ErrInvalidBarcode
+		}
+	}
+	switch len(code) {
+	case 12:
+		code = "0" + code
+	case 8, 13, 14:
+	default:
+		return "", ErrInvalidBarcode
+	}
+	if !validGTINCheckDigit(code) {
+		return "", ErrInvalidBarcode
+	}
+	return code, nil
+}
+
+// validGTINCheckDigit GS1 校验位：从右往左（不含校验位）奇数位 ×3、偶数位 ×1。
+func validGTINCheckDigit(code string) bool {
+	sum := 0
+	weight := 3
+	for i := len(code) - 2; i >= 0; i-- {
+		sum += int(code[i]-'0') * weight
+		weight = 4 - weight
+	}
+	return (10-sum%10)%10 == int(code[len(code)-1]-'0')
+}
+
+// BarcodeLookup 条码查询结果；Corrected 表示使用了用户自己的修正。
+type BarcodeLookup struct {
+	Item      *model.FoodBarcode
+	Corrected bool
+}
+
+type BarcodeService struct {
+	repo *repository.FoodBarcodeRepository
+}
+
+func NewBarcodeService(repo *repository.FoodBarcodeRepository) *BarcodeService {
+	return &BarcodeService{repo: repo}
+}
+
+func (s *BarcodeService) IsEnabled() bool {
+	return s != nil && s.repo != nil
+}
+
+// Lookup 用户自己的修正或 AI 估算优先，其次是导入的 Open Food Facts 数据；都没有时返回 nil。
+// food_barcode 中旧版缓存的 AI 估算来自其他用户输入的商品名，未经核实，不再返回。
+func (s *BarcodeService) Lookup(userID int64, barcode string) (*BarcodeLookup, error) {
+	if !s.IsEnabled() {
+		return nil, nil
+	}
+	base, err := s.repo.Find(barcode)
+	if err != nil {
+		return nil, err
+	}
+	if base != nil && base.Nutrition != nil && base.Nutrition.Source == model.NutritionSourceAISearch {
+		base = nil
+	}
+	correction, err := s.repo.FindCorrection(userID, barcode)
+	if err != nil {
+		return nil, err
+	}
+	if correction != nil {
+		item := &model.FoodBarcode{
+			Barcode:   barcode,
+			Name:      correction.Name,
+			Brand:     correction.Brand,
+			Nutrition: correction.Nutrition,
+			CreatedAt: correction.CreatedAt,
+			UpdatedAt: correction.UpdatedAt,
+		}
+		if base != nil {
+			item.Quantity = base.Quantity
+		}
+		corrected := correction.Nutrition == nil || correction.Nutrition.Source != model.NutritionSourceAISearch
+		return &BarcodeLookup{Item: item, Corrected: corrected}, nil
+	}
+	if base == nil {
+		return nil, nil
+	}
+	return &BarcodeLookup{Item: base}, nil
+}
+
+// SaveEstimate 保存按商品名得到的 AI 估算。商品名由用户输入，估算与修正一样只保存在该用户名下，不写入共享的 food_barcode。
+func (s *BarcodeService) SaveEstimate(userID int64, item *model.FoodBarcode) error {
+	if !s.IsEnabled() {
+		return nil
+	}
+	return s.repo.SaveCorrection(&model.FoodBarcodeCorrection{
+		Barcode:   item.Barcode,
+		UserID:    userID,
+		Name:      item.Name,
+		Brand:     item.Brand,
+		Nutrition: item.Nutrition,
+	})
+}
+
+// SaveCorrection 保存用户修正，只影响该用户之后的查询。
+func (s *BarcodeService) SaveCorrection(item *model.FoodBarcodeCorrection) error {
+	if !s.IsEnabled() {
+		return errors.New("barcode storage not configured")
+	}
+	return s.repo.SaveCorrection(item)
+}
@@ -1,45 +1,38 @@
 package service
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
-	"net/http"
 	"strings"
-	"time"
 
 	"eatclean/internal/config"
 	"eatclean/internal/model"
 )
 
 type ChatAIService struct {
-	apiKey  string
-	baseURL string
-	model   string
-	client  *http.Client
+	ai *aiClient
 }
 
-func NewChatAIService(cfg *config.QwenConfig) *ChatAIService {
-	if cfg == nil {
-		return &ChatAIService{}
-	}
-	return &ChatAIService{
-		apiKey:  cfg.APIKey,
-		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
-		model:   cfg.Model,
-		client: &http.Client{
-			Timeout: 45 * time.Second,
-		},
-	}
+func NewChatAIService(cfg *config.QwenConfig, transport *AITransport) *ChatAIService {
+	return &ChatAIService{ai: newAIClient(cfg, transport)}
 }
 
 func (s *ChatAIService) IsEnabled() bool {
-	return s.apiKey != "" && s.baseURL != "" && s.model != ""
+	return s.ai.isEnabled()
+}
+
+// RouteAvailable 路由熔断时返回 false，调用方可直接返回降级结果。
+func (s *ChatAIService) RouteAvailable(route string) bool {
+	return s.ai.routeAvailable(route)
 }
 
+// Chat 使用 chat 路由生成回复。
 func (s *ChatAIService) Chat(ctx context.Context, systemPrompt string, preUser string, history []model.ChatMessage, userText string, imageUrls []string) (string, error) {
+	return s.ChatWithRoute(ctx, AIRouteChat, systemPrompt, preUser, history, userText, imageUrls)
+}
+
+// ChatWithRoute 按指定路由（模型/温度/fallback）生成回复。
+func (s *ChatAIService) ChatWithRoute(ctx context.Context, route string, systemPrompt string, preUser string, history []model.ChatMessage, userText string, imageUrls []string) (string, error) {
 	if !s.IsEnabled() {
 		return "", errors.New("ai service not configured")
 	}
@@ -97,55 +90,26 @@ func (s *ChatAIService) Chat(ctx context.Context, systemPrompt string, preUser s
 		})
 	}
 
-	reqBody := map[string]interface{}{
-		"model":       s.model,
-		"messages":    messages,
-		"temperature": 0.6,
-	}
-	payload, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodPost,
-		fmt.Sprintf("%s/chat/completions", s.baseURL),
-		bytes.NewReader(payload),
-	)
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+	return s.ai.complete(ctx, route, messages)
+}
 
-	var decoded struct {
-		Choices []struct {
-			Message struct {
-				Content interface{} `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Error *struct {
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
-		return "", err
-	}
-	if decoded.Error != nil {
-		return "", errors.New(decoded.Error.Message)
+// ChatStructured 按路由请求 JSON 输出并解码到 out，校验失败会自动修复重试一次。
+func (s *ChatAIService) ChatStructured(ctx context.Context, route string, systemPrompt string, userText string, out AIOutput) (string, error) {
+	if !s.IsEnabled() {
+		return "", errors.New("ai service not configured")
 	}
-	if len(decoded.Choices) == 0 {
-		return "", errors.New("empty response")
+	messages := make([]map[string]interface{}, 0, 2)
+	if strings.TrimSpace(systemPrompt) != "" {
+		messages = append(messages, map[string]interface{}{
+			"role":    "system",
+			"content": systemPrompt,
+		})
 	}
-
-	return parseAIContent(decoded.Choices[0].Message.Content)
+	messages = append(messages, map[string]interface{}{
+		"role":    "user",
+		"content": userText,
+	})
+	return s.ai.completeStructured(ctx, route, messages, out)
 }
 
 func parseAIContent(content interface{}) (string, error) {
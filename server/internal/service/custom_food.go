@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
+
+// customFoodListLimit 单个用户自建食物的列表上限。
+const customFoodListLimit = 200
+
+// CustomFoodService 用户自建食物，食物搜索时优先于成分表与 AI 估算。
+type CustomFoodService struct {
+	repo *repository.CustomFoodRepository
+}
+
+func NewCustomFoodService(repo *repository.CustomFoodRepository) *CustomFoodService {
+	return &CustomFoodService{repo: repo}
+}
+
+func (s *CustomFoodService) IsEnabled() bool {
+	return s != nil && s.repo != nil
+}
+
+// Save 按规范化名称去重后写入，同名覆盖。
+func (s *CustomFoodService) Save(food *model.CustomFood) error {
+	if !s.IsEnabled() {
+		return errors.New("custom food storage not configured")
+	}
+	food.Name = strings.TrimSpace(food.Name)
+	food.NormalizedName = model.NormalizeFoodName(food.Name)
+	if food.NormalizedName == "" {
+		return errors.New("name is required")
+	}
+	if food.Nutrition.IsEmpty() {
+		return errors.New("nutrition is required")
+	}
+	return s.repo.Upsert(food)
+}
+
+// Lookup 用户自建食物中名称完全匹配的条目，没有时返回 nil。
+func (s *CustomFoodService) Lookup(userID int64, name string) (*model.CustomFood, error) {
+	if !s.IsEnabled() {
+		return nil, nil
+	}
+	norm := model.NormalizeFoodName(name)
+	if norm == "" {
+		return nil, nil
+	}
+	return s.repo.FindByNormalizedName(userID, norm)
+}
+
+func (s *CustomFoodService) List(userID int64) ([]model.CustomFood, error) {
+	if !s.IsEnabled() {
+		return nil, nil
+	}
+	return s.repo.ListByUser(userID, customFoodListLimit)
+}
+
+func (s *CustomFoodService) Delete(userID int64, id int64) (bool, error) {
+	if !s.IsEnabled() {
+		return false, nil
+	}
+	return s.repo.Delete(userID, id)
+}
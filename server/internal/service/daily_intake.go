@@ -1,23 +1,26 @@
 package service
 
 import (
+	"time"
+
 	"eatclean/internal/model"
 	"eatclean/internal/repository"
 )
 
 type DailyIntakeService struct {
-	repo *repository.DailyIntakeRepository
+	repo  *repository.DailyIntakeRepository
+	meals *MealRecordService
 }
 
-func NewDailyIntakeService(repo *repository.DailyIntakeRepository) *DailyIntakeService {
-	return &DailyIntakeService{repo: repo}
+func NewDailyIntakeService(repo *repository.DailyIntakeRepository, meals *MealRecordService) *DailyIntakeService {
+	return &DailyIntakeService{repo: repo, meals: meals}
 }
 
-func (s *DailyIntakeService) Upsert(userID int64, day string, calories, protein, carbs, fat int) error {
+func (s *DailyIntakeService) Upsert(record *model.DailyIntake) error {
 	if s == nil || s.repo == nil {
 		return nil
 	}
-	return s.repo.Upsert(userID, day, calories, protein, carbs, fat)
+	return s.repo.Upsert(record)
 }
 
 func (s *DailyIntakeService) GetByDate(userID int64, day string) (*model.DailyIntake, error) {
@@ -26,3 +29,27 @@ func (s *DailyIntakeService) GetByDate(userID int64, day string) (*model.DailyIn
 	}
 	return s.repo.GetByDate(userID, day)
 }
+
+// MealTotals 按当天就餐记录合计的每份营养，用于客户端未上报的微量营养素。
+func (s *DailyIntakeService) MealTotals(userID int64, day time.Time) (model.NutritionFacts, error) {
+	if s == nil || s.meals == nil {
+		return model.NutritionFacts{}, nil
+	}
+	return s.meals.DayNutrition(userID, day)
+}
+
+// Micronutrients 当天的微量营养素：record 已有同步的数值时直接使用，否则按就餐记录合计。
+func (s *DailyIntakeService) Micronutrients(userID int64, day time.Time, record *model.DailyIntake) (model.NutritionFacts, error) {
+	if record != nil {
+		if facts := record.Micronutrients(); !facts.IsZero() {
+			return facts, nil
+		}
+	}
+	totals, err := s.MealTotals(userID, day)
+	if err != nil {
+		return model.NutritionFacts{}, err
+	}
+	var intake model.DailyIntake
+	intake.SetMicronutrients(totals)
+	return intake.Micronutrients(), nil
+}
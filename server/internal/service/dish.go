@@ -4,17 +4,18 @@ import (
 	"eatclean/internal/model"
 	"eatclean/internal/repository"
 	"encoding/json"
-	"regexp"
+	"math"
 	"strconv"
 	"strings"
 )
 
 type DishService struct {
-	repo *repository.DishRepository
+	repo  *repository.DishRepository
+	foods *FoodService
 }
 
-func NewDishService(repo *repository.DishRepository) *DishService {
-	return &DishService{repo: repo}
+func NewDishService(repo *repository.DishRepository, foods *FoodService) *DishService {
+	return &DishService{repo: repo, foods: foods}
 }
 
 func (s *DishService) IsEnabled() bool {
@@ -28,7 +29,7 @@ func (s *DishService) FindByNames(names []string) (map[string]model.Dish, error)
 	normalized := make([]string, 0, len(names))
 	seen := map[string]struct{}{}
 	for _, name := range names {
-		norm := NormalizeDishName(name)
+		norm := model.NormalizeFoodName(name)
 		if norm == "" {
 			continue
 		}
@@ -41,7 +42,8 @@ func (s *DishService) FindByNames(names []string) (map[string]model.Dish, error)
 	return s.repo.FindByNormalizedNames(normalized)
 }
 
-func (s *DishService) UpsertFromMap(dish map[string]interface{}) error {
+// UpsertFromMap 缓存 AI 输出的菜品：只保存名称、分类与营养数据（kcal/protein 等视为每份），source 为 model.NutritionSource*。
+func (s *DishService) UpsertFromMap(dish map[string]interface{}, source string) error {
 	if !s.IsEnabled() || dish == nil {
 		return nil
 	}
@@ -49,7 +51,7 @@ func (s *DishService) UpsertFromMap(dish map[string]interface{}) error {
 	if strings.TrimSpace(name) == "" {
 		return nil
 	}
-	normalized := NormalizeDishName(name)
+	normalized := model.NormalizeFoodName(name)
 	if normalized == "" {
 		return nil
 	}
@@ -61,16 +63,17 @@ func (s *DishService) UpsertFromMap(dish map[string]interface{}) error {
 	if strings.TrimSpace(category) != "" {
 		categoryPtr = &category
 	}
-	payload, _ := json.Marshal(dish)
 	item := &model.Dish{
-		Name:              name,
-		NormalizedName:    normalized,
-		Category:          categoryPtr,
-		NutritionEstimate: payload,
+		Name:           name,
+		NormalizedName: normalized,
+		Category:       categoryPtr,
+		Nutrition:      model.NutritionFromMap(dish, source),
 	}
 	return s.repo.Upsert(item)
 }
 
+// HydrateDishMaps 用缓存的每份营养覆盖识别结果中的营养字段，未缓存的菜品写入缓存；
+// 能匹配到食物成分表的菜品最终以成分表数据为准。
 func (s *DishService) HydrateDishMaps(dishes []map[string]interface{}) []map[string]interface{} {
 	if !s.IsEnabled() || len(dishes) == 0 {
 		return dishes
@@ -87,9 +90,9 @@ func (s *DishService) HydrateDishMaps(dishes []map[string]interface{}) []map[str
 	cached, err := s.FindByNames(names)
 	if err != nil || len(cached) == 0 {
 		for _, dish := range dishes {
-			_ = s.UpsertFromMap(dish)
+			_ = s.UpsertFromMap(dish, model.NutritionSourceAIVision)
 		}
-		return dishes
+		return s.foods.ApplyAuthoritative(dishes)
 	}
 
 	out := make([]map[string]interface{}, 0, len(dishes))
@@ -100,20 +103,14 @@ func (s *DishService) HydrateDishMaps(dishes []map[string]interface{}) []map[str
 			out = append(out, dish)
 			continue
 		}
-		norm := NormalizeDishName(name)
-		if cachedDish, ok := cached[norm]; ok && len(cachedDish.NutritionEstimate) > 0 {
-			var cachedMap map[string]interface{}
-			if err := json.Unmarshal(cachedDish.NutritionEstimate, &cachedMap); err == nil && len(cachedMap) > 0 {
-				merged := make(map[string]interface{}, len(dish)+len(cachedMap))
+		norm := model.NormalizeFoodName(name)
+		if cachedDish, ok := cached[norm]; ok {
+			if facts, ok := cachedDish.Nutrition.Serving(); ok {
+				merged := make(map[string]interface{}, len(dish))
 				for key, value := range dish {
 					merged[key] = value
 				}
-				for key, value := range cachedMap {
-					merged[key] = value
-				}
-				if _, ok := merged["name"]; !ok && name != "" {
-					merged["name"] = name
-				}
+				ApplyNutritionToMap(merged, facts)
 				out = append(out, merged)
 				continue
 			}
@@ -123,32 +120,40 @@ func (s *DishService) HydrateDishMaps(dishes []map[string]interface{}) []map[str
 	}
 
 	for _, dish := range missing {
-		_ = s.UpsertFromMap(dish)
+		_ = s.UpsertFromMap(dish, model.NutritionSourceAIVision)
 	}
-	return out
+	return s.foods.ApplyAuthoritative(out)
 }
 
+// HydrateDishMapsPreferFresh 本次 AI 估算优先于缓存的旧估算，但仍以食物成分表数据为准。
 func (s *DishService) HydrateDishMapsPreferFresh(dishes []map[string]interface{}) []map[string]interface{} {
 	if !s.IsEnabled() || len(dishes) == 0 {
 		return dishes
 	}
 	for _, dish := range dishes {
-		_ = s.UpsertFromMap(dish)
+		_ = s.UpsertFromMap(dish, model.NutritionSourceAIVision)
 	}
-	return dishes
+	return s.foods.ApplyAuthoritative(dishes)
 }
 
-func NormalizeDishName(name string) string {
-	name = strings.ToLower(strings.TrimSpace(name))
-	if name == "" {
-		return ""
+// ApplyNutritionToMap 把每份营养写回菜品 map：识别结果使用 kcal，发现页餐食使用 calories，沿用已有的键。
+// 钠、糖等微量营养素只写入已知（大于 0）的值，未知时保留 map 中原有的估算。
+func ApplyNutritionToMap(dish map[string]interface{}, facts model.NutritionFacts) {
+	if _, ok := dish["calories"]; ok {
+		dish["calories"] = int(math.Round(facts.EnergyKcal))
+	} else {
+		dish["kcal"] = int(math.Round(facts.EnergyKcal))
+	}
+	dish["protein"] = int(math.Round(facts.ProteinG))
+	dish["carbs"] = int(math.Round(facts.CarbsG))
+	dish["fat"] = int(math.Round(facts.FatG))
+	for _, limit := range DefaultNutrientLimits {
+		if value := limit.Amount(facts); value > 0 {
+			dish[limit.Key] = value
+		}
 	}
-	name = dishNameCleaner.ReplaceAllString(name, "")
-	return name
 }
 
-var dishNameCleaner = regexp.MustCompile(`[\s\p{P}\p{S}]+`)
-
 func readString(value interface{}) string {
 	switch v := value.(type) {
 	case string:
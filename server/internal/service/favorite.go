@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
+
+// favoriteListLimit 单个用户收藏的列表上限。
+const favoriteListLimit = 200
+
+// ErrFavoriteInvalid 收藏类型、名称或引用无效，错误信息可直接返回给客户端。
+var ErrFavoriteInvalid = errors.New("invalid favorite")
+
+// FavoriteService 收藏菜品、食物与菜谱。收藏时生成一份可直接记录的条目快照；菜谱在使用时按最新营养重新生成。
+type FavoriteService struct {
+	repo    *repository.FavoriteRepository
+	recipes *RecipeService
+	foods   *FoodService
+	customs *CustomFoodService
+}
+
+func NewFavoriteService(repo *repository.FavoriteRepository, recipes *RecipeService, foods *FoodService, customs *CustomFoodService) *FavoriteService {
+	return &FavoriteService{repo: repo, recipes: recipes, foods: foods, customs: customs}
+}
+
+func (s *FavoriteService) IsEnabled() bool {
+	return s != nil && s.repo != nil
+}
+
+// Add 按类型补全名称与条目快照后写入：recipe 需要 ref_id；food 按名称查自建食物与成分表；
+// dish 使用客户端传入的条目（通常来自就餐记录）。
+func (s *FavoriteService) Add(favorite *model.FavoriteItem) error {
+	if !s.IsEnabled() {
+		return errors.New("favorite storage not configured")
+	}
+	favorite.Name = strings.TrimSpace(favorite.Name)
+	switch favorite.Kind {
+	case model.FavoriteKindRecipe:
+		recipe, err := s.recipes.Get(favorite.UserID, favorite.RefID)
+		if err != nil {
+			return err
+		}
+		item, err := s.recipes.MealItem(recipe, 1)
+		if err != nil {
+			return err
+		}
+		favorite.Name = recipe.Title
+		favorite.Item = item
+	case model.FavoriteKindFood:
+		nutrition, err := s.lookupFood(favorite.UserID, favorite.Name)
+		if err != nil {
+			return err
+		}
+		if nutrition == nil {
+			return fmt.Errorf("%w: food not found", ErrFavoriteInvalid)
+		}
+		item, err := foodMealItem(favorite.Name, nutrition)
+		if err != nil {
+			return err
+		}
+		favorite.Item = item
+	case model.FavoriteKindDish:
+		if favorite.Name == "" {
+			favorite.Name = readString(favorite.Item["name"])
+		}
+		if len(favorite.Item) == 0 {
+			return fmt.Errorf("%w: item is required", ErrFavoriteInvalid)
+		}
+		favorite.Item["name"] = favorite.Name
+	default:
+		return fmt.Errorf("%w: unsupported kind", ErrFavoriteInvalid)
+	}
+	favorite.NormalizedName = model.NormalizeFoodName(favorite.Name)
+	if favorite.NormalizedName == "" {
+		return fmt.Errorf("%w: name is required", ErrFavoriteInvalid)
+	}
+	return s.repo.Upsert(favorite)
+}
+
+// List 收藏列表；菜谱收藏的条目按菜谱当前营养刷新，菜谱已删除的保留快照。
+func (s *FavoriteService) List(userID int64) ([]model.FavoriteItem, error) {
+	if !s.IsEnabled() {
+		return nil, nil
+	}
+	favorites, err := s.repo.ListByUser(userID, favoriteListLimit)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range favorites {
+		favorite := &favorites[idx]
+		if favorite.Kind != model.FavoriteKindRecipe {
+			continue
+		}
+		recipe, err := s.recipes.Get(userID, favorite.RefID)
+		if err != nil {
+			continue
+		}
+		if item, err := s.recipes.MealItem(recipe, 1); err == nil {
+			favorite.Name = recipe.Title
+			favorite.Item = item
+		}
+	}
+	return favorites, nil
+}
+
+func (s *FavoriteService) Delete(userID int64, id int64) (bool, error) {
+	if !s.IsEnabled() {
+		return false, nil
+	}
+	return s.repo.Delete(userID, id)
+}
+
+func (s *FavoriteService) lookupFood(userID int64, name string) (*model.Nutrition, error) {
+	custom, err := s.customs.Lookup(userID, name)
+	if err != nil {
+		return nil, err
+	}
+	if custom != nil {
+		return custom.Nutrition, nil
+	}
+	food, err := s.foods.Lookup(name)
+	if err != nil || food == nil {
+		return nil, err
+	}
+	return food.Nutrition, nil
+}
+
+// foodMealItem 食物的 1 份条目：份量取 serving_size_g，未知时按 100g。
+func foodMealItem(name string, nutrition *model.Nutrition) (map[string]interface{}, error) {
+	servingG := 100.0
+	if nutrition != nil && nutrition.ServingSizeG > 0 {
+		servingG = nutrition.ServingSizeG
+	}
+	per100, ok := nutrition.Per100()
+	if !ok {
+		return nil, ErrPortionBase
+	}
+	item := map[string]interface{}{
+		"name":             name,
+		"nutrition_source": nutrition.Source,
+		"grams":            servingG,
+		"serving_g":        servingG,
+	}
+	setPer100Keys(item, per100)
+	return ScalePortion(item, 1, model.UnitServing)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"math"
+
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
+
+// authoritativeFoodSources 同名条目的取用顺序：中文场景优先中国食物成分表。
+var authoritativeFoodSources = []string{model.NutritionSourceCFCT, model.NutritionSourceUSDA}
+
+// FoodService 查询导入的食物成分表，名称统一按 model.NormalizeFoodName 匹配。
+type FoodService struct {
+	repo *repository.FoodRepository
+}
+
+func NewFoodService(repo *repository.FoodRepository) *FoodService {
+	return &FoodService{repo: repo}
+}
+
+func (s *FoodService) IsEnabled() bool {
+	return s != nil && s.repo != nil
+}
+
+// Lookup 名称或别名完全匹配的权威条目，没有时返回 nil。
+func (s *FoodService) Lookup(name string) (*model.Food, error) {
+	norm := model.NormalizeFoodName(name)
+	found, err := s.FindByNames([]string{name})
+	if err != nil {
+		return nil, err
+	}
+	if food, ok := found[norm]; ok {
+		return &food, nil
+	}
+	return nil, nil
+}
+
+// FindByNames 批量完全匹配，key 为规范化名称。
+func (s *FoodService) FindByNames(names []string) (map[string]model.Food, error) {
+	if !s.IsEnabled() {
+		return map[string]model.Food{}, nil
+	}
+	normalized := make([]string, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		norm := model.NormalizeFoodName(name)
+		if norm == "" || seen[norm] {
+			continue
+		}
+		seen[norm] = true
+		normalized = append(normalized, norm)
+	}
+	return s.repo.FindByNormalizedNames(normalized, authoritativeFoodSources)
+}
+
+// ApplyAuthoritative 菜品名与成分表条目完全匹配时，用成分表的每 100g 数据重算营养：
+// 份量取 weight_g/grams，没有时按 AI 估算的热量反推，即保留 AI 的份量判断、替换营养构成。
+// 匹配到的菜品写入 nutrition_source 与 portion_g，返回新的切片，原 map 不修改。
+func (s *FoodService) ApplyAuthoritative(dishes []map[string]interface{}) []map[string]interface{} {
+	if !s.IsEnabled() || len(dishes) == 0 {
+		return dishes
+	}
+	names := make([]string, 0, len(dishes))
+	for _, dish := range dishes {
+		names = append(names, readString(dish["name"]))
+	}
+	foods, err := s.FindByNames(names)
+	if err != nil || len(foods) == 0 {
+		return dishes
+	}
+	out := make([]map[string]interface{}, 0, len(dishes))
+	for _, dish := range dishes {
+		food, ok := foods[model.NormalizeFoodName(readString(dish["name"]))]
+		if !ok {
+			out = append(out, dish)
+			continue
+		}
+		per100, ok := food.Nutrition.Per100()
+		grams := portionGrams(dish, per100)
+		if !ok || grams <= 0 {
+			out = append(out, dish)
+			continue
+		}
+		merged := make(map[string]interface{}, len(dish)+2)
+		for key, value := range dish {
+			merged[key] = value
+		}
+		ApplyNutritionToMap(merged, per100.Scale(grams/100))
+		merged["nutrition_source"] = food.Source
+		merged["portion_g"] = int(math.Round(grams))
+		out = append(out, merged)
+	}
+	return out
+}
+
+// portionGrams 菜品份量（克），限制在 10-2000g，无法判断时为 0。
+func portionGrams(dish map[string]interface{}, per100 model.NutritionFacts) float64 {
+	grams := model.NutrientValue(dish, "weight_g", "grams", "portion_g")
+	if grams <= 0 && per100.EnergyKcal > 0 {
+		grams = model.NutrientValue(dish, "kcal", "calories") / per100.EnergyKcal * 100
+	}
+	if grams <= 0 {
+		return 0
+	}
+	return math.Min(math.Max(grams, 10), 2000)
+}
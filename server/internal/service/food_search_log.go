@@ -0,0 +1,27 @@
+package service
+
+import (
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+	"strings"
+)
+
+type FoodSearchLogService struct {
+	repo *repository.FoodSearchLogRepository
+}
+
+func NewFoodSearchLogService(repo *repository.FoodSearchLogRepository) *FoodSearchLogService {
+	return &FoodSearchLogService{repo: repo}
+}
+
+func (s *FoodSearchLogService) Record(userID int64, query string) error {
+	query = strings.TrimSpace(query)
+	if s == nil || s.repo == nil || userID <= 0 || query == "" {
+		return nil
+	}
+	return s.repo.Create(&model.FoodSearchLog{UserID: userID, Query: query})
+}
+
+func (s *FoodSearchLogService) ListRecent(userID int64, limit int) ([]model.FoodSearchLog, error) {
+	return s.repo.ListRecent(userID, limit)
+}
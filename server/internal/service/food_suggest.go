@@ -0,0 +1,264 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
+
+// 食物建议的来源。
+const (
+	FoodSuggestionCustom = "custom" // 用户自建食物
+	FoodSuggestionFood   = "food"   // 食物成分表
+	FoodSuggestionDish   = "dish"   // AI 估算缓存
+)
+
+// foodSuggestMinScore 低于该分数的候选不返回。
+const foodSuggestMinScore = 0.3
+
+// 同名候选按来源加分：自建食物 > 成分表 > AI 缓存。
+var foodSuggestionBonus = map[string]float64{
+	FoodSuggestionCustom: 0.03,
+	FoodSuggestionFood:   0.02,
+}
+
+// FoodSuggestion 一条搜索建议，Per100g 未知时省略。
+type FoodSuggestion struct {
+	Name    string                `json:"name"`
+	Kind    string                `json:"kind"`
+	ID      int64                 `json:"id,omitempty"`
+	Source  string                `json:"source,omitempty"`
+	Score   float64               `json:"score"`
+	Per100g *model.NutritionFacts `json:"per_100g,omitempty"`
+	// Units 可选单位及每单位克数，用于按“1碗”“2个”记录
+	Units []PortionOption `json:"units,omitempty"`
+
+	normalized string
+	servingG   float64
+}
+
+// FoodSuggestService 在自建食物、食物成分表与菜品缓存中做排序的模糊搜索：
+// 规范名称（含别名替换）包含/前缀、拼音与首字母、同音字，以及字符二元组相似度。
+type FoodSuggestService struct {
+	foods   *repository.FoodRepository
+	dishes  *repository.DishRepository
+	customs *repository.CustomFoodRepository
+}
+
+func NewFoodSuggestService(foods *repository.FoodRepository, dishes *repository.DishRepository, customs *repository.CustomFoodRepository) *FoodSuggestService {
+	return &FoodSuggestService{foods: foods, dishes: dishes, customs: customs}
+}
+
+func (s *FoodSuggestService) IsEnabled() bool {
+	return s != nil && (s.foods != nil || s.dishes != nil || s.customs != nil)
+}
+
+// Suggest 按分数降序返回至多 limit 条，同一规范名称只保留分数最高的来源。
+func (s *FoodSuggestService) Suggest(userID int64, query string, limit int) ([]FoodSuggestion, error) {
+	if !s.IsEnabled() || limit <= 0 {
+		return nil, nil
+	}
+	norm := model.NormalizeFoodName(query)
+	if norm == "" {
+		return nil, nil
+	}
+	pinyinInput := model.IsPinyinQuery(norm)
+	pinyinKey := norm
+	if !pinyinInput {
+		pinyinKey, _ = model.FoodNamePinyin(norm)
+	}
+	candidateLimit := limit * 3
+
+	best := map[string]FoodSuggestion{}
+	add := func(item FoodSuggestion) {
+		item.Score = foodNameScore(norm, pinyinKey, pinyinInput, item.normalized)
+		if item.Score < foodSuggestMinScore {
+			return
+		}
+		item.Score = roundScore(item.Score + foodSuggestionBonus[item.Kind])
+		if current, ok := best[item.normalized]; ok && current.Score >= item.Score {
+			return
+		}
+		best[item.normalized] = item
+	}
+
+	if s.customs != nil && userID > 0 {
+		customs, err := s.customs.ListByUser(userID, customFoodListLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, food := range customs {
+			add(FoodSuggestion{
+				Name:       food.Name,
+				Kind:       FoodSuggestionCustom,
+				ID:         food.ID,
+				Source:     nutritionSource(food.Nutrition),
+				Per100g:    per100OrNil(food.Nutrition),
+				normalized: food.NormalizedName,
+				servingG:   servingSizeG(food.Nutrition),
+			})
+		}
+	}
+	if s.foods != nil {
+		foods, err := s.foods.SearchFuzzy(norm, pinyinKey, authoritativeFoodSources, candidateLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, food := range foods {
+			add(FoodSuggestion{
+				Name:       food.Name,
+				Kind:       FoodSuggestionFood,
+				ID:         food.ID,
+				Source:     food.Source,
+				Per100g:    per100OrNil(food.Nutrition),
+				normalized: food.NormalizedName,
+				servingG:   servingSizeG(food.Nutrition),
+			})
+		}
+	}
+	if s.dishes != nil {
+		dishes, err := s.dishes.SearchFuzzy(norm, pinyinKey, candidateLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, dish := range dishes {
+			add(FoodSuggestion{
+				Name:       dish.Name,
+				Kind:       FoodSuggestionDish,
+				Source:     nutritionSource(dish.Nutrition),
+				Per100g:    per100OrNil(dish.Nutrition),
+				normalized: dish.NormalizedName,
+				servingG:   servingSizeG(dish.Nutrition),
+			})
+		}
+	}
+
+	suggestions := make([]FoodSuggestion, 0, len(best))
+	for _, item := range best {
+		suggestions = append(suggestions, item)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		li, lj := utf8.RuneCountInString(suggestions[i].normalized), utf8.RuneCountInString(suggestions[j].normalized)
+		if li != lj {
+			return li < lj
+		}
+		return suggestions[i].normalized < suggestions[j].normalized
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	for idx := range suggestions {
+		if suggestions[idx].Per100g != nil {
+			suggestions[idx].Units = PortionOptions(suggestions[idx].Name, suggestions[idx].servingG)
+		}
+	}
+	return suggestions, nil
+}
+
+// foodNameScore 0-1：完全相同 1；前缀/包含按多出的字数递减；拼音输入匹配全拼或首字母前缀；
+// 中文输入全拼相同视为同音（错别字）；其余按字符二元组 Dice 系数。
+func foodNameScore(query string, pinyinKey string, pinyinInput bool, candidate string) float64 {
+	if candidate == "" {
+		return 0
+	}
+	if candidate == query {
+		return 1
+	}
+	extra := utf8.RuneCountInString(candidate) - utf8.RuneCountInString(query)
+	penalty := 0.02 * float64(extra)
+	if penalty < 0 {
+		penalty = 0
+	}
+	if penalty > 0.2 {
+		penalty = 0.2
+	}
+	score := 0.0
+	switch {
+	case strings.HasPrefix(candidate, query):
+		score = 0.9 - penalty
+	case strings.Contains(candidate, query):
+		score = 0.8 - penalty
+	}
+	if pinyinKey != "" {
+		candPinyin, candInitials := model.FoodNamePinyin(candidate)
+		switch {
+		case candPinyin == pinyinKey:
+			score = maxScore(score, 0.92)
+		case pinyinInput && strings.HasPrefix(candPinyin, pinyinKey):
+			score = maxScore(score, 0.85-penalty)
+		case pinyinInput && strings.HasPrefix(candInitials, pinyinKey):
+			score = maxScore(score, 0.82-penalty)
+		}
+	}
+	return maxScore(score, 0.75*bigramDice(query, candidate))
+}
+
+// bigramDice 字符二元组的 Dice 系数，单字时按字符比较。
+func bigramDice(a string, b string) float64 {
+	ga, gb := runeBigrams(a), runeBigrams(b)
+	if len(ga) == 0 || len(gb) == 0 {
+		return 0
+	}
+	counts := make(map[string]int, len(ga))
+	for _, g := range ga {
+		counts[g]++
+	}
+	common := 0
+	for _, g := range gb {
+		if counts[g] > 0 {
+			counts[g]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(ga)+len(gb))
+}
+
+func runeBigrams(s string) []string {
+	runes := []rune(s)
+	if len(runes) == 1 {
+		return []string{s}
+	}
+	grams := make([]string, 0, len(runes))
+	for i := 0; i+1 < len(runes); i++ {
+		grams = append(grams, string(runes[i:i+2]))
+	}
+	return grams
+}
+
+func maxScore(a float64, b float64) float64 {
+	if b > a {
+		return b
+	}
+	return a
+}
+
+func roundScore(value float64) float64 {
+	return float64(int(value*1000+0.5)) / 1000
+}
+
+func nutritionSource(n *model.Nutrition) string {
+	if n == nil {
+		return ""
+	}
+	return n.Source
+}
+
+func servingSizeG(n *model.Nutrition) float64 {
+	if n == nil {
+		return 0
+	}
+	return n.ServingSizeG
+}
+
+func per100OrNil(n *model.Nutrition) *model.NutritionFacts {
+	if facts, ok := n.Per100(); ok {
+		return &facts
+	}
+	return nil
+}
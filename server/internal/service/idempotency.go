@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"time"
+
+	"eatclean/internal/config"
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
+
+var (
+	// ErrIdempotencyInFlight 相同 key 的首次请求仍在处理。
+	ErrIdempotencyInFlight = errors.New("idempotent request in flight")
+	// ErrIdempotencyMismatch 同一个 key 用于不同的请求体。
+	ErrIdempotencyMismatch = errors.New("idempotency key reused with different request")
+)
+
+// idempotencyMetrics 统计：acquired、replayed、in_flight、mismatch、released、store_error
+var idempotencyMetrics = expvar.NewMap("idempotency")
+
+// IdempotencyService 按 (用户, 路由, Idempotency-Key) 保存首次响应，重试时重放。
+type IdempotencyService struct {
+	repo *repository.IdempotencyRepository
+	cfg  config.IdempotencyConfig
+}
+
+func NewIdempotencyService(repo *repository.IdempotencyRepository, cfg config.IdempotencyConfig) *IdempotencyService {
+	return &IdempotencyService{repo: repo, cfg: cfg}
+}
+
+func (s *IdempotencyService) IsEnabled() bool {
+	return s != nil && s.repo != nil
+}
+
+// MaxResponseBytes 可保存的最大响应体。
+func (s *IdempotencyService) MaxResponseBytes() int {
+	return s.cfg.MaxResponseKB * 1024
+}
+
+// MaxRequestBytes 计算请求哈希时读取的最大请求体。
+func (s *IdempotencyService) MaxRequestBytes() int64 {
+	return int64(s.cfg.MaxRequestKB) * 1024
+}
+
+// Begin 登记一次执行。返回已完成的记录时调用方应重放；
+// 返回 nil 且无错误时由调用方执行请求，结束后调用 Complete 或 Release。
+func (s *IdempotencyService) Begin(userID int64, route string, key string, requestHash string) (*model.IdempotencyRecord, error) {
+	acquired, existing, err := s.repo.Acquire(
+		userID,
+		route,
+		key,
+		requestHash,
+		time.Duration(s.cfg.TTLHours)*time.Hour,
+		time.Duration(s.cfg.InFlightTimeoutSeconds)*time.Second,
+	)
+	if err != nil {
+		idempotencyMetrics.Add("store_error", 1)
+		return nil, err
+	}
+	if acquired {
+		idempotencyMetrics.Add("acquired", 1)
+		return nil, nil
+	}
+	if existing != nil && existing.RequestHash != requestHash {
+		idempotencyMetrics.Add("mismatch", 1)
+		return nil, ErrIdempotencyMismatch
+	}
+	// 读不到说明首次请求刚刚释放，同样按处理中返回，由客户端稍后重试
+	if existing == nil || existing.Status != model.IdempotencyCompleted {
+		idempotencyMetrics.Add("in_flight", 1)
+		return nil, ErrIdempotencyInFlight
+	}
+	idempotencyMetrics.Add("replayed", 1)
+	return existing, nil
+}
+
+// Complete 保存首次响应。
+func (s *IdempotencyService) Complete(userID int64, route string, key string, status int, contentType string, body []byte) {
+	if err := s.repo.Complete(userID, route, key, status, contentType, body); err != nil {
+		idempotencyMetrics.Add("store_error", 1)
+		log.Printf("idempotency complete failed (user %d, %s): %v", userID, route, err)
+	}
+}
+
+// Release 放弃本次登记，客户端可用同一个 key 重试。
+func (s *IdempotencyService) Release(userID int64, route string, key string) {
+	idempotencyMetrics.Add("released", 1)
+	if err := s.repo.Release(userID, route, key); err != nil {
+		idempotencyMetrics.Add("store_error", 1)
+		log.Printf("idempotency release failed (user %d, %s): %v", userID, route, err)
+	}
+}
+
+// Start 每小时清理过期记录。
+func (s *IdempotencyService) Start(ctx context.Context) {
+	if !s.IsEnabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.repo.DeleteExpired(); err != nil {
+					log.Printf("idempotency cleanup failed: %v", err)
+				}
+			}
+		}
+	}()
+}
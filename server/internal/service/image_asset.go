@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"eatclean/internal/config"
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
+
+// ErrImageAssetNotFound 引用的图片不存在或不属于当前用户。
+var ErrImageAssetNotFound = errors.New("image asset not found")
+
+// ResolvedImages 请求中的图片解析结果：记录里保存 AssetIDs 与 Keys，ModelURLs 传给视觉模型。
+type ResolvedImages struct {
+	AssetIDs  []int64
+	Keys      []string
+	ModelURLs []string
+	// ContentHashes/PHashes 与 AssetIDs 一一对应，用于识别结果缓存
+	ContentHashes []string
+	PHashes       []int64
+	// Qualities 各图片的质量指标，旧记录为 nil
+	Qualities []*model.ImageQuality
+}
+
+// ImageAssetService 图片入库：校验、去除 EXIF、纠正方向、生成模型尺寸与缩略图，并按内容哈希去重。
+type ImageAssetService struct {
+	repo    *repository.ImageAssetRepository
+	storage *StorageService
+	cfg     config.ImageConfig
+}
+
+func NewImageAssetService(repo *repository.ImageAssetRepository, storage *StorageService, cfg config.ImageConfig) *ImageAssetService {
+	return &ImageAssetService{repo: repo, storage: storage, cfg: cfg}
+}
+
+// Ingest 处理一张上传图片。同一用户相同内容直接返回已有记录，deduplicated 为 true。
+func (s *ImageAssetService) Ingest(ctx context.Context, userID int64, category string, data []byte) (*model.ImageAsset, bool, error) {
+	if s == nil || s.repo == nil || s.storage == nil || s.storage.Store() == nil {
+		return nil, false, ErrStorageNotConfigured
+	}
+	category = strings.ToLower(strings.TrimSpace(category))
+	if !ossUploadCategories[category] {
+		return nil, false, ErrInvalidUploadCategory
+	}
+	if int64(len(data)) > s.maxBytes() {
+		return nil, false, ErrImageTooLarge
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if existing, err := s.repo.FindByHash(userID, hash); err != nil {
+		return nil, false, err
+	} else if existing != nil {
+		return existing, true, nil
+	}
+
+	contentType := http.DetectContentType(data)
+	processed, err := decodeImage(data, contentType, s.cfg.MaxMegapixels*1000*1000)
+	if err != nil {
+		return nil, false, err
+	}
+	modelData, modelWidth, modelHeight, err := processed.rendition(s.cfg.ModelMaxSide, s.cfg.JPEGQuality)
+	if err != nil {
+		return nil, false, err
+	}
+	thumbData, _, _, err := processed.rendition(s.cfg.ThumbMaxSide, s.cfg.JPEGQuality)
+	if err != nil {
+		return nil, false, err
+	}
+
+	prefix, err := s.storage.UserPrefix(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	base := fmt.Sprintf("%s%s/assets/%s", prefix, category, hash)
+	asset := &model.ImageAsset{
+		UserID:      userID,
+		ContentHash: hash,
+		Category:    category,
+		SourceMime:  contentType,
+		SourceBytes: int64(len(data)),
+		Width:       processed.Width,
+		Height:      processed.Height,
+		ModelKey:    base + ".jpg",
+		ModelWidth:  modelWidth,
+		ModelHeight: modelHeight,
+		ThumbKey:    base + "_thumb.jpg",
+		PHash:       int64(processed.differenceHash()),
+		Quality:     processed.quality(),
+	}
+	store := s.storage.Store()
+	if err := store.Put(ctx, asset.ModelKey, "image/jpeg", modelData); err != nil {
+		return nil, false, err
+	}
+	if err := store.Put(ctx, asset.ThumbKey, "image/jpeg", thumbData); err != nil {
+		return nil, false, err
+	}
+	created, err := s.repo.Create(asset)
+	if err != nil {
+		return nil, false, err
+	}
+	return asset, !created, nil
+}
+
+// IngestObject 处理客户端直传到存储的原图，已入库的版本直接返回。原图可能仍被旧记录引用，不在此删除，
+// 无引用的原图（含 EXIF）由 images.retention 在宽限期后清理。
+func (s *ImageAssetService) IngestObject(ctx context.Context, userID int64, category string, rawURL string) (*model.ImageAsset, bool, error) {
+	if s == nil || s.repo == nil || s.storage == nil {
+		return nil, false, ErrStorageNotConfigured
+	}
+	key, err := s.storage.CheckOwnership(userID, rawURL)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing, err := s.repo.FindByModelKey(userID, key); err != nil {
+		return nil, false, err
+	} else if existing != nil {
+		return existing, true, nil
+	}
+	body, err := s.storage.Store().Open(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := io.ReadAll(io.LimitReader(body, s.maxBytes()+1))
+	body.Close()
+	if err != nil {
+		return nil, false, err
+	}
+	return s.Ingest(ctx, userID, category, data)
+}
+
+// Assets 解析请求中的图片：优先使用 image_asset_ids，否则把 image_urls 逐个入库。结果与请求顺序一致。
+func (s *ImageAssetService) Assets(ctx context.Context, userID int64, category string, assetIDs []int64, urls []string) ([]model.ImageAsset, error) {
+	if s == nil || s.repo == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	var assets []model.ImageAsset
+	if len(assetIDs) > 0 {
+		found, err := s.repo.ListByIDs(userID, assetIDs)
+		if err != nil {
+			return nil, err
+		}
+		byID := make(map[int64]model.ImageAsset, len(found))
+		for _, asset := range found {
+			byID[asset.ID] = asset
+		}
+		for _, id := range assetIDs {
+			asset, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("%w: %d", ErrImageAssetNotFound, id)
+			}
+			assets = append(assets, asset)
+		}
+		return assets, nil
+	}
+	for _, raw := range urls {
+		asset, _, err := s.IngestObject(ctx, userID, category, raw)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, *asset)
+	}
+	return assets, nil
+}
+
+// Resolve 在 Assets 基础上生成传给视觉模型的图片地址（签名 URL 或内联 base64）。
+func (s *ImageAssetService) Resolve(ctx context.Context, userID int64, category string, assetIDs []int64, urls []string) (*ResolvedImages, error) {
+	assets, err := s.Assets(ctx, userID, category, assetIDs, urls)
+	if err != nil {
+		return nil, err
+	}
+	resolved := &ResolvedImages{}
+	for _, asset := range assets {
+		resolved.AssetIDs = append(resolved.AssetIDs, asset.ID)
+		resolved.Keys = append(resolved.Keys, asset.ModelKey)
+		resolved.ContentHashes = append(resolved.ContentHashes, asset.ContentHash)
+		resolved.PHashes = append(resolved.PHashes, asset.PHash)
+		resolved.Qualities = append(resolved.Qualities, asset.Quality)
+	}
+	modelURLs, err := s.storage.ModelImageURLs(ctx, userID, resolved.Keys, 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	resolved.ModelURLs = modelURLs
+	return resolved, nil
+}
+
+// SignAsset 返回模型尺寸版本与缩略图的签名 URL。
+func (s *ImageAssetService) SignAsset(userID int64, asset *model.ImageAsset, ttl time.Duration) (string, string, error) {
+	modelURL, err := s.storage.SignURL(userID, asset.ModelKey, ttl)
+	if err != nil {
+		return "", "", err
+	}
+	thumbURL, err := s.storage.SignURL(userID, asset.ThumbKey, ttl)
+	if err != nil {
+		return "", "", err
+	}
+	return modelURL, thumbURL, nil
+}
+
+// MaxBytes 单张图片大小上限。
+func (s *ImageAssetService) MaxBytes() int64 {
+	return s.maxBytes()
+}
+
+func (s *ImageAssetService) maxBytes() int64 {
+	if s == nil || s.cfg.MaxUploadMB <= 0 {
+		return 10 << 20
+	}
+	return int64(s.cfg.MaxUploadMB) << 20
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"strings"
+
+	"eatclean/internal/config"
+	"eatclean/internal/model"
+)
+
+// 图片预检错误码，客户端据此提示用户重拍。
+const (
+	ImageIssueTooBlurry          = "IMAGE_TOO_BLURRY"
+	ImageIssueTooDark            = "IMAGE_TOO_DARK"
+	ImageIssueOverexposed        = "IMAGE_OVEREXPOSED"
+	ImageIssueNotFood            = "IMAGE_NOT_FOOD"
+	ImageIssueNotMenu            = "IMAGE_NOT_MENU"
+	ImageIssueNotIngredientLabel = "IMAGE_NOT_INGREDIENT_LABEL"
+)
+
+var imageIssueMessages = map[string]string{
+	ImageIssueTooBlurry:          "图片模糊，请对焦后重拍",
+	ImageIssueTooDark:            "图片过暗，请在光线充足处重拍",
+	ImageIssueOverexposed:        "图片过曝，请避开强光重拍",
+	ImageIssueNotFood:            "未识别到食物，请拍摄餐食照片",
+	ImageIssueNotMenu:            "未识别到菜单，请拍摄完整的菜单",
+	ImageIssueNotIngredientLabel: "未识别到配料表，请拍摄包装上的配料表或营养成分表",
+}
+
+// imagePrecheckMetrics 预检统计：<route>.<code>、<route>.rejected、<route>.relevance_error
+var imagePrecheckMetrics = expvar.NewMap("image_precheck")
+
+// relevanceExpectations 各识别路由可接受的图片类别及不符合时的错误码。
+var relevanceExpectations = map[string]struct {
+	categories map[string]bool
+	code       string
+}{
+	AIRouteMenuScan:       {map[string]bool{"menu": true}, ImageIssueNotMenu},
+	AIRouteFoodScan:       {map[string]bool{"food": true}, ImageIssueNotFood},
+	AIRouteIngredientScan: {map[string]bool{"ingredient_label": true, "food": true}, ImageIssueNotIngredientLabel},
+	AIRouteLabelScan:      {map[string]bool{"ingredient_label": true}, ImageIssueNotIngredientLabel},
+}
+
+// ImageIssue 单张图片的预检问题，Index 从 0 开始与请求顺序一致。
+type ImageIssue struct {
+	Index   int    `json:"index"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ImagePrecheckError 所有图片都不合格时返回，Code 为第一个问题的错误码。
+type ImagePrecheckError struct {
+	Code   string
+	Issues []ImageIssue
+}
+
+func (e *ImagePrecheckError) Error() string {
+	return fmt.Sprintf("image precheck failed: %s", e.Code)
+}
+
+// Message 返回第一个问题的用户提示。
+func (e *ImagePrecheckError) Message() string {
+	if len(e.Issues) > 0 {
+		return e.Issues[0].Message
+	}
+	return imageIssueMessages[e.Code]
+}
+
+// ImagePrecheckService 调用视觉识别前的预检：入库时计算的清晰度/曝光指标，以及可选的轻量模型相关性判断。
+// 全部图片不合格时返回 ImagePrecheckError（reject 模式），部分不合格时作为提示返回。
+type ImagePrecheckService struct {
+	vision *VisionService
+	cfg    config.ImagePrecheckConfig
+}
+
+func NewImagePrecheckService(vision *VisionService, cfg config.ImagePrecheckConfig) *ImagePrecheckService {
+	return &ImagePrecheckService{vision: vision, cfg: cfg}
+}
+
+func (s *ImagePrecheckService) enabled() bool {
+	return s != nil && s.cfg.Mode != "off"
+}
+
+// CheckQuality 按清晰度与平均亮度检查，没有质量指标的旧图片跳过。
+func (s *ImagePrecheckService) CheckQuality(route string, images *ResolvedImages) ([]ImageIssue, error) {
+	if !s.enabled() || images == nil {
+		return nil, nil
+	}
+	var issues []ImageIssue
+	for idx, quality := range images.Qualities {
+		if code := s.qualityIssue(quality); code != "" {
+			issues = append(issues, newImageIssue(idx, code))
+		}
+	}
+	return s.decide(route, len(images.Qualities), issues)
+}
+
+// CheckRelevance 开启 relevance_check 时调用轻量模型判断图片类别；模型不可用时放行。
+func (s *ImagePrecheckService) CheckRelevance(ctx context.Context, route string, images *ResolvedImages) ([]ImageIssue, error) {
+	if !s.enabled() || !s.cfg.RelevanceCheck || images == nil || len(images.ModelURLs) == 0 {
+		return nil, nil
+	}
+	expected, ok := relevanceExpectations[route]
+	if !ok || s.vision == nil || !s.vision.IsEnabled() || !s.vision.RouteAvailable(AIRouteImagePrecheck) {
+		return nil, nil
+	}
+	check, err := s.vision.ClassifyImages(ctx, images.ModelURLs)
+	if err != nil {
+		log.Printf("image precheck relevance failed (route %s): %v", route, err)
+		imagePrecheckMetrics.Add(route+".relevance_error", 1)
+		return nil, nil
+	}
+	var issues []ImageIssue
+	for idx, item := range check.Images {
+		if idx >= len(images.ModelURLs) {
+			break
+		}
+		category := strings.TrimSpace(item.Category)
+		if !expected.categories[category] && float64(item.Confidence) >= s.cfg.MinConfidence {
+			issues = append(issues, newImageIssue(idx, expected.code))
+		}
+	}
+	return s.decide(route, len(images.ModelURLs), issues)
+}
+
+func (s *ImagePrecheckService) qualityIssue(quality *model.ImageQuality) string {
+	switch {
+	case quality == nil:
+		return ""
+	case quality.Brightness < s.cfg.MinBrightness:
+		return ImageIssueTooDark
+	case quality.Brightness > s.cfg.MaxBrightness:
+		return ImageIssueOverexposed
+	case quality.Sharpness < s.cfg.MinSharpness:
+		return ImageIssueTooBlurry
+	}
+	return ""
+}
+
+// decide reject 模式下所有图片都有问题时拒绝，否则把问题作为提示返回。
+func (s *ImagePrecheckService) decide(route string, total int, issues []ImageIssue) ([]ImageIssue, error) {
+	if len(issues) == 0 {
+		return nil, nil
+	}
+	for _, issue := range issues {
+		imagePrecheckMetrics.Add(route+"."+issue.Code, 1)
+	}
+	failed := map[int]bool{}
+	for _, issue := range issues {
+		failed[issue.Index] = true
+	}
+	if s.cfg.Mode == "reject" && len(failed) >= total {
+		imagePrecheckMetrics.Add(route+".rejected", 1)
+		return nil, &ImagePrecheckError{Code: issues[0].Code, Issues: issues}
+	}
+	return issues, nil
+}
+
+func newImageIssue(index int, code string) ImageIssue {
+	return ImageIssue{Index: index, Code: code, Message: imageIssueMessages[code]}
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math/bits"
+	"net/http"
+
+	_ "image/gif"
+	_ "image/png"
+
+	"eatclean/internal/model"
+)
+
+var (
+	// ErrUnsupportedImage 不是可解码的 JPEG/PNG/GIF 图片。
+	ErrUnsupportedImage = errors.New("unsupported image format")
+	// ErrImageTooLarge 文件或像素尺寸超过限制。
+	ErrImageTooLarge = errors.New("image too large")
+)
+
+// 未经入库的原始图片发送给模型前的默认处理参数
+const (
+	defaultModelMaxSide   = 1280
+	defaultModelQuality   = 85
+	defaultModelMaxPixels = 25 * 1000 * 1000
+)
+
+// supportedImageTypes 可接受的上传格式（按内容嗅探，不信任扩展名）。
+var supportedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// processedImage 去除元数据并纠正方向后的图片，Width/Height 为纠正后的尺寸。
+type processedImage struct {
+	img    *image.RGBA
+	Width  int
+	Height int
+}
+
+// decodeImage 按内容校验格式与像素数，解码并按 EXIF 方向旋转。重新编码时 EXIF/GPS 等元数据全部丢弃。
+func decodeImage(data []byte, contentType string, maxPixels int) (*processedImage, error) {
+	if !supportedImageTypes[contentType] {
+		return nil, ErrUnsupportedImage
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrUnsupportedImage
+	}
+	if maxPixels > 0 && cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	rgba := toRGBA(decoded)
+	if contentType == "image/jpeg" {
+		rgba = applyOrientation(rgba, jpegOrientation(data))
+	}
+	bounds := rgba.Bounds()
+	return &processedImage{img: rgba, Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// rendition 等比缩放到最长边不超过 maxSide 并编码为 JPEG。
+func (p *processedImage) rendition(maxSide int, quality int) ([]byte, int, int, error) {
+	img := p.img
+	if maxSide > 0 && (p.Width > maxSide || p.Height > maxSide) {
+		width, height := fitWithin(p.Width, p.Height, maxSide)
+		img = downscale(p.img, width, height)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, 0, 0, err
+	}
+	bounds := img.Bounds()
+	return buf.Bytes(), bounds.Dx(), bounds.Dy(), nil
+}
+
+// modelImageBytes 把原始图片处理为模型尺寸的 JPEG（去除 EXIF），无法解码时原样返回。
+func modelImageBytes(data []byte) []byte {
+	processed, err := decodeImage(data, http.DetectContentType(data), defaultModelMaxPixels)
+	if err != nil {
+		return data
+	}
+	encoded, _, _, err := processed.rendition(defaultModelMaxSide, defaultModelQuality)
+	if err != nil {
+		return data
+	}
+	return encoded
+}
+
+// differenceHash 64 位差值哈希（dHash）：缩小为 9x8 后比较相邻像素亮度，近似图片的汉明距离很小。
+func (p *processedImage) differenceHash() uint64 {
+	small := downscale(p.img, 9, 8)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if pixelLuma(small, x, y) < pixelLuma(small, x+1, y) {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return hash
+}
+
+// qualityMaxSide 计算质量指标前统一缩小，使清晰度阈值与原图分辨率无关
+const qualityMaxSide = 512
+
+// quality 计算拉普拉斯方差（清晰度）与亮度分布（曝光）。
+func (p *processedImage) quality() *model.ImageQuality {
+	img := p.img
+	if p.Width > qualityMaxSide || p.Height > qualityMaxSide {
+		width, height := fitWithin(p.Width, p.Height, qualityMaxSide)
+		img = downscale(p.img, width, height)
+	}
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	gray := make([]float64, width*height)
+	var sum float64
+	var dark, bright int
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			value := float64(pixelLuma(img, x, y)) / 1000
+			gray[y*width+x] = value
+			sum += value
+			if value < 20 {
+				dark++
+			} else if value > 245 {
+				bright++
+			}
+		}
+	}
+	total := float64(width * height)
+	result := &model.ImageQuality{
+		Brightness:  sum / total,
+		DarkRatio:   float64(dark) / total,
+		BrightRatio: float64(bright) / total,
+	}
+	if width < 3 || height < 3 {
+		return result
+	}
+	var lapSum, lapSquares float64
+	var count float64
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			i := y*width + x
+			lap := gray[i-width] + gray[i+width] + gray[i-1] + gray[i+1] - 4*gray[i]
+			lapSum += lap
+			lapSquares += lap * lap
+			count++
+		}
+	}
+	mean := lapSum / count
+	result.Sharpness = lapSquares/count - mean*mean
+	return result
+}
+
+func pixelLuma(img *image.RGBA, x, y int) uint32 {
+	i := y*img.Stride + x*4
+	return 299*uint32(img.Pix[i]) + 587*uint32(img.Pix[i+1]) + 114*uint32(img.Pix[i+2])
+}
+
+// HammingDistance 两个感知哈希不同的位数。
+func HammingDistance(a, b int64) int {
+	return bits.OnesCount64(uint64(a ^ b))
+}
+
+func fitWithin(width, height, maxSide int) (int, int) {
+	if width >= height {
+		scaled := height * maxSide / width
+		if scaled < 1 {
+			scaled = 1
+		}
+		return maxSide, scaled
+	}
+	scaled := width * maxSide / height
+	if scaled < 1 {
+		scaled = 1
+	}
+	return scaled, maxSide
+}
+
+// toRGBA 转为 RGBA，透明区域铺白底（JPEG 不支持透明）。
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+	return dst
+}
+
+// downscale 区域平均缩小，每个目标像素取其覆盖的源像素均值。
+func downscale(src *image.RGBA, width, height int) *image.RGBA {
+	srcW, srcH := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := y * srcH / height
+		y1 := (y + 1) * srcH / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := x * srcW / width
+			x1 := (x + 1) * srcW / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				offset := sy*src.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(src.Pix[offset])
+					g += uint32(src.Pix[offset+1])
+					b += uint32(src.Pix[offset+2])
+					a += uint32(src.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// applyOrientation 按 EXIF Orientation(1-8) 旋转/翻转为正向。
+func applyOrientation(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			si := y*src.Stride + x*4
+			di := dy*dst.Stride + dx*4
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation 从 APP1 Exif 段读取 Orientation 标签，读取失败返回 1。
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8 : entry+10]))
+		}
+	}
+	return 1
+}
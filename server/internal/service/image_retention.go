@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"eatclean/internal/config"
+	"eatclean/internal/repository"
+)
+
+// 清理原因。
+const (
+	ImageRetentionOrphaned = "orphaned"
+	ImageRetentionExpired  = "expired"
+)
+
+// imageRetentionSampleLimit 报告中最多列出的待删除对象数。
+const imageRetentionSampleLimit = 200
+
+// ErrImageRetentionRunning 上一次清理尚未结束。
+var ErrImageRetentionRunning = errors.New("image retention already running")
+
+// imageRetentionMetrics 清理统计：runs、failed_runs、deleted、delete_errors、deleted_bytes
+var imageRetentionMetrics = expvar.NewMap("image_retention")
+
+// ImageRetentionCandidate 待删除（dry-run 时为将被删除）的对象。
+type ImageRetentionCandidate struct {
+	Key          string    `json:"key"`
+	Category     string    `json:"category"`
+	Reason       string    `json:"reason"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// ImageRetentionCategoryStats 单个分类的扫描结果。
+type ImageRetentionCategoryStats struct {
+	Scanned        int   `json:"scanned"`
+	Orphaned       int   `json:"orphaned"`
+	Expired        int   `json:"expired"`
+	CandidateBytes int64 `json:"candidate_bytes"`
+}
+
+// ImageRetentionReport 一次清理的结果；DryRun 时 Deleted 始终为 0。
+type ImageRetentionReport struct {
+	DryRun         bool                                    `json:"dry_run"`
+	StartedAt      time.Time                               `json:"started_at"`
+	FinishedAt     time.Time                               `json:"finished_at"`
+	Prefix         string                                  `json:"prefix"`
+	Scanned        int                                     `json:"scanned"`
+	Referenced     int                                     `json:"referenced"`
+	InGracePeriod  int                                     `json:"in_grace_period"`
+	Orphaned       int                                     `json:"orphaned"`
+	Expired        int                                     `json:"expired"`
+	CandidateBytes int64                                   `json:"candidate_bytes"`
+	Deleted        int                                     `json:"deleted"`
+	DeletedBytes   int64                                   `json:"deleted_bytes"`
+	DeleteErrors   int                                     `json:"delete_errors"`
+	AssetsRemoved  int64                                   `json:"assets_removed"`
+	LimitReached   bool                                    `json:"limit_reached"`
+	Categories     map[string]*ImageRetentionCategoryStats `json:"categories"`
+	Candidates     []ImageRetentionCandidate               `json:"candidates"`
+	Error          string                                  `json:"error,omitempty"`
+}
+
+// ImageRetentionService 清理存储桶中无引用的图片，并按分类保留期删除过期图片。
+// 先列出对象再读取引用，列出之后新增的引用一定能被看到；宽限期覆盖“先上传、后提交记录”的窗口。
+type ImageRetentionService struct {
+	store  ObjectStore
+	refs   *repository.ImageRetentionRepository
+	assets *repository.ImageAssetRepository
+	cfg    config.ImageRetentionConfig
+
+	running sync.Mutex
+	mu      sync.RWMutex
+	last    *ImageRetentionReport
+}
+
+func NewImageRetentionService(store ObjectStore, refs *repository.ImageRetentionRepository, assets *repository.ImageAssetRepository, cfg config.ImageRetentionConfig) *ImageRetentionService {
+	return &ImageRetentionService{store: store, refs: refs, assets: assets, cfg: cfg}
+}
+
+func (s *ImageRetentionService) IsEnabled() bool {
+	return s != nil && s.store != nil && s.refs != nil
+}
+
+// Config 当前生效的清理配置，供管理接口展示。
+func (s *ImageRetentionService) Config() config.ImageRetentionConfig {
+	if s == nil {
+		return config.ImageRetentionConfig{}
+	}
+	return s.cfg
+}
+
+// LastReport 最近一次清理报告，未运行过时为 nil。
+func (s *ImageRetentionService) LastReport() *ImageRetentionReport {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.last
+}
+
+// Start 按 interval_hours 定期执行，retention.enabled 关闭时不启动。
+func (s *ImageRetentionService) Start(ctx context.Context) {
+	if !s.IsEnabled() || !s.cfg.Enabled {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(s.cfg.IntervalHours) * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := s.Run(ctx, s.cfg.DryRun)
+				if err != nil {
+					log.Printf("image retention failed: %v", err)
+					continue
+				}
+				log.Printf("image retention finished (dry_run=%v): scanned=%d orphaned=%d expired=%d deleted=%d errors=%d",
+					report.DryRun, report.Scanned, report.Orphaned, report.Expired, report.Deleted, report.DeleteErrors)
+			}
+		}
+	}()
+}
+
+// Run 执行一次清理；dryRun 时只统计不删除。
+func (s *ImageRetentionService) Run(ctx context.Context, dryRun bool) (*ImageRetentionReport, error) {
+	if !s.IsEnabled() {
+		return nil, ErrStorageNotConfigured
+	}
+	if !s.running.TryLock() {
+		return nil, ErrImageRetentionRunning
+	}
+	defer s.running.Unlock()
+
+	report, err := s.run(ctx, dryRun)
+	if err != nil {
+		imageRetentionMetrics.Add("failed_runs", 1)
+		report.Error = err.Error()
+	} else {
+		imageRetentionMetrics.Add("runs", 1)
+	}
+	report.FinishedAt = time.Now()
+	s.mu.Lock()
+	s.last = report
+	s.mu.Unlock()
+	return report, err
+}
+
+func (s *ImageRetentionService) run(ctx context.Context, dryRun bool) (*ImageRetentionReport, error) {
+	now := time.Now()
+	report := &ImageRetentionReport{
+		DryRun:     dryRun,
+		StartedAt:  now,
+		Prefix:     s.cfg.Prefix,
+		Categories: map[string]*ImageRetentionCategoryStats{},
+		Candidates: []ImageRetentionCandidate{},
+	}
+	objects, err := s.store.List(ctx, s.cfg.Prefix, 0)
+	if err != nil {
+		return report, err
+	}
+	referenced, err := s.referencedKeys()
+	if err != nil {
+		return report, err
+	}
+
+	grace := time.Duration(s.cfg.GraceHours) * time.Hour
+	var candidates []ImageRetentionCandidate
+	for _, object := range objects {
+		category := imageObjectCategory(s.cfg.Prefix, object.Key)
+		stats := report.Categories[category]
+		if stats == nil {
+			stats = &ImageRetentionCategoryStats{}
+			report.Categories[category] = stats
+		}
+		stats.Scanned++
+		report.Scanned++
+
+		age := now.Sub(object.LastModified)
+		if age < grace {
+			report.InGracePeriod++
+			continue
+		}
+		reason := ""
+		if days := s.cfg.Categories[category]; days > 0 && age > time.Duration(days)*24*time.Hour {
+			reason = ImageRetentionExpired
+			stats.Expired++
+			report.Expired++
+		} else if !referenced[object.Key] {
+			reason = ImageRetentionOrphaned
+			stats.Orphaned++
+			report.Orphaned++
+		} else {
+			report.Referenced++
+			continue
+		}
+		stats.CandidateBytes += object.Size
+		report.CandidateBytes += object.Size
+		candidates = append(candidates, ImageRetentionCandidate{
+			Key:          object.Key,
+			Category:     category,
+			Reason:       reason,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		})
+	}
+
+	// 最早的对象优先删除，达到 max_deletes 后留给下一次
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].LastModified.Before(candidates[j].LastModified)
+	})
+	if len(candidates) > s.cfg.MaxDeletes {
+		candidates = candidates[:s.cfg.MaxDeletes]
+		report.LimitReached = true
+	}
+	for i, candidate := range candidates {
+		if i < imageRetentionSampleLimit {
+			report.Candidates = append(report.Candidates, candidate)
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	var deletedKeys []string
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if err := s.store.Delete(ctx, candidate.Key); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			log.Printf("image retention delete %s failed: %v", candidate.Key, err)
+			report.DeleteErrors++
+			imageRetentionMetrics.Add("delete_errors", 1)
+			continue
+		}
+		deletedKeys = append(deletedKeys, candidate.Key)
+		report.Deleted++
+		report.DeletedBytes += candidate.Size
+	}
+	imageRetentionMetrics.Add("deleted", int64(report.Deleted))
+	imageRetentionMetrics.Add("deleted_bytes", report.DeletedBytes)
+	if s.assets != nil && len(deletedKeys) > 0 {
+		removed, err := s.assets.DeleteByKeys(deletedKeys)
+		if err != nil {
+			return report, err
+		}
+		report.AssetsRemoved = removed
+	}
+	return report, nil
+}
+
+// referencedKeys 把各表的引用统一解析为对象名；被引用图片的缩略图同样视为被引用。
+func (s *ImageRetentionService) referencedKeys() (map[string]bool, error) {
+	urls, err := s.refs.ReferencedImageURLs()
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool, len(urls))
+	for _, raw := range urls {
+		key, err := s.store.KeyFromURL(raw)
+		if err != nil || key == "" {
+			continue
+		}
+		referenced[key] = true
+	}
+	if s.assets != nil {
+		thumbs, err := s.assets.ThumbKeys()
+		if err != nil {
+			return nil, err
+		}
+		for modelKey, thumbKey := range thumbs {
+			if referenced[modelKey] {
+				referenced[thumbKey] = true
+			}
+		}
+	}
+	return referenced, nil
+}
+
+// imageObjectCategory 从 {prefix}{owner}/{category}/... 中取出分类，无法识别时返回 "other"。
+func imageObjectCategory(prefix string, key string) string {
+	parts := strings.SplitN(strings.TrimPrefix(key, prefix), "/", 3)
+	if len(parts) < 3 || parts[1] == "" {
+		return "other"
+	}
+	return parts[1]
+}
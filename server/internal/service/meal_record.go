@@ -3,19 +3,145 @@ package service
 import (
 	"eatclean/internal/model"
 	"eatclean/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"time"
 )
 
+// 常吃条目：回填时读取的最近记录数与返回上限。
+const (
+	frequentBackfillRecords = 300
+	frequentItemLimit       = 200
+)
+
+var (
+	ErrMealRecordNotFound = errors.New("meal record not found")
+	ErrMealItemNotFound   = errors.New("meal item not found")
+)
+
 type MealRecordService struct {
-	repo *repository.MealRecordRepository
+	repo     *repository.MealRecordRepository
+	frequent *repository.FrequentItemRepository
 }
 
-func NewMealRecordService(repo *repository.MealRecordRepository) *MealRecordService {
-	return &MealRecordService{repo: repo}
+func NewMealRecordService(repo *repository.MealRecordRepository, frequent *repository.FrequentItemRepository) *MealRecordService {
+	return &MealRecordService{repo: repo, frequent: frequent}
 }
 
+// Create 写入前按 quantity/unit 重算带份量的条目营养，单位或数量无效时返回 ErrPortionUnit/ErrPortionQuantity。
+// 写入成功后更新常吃条目统计，统计失败不影响记录。
 func (s *MealRecordService) Create(record *model.MealRecord) error {
-	return s.repo.Create(record)
+	items, err := scalePortionItems(record.Items)
+	if err != nil {
+		return err
+	}
+	record.Items = items
+	if err := s.repo.Create(record); err != nil {
+		return err
+	}
+	s.countFrequent(record)
+	return nil
+}
+
+// countFrequent 更新常吃条目统计，失败只记录日志。
+func (s *MealRecordService) countFrequent(record *model.MealRecord) {
+	if s.frequent == nil {
+		return
+	}
+	if err := s.frequent.Increment(record.UserID, TimeOfDayLabel(record.RecordedAt), frequentItemsOf(record), record.RecordedAt); err != nil {
+		log.Printf("frequent item update failed (user %d): %v", record.UserID, err)
+	}
+}
+
+// Copy 把一条记录复制到 recordedAt，条目与图片不变。复制的记录不计入拍照识别额度。
+func (s *MealRecordService) Copy(userID int64, id int64, recordedAt time.Time) (*model.MealRecord, error) {
+	source, err := s.repo.FindByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil {
+		return nil, ErrMealRecordNotFound
+	}
+	copied := copyMealRecord(source, recordedAt)
+	if err := s.Create(copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+// CopyDay 把 sourceDay 当天的全部记录复制到 targetDay，保留各自的时刻；在一个事务内写入，不会只复制一部分。
+// 没有记录时返回 ErrMealRecordNotFound。
+func (s *MealRecordService) CopyDay(userID int64, sourceDay time.Time, targetDay time.Time) ([]model.MealRecord, error) {
+	start := startOfDay(sourceDay)
+	records, err := s.repo.ListByUserBetween(userID, start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrMealRecordNotFound
+	}
+	offset := startOfDay(targetDay).Sub(start)
+	pending := make([]*model.MealRecord, 0, len(records))
+	for idx := range records {
+		pending = append(pending, copyMealRecord(&records[idx], records[idx].RecordedAt.Add(offset)))
+	}
+	if err := s.repo.CreateAll(pending); err != nil {
+		return nil, err
+	}
+	copied := make([]model.MealRecord, 0, len(pending))
+	for _, record := range pending {
+		s.countFrequent(record)
+		copied = append(copied, *record)
+	}
+	return copied, nil
+}
+
+// DayNutrition 当天全部就餐记录条目的营养合计；条目按写入时的每份数值计算，无法解析的条目跳过。
+func (s *MealRecordService) DayNutrition(userID int64, day time.Time) (model.NutritionFacts, error) {
+	start := startOfDay(day)
+	records, err := s.repo.ListByUserBetween(userID, start, start.AddDate(0, 0, 1))
+	if err != nil {
+		return model.NutritionFacts{}, err
+	}
+	var total model.NutritionFacts
+	for _, record := range records {
+		var items []map[string]interface{}
+		if err := json.Unmarshal(record.Items, &items); err != nil {
+			continue
+		}
+		for _, item := range items {
+			if facts, ok := model.NutritionFromMap(item, "").Serving(); ok {
+				total = total.Add(facts)
+			}
+		}
+	}
+	return total, nil
+}
+
+// FrequentItems 常吃条目；用户还没有统计数据时先由最近的就餐记录回填。
+func (s *MealRecordService) FrequentItems(userID int64) ([]model.FrequentItem, error) {
+	if s.frequent == nil {
+		return nil, nil
+	}
+	exists, err := s.frequent.HasAny(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		records, err := s.repo.ListByUser(userID, frequentBackfillRecords)
+		if err != nil {
+			return nil, err
+		}
+		for idx := len(records) - 1; idx >= 0; idx-- {
+			record := &records[idx]
+			if err := s.frequent.Increment(userID, TimeOfDayLabel(record.RecordedAt), frequentItemsOf(record), record.RecordedAt); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.frequent.ListByUser(userID, frequentItemLimit)
 }
 
 func (s *MealRecordService) ListByUser(userID int64, limit int) ([]model.MealRecord, error) {
@@ -30,3 +156,108 @@ func (s *MealRecordService) CountByUserSourceBetween(
 ) (int, error) {
 	return s.repo.CountByUserSourceBetween(userID, source, start, end)
 }
+
+// UpdateItemPortion 修改记录中第 index 个条目的份量并按比例重算营养。读取与写回在同一事务内并锁定记录，
+// 同时修改同一记录的不同条目不会互相覆盖。
+func (s *MealRecordService) UpdateItemPortion(userID int64, id int64, index int, quantity float64, unit string) (*model.MealRecord, error) {
+	record, err := s.repo.UpdateItemsLocked(userID, id, func(raw json.RawMessage) (json.RawMessage, error) {
+		var items []map[string]interface{}
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, fmt.Errorf("decode meal items: %w", err)
+		}
+		if index < 0 || index >= len(items) {
+			return nil, ErrMealItemNotFound
+		}
+		scaled, err := ScalePortion(items[index], quantity, unit)
+		if err != nil {
+			return nil, err
+		}
+		items[index] = scaled
+		return json.Marshal(items)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrMealRecordNotFound
+	}
+	return record, nil
+}
+
+func copyMealRecord(source *model.MealRecord, recordedAt time.Time) *model.MealRecord {
+	meta, _ := json.Marshal(map[string]interface{}{
+		"source":      "copy",
+		"copied_from": source.ID,
+	})
+	return &model.MealRecord{
+		UserID:        source.UserID,
+		Source:        source.Source,
+		Items:         source.Items,
+		ImageUrls:     source.ImageUrls,
+		ImageAssetIDs: source.ImageAssetIDs,
+		Ratings:       source.Ratings,
+		Meta:          meta,
+		QuotaExempt:   true,
+		RecordedAt:    recordedAt,
+	}
+}
+
+// frequentItemsOf 记录中有名称的条目，同一记录内同名只计一次。
+func frequentItemsOf(record *model.MealRecord) []model.FrequentItem {
+	var items []map[string]interface{}
+	if json.Unmarshal(record.Items, &items) != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	out := make([]model.FrequentItem, 0, len(items))
+	for _, item := range items {
+		name := readString(item["name"])
+		if name == "" {
+			name = readString(item["title"])
+		}
+		norm := model.NormalizeFoodName(name)
+		if norm == "" || seen[norm] {
+			continue
+		}
+		seen[norm] = true
+		out = append(out, model.FrequentItem{Name: name, NormalizedName: norm, Item: item})
+	}
+	return out
+}
+
+func startOfDay(value time.Time) time.Time {
+	year, month, day := value.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, value.Location())
+}
+
+// scalePortionItems 重算带 quantity/unit 的条目；无法换算（缺少营养基准）的条目与非对象条目原样保留。
+func scalePortionItems(raw json.RawMessage) (json.RawMessage, error) {
+	var items []interface{}
+	if len(raw) == 0 || json.Unmarshal(raw, &items) != nil {
+		return raw, nil
+	}
+	changed := false
+	for idx, entry := range items {
+		item, ok := entry.(map[string]interface{})
+		if !ok || !HasPortion(item) {
+			continue
+		}
+		scaled, err := ScalePortion(item, model.NutrientValue(item, "quantity"), readString(item["unit"]))
+		if errors.Is(err, ErrPortionBase) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("items[%d]: %w", idx, err)
+		}
+		items[idx] = scaled
+		changed = true
+	}
+	if !changed {
+		return raw, nil
+	}
+	payload, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
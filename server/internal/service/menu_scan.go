@@ -25,3 +25,7 @@ func (s *MenuScanService) CountByUserBetween(
 ) (int, error) {
 	return s.repo.CountByUserBetween(userID, start, end)
 }
+
+func (s *MenuScanService) ListByUser(userID int64, limit int) ([]model.MenuScan, error) {
+	return s.repo.ListByUser(userID, limit)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"eatclean/internal/model"
+)
+
+// 营养素限量的键，与菜品/就餐条目 map 中的每份数值及 settings.nutrient_limits 中的键一致。
+const (
+	NutrientSodium       = "sodium_mg"
+	NutrientSugar        = "sugar_g"
+	NutrientAddedSugar   = "added_sugar_g"
+	NutrientSaturatedFat = "saturated_fat_g"
+	NutrientFiber        = "fiber_g"
+)
+
+// dishNutrientShare 单道菜达到每日上限的该比例即标记，即使当天仍有余量。
+const dishNutrientShare = 0.5
+
+// 限量状态。
+const (
+	NutrientStatusOK           = "ok"
+	NutrientStatusExceeded     = "exceeded"     // 上限已超出
+	NutrientStatusInsufficient = "insufficient" // 下限尚未达到
+)
+
+// NutrientLimit 一项每日限量。Minimum 为 true 时是需要达到的下限（膳食纤维），其余为上限。
+type NutrientLimit struct {
+	Key     string  `json:"key"`
+	Label   string  `json:"label"`
+	Unit    string  `json:"unit"`
+	Value   float64 `json:"value"`
+	Minimum bool    `json:"minimum,omitempty"`
+	// maxSetting 设置中允许的最大值，超出视为误填并使用默认值
+	maxSetting float64
+}
+
+// DefaultNutrientLimits 默认限量：钠按《中国居民膳食指南》食盐不超过 5g，糖与添加糖按 WHO 游离糖不超过能量的 10%/5%，
+// 饱和脂肪约为 2000kcal 的 10%，膳食纤维为每日至少 25g。
+var DefaultNutrientLimits = []NutrientLimit{
+	{Key: NutrientSodium, Label: "钠", Unit: "mg", Value: 2000, maxSetting: 10000},
+	{Key: NutrientSugar, Label: "糖", Unit: "g", Value: 50, maxSetting: 300},
+	{Key: NutrientAddedSugar, Label: "添加糖", Unit: "g", Value: 25, maxSetting: 300},
+	{Key: NutrientSaturatedFat, Label: "饱和脂肪", Unit: "g", Value: 20, maxSetting: 200},
+	{Key: NutrientFiber, Label: "膳食纤维", Unit: "g", Value: 25, Minimum: true, maxSetting: 100},
+}
+
+// Amount facts 中对应的营养素数值。
+func (l NutrientLimit) Amount(facts model.NutritionFacts) float64 {
+	switch l.Key {
+	case NutrientSodium:
+		return facts.SodiumMg
+	case NutrientSugar:
+		return facts.SugarG
+	case NutrientAddedSugar:
+		return facts.AddedSugarG
+	case NutrientSaturatedFat:
+		return facts.SaturatedFatG
+	case NutrientFiber:
+		return facts.FiberG
+	}
+	return 0
+}
+
+// NutrientLimitsFromSettings 默认限量按 settings.nutrient_limits 覆盖，如 {"sodium_mg": 1500, "added_sugar_g": 15}。
+func NutrientLimitsFromSettings(settings map[string]interface{}) []NutrientLimit {
+	overrides, _ := settings["nutrient_limits"].(map[string]interface{})
+	limits := make([]NutrientLimit, len(DefaultNutrientLimits))
+	for idx, limit := range DefaultNutrientLimits {
+		if value := settingFloat(overrides, 0, limit.maxSetting, limit.Key); value > 0 {
+			limit.Value = value
+		}
+		limits[idx] = limit
+	}
+	return limits
+}
+
+// NutrientStatus 当天一项营养素与限量的对比。
+type NutrientStatus struct {
+	NutrientLimit
+	Consumed float64 `json:"consumed"`
+	Percent  int     `json:"percent"`
+	Status   string  `json:"status"`
+}
+
+// NutrientStatuses 按限量顺序返回当天的对比结果。
+func NutrientStatuses(limits []NutrientLimit, consumed model.NutritionFacts) []NutrientStatus {
+	out := make([]NutrientStatus, 0, len(limits))
+	for _, limit := range limits {
+		amount := limit.Amount(consumed)
+		status := NutrientStatus{
+			NutrientLimit: limit,
+			Consumed:      roundNutrientValue(amount),
+			Status:        NutrientStatusOK,
+		}
+		if limit.Value > 0 {
+			status.Percent = int(math.Round(amount / limit.Value * 100))
+		}
+		switch {
+		case limit.Minimum && amount < limit.Value:
+			status.Status = NutrientStatusInsufficient
+		case !limit.Minimum && amount > limit.Value:
+			status.Status = NutrientStatusExceeded
+		}
+		out = append(out, status)
+	}
+	return out
+}
+
+// NutrientSummary 模板变量 nutrient_consumed：各项“已摄入/限量”，当天没有任何数值时为“暂无”。
+func NutrientSummary(limits []NutrientLimit, consumed model.NutritionFacts) string {
+	parts := make([]string, 0, len(limits))
+	recorded := false
+	for _, status := range NutrientStatuses(limits, consumed) {
+		if status.Consumed > 0 {
+			recorded = true
+		}
+		part := fmt.Sprintf("%s %s/%s%s", status.Label, formatNutrient(status.Consumed), formatNutrient(status.Value), status.Unit)
+		if status.Minimum {
+			part += "（下限）"
+		}
+		switch status.Status {
+		case NutrientStatusExceeded:
+			part += "，已超出"
+		case NutrientStatusInsufficient:
+			part += "，未达标"
+		}
+		parts = append(parts, part)
+	}
+	if !recorded {
+		return promptEmptyValue
+	}
+	return strings.Join(parts, "；")
+}
+
+// NutrientBudget 每日限量与当天已摄入，用于标记会超出限量的菜品。
+type NutrientBudget struct {
+	Limits   []NutrientLimit
+	Consumed model.NutritionFacts
+}
+
+// NutrientExcess 菜品超出的一项上限，Remaining 为当天剩余额度。
+type NutrientExcess struct {
+	Key       string  `json:"key"`
+	Label     string  `json:"label"`
+	Unit      string  `json:"unit"`
+	Amount    float64 `json:"amount"`
+	Limit     float64 `json:"limit"`
+	Remaining float64 `json:"remaining"`
+}
+
+func (e NutrientExcess) String() string {
+	if e.Amount <= e.Remaining {
+		return fmt.Sprintf("%s %s%s（占每日限量 %d%%）", e.Label, formatNutrient(e.Amount), e.Unit, int(math.Round(e.Amount/e.Limit*100)))
+	}
+	return fmt.Sprintf("%s %s%s（今日剩余 %s%s）", e.Label, formatNutrient(e.Amount), e.Unit, formatNutrient(e.Remaining), e.Unit)
+}
+
+// Check 一份菜品超出的上限：加上当天已摄入会超过限量，或单份已达到限量的一半。下限（膳食纤维）不检查，未知的营养素跳过。
+func (b *NutrientBudget) Check(facts model.NutritionFacts) []NutrientExcess {
+	if b == nil {
+		return nil
+	}
+	var out []NutrientExcess
+	for _, limit := range b.Limits {
+		amount := limit.Amount(facts)
+		if limit.Minimum || limit.Value <= 0 || amount <= 0 {
+			continue
+		}
+		remaining := math.Max(limit.Value-limit.Amount(b.Consumed), 0)
+		if amount <= remaining && amount < limit.Value*dishNutrientShare {
+			continue
+		}
+		out = append(out, NutrientExcess{
+			Key:       limit.Key,
+			Label:     limit.Label,
+			Unit:      limit.Unit,
+			Amount:    roundNutrientValue(amount),
+			Limit:     limit.Value,
+			Remaining: roundNutrientValue(remaining),
+		})
+	}
+	return out
+}
+
+// NutrientRiskAlert 超出限量的确定性风险提示。
+func NutrientRiskAlert(name string, excess []NutrientExcess) string {
+	parts := make([]string, 0, len(excess))
+	for _, item := range excess {
+		parts = append(parts, item.String())
+	}
+	return fmt.Sprintf("「%s」%s，接近或超出你的每日限量，建议少吃或换一道菜", name, strings.Join(parts, "、"))
+}
+
+func roundNutrientValue(value float64) float64 {
+	return math.Round(value*10) / 10
+}
+
+func formatNutrient(value float64) string {
+	return strconv.FormatFloat(roundNutrientValue(value), 'f', -1, 64)
+}
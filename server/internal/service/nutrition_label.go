@@ -0,0 +1,238 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"eatclean/internal/model"
+)
+
+// kJPerKcal GB 28050 的能量换算系数。
+const kJPerKcal = 4.184
+
+// 能量一致性容差：标签允许的修约与误差，偏差同时超过比例和绝对值才视为不一致。
+const (
+	labelEnergyTolerance = 0.2
+	labelEnergySlackKcal = 15
+)
+
+// nutrientReferenceValues GB 28050 营养素参考值（NRV），能量以 kcal 计（8400 kJ）。
+var nutrientReferenceValues = map[string]float64{
+	"energy":        8400 / kJPerKcal,
+	"protein":       60,
+	"fat":           60,
+	"saturated_fat": 20,
+	"carbs":         300,
+	"fiber":         25,
+	"sodium":        2000,
+}
+
+// NutritionLabelCheck 按蛋白质/碳水 4 kcal/g、脂肪 9 kcal/g 核对标示能量。
+type NutritionLabelCheck struct {
+	DeclaredKcal float64 `json:"declared_kcal"`
+	ComputedKcal float64 `json:"computed_kcal"`
+	// Deviation 相对标示能量的偏差比例
+	Deviation  float64 `json:"deviation"`
+	Consistent bool    `json:"consistent"`
+}
+
+// NutritionLabelResult 换算后的营养成分表；NRVPercent 与标签同一基准，标签未标示的按 NRV 计算。
+type NutritionLabelResult struct {
+	ProductName string              `json:"product_name,omitempty"`
+	Basis       string              `json:"basis"`
+	Nutrition   *model.Nutrition    `json:"nutrition"`
+	NRVPercent  map[string]float64  `json:"nrv_percent"`
+	Check       NutritionLabelCheck `json:"check"`
+	Warnings    []string            `json:"warnings,omitempty"`
+}
+
+// ParseNutritionLabel 把逐行读数换算为 model.Nutrition：能量 kJ→kcal，克/毫克统一，
+// 按 basis 写入每 100g 或每份，并做 4/4/9 能量一致性校验。
+func ParseNutritionLabel(label *AINutritionLabel) (*NutritionLabelResult, error) {
+	if label == nil {
+		return nil, errors.New("empty nutrition label")
+	}
+	result := &NutritionLabelResult{
+		ProductName: strings.TrimSpace(label.ProductName),
+		Basis:       strings.TrimSpace(label.Basis),
+		NRVPercent:  map[string]float64{},
+	}
+	var facts model.NutritionFacts
+	energyFromKcal := false
+	seen := map[string]bool{}
+	for _, item := range label.Items {
+		key := labelNutrientKey(item.Name)
+		if key == "" {
+			continue
+		}
+		value := float64(item.Value)
+		unit := strings.ToLower(strings.TrimSpace(item.Unit))
+		declaredNRV := float64(item.NRVPercent)
+		if key == "energy" {
+			kcal, isKcal := labelEnergyKcal(value, unit, declaredNRV)
+			// 同时标示 kJ 与 kcal 时以 kcal 行为准
+			if seen[key] && (energyFromKcal || !isKcal) {
+				continue
+			}
+			facts.EnergyKcal = kcal
+			energyFromKcal = isKcal
+			value = kcal
+		} else {
+			if seen[key] {
+				continue
+			}
+			if key == "sodium" {
+				value = labelMilligrams(value, unit)
+			} else {
+				value = labelGrams(value, unit)
+			}
+			setLabelNutrient(&facts, key, value)
+		}
+		seen[key] = true
+		if declaredNRV > 0 {
+			result.NRVPercent[key] = declaredNRV
+		} else if ref := nutrientReferenceValues[key]; ref > 0 {
+			result.NRVPercent[key] = math.Round(value / ref * 100)
+		}
+	}
+	if !seen["energy"] {
+		return nil, errors.New("nutrition label has no energy row")
+	}
+	facts = facts.Scale(1)
+
+	servingSize := float64(label.ServingSize)
+	if result.Basis == "100ml" || strings.Contains(strings.ToLower(label.ServingUnit), "ml") || strings.Contains(label.ServingUnit, "毫升") {
+		result.Warnings = append(result.Warnings, "液体按 1ml≈1g 换算")
+	}
+	nutrition := &model.Nutrition{
+		Confidence: model.DefaultNutritionConfidence(model.NutritionSourceLabel),
+		Source:     model.NutritionSourceLabel,
+	}
+	if servingSize > 0 {
+		nutrition.ServingSizeG = servingSize
+	}
+	if result.Basis == "serving" {
+		nutrition.PerServing = &facts
+	} else {
+		nutrition.Per100g = &facts
+	}
+	result.Nutrition = nutrition.Complete()
+
+	result.Check = checkLabelEnergy(facts)
+	if !result.Check.Consistent {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"标示能量 %.0f kcal 与按蛋白质/脂肪/碳水计算的 %.0f kcal 相差较大，请核对识别结果",
+			result.Check.DeclaredKcal, result.Check.ComputedKcal,
+		))
+	}
+	return result, nil
+}
+
+// checkLabelEnergy 4/4/9 校验；含糖醇、酒精或膳食纤维较多的食品可能略有偏差。
+func checkLabelEnergy(facts model.NutritionFacts) NutritionLabelCheck {
+	computed := 4*facts.ProteinG + 4*facts.CarbsG + 9*facts.FatG
+	diff := math.Abs(facts.EnergyKcal - computed)
+	check := NutritionLabelCheck{
+		DeclaredKcal: facts.EnergyKcal,
+		ComputedKcal: math.Round(computed*10) / 10,
+	}
+	if facts.EnergyKcal > 0 {
+		check.Deviation = math.Round(diff/facts.EnergyKcal*1000) / 1000
+	}
+	check.Consistent = diff <= labelEnergySlackKcal || check.Deviation <= labelEnergyTolerance
+	return check
+}
+
+// labelNutrientKey 表格行名到营养素，反式/不饱和脂肪、糖醇等不记录。
+func labelNutrientKey(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case strings.Contains(name, "能量"), strings.Contains(name, "热量"), strings.Contains(name, "energy"):
+		return "energy"
+	case strings.Contains(name, "反式"), strings.Contains(name, "trans"),
+		strings.Contains(name, "不饱和"), strings.Contains(name, "unsaturated"),
+		strings.Contains(name, "糖醇"), strings.Contains(name, "polyol"):
+		return ""
+	case strings.Contains(name, "饱和"), strings.Contains(name, "saturated"):
+		return "saturated_fat"
+	case strings.Contains(name, "脂肪"), strings.Contains(name, "fat"):
+		return "fat"
+	case strings.Contains(name, "蛋白"), strings.Contains(name, "protein"):
+		return "protein"
+	case strings.Contains(name, "碳水"), strings.Contains(name, "carbohydrate"):
+		return "carbs"
+	case strings.Contains(name, "纤维"), strings.Contains(name, "fiber"), strings.Contains(name, "fibre"):
+		return "fiber"
+	case strings.Contains(name, "添加糖"), strings.Contains(name, "added sugar"):
+		return "added_sugar"
+	case strings.Contains(name, "糖"), strings.Contains(name, "sugar"):
+		return "sugar"
+	case strings.Contains(name, "钠"), strings.Contains(name, "sodium"):
+		return "sodium"
+	}
+	return ""
+}
+
+// labelEnergyKcal 返回 kcal 与原单位是否为 kcal。国标要求标示 kJ，单位缺失时参考 NRV% 判断。
+func labelEnergyKcal(value float64, unit string, declaredNRV float64) (float64, bool) {
+	switch {
+	case strings.Contains(unit, "kcal"), strings.Contains(unit, "千卡"), strings.Contains(unit, "大卡"), unit == "cal":
+		return value, true
+	case strings.Contains(unit, "kj"), strings.Contains(unit, "千焦"):
+		return value / kJPerKcal, false
+	}
+	if declaredNRV > 0 {
+		asKJ := math.Abs(value/8400*100 - declaredNRV)
+		asKcal := math.Abs(value*kJPerKcal/8400*100 - declaredNRV)
+		if asKcal < asKJ {
+			return value, true
+		}
+	}
+	return value / kJPerKcal, false
+}
+
+func labelGrams(value float64, unit string) float64 {
+	switch {
+	case strings.Contains(unit, "mg"), strings.Contains(unit, "毫克"):
+		return value / 1000
+	case strings.Contains(unit, "μg"), strings.Contains(unit, "ug"), strings.Contains(unit, "微克"):
+		return value / 1000000
+	}
+	return value
+}
+
+// labelMilligrams 钠默认以 mg 标示。
+func labelMilligrams(value float64, unit string) float64 {
+	switch {
+	case strings.Contains(unit, "mg"), strings.Contains(unit, "毫克"), unit == "":
+		return value
+	case strings.Contains(unit, "μg"), strings.Contains(unit, "ug"), strings.Contains(unit, "微克"):
+		return value / 1000
+	case strings.Contains(unit, "g"), strings.Contains(unit, "克"):
+		return value * 1000
+	}
+	return value
+}
+
+func setLabelNutrient(facts *model.NutritionFacts, key string, value float64) {
+	switch key {
+	case "protein":
+		facts.ProteinG = value
+	case "fat":
+		facts.FatG = value
+	case "saturated_fat":
+		facts.SaturatedFatG = value
+	case "carbs":
+		facts.CarbsG = value
+	case "sugar":
+		facts.SugarG = value
+	case "added_sugar":
+		facts.AddedSugarG = value
+	case "fiber":
+		facts.FiberG = value
+	case "sodium":
+		facts.SodiumMg = value
+	}
+}
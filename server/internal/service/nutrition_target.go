@@ -0,0 +1,499 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// 目标计算参数。
+const (
+	kcalPerKgBodyWeight      = 7700.0 // 每千克体重变化对应的热量
+	trainingSessionKcalPerKg = 4.0    // 单次训练在日常活动之外的额外消耗
+	maxDeficitShare          = 0.25   // 每日缺口占 TDEE 的上限
+	maxDeficitKcal           = 1000.0
+	maxSurplusShare          = 0.15 // 每日盈余占 TDEE 的上限
+	maxSurplusKcal           = 500.0
+	minCaloriesMale          = 1500.0
+	minCaloriesFemale        = 1200.0
+	minCaloriesUnknown       = 1350.0
+	minCarbsG                = 100.0
+	minFatPerKg              = 0.6
+	defaultTargetAge         = 30
+	highBMIReference         = 27.0 // BMI 过高且体脂未知时，蛋白质按该 BMI 对应体重计算
+)
+
+// 目标类型，与设置中 goal_type 的中文写法一致。
+const (
+	GoalFatLoss    = "减脂"
+	GoalWeightLoss = "减重"
+	GoalMuscleGain = "增肌"
+	GoalWeightGain = "增重"
+	GoalMaintain   = "维持"
+)
+
+var goalAliases = map[string]string{
+	"fat_loss": GoalFatLoss, "cut": GoalFatLoss,
+	"weight_loss": GoalWeightLoss, "lose": GoalWeightLoss, "loss": GoalWeightLoss,
+	"muscle_gain": GoalMuscleGain, "bulk": GoalMuscleGain,
+	"weight_gain": GoalWeightGain, "gain": GoalWeightGain,
+	"maintain": GoalMaintain, "maintenance": GoalMaintain,
+}
+
+// goalSettings 各目标的热量调整比例（无体重计划时使用）、蛋白质系数（g/kg）与休息日/训练日脂肪供能比。
+type goalSettings struct {
+	adjust        float64
+	proteinPerKg  float64
+	restFatShare  float64
+	trainFatShare float64
+}
+
+var goalTable = map[string]goalSettings{
+	GoalFatLoss:    {adjust: -0.20, proteinPerKg: 2.0, restFatShare: 0.30, trainFatShare: 0.25},
+	GoalWeightLoss: {adjust: -0.20, proteinPerKg: 1.6, restFatShare: 0.30, trainFatShare: 0.25},
+	GoalMuscleGain: {adjust: 0.10, proteinPerKg: 1.8, restFatShare: 0.25, trainFatShare: 0.20},
+	GoalWeightGain: {adjust: 0.15, proteinPerKg: 1.6, restFatShare: 0.30, trainFatShare: 0.25},
+	GoalMaintain:   {adjust: 0, proteinPerKg: 1.6, restFatShare: 0.30, trainFatShare: 0.25},
+}
+
+// activityFactors 日常活动系数，不含训练；训练消耗按每周训练天数另计。
+var activityFactors = map[string]float64{
+	"久坐": 1.2, "sedentary": 1.2,
+	"轻活动": 1.3, "light": 1.3,
+	"中等活动": 1.4, "moderate": 1.4,
+	"高强度活动": 1.5, "active": 1.5, "very_active": 1.6,
+}
+
+const defaultActivityLevel = "轻活动"
+
+// TargetProfileError 计算目标缺少必需的身体数据。
+type TargetProfileError struct {
+	Missing []string
+}
+
+func (e *TargetProfileError) Error() string {
+	return "incomplete body profile: " + strings.Join(e.Missing, ", ")
+}
+
+// TargetProfile 计算使用的身体数据与目标，字段已规范化。
+type TargetProfile struct {
+	Gender              string  `json:"gender"`
+	Age                 int     `json:"age"`
+	HeightCm            float64 `json:"height_cm"`
+	WeightKg            float64 `json:"weight_kg"`
+	BodyFatPct          float64 `json:"body_fat_pct,omitempty"`
+	ActivityLevel       string  `json:"activity_level"`
+	Goal                string  `json:"goal"`
+	WeightPlanMode      string  `json:"weight_plan_mode,omitempty"`
+	WeightPlanKg        float64 `json:"weight_plan_kg,omitempty"`
+	WeightPlanDays      int     `json:"weight_plan_days,omitempty"`
+	TrainingDaysPerWeek int     `json:"training_days_per_week"`
+}
+
+// MacroTargets 一天的热量与三大营养素目标（g）。
+type MacroTargets struct {
+	Calories int `json:"calories"`
+	Protein  int `json:"protein"`
+	Carbs    int `json:"carbs"`
+	Fat      int `json:"fat"`
+}
+
+// TargetCheck 设置中已有目标的检查结果。
+type TargetCheck struct {
+	CalorieTarget int          `json:"calorie_target"`
+	MacroTargets  MacroTargets `json:"macro_targets"`
+	Reasonable    bool         `json:"reasonable"`
+	Issues        []string     `json:"issues"`
+}
+
+// NutritionTargets 推荐目标及计算过程。CalorieTarget/Average 为按训练天数加权的每日平均，
+// SuggestedSettings 可直接合并进 user_settings。
+type NutritionTargets struct {
+	Profile           TargetProfile          `json:"profile"`
+	BMR               int                    `json:"bmr"`
+	BMRFormula        string                 `json:"bmr_formula"`
+	ActivityFactor    float64                `json:"activity_factor"`
+	TrainingKcal      int                    `json:"training_kcal"`
+	TDEE              int                    `json:"tdee"`
+	GoalAdjustment    int                    `json:"goal_adjustment"`
+	CalorieFloor      int                    `json:"calorie_floor"`
+	CalorieTarget     int                    `json:"calorie_target"`
+	TrainingDay       MacroTargets           `json:"training_day"`
+	RestDay           MacroTargets           `json:"rest_day"`
+	Average           MacroTargets           `json:"average"`
+	DayType           string                 `json:"day_type"`
+	Today             MacroTargets           `json:"today"`
+	SuggestedSettings map[string]interface{} `json:"suggested_settings"`
+	Explanation       []string               `json:"explanation"`
+	Warnings          []string               `json:"warnings"`
+	Current           *TargetCheck           `json:"current,omitempty"`
+}
+
+// NutritionTargetService 根据用户设置计算推荐的热量与宏量营养素目标。
+type NutritionTargetService struct {
+	settings *SettingsService
+}
+
+func NewNutritionTargetService(settings *SettingsService) *NutritionTargetService {
+	return &NutritionTargetService{settings: settings}
+}
+
+// Recommend 已保存的设置叠加 overrides（引导页尚未保存的字段）后计算，date 决定当天按训练日还是休息日。
+func (s *NutritionTargetService) Recommend(userID int64, overrides map[string]interface{}, date time.Time) (*NutritionTargets, error) {
+	settings, err := s.settings.Values(userID)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range overrides {
+		settings[key] = value
+	}
+	return ComputeNutritionTargets(settings, date)
+}
+
+// TargetProfileFromSettings 读取并规范化身体数据，超出合理范围的值视为未填写。
+func TargetProfileFromSettings(settings map[string]interface{}) TargetProfile {
+	profile := TargetProfile{
+		Gender:              normalizeGender(stringifyValue(settings["gender"])),
+		Age:                 readIntValue(settings["age"]),
+		HeightCm:            settingFloat(settings, 120, 230, "height"),
+		WeightKg:            settingFloat(settings, 30, 250, "weight"),
+		BodyFatPct:          settingFloat(settings, 3, 60, "body_fat_pct", "body_fat"),
+		ActivityLevel:       strings.TrimSpace(stringifyValue(settings["activity_level"])),
+		Goal:                normalizeGoal(stringifyValue(settings["goal_type"])),
+		WeightPlanMode:      normalizeGoal(stringifyValue(settings["weight_plan_mode"])),
+		WeightPlanKg:        settingFloat(settings, 0, 50, "weight_plan_kg"),
+		WeightPlanDays:      readIntValue(settings["weight_plan_days"]),
+		TrainingDaysPerWeek: trainingDaysPerWeek(settings),
+	}
+	if profile.Age < 14 || profile.Age > 90 {
+		profile.Age = 0
+	}
+	if _, ok := activityFactors[strings.ToLower(profile.ActivityLevel)]; !ok {
+		profile.ActivityLevel = ""
+	}
+	if profile.WeightPlanKg <= 0 || profile.WeightPlanDays <= 0 {
+		profile.WeightPlanMode, profile.WeightPlanKg, profile.WeightPlanDays = "", 0, 0
+	}
+	return profile
+}
+
+// ComputeNutritionTargets BMR（有体脂率用 Katch-McArdle，否则 Mifflin-St Jeor）× 日常活动系数 + 训练消耗得到 TDEE，
+// 按体重计划（无计划时按目标比例）调整并设安全下限，再按训练日/休息日拆分宏量营养素。
+func ComputeNutritionTargets(settings map[string]interface{}, date time.Time) (*NutritionTargets, error) {
+	profile := TargetProfileFromSettings(settings)
+	var missing []string
+	if profile.WeightKg == 0 {
+		missing = append(missing, "weight")
+	}
+	if profile.HeightCm == 0 && profile.BodyFatPct == 0 {
+		missing = append(missing, "height")
+	}
+	if len(missing) > 0 {
+		return nil, &TargetProfileError{Missing: missing}
+	}
+	targets := &NutritionTargets{Explanation: []string{}, Warnings: []string{}}
+	// 年龄与性别只用于 Mifflin-St Jeor，已知体脂率时不提示
+	if profile.Age == 0 {
+		profile.Age = defaultTargetAge
+		if profile.BodyFatPct == 0 {
+			targets.Warnings = append(targets.Warnings, fmt.Sprintf("未填写年龄，按 %d 岁计算", defaultTargetAge))
+		}
+	}
+	if profile.Gender == "" && profile.BodyFatPct == 0 {
+		targets.Warnings = append(targets.Warnings, "未填写性别，基础代谢取男女平均值")
+	}
+	if profile.ActivityLevel == "" {
+		profile.ActivityLevel = defaultActivityLevel
+		targets.Warnings = append(targets.Warnings, "未填写日常活动水平，按“轻活动”计算")
+	}
+	if profile.Goal == "" {
+		profile.Goal = GoalMaintain
+	}
+	targets.Profile = profile
+
+	bmr := computeBMR(targets, profile)
+	targets.ActivityFactor = activityFactors[strings.ToLower(profile.ActivityLevel)]
+	sessionKcal := trainingSessionKcalPerKg * profile.WeightKg
+	trainingKcal := sessionKcal * float64(profile.TrainingDaysPerWeek) / 7
+	tdee := bmr*targets.ActivityFactor + trainingKcal
+	targets.TrainingKcal = int(math.Round(trainingKcal))
+	targets.TDEE = roundKcal(tdee)
+	if profile.TrainingDaysPerWeek > 0 {
+		targets.Explanation = append(targets.Explanation, fmt.Sprintf(
+			"TDEE ≈ %d kcal：BMR × 日常活动系数 %.2f（%s），加每周 %d 次训练、每次约 %d kcal 的平均消耗",
+			targets.TDEE, targets.ActivityFactor, profile.ActivityLevel, profile.TrainingDaysPerWeek, int(math.Round(sessionKcal))))
+	} else {
+		targets.Explanation = append(targets.Explanation, fmt.Sprintf(
+			"TDEE ≈ %d kcal：BMR × 日常活动系数 %.2f（%s），未设置训练日", targets.TDEE, targets.ActivityFactor, profile.ActivityLevel))
+	}
+
+	adjustment := goalAdjustment(targets, profile, tdee)
+	targets.GoalAdjustment = int(math.Round(adjustment))
+
+	floor := math.Max(bmr, calorieFloor(profile.Gender))
+	targets.CalorieFloor = roundKcal(floor)
+	average := tdee + adjustment
+	if average < floor {
+		average = floor
+		targets.Warnings = append(targets.Warnings, fmt.Sprintf("目标热量低于安全下限，已提高到 %d kcal（不低于基础代谢与 %d kcal）", roundKcal(floor), int(calorieFloor(profile.Gender))))
+	}
+
+	// 训练日多吃一次训练的消耗，休息日相应减少，使一周平均等于目标
+	days := float64(profile.TrainingDaysPerWeek)
+	trainingCalories := average + sessionKcal*(7-days)/7
+	restCalories := average - sessionKcal*days/7
+	if days == 0 {
+		trainingCalories = average
+	}
+	if restCalories < floor {
+		restCalories = floor
+	}
+	goal := goalTable[profile.Goal]
+	proteinWeight := proteinReferenceWeight(targets, profile)
+	targets.TrainingDay = splitMacros(targets, trainingCalories, goal.proteinPerKg*proteinWeight, goal.trainFatShare, profile.WeightKg)
+	targets.RestDay = splitMacros(targets, restCalories, goal.proteinPerKg*proteinWeight, goal.restFatShare, profile.WeightKg)
+	targets.Average = averageMacros(targets.TrainingDay, targets.RestDay, profile.TrainingDaysPerWeek)
+	targets.CalorieTarget = targets.Average.Calories
+	targets.Explanation = append(targets.Explanation, fmt.Sprintf(
+		"蛋白质按 %.1fg/kg × %.1fkg，训练日脂肪约 %d%%、休息日约 %d%% 供能，其余热量给碳水（不少于 %dg）；训练日 %d kcal，休息日 %d kcal",
+		goal.proteinPerKg, proteinWeight, int(goal.trainFatShare*100), int(goal.restFatShare*100), int(minCarbsG),
+		targets.TrainingDay.Calories, targets.RestDay.Calories))
+
+	isTraining, isCheat := ComputeDayFlags(settings, date)
+	targets.DayType = DayTypeLabel(isTraining, isCheat)
+	targets.Today = targets.RestDay
+	if isTraining {
+		targets.Today = targets.TrainingDay
+	}
+	targets.SuggestedSettings = map[string]interface{}{
+		"calorie_target": targets.CalorieTarget,
+		"macro_targets": map[string]interface{}{
+			"protein": targets.Average.Protein,
+			"carbs":   targets.Average.Carbs,
+			"fat":     targets.Average.Fat,
+		},
+	}
+	targets.Current = checkCurrentTargets(settings, targets)
+	return targets, nil
+}
+
+func computeBMR(targets *NutritionTargets, profile TargetProfile) float64 {
+	if profile.BodyFatPct > 0 {
+		leanMass := profile.WeightKg * (1 - profile.BodyFatPct/100)
+		bmr := 370 + 21.6*leanMass
+		targets.BMR = roundKcal(bmr)
+		targets.BMRFormula = "katch_mcardle"
+		targets.Explanation = append(targets.Explanation, fmt.Sprintf(
+			"BMR ≈ %d kcal（Katch-McArdle：370 + 21.6 × 去脂体重 %.1fkg，体脂率 %.0f%%）", targets.BMR, leanMass, profile.BodyFatPct))
+		return bmr
+	}
+	offset := -78.0
+	switch profile.Gender {
+	case "male":
+		offset = 5
+	case "female":
+		offset = -161
+	}
+	bmr := 10*profile.WeightKg + 6.25*profile.HeightCm - 5*float64(profile.Age) + offset
+	targets.BMR = roundKcal(bmr)
+	targets.BMRFormula = "mifflin_st_jeor"
+	targets.Explanation = append(targets.Explanation, fmt.Sprintf(
+		"BMR ≈ %d kcal（Mifflin-St Jeor：10 × %.1fkg + 6.25 × %.0fcm − 5 × %d 岁 %+.0f）",
+		targets.BMR, profile.WeightKg, profile.HeightCm, profile.Age, offset))
+	return bmr
+}
+
+// goalAdjustment 有体重计划时按计划速度换算每日缺口/盈余，否则按目标比例；超过上限时截断并提示实际所需天数。
+func goalAdjustment(targets *NutritionTargets, profile TargetProfile, tdee float64) float64 {
+	goal := goalTable[profile.Goal]
+	if profile.WeightPlanKg == 0 {
+		adjustment := tdee * goal.adjust
+		if adjustment != 0 {
+			targets.Explanation = append(targets.Explanation, fmt.Sprintf("目标“%s”，每日热量调整 %+.0f%%（%+d kcal）", profile.Goal, goal.adjust*100, int(math.Round(adjustment))))
+		} else {
+			targets.Explanation = append(targets.Explanation, fmt.Sprintf("目标“%s”，热量与 TDEE 持平", profile.Goal))
+		}
+		return adjustment
+	}
+
+	sign := -1.0
+	if profile.WeightPlanMode == GoalWeightGain || profile.WeightPlanMode == GoalMuscleGain {
+		sign = 1
+	}
+	if (sign < 0 && goal.adjust > 0) || (sign > 0 && goal.adjust < 0) {
+		targets.Warnings = append(targets.Warnings, fmt.Sprintf("体重计划与目标“%s”方向不一致，热量按体重计划计算", profile.Goal))
+	}
+	planned := profile.WeightPlanKg * kcalPerKgBodyWeight / float64(profile.WeightPlanDays)
+	limit := math.Min(tdee*maxDeficitShare, maxDeficitKcal)
+	if sign > 0 {
+		limit = math.Min(tdee*maxSurplusShare, maxSurplusKcal)
+	}
+	direction, kind := "减", "缺口"
+	if sign > 0 {
+		direction, kind = "增", "盈余"
+	}
+	targets.Explanation = append(targets.Explanation, fmt.Sprintf(
+		"体重计划 %d 天%s %.1fkg，按每千克 %.0f kcal 折算每日%s约 %d kcal",
+		profile.WeightPlanDays, direction, profile.WeightPlanKg, kcalPerKgBodyWeight, kind, int(math.Round(planned))))
+	if planned > limit {
+		needed := int(math.Ceil(profile.WeightPlanKg * kcalPerKgBodyWeight / limit))
+		targets.Warnings = append(targets.Warnings, fmt.Sprintf("计划速度过快，每日%s已限制为 %d kcal，按此速度约需 %d 天", kind, int(math.Round(limit)), needed))
+		planned = limit
+	}
+	return sign * planned
+}
+
+// proteinReferenceWeight 蛋白质计算用体重：已知体脂率时不超过去脂体重的 1.25 倍，BMI 过高时按参考 BMI 对应体重。
+func proteinReferenceWeight(targets *NutritionTargets, profile TargetProfile) float64 {
+	weight := profile.WeightKg
+	if profile.BodyFatPct > 0 {
+		return math.Min(weight, profile.WeightKg*(1-profile.BodyFatPct/100)*1.25)
+	}
+	if profile.HeightCm > 0 {
+		heightM := profile.HeightCm / 100
+		if reference := highBMIReference * heightM * heightM; weight > reference && weight/(heightM*heightM) >= 30 {
+			targets.Explanation = append(targets.Explanation, fmt.Sprintf("BMI 较高，蛋白质按 BMI %.0f 对应体重 %.1fkg 计算", highBMIReference, reference))
+			return reference
+		}
+	}
+	return weight
+}
+
+// splitMacros 蛋白质固定，脂肪按供能比且不低于下限，其余给碳水；碳水低于下限时补足并提示热量随之上调。
+func splitMacros(targets *NutritionTargets, calories, protein, fatShare, weight float64) MacroTargets {
+	fat := math.Max(calories*fatShare/9, minFatPerKg*weight)
+	carbs := (calories - protein*4 - fat*9) / 4
+	if carbs < minCarbsG {
+		carbs = minCarbsG
+		warning := fmt.Sprintf("碳水按下限 %dg 计算，部分日子的热量会略高于目标", int(minCarbsG))
+		if !containsString(targets.Warnings, warning) {
+			targets.Warnings = append(targets.Warnings, warning)
+		}
+	}
+	protein, carbs, fat = math.Round(protein), math.Round(carbs), math.Round(fat)
+	return MacroTargets{
+		Calories: roundKcal(protein*4 + carbs*4 + fat*9),
+		Protein:  int(protein),
+		Carbs:    int(carbs),
+		Fat:      int(fat),
+	}
+}
+
+func averageMacros(training, rest MacroTargets, trainingDays int) MacroTargets {
+	weight := float64(trainingDays) / 7
+	mix := func(a, b int) int {
+		return int(math.Round(float64(a)*weight + float64(b)*(1-weight)))
+	}
+	return MacroTargets{
+		Calories: roundKcal(float64(training.Calories)*weight + float64(rest.Calories)*(1-weight)),
+		Protein:  mix(training.Protein, rest.Protein),
+		Carbs:    mix(training.Carbs, rest.Carbs),
+		Fat:      mix(training.Fat, rest.Fat),
+	}
+}
+
+// checkCurrentTargets 检查设置中的 calorie_target/macro_targets：低于安全下限、偏离推荐过多、宏量与热量不符、蛋白质过低或过高。
+func checkCurrentTargets(settings map[string]interface{}, targets *NutritionTargets) *TargetCheck {
+	calories := readIntValue(settings["calorie_target"])
+	macros, _ := settings["macro_targets"].(map[string]interface{})
+	if calories <= 0 && len(macros) == 0 {
+		return nil
+	}
+	check := &TargetCheck{
+		CalorieTarget: calories,
+		MacroTargets: MacroTargets{
+			Calories: calories,
+			Protein:  readIntValue(macros["protein"]),
+			Carbs:    readIntValue(macros["carbs"]),
+			Fat:      readIntValue(macros["fat"]),
+		},
+		Issues: []string{},
+	}
+	if calories > 0 {
+		if calories < targets.CalorieFloor {
+			check.Issues = append(check.Issues, fmt.Sprintf("热量目标 %d kcal 低于安全下限 %d kcal", calories, targets.CalorieFloor))
+		}
+		if deviation := float64(calories-targets.CalorieTarget) / float64(targets.CalorieTarget); math.Abs(deviation) > 0.25 {
+			check.Issues = append(check.Issues, fmt.Sprintf("热量目标与推荐的 %d kcal 相差 %.0f%%", targets.CalorieTarget, deviation*100))
+		}
+	}
+	current := check.MacroTargets
+	if current.Protein > 0 || current.Carbs > 0 || current.Fat > 0 {
+		macroKcal := current.Protein*4 + current.Carbs*4 + current.Fat*9
+		if calories > 0 && math.Abs(float64(macroKcal-calories))/float64(calories) > 0.15 {
+			check.Issues = append(check.Issues, fmt.Sprintf("三大营养素合计 %d kcal，与热量目标 %d kcal 不符", macroKcal, calories))
+		}
+		perKg := float64(current.Protein) / targets.Profile.WeightKg
+		switch {
+		case perKg < 0.8:
+			check.Issues = append(check.Issues, fmt.Sprintf("蛋白质 %dg（%.1fg/kg）偏低，建议不少于 0.8g/kg", current.Protein, perKg))
+		case perKg > 3.5:
+			check.Issues = append(check.Issues, fmt.Sprintf("蛋白质 %dg（%.1fg/kg）过高，建议不超过 3.5g/kg", current.Protein, perKg))
+		}
+	}
+	check.Reasonable = len(check.Issues) == 0
+	return check
+}
+
+func calorieFloor(gender string) float64 {
+	switch gender {
+	case "male":
+		return minCaloriesMale
+	case "female":
+		return minCaloriesFemale
+	}
+	return minCaloriesUnknown
+}
+
+// trainingDaysPerWeek 优先使用每周训练日列表，其次每月训练日（折算到每周），最后是每周训练天数。
+func trainingDaysPerWeek(settings map[string]interface{}) int {
+	if days := readIntSlice(settings["weekly_training_days_list"]); len(days) > 0 {
+		return clampInt(len(days), 0, 7)
+	}
+	if days := readIntSlice(settings["monthly_training_days"]); len(days) > 0 {
+		return clampInt(int(math.Round(float64(len(days))*7/30)), 0, 7)
+	}
+	return clampInt(readIntValue(settings["weekly_training_days"]), 0, 7)
+}
+
+func normalizeGender(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "男", "male", "m":
+		return "male"
+	case "女", "female", "f":
+		return "female"
+	}
+	return ""
+}
+
+func normalizeGoal(value string) string {
+	value = strings.TrimSpace(value)
+	if _, ok := goalTable[value]; ok {
+		return value
+	}
+	return goalAliases[strings.ToLower(value)]
+}
+
+// settingFloat 依次读取 keys 中第一个有效值，超出 [min, max] 视为未填写。
+func settingFloat(settings map[string]interface{}, min, max float64, keys ...string) float64 {
+	for _, key := range keys {
+		if value, ok := toFloat(settings[key]); ok && value > min && value <= max {
+			return value
+		}
+	}
+	return 0
+}
+
+func roundKcal(value float64) int {
+	return int(math.Round(value/10) * 10)
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
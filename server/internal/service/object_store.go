@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"eatclean/internal/config"
+)
+
+// 存储后端名称，对应 config.yaml storage.backend。
+const (
+	StorageBackendOSS   = "oss"
+	StorageBackendS3    = "s3"
+	StorageBackendLocal = "local"
+)
+
+var (
+	ErrObjectNotFound = errors.New("object not found")
+	// ErrStorageNotConfigured 所选后端缺少必要配置。
+	ErrStorageNotConfigured = errors.New("object storage not configured")
+)
+
+// ObjectInfo 对象元数据。
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// PresignedUpload 客户端直传所需的请求信息，Headers 需原样带上。
+type PresignedUpload struct {
+	Key       string            `json:"key"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// ObjectStore 图片存储后端。对象名统一使用不带前导斜杠的 key。
+type ObjectStore interface {
+	Backend() string
+	PresignUpload(key string, contentType string, ttl time.Duration) (*PresignedUpload, error)
+	PresignDownload(key string, ttl time.Duration) (string, error)
+	// Put 服务端直接写入对象（图片处理后的各尺寸版本）
+	Put(ctx context.Context, key string, contentType string, data []byte) error
+	Delete(ctx context.Context, key string) error
+	// Head 对象不存在时返回 ErrObjectNotFound
+	Head(ctx context.Context, key string) (*ObjectInfo, error)
+	// List 按前缀列出对象，limit<=0 时不限制数量
+	List(ctx context.Context, prefix string, limit int) ([]ObjectInfo, error)
+	// Open 读取对象内容，用于内联传给模型
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// KeyFromURL 从客户端传来的 URL 或 key 中解析对象名
+	KeyFromURL(rawURL string) (string, error)
+	// ModelReachable 模型服务能否直接访问签名 URL
+	ModelReachable() bool
+}
+
+// NewObjectStore 按配置创建存储后端。
+func NewObjectStore(cfg *config.Config) (ObjectStore, error) {
+	if cfg == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.Storage.Backend)) {
+	case "", StorageBackendOSS:
+		return newOSSObjectStore(&cfg.OSS), nil
+	case StorageBackendS3:
+		return newS3ObjectStore(&cfg.Storage.S3)
+	case StorageBackendLocal:
+		return newLocalObjectStore(&cfg.Storage.Local)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Storage.Backend)
+	}
+}
+
+// shouldInlineImages 按 storage.inline_images 与后端可达性决定是否内联图片。
+func shouldInlineImages(mode string, store ObjectStore) bool {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return store != nil && !store.ModelReachable()
+	}
+}
+
+// keyFromURL 通用的对象名解析：不含 scheme 的视为 key，否则取路径并去掉 bucket 段。
+func keyFromURL(rawURL string, bucket string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", errors.New("empty url")
+	}
+	if !strings.Contains(rawURL, "://") {
+		return strings.TrimPrefix(rawURL, "/"), nil
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimPrefix(parsed.Path, "/")
+	if bucket != "" {
+		path = strings.TrimPrefix(path, bucket+"/")
+	}
+	if path == "" {
+		return "", errors.New("empty object key")
+	}
+	return path, nil
+}
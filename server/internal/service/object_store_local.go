@@ -0,0 +1,268 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"eatclean/internal/config"
+)
+
+// LocalFilesPath 本地存储签名 URL 的路由前缀，由 StorageHandler 提供上传与下载。
+const LocalFilesPath = "/files/"
+
+var (
+	ErrLocalSignatureInvalid = errors.New("invalid or expired signature")
+	ErrLocalUploadTooLarge   = errors.New("upload too large")
+)
+
+// LocalObjectStore 本地磁盘后端，签名 URL 指向本服务 /files/{key}。
+type LocalObjectStore struct {
+	root      string
+	baseURL   string
+	secret    []byte
+	maxUpload int64
+}
+
+func newLocalObjectStore(cfg *config.LocalStorageConfig) (*LocalObjectStore, error) {
+	if cfg == nil || strings.TrimSpace(cfg.Root) == "" || cfg.Secret == "" {
+		return nil, fmt.Errorf("%w: local root and secret are required", ErrStorageNotConfigured)
+	}
+	root, err := filepath.Abs(cfg.Root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalObjectStore{
+		root:      root,
+		baseURL:   strings.TrimRight(cfg.BaseURL, "/"),
+		secret:    []byte(cfg.Secret),
+		maxUpload: int64(cfg.MaxUploadMB) << 20,
+	}, nil
+}
+
+func (s *LocalObjectStore) Backend() string {
+	return StorageBackendLocal
+}
+
+// ModelReachable 本地地址通常无法被模型服务访问，默认内联 base64。
+func (s *LocalObjectStore) ModelReachable() bool {
+	return false
+}
+
+func (s *LocalObjectStore) PresignUpload(key string, contentType string, ttl time.Duration) (*PresignedUpload, error) {
+	if _, err := s.path(key); err != nil {
+		return nil, err
+	}
+	signed, expiresAt := s.sign(http.MethodPut, key, ttl)
+	headers := map[string]string{}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	return &PresignedUpload{Key: key, URL: signed, Method: http.MethodPut, Headers: headers, ExpiresAt: expiresAt}, nil
+}
+
+func (s *LocalObjectStore) PresignDownload(key string, ttl time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	signed, _ := s.sign(http.MethodGet, key, ttl)
+	return signed, nil
+}
+
+func (s *LocalObjectStore) Put(ctx context.Context, key string, contentType string, data []byte) error {
+	return s.Write(key, bytes.NewReader(data))
+}
+
+func (s *LocalObjectStore) Delete(ctx context.Context, key string) error {
+	full, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrObjectNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *LocalObjectStore) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	full, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(full)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, ErrObjectNotFound
+	}
+	return s.info(key, stat), nil
+}
+
+func (s *LocalObjectStore) List(ctx context.Context, prefix string, limit int) ([]ObjectInfo, error) {
+	var result []ObjectInfo
+	err := filepath.WalkDir(s.root, func(full string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, relErr := filepath.Rel(s.root, full)
+		if relErr != nil {
+			return relErr
+		}
+		key := filepath.ToSlash(rel)
+		if entry.IsDir() {
+			// 跳过与前缀无关的目录
+			if key != "." && !strings.HasPrefix(key+"/", prefix) && !strings.HasPrefix(prefix, key+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, prefix) || strings.HasPrefix(entry.Name(), ".upload-") {
+			return nil
+		}
+		stat, statErr := entry.Info()
+		if statErr != nil {
+			return nil
+		}
+		result = append(result, *s.info(key, stat))
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *LocalObjectStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	full, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(full)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// KeyFromURL 本地签名 URL 的路径为 /files/{key}。
+func (s *LocalObjectStore) KeyFromURL(rawURL string) (string, error) {
+	key, err := keyFromURL(rawURL, "")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(key, strings.TrimPrefix(LocalFilesPath, "/")), nil
+}
+
+// Verify 校验 /files 请求的签名与有效期。
+func (s *LocalObjectStore) Verify(method string, key string, expires string, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return ErrLocalSignatureInvalid
+	}
+	expected := s.signature(method, key, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrLocalSignatureInvalid
+	}
+	return nil
+}
+
+// Write 写入上传内容，先写临时文件再重命名，超过大小限制时丢弃。
+func (s *LocalObjectStore) Write(key string, body io.Reader) error {
+	full, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	written, err := io.Copy(tmp, io.LimitReader(body, s.maxUpload+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if written > s.maxUpload {
+		return ErrLocalUploadTooLarge
+	}
+	return os.Rename(tmp.Name(), full)
+}
+
+// FilePath 返回对象在磁盘上的路径，供下载时直接发送文件。
+func (s *LocalObjectStore) FilePath(key string) (string, error) {
+	return s.path(key)
+}
+
+func (s *LocalObjectStore) path(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") || strings.Contains(key, "\\") || path.Clean(key) != key || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalObjectStore) info(key string, stat fs.FileInfo) *ObjectInfo {
+	return &ObjectInfo{
+		Key:          key,
+		Size:         stat.Size(),
+		ContentType:  mime.TypeByExtension(path.Ext(key)),
+		LastModified: stat.ModTime(),
+	}
+}
+
+func (s *LocalObjectStore) sign(method string, key string, ttl time.Duration) (string, time.Time) {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	expiresAt := time.Now().Add(ttl)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	query := url.Values{"expires": {expires}, "sig": {s.signature(method, key, expires)}}
+	escaped := (&url.URL{Path: key}).EscapedPath()
+	return s.baseURL + LocalFilesPath + escaped + "?" + query.Encode(), expiresAt
+}
+
+func (s *LocalObjectStore) signature(method string, key string, expires string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(method + "\n" + key + "\n" + expires))
+	return hex.EncodeToString(h.Sum(nil))
+}
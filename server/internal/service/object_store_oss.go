@@ -0,0 +1,361 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"eatclean/internal/config"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+// StorageCredentials 客户端直传使用的临时凭证（仅阿里云 OSS 提供）。
+type StorageCredentials struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SecurityToken   string
+	Expiration      string
+}
+
+// ossObjectStore 阿里云 OSS 后端，另外支持按用户目录签发 STS 凭证。
+type ossObjectStore struct {
+	cfg *config.OSSConfig
+
+	mu     sync.Mutex
+	bucket *oss.Bucket
+}
+
+func newOSSObjectStore(cfg *config.OSSConfig) *ossObjectStore {
+	return &ossObjectStore{cfg: cfg}
+}
+
+func (s *ossObjectStore) Backend() string {
+	return StorageBackendOSS
+}
+
+func (s *ossObjectStore) ModelReachable() bool {
+	return true
+}
+
+func (s *ossObjectStore) PresignUpload(key string, contentType string, ttl time.Duration) (*PresignedUpload, error) {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return nil, err
+	}
+	var options []oss.Option
+	headers := map[string]string{}
+	if contentType != "" {
+		options = append(options, oss.ContentType(contentType))
+		headers["Content-Type"] = contentType
+	}
+	signed, err := bucket.SignURL(key, oss.HTTPPut, expireSeconds(ttl), options...)
+	if err != nil {
+		return nil, err
+	}
+	return &PresignedUpload{
+		Key:       key,
+		URL:       signed,
+		Method:    http.MethodPut,
+		Headers:   headers,
+		ExpiresAt: time.Now().Add(time.Duration(expireSeconds(ttl)) * time.Second),
+	}, nil
+}
+
+func (s *ossObjectStore) PresignDownload(key string, ttl time.Duration) (string, error) {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return "", err
+	}
+	return bucket.SignURL(key, oss.HTTPGet, expireSeconds(ttl))
+}
+
+func (s *ossObjectStore) Put(ctx context.Context, key string, contentType string, data []byte) error {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return err
+	}
+	var options []oss.Option
+	if contentType != "" {
+		options = append(options, oss.ContentType(contentType))
+	}
+	return bucket.PutObject(key, bytes.NewReader(data), options...)
+}
+
+func (s *ossObjectStore) Delete(ctx context.Context, key string) error {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return err
+	}
+	return bucket.DeleteObject(key)
+}
+
+func (s *ossObjectStore) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return nil, err
+	}
+	header, err := bucket.GetObjectDetailedMeta(key)
+	if err != nil {
+		return nil, ossNotFound(err)
+	}
+	info := &ObjectInfo{
+		Key:         key,
+		ContentType: header.Get("Content-Type"),
+		ETag:        strings.Trim(header.Get("ETag"), `"`),
+	}
+	info.Size, _ = strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if modified, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		info.LastModified = modified
+	}
+	return info, nil
+}
+
+func (s *ossObjectStore) List(ctx context.Context, prefix string, limit int) ([]ObjectInfo, error) {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return nil, err
+	}
+	var result []ObjectInfo
+	token := ""
+	for {
+		options := []oss.Option{oss.Prefix(prefix), oss.MaxKeys(1000)}
+		if token != "" {
+			options = append(options, oss.ContinuationToken(token))
+		}
+		page, err := bucket.ListObjectsV2(options...)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Objects {
+			result = append(result, ObjectInfo{
+				Key:          object.Key,
+				Size:         object.Size,
+				ETag:         strings.Trim(object.ETag, `"`),
+				LastModified: object.LastModified,
+			})
+			if limit > 0 && len(result) >= limit {
+				return result, nil
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return result, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *ossObjectStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	bucket, err := s.getBucket()
+	if err != nil {
+		return nil, err
+	}
+	body, err := bucket.GetObject(key)
+	if err != nil {
+		return nil, ossNotFound(err)
+	}
+	return body, nil
+}
+
+func (s *ossObjectStore) KeyFromURL(rawURL string) (string, error) {
+	if s.cfg == nil {
+		return "", ErrStorageNotConfigured
+	}
+	return keyFromURL(rawURL, s.cfg.Bucket)
+}
+
+func (s *ossObjectStore) getBucket() (*oss.Bucket, error) {
+	if s.cfg == nil {
+		return nil, errors.New("oss config is missing")
+	}
+	if s.cfg.AccessKeyID == "" || s.cfg.AccessKeySecret == "" || s.cfg.Bucket == "" || s.cfg.Endpoint == "" {
+		return nil, errors.New("oss signer not configured")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bucket != nil {
+		return s.bucket, nil
+	}
+	endpoint := strings.TrimSpace(s.cfg.Endpoint)
+	if !strings.HasPrefix(endpoint, "http") {
+		endpoint = "https://" + endpoint
+	}
+	client, err := oss.New(endpoint, s.cfg.AccessKeyID, s.cfg.AccessKeySecret)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := client.Bucket(s.cfg.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	s.bucket = bucket
+	return bucket, nil
+}
+
+func ossNotFound(err error) error {
+	var serviceErr oss.ServiceError
+	if errors.As(err, &serviceErr) && serviceErr.StatusCode == http.StatusNotFound {
+		return ErrObjectNotFound
+	}
+	return err
+}
+
+func expireSeconds(ttl time.Duration) int64 {
+	seconds := int64(ttl.Seconds())
+	if seconds <= 0 {
+		seconds = int64(time.Hour.Seconds())
+	}
+	return seconds
+}
+
+// stsEnabled 配置了 RoleArn 时才签发 STS，否则客户端使用预签名直传。
+func (s *ossObjectStore) stsEnabled() bool {
+	return s.cfg != nil && s.cfg.AccessKeyID != "" && s.cfg.AccessKeySecret != "" && s.cfg.RoleArn != ""
+}
+
+// AssumeUserRole 申请只能访问 prefix 目录的临时凭证。
+func (s *ossObjectStore) AssumeUserRole(ctx context.Context, userID int64, prefix string) (*StorageCredentials, error) {
+	if s.cfg == nil {
+		return nil, errors.New("oss config is missing")
+	}
+	if !s.stsEnabled() {
+		return nil, errors.New("oss credentials are not configured")
+	}
+	return s.assumeRoleWithHTTP(ctx, userID, s.userPolicy(prefix))
+}
+
+// userPolicy STS 内联策略，把角色权限收窄到用户目录。
+func (s *ossObjectStore) userPolicy(prefix string) string {
+	resource := fmt.Sprintf("acs:oss:*:*:%s/%s*", s.cfg.Bucket, prefix)
+	policy := map[string]interface{}{
+		"Version": "1",
+		"Statement": []map[string]interface{}{
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"oss:PutObject", "oss:GetObject", "oss:AbortMultipartUpload", "oss:ListParts"},
+				"Resource": []string{resource},
+			},
+		},
+	}
+	data, _ := json.Marshal(policy)
+	return string(data)
+}
+
+func (s *ossObjectStore) assumeRoleWithHTTP(ctx context.Context, userID int64, policy string) (*StorageCredentials, error) {
+	params := map[string]string{
+		"Format":           "JSON",
+		"Version":          "2015-04-01",
+		"AccessKeyId":      s.cfg.AccessKeyID,
+		"Action":           "AssumeRole",
+		"RoleArn":          s.cfg.RoleArn,
+		"RoleSessionName":  fmt.Sprintf("eatclean-%d-%d", userID, time.Now().Unix()),
+		"DurationSeconds":  strconv.Itoa(s.cfg.StsDuration),
+		"Policy":           policy,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureVersion": "1.0",
+		"SignatureNonce":   fmt.Sprintf("%d", time.Now().UnixNano()),
+		"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+	}
+
+	canonicalized := buildCanonicalQuery(params)
+	stringToSign := "GET&%2F&" + percentEncode(canonicalized)
+	signature := signString(stringToSign, s.cfg.AccessKeySecret+"&")
+	params["Signature"] = signature
+
+	endpoint := strings.TrimSpace(s.cfg.StsEndpoint)
+	if endpoint == "" {
+		endpoint = "https://sts.aliyuncs.com"
+	}
+	if !strings.HasPrefix(endpoint, "http") {
+		endpoint = "https://" + endpoint
+	}
+
+	query := buildCanonicalQuery(params)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/?"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var decoded struct {
+		Credentials *struct {
+			AccessKeyID     string `json:"AccessKeyId"`
+			AccessKeySecret string `json:"AccessKeySecret"`
+			SecurityToken   string `json:"SecurityToken"`
+			Expiration      string `json:"Expiration"`
+		} `json:"Credentials"`
+		Code    string `json:"Code"`
+		Message string `json:"Message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, err
+	}
+	if decoded.Credentials == nil {
+		if decoded.Message != "" {
+			return nil, errors.New(decoded.Message)
+		}
+		return nil, errors.New("empty sts response")
+	}
+	return &StorageCredentials{
+		AccessKeyID:     sanitizeSTSField(decoded.Credentials.AccessKeyID),
+		AccessKeySecret: sanitizeSTSField(decoded.Credentials.AccessKeySecret),
+		SecurityToken:   sanitizeSTSField(decoded.Credentials.SecurityToken),
+		Expiration:      sanitizeSTSField(decoded.Credentials.Expiration),
+	}, nil
+}
+
+func sanitizeSTSField(value string) string {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(value, "\uFEFF")
+	value = strings.ReplaceAll(value, "\r", "")
+	value = strings.ReplaceAll(value, "\n", "")
+	value = strings.ReplaceAll(value, "\t", "")
+	return value
+}
+
+func buildCanonicalQuery(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, percentEncode(key)+"="+percentEncode(params[key]))
+	}
+	return strings.Join(parts, "&")
+}
+
+func percentEncode(value string) string {
+	escaped := url.QueryEscape(value)
+	escaped = strings.ReplaceAll(escaped, "+", "%20")
+	escaped = strings.ReplaceAll(escaped, "*", "%2A")
+	escaped = strings.ReplaceAll(escaped, "%7E", "~")
+	return escaped
+}
+
+func signString(source, key string) string {
+	h := hmac.New(sha1.New, []byte(key))
+	h.Write([]byte(source))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
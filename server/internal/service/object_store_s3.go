@@ -0,0 +1,305 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"eatclean/internal/config"
+)
+
+// s3MaxPresign S3 预签名 URL 最长有效期为 7 天。
+const s3MaxPresign = 7 * 24 * time.Hour
+
+// s3ObjectStore S3/MinIO 兼容后端。所有请求（含 HEAD/DELETE/List）都使用 SigV4 查询串签名，不依赖 SDK。
+type s3ObjectStore struct {
+	cfg    *config.S3StorageConfig
+	base   *url.URL
+	client *http.Client
+}
+
+func newS3ObjectStore(cfg *config.S3StorageConfig) (*s3ObjectStore, error) {
+	if cfg == nil || cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("%w: s3 endpoint, bucket and keys are required", ErrStorageNotConfigured)
+	}
+	endpoint := strings.TrimRight(strings.TrimSpace(cfg.Endpoint), "/")
+	if !strings.HasPrefix(endpoint, "http") {
+		endpoint = "https://" + endpoint
+	}
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &s3ObjectStore{cfg: cfg, base: base, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (s *s3ObjectStore) Backend() string {
+	return StorageBackendS3
+}
+
+func (s *s3ObjectStore) ModelReachable() bool {
+	return true
+}
+
+func (s *s3ObjectStore) PresignUpload(key string, contentType string, ttl time.Duration) (*PresignedUpload, error) {
+	signed, expiresAt := s.presign(http.MethodPut, key, nil, ttl)
+	headers := map[string]string{}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	return &PresignedUpload{Key: key, URL: signed, Method: http.MethodPut, Headers: headers, ExpiresAt: expiresAt}, nil
+}
+
+func (s *s3ObjectStore) PresignDownload(key string, ttl time.Duration) (string, error) {
+	signed, _ := s.presign(http.MethodGet, key, nil, ttl)
+	return signed, nil
+}
+
+func (s *s3ObjectStore) Put(ctx context.Context, key string, contentType string, data []byte) error {
+	signed, _ := s.presign(http.MethodPut, key, nil, 5*time.Minute)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, signed, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("s3 put failed: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *s3ObjectStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrObjectNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("s3 delete failed: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *s3ObjectStore) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("s3 head failed: status %d", resp.StatusCode)
+	}
+	info := &ObjectInfo{
+		Key:         key,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+		ETag:        strings.Trim(resp.Header.Get("ETag"), `"`),
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = modified
+	}
+	return info, nil
+}
+
+func (s *s3ObjectStore) List(ctx context.Context, prefix string, limit int) ([]ObjectInfo, error) {
+	var result []ObjectInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}, "max-keys": {"1000"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+			Contents              []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				ETag         string    `xml:"ETag"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+		}
+		if resp.StatusCode >= 300 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("s3 list failed: status %d", resp.StatusCode)
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			result = append(result, ObjectInfo{
+				Key:          object.Key,
+				Size:         object.Size,
+				ETag:         strings.Trim(object.ETag, `"`),
+				LastModified: object.LastModified,
+			})
+			if limit > 0 && len(result) >= limit {
+				return result, nil
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return result, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *s3ObjectStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrObjectNotFound
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 get failed: status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (s *s3ObjectStore) KeyFromURL(rawURL string) (string, error) {
+	bucket := ""
+	if s.cfg.PathStyle {
+		bucket = s.cfg.Bucket
+	}
+	return keyFromURL(rawURL, bucket)
+}
+
+func (s *s3ObjectStore) do(ctx context.Context, method string, key string, query url.Values) (*http.Response, error) {
+	signed, _ := s.presign(method, key, query, 5*time.Minute)
+	req, err := http.NewRequestWithContext(ctx, method, signed, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.Do(req)
+}
+
+// presign 生成 SigV4 查询串签名 URL（UNSIGNED-PAYLOAD，仅签 host 头）。
+func (s *s3ObjectStore) presign(method string, key string, query url.Values, ttl time.Duration) (string, time.Time) {
+	return s.presignAt(method, key, query, ttl, time.Now().UTC())
+}
+
+func (s *s3ObjectStore) presignAt(method string, key string, query url.Values, ttl time.Duration, now time.Time) (string, time.Time) {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	if ttl > s3MaxPresign {
+		ttl = s3MaxPresign
+	}
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", day, s.cfg.Region)
+
+	host := s.base.Host
+	path := "/" + s3EscapePath(key)
+	if s.cfg.PathStyle {
+		path = "/" + s.cfg.Bucket + path
+	} else {
+		host = s.cfg.Bucket + "." + host
+	}
+
+	params := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.cfg.AccessKeyID + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.Itoa(int(ttl.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	for name, values := range query {
+		if len(values) > 0 {
+			params[name] = values[0]
+		}
+	}
+	canonicalQuery := s3CanonicalQuery(params)
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		canonicalQuery,
+		"host:" + host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(hashed[:])}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), day)
+	signingKey = hmacSHA256(signingKey, s.cfg.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	signed := fmt.Sprintf("%s://%s%s?%s&X-Amz-Signature=%s", s.base.Scheme, host, path, canonicalQuery, signature)
+	return signed, now.Add(ttl)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func s3CanonicalQuery(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, s3Escape(key, true)+"="+s3Escape(params[key], true))
+	}
+	return strings.Join(parts, "&")
+}
+
+func s3EscapePath(key string) string {
+	return s3Escape(key, false)
+}
+
+// s3Escape 按 SigV4 规则编码：只保留 A-Z a-z 0-9 - _ . ~，路径中保留斜杠。
+func s3Escape(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
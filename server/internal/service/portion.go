@@ -0,0 +1,171 @@
+package service
+
+import (
+	"errors"
+	"math"
+
+	"eatclean/internal/model"
+)
+
+// 单条记录的份量范围（克）。
+const (
+	minPortionGrams = 1
+	maxPortionGrams = 5000
+)
+
+var (
+	// ErrPortionUnit 无法识别的单位。
+	ErrPortionUnit = errors.New("unsupported portion unit")
+	// ErrPortionQuantity 数量或换算后的克数超出范围。
+	ErrPortionQuantity = errors.New("portion quantity out of range")
+	// ErrPortionBase 条目缺少营养或份量，无法按份量换算。
+	ErrPortionBase = errors.New("item has no nutrition base for scaling")
+)
+
+// PortionOption 条目可选的单位及每单位克数，供客户端编辑份量。
+type PortionOption struct {
+	Unit  string  `json:"unit"`
+	Grams float64 `json:"grams"`
+}
+
+// PortionOptions g 与 份 总是可选，其余为该食物收录的常用单位。
+func PortionOptions(name string, servingG float64) []PortionOption {
+	norm := model.NormalizeFoodName(name)
+	options := []PortionOption{{Unit: model.UnitGram, Grams: 1}}
+	if servingG > 0 {
+		options = append(options, PortionOption{Unit: model.UnitServing, Grams: roundGrams(servingG)})
+	}
+	for _, unit := range model.PortionUnits {
+		if unit == model.UnitGram || unit == model.UnitServing || !model.HasFoodUnit(norm, unit) {
+			continue
+		}
+		options = append(options, PortionOption{Unit: unit, Grams: model.UnitGrams(norm, unit, servingG)})
+	}
+	return options
+}
+
+// AnnotatePortions 为识别出的菜品补充可编辑份量：quantity/unit/grams、serving_g（每份克数）、
+// 每 100g 营养（*_per100g，作为后续换算基准）与 unit_options。克数取 portion_g/weight_g，
+// 没有时按 AI 给出的 portion 描述（如“1碗”）换算；无法判断克数的菜品原样返回。原 map 不修改。
+func AnnotatePortions(dishes []map[string]interface{}) []map[string]interface{} {
+	if len(dishes) == 0 {
+		return dishes
+	}
+	out := make([]map[string]interface{}, 0, len(dishes))
+	for _, dish := range dishes {
+		name := readString(dish["name"])
+		norm := model.NormalizeFoodName(name)
+		grams := model.NutrientValue(dish, "portion_g", "weight_g", "grams")
+		quantity, unit, parsed := model.ParsePortion(readString(dish["portion"]))
+		if grams <= 0 && parsed && unit != model.UnitServing {
+			grams = quantity * model.UnitGrams(norm, unit, 0)
+		}
+		if grams < minPortionGrams || grams > maxPortionGrams {
+			out = append(out, dish)
+			continue
+		}
+		servingG := grams
+		switch {
+		case !parsed:
+			quantity, unit = 1, model.UnitServing
+		case unit == model.UnitServing:
+			servingG = grams / quantity
+		}
+
+		merged := make(map[string]interface{}, len(dish)+8)
+		for key, value := range dish {
+			merged[key] = value
+		}
+		if per100, ok := itemPer100(dish, grams); ok {
+			setPer100Keys(merged, per100)
+		}
+		setPortionKeys(merged, name, quantity, unit, grams, servingG)
+		out = append(out, merged)
+	}
+	return out
+}
+
+// ScalePortion 按新的数量与单位重算条目营养，返回新的 map。基准优先取 *_per100g，
+// 没有时按当前营养与克数反推；份 的克数取条目的 serving_g。
+func ScalePortion(item map[string]interface{}, quantity float64, rawUnit string) (map[string]interface{}, error) {
+	unit, ok := model.NormalizeUnit(rawUnit)
+	if !ok {
+		return nil, ErrPortionUnit
+	}
+	if quantity <= 0 || math.IsInf(quantity, 0) || math.IsNaN(quantity) {
+		return nil, ErrPortionQuantity
+	}
+	name := readString(item["name"])
+	currentGrams := model.NutrientValue(item, "grams", "portion_g", "weight_g")
+	per100, ok := itemPer100(item, currentGrams)
+	if !ok {
+		return nil, ErrPortionBase
+	}
+	servingG := model.NutrientValue(item, "serving_g")
+	if servingG <= 0 {
+		servingG = currentGrams
+	}
+	grams := quantity * model.UnitGrams(model.NormalizeFoodName(name), unit, servingG)
+	if grams < minPortionGrams || grams > maxPortionGrams {
+		return nil, ErrPortionQuantity
+	}
+
+	merged := make(map[string]interface{}, len(item)+8)
+	for key, value := range item {
+		merged[key] = value
+	}
+	ApplyNutritionToMap(merged, per100.Scale(grams/100))
+	setPer100Keys(merged, per100)
+	setPortionKeys(merged, name, quantity, unit, grams, servingG)
+	return merged, nil
+}
+
+// HasPortion 条目是否带有客户端指定的 quantity 与 unit。
+func HasPortion(item map[string]interface{}) bool {
+	_, hasQuantity := item["quantity"]
+	_, hasUnit := item["unit"]
+	return hasQuantity && hasUnit
+}
+
+// itemPer100 条目每 100g 营养：显式的 *_per100g 优先，否则按当前每份营养与 grams 换算。
+func itemPer100(item map[string]interface{}, grams float64) (model.NutritionFacts, bool) {
+	n := model.NutritionFromMap(item, "")
+	if n == nil {
+		return model.NutritionFacts{}, false
+	}
+	if n.Per100g == nil {
+		n.ServingSizeG = grams
+	}
+	return n.Per100()
+}
+
+func setPer100Keys(item map[string]interface{}, per100 model.NutritionFacts) {
+	item["calories_kcal_per100g"] = per100.EnergyKcal
+	item["protein_g_per100g"] = per100.ProteinG
+	item["fat_g_per100g"] = per100.FatG
+	item["carbs_g_per100g"] = per100.CarbsG
+	optional := map[string]float64{
+		"saturated_fat_g_per100g": per100.SaturatedFatG,
+		"sugar_g_per100g":         per100.SugarG,
+		"added_sugar_g_per100g":   per100.AddedSugarG,
+		"fiber_g_per100g":         per100.FiberG,
+		"sodium_mg_per100g":       per100.SodiumMg,
+	}
+	for key, value := range optional {
+		if value > 0 {
+			item[key] = value
+		}
+	}
+}
+
+func setPortionKeys(item map[string]interface{}, name string, quantity float64, unit string, grams float64, servingG float64) {
+	item["quantity"] = math.Round(quantity*100) / 100
+	item["unit"] = unit
+	item["grams"] = roundGrams(grams)
+	item["serving_g"] = roundGrams(servingG)
+	item["unit_options"] = PortionOptions(name, servingG)
+}
+
+func roundGrams(grams float64) float64 {
+	return math.Round(grams)
+}
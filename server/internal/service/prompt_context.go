@@ -0,0 +1,432 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"eatclean/internal/model"
+)
+
+// promptUnsetMetrics 统计渲染时模板引用但上下文未提供的变量：<template>.<field>
+var promptUnsetMetrics = expvar.NewMap("prompt_unset_fields")
+
+const (
+	promptEmptyValue   = "暂无"
+	promptPendingValue = "待识别（以图片为准）"
+)
+
+// PromptIntake 当日已摄入与热量目标；Nutrients 为钠、糖等微量营养素合计，NutrientLimits 为用户的每日限量。
+type PromptIntake struct {
+	Calories       int
+	Protein        int
+	Carbs          int
+	Fat            int
+	CalorieTarget  int
+	Nutrients      model.NutritionFacts
+	NutrientLimits []NutrientLimit
+}
+
+// PromptAction 最近一次功能使用记录。
+type PromptAction struct {
+	Label string
+	At    time.Time
+}
+
+// PromptContext 所有 AI 入口共用的用户上下文。
+type PromptContext struct {
+	UserID        int64
+	Settings      map[string]interface{}
+	ClientTime    time.Time
+	TargetDate    time.Time
+	IsTrainingDay bool
+	IsCheatDay    bool
+	Intake        PromptIntake
+
+	RecentMealSummary string
+	LastMealAt        time.Time
+	RecentChatSummary string
+	RecentActions     []PromptAction
+	LastScanAt        time.Time
+	LastScanText      string
+	LastWeight        float64
+	LastWeightAt      time.Time
+	RecentSearches    []string
+}
+
+// PromptContextOptions 构建上下文的参数，TargetDate 为空时按 ClientTime 判断日类型。
+type PromptContextOptions struct {
+	ClientTime time.Time
+	TargetDate time.Time
+}
+
+// RenderedPrompt 渲染结果，Unset 为模板引用但未提供的变量。
+type RenderedPrompt struct {
+	Text    string
+	Version string
+	Unset   []string
+}
+
+// PromptContextBuilder 从各仓储汇总用户上下文并渲染提示词模板。
+type PromptContextBuilder struct {
+	prompts  *PromptRegistry
+	settings *SettingsService
+	meals    *MealRecordService
+	chats    *ChatMessageService
+	scans    *MenuScanService
+	daily    *DailyIntakeService
+	searches *FoodSearchLogService
+}
+
+func NewPromptContextBuilder(
+	prompts *PromptRegistry,
+	settings *SettingsService,
+	meals *MealRecordService,
+	chats *ChatMessageService,
+	scans *MenuScanService,
+	daily *DailyIntakeService,
+	searches *FoodSearchLogService,
+) *PromptContextBuilder {
+	return &PromptContextBuilder{
+		prompts:  prompts,
+		settings: settings,
+		meals:    meals,
+		chats:    chats,
+		scans:    scans,
+		daily:    daily,
+		searches: searches,
+	}
+}
+
+// Build 汇总用户上下文。单个数据源失败只记录日志，对应字段留空。
+func (b *PromptContextBuilder) Build(userID int64, opts PromptContextOptions) *PromptContext {
+	clientTime := opts.ClientTime
+	if clientTime.IsZero() {
+		clientTime = time.Now()
+	}
+	targetDate := opts.TargetDate
+	if targetDate.IsZero() {
+		targetDate = clientTime
+	}
+	pctx := &PromptContext{
+		UserID:     userID,
+		Settings:   map[string]interface{}{},
+		ClientTime: clientTime,
+		TargetDate: targetDate,
+	}
+	if b == nil {
+		return pctx
+	}
+
+	if b.settings != nil {
+		if record, err := b.settings.GetRecord(userID); err != nil {
+			log.Printf("prompt context settings failed (user %d): %v", userID, err)
+		} else if record != nil && len(record.Settings) > 0 {
+			if err := json.Unmarshal(record.Settings, &pctx.Settings); err != nil || pctx.Settings == nil {
+				pctx.Settings = map[string]interface{}{}
+			}
+			if weight, ok := toFloat(pctx.Settings["weight"]); ok && weight > 0 {
+				pctx.LastWeight = weight
+				if updatedAt, err := time.Parse(time.RFC3339, record.UpdatedAt); err == nil {
+					pctx.LastWeightAt = updatedAt
+				}
+			}
+		}
+	}
+	pctx.IsTrainingDay, pctx.IsCheatDay = ComputeDayFlags(pctx.Settings, targetDate)
+	pctx.Intake.CalorieTarget = readIntValue(pctx.Settings["calorie_target"])
+	if pctx.Intake.CalorieTarget == 0 {
+		// 未设置热量目标时按身体数据推荐的当日目标计算剩余热量
+		if targets, err := ComputeNutritionTargets(pctx.Settings, targetDate); err == nil {
+			pctx.Intake.CalorieTarget = targets.Today.Calories
+		}
+	}
+
+	pctx.Intake.NutrientLimits = NutrientLimitsFromSettings(pctx.Settings)
+	if b.daily != nil {
+		record, err := b.daily.GetByDate(userID, clientTime.Format("2006-01-02"))
+		if err == nil && record != nil {
+			pctx.Intake.Calories = record.Calories
+			pctx.Intake.Protein = record.Protein
+			pctx.Intake.Carbs = record.Carbs
+			pctx.Intake.Fat = record.Fat
+		}
+		if nutrients, err := b.daily.Micronutrients(userID, clientTime, record); err != nil {
+			log.Printf("prompt context nutrients failed (user %d): %v", userID, err)
+		} else {
+			pctx.Intake.Nutrients = nutrients
+		}
+	}
+
+	if b.meals != nil {
+		if records, err := b.meals.ListByUser(userID, 20); err != nil {
+			log.Printf("prompt context meals failed (user %d): %v", userID, err)
+		} else {
+			pctx.RecentMealSummary = SummarizeMealRecords(records, 3)
+			for _, record := range records {
+				at := record.RecordedAt
+				if at.IsZero() {
+					at = record.CreatedAt
+				}
+				if at.After(pctx.LastMealAt) {
+					pctx.LastMealAt = at
+				}
+				pctx.RecentActions = append(pctx.RecentActions, PromptAction{Label: mealActionLabel(record.Source), At: at})
+			}
+		}
+	}
+
+	if b.chats != nil {
+		if messages, err := b.chats.ListByUser(userID, 20); err != nil {
+			log.Printf("prompt context chats failed (user %d): %v", userID, err)
+		} else {
+			pctx.RecentChatSummary = SummarizeChatMessages(messages, 3)
+			for _, message := range messages {
+				if message.Role == "user" {
+					pctx.RecentActions = append(pctx.RecentActions, PromptAction{Label: "AI 对话", At: message.CreatedAt})
+				}
+			}
+		}
+	}
+
+	if b.scans != nil {
+		if scans, err := b.scans.ListByUser(userID, 5); err != nil {
+			log.Printf("prompt context scans failed (user %d): %v", userID, err)
+		} else {
+			for idx, scan := range scans {
+				if idx == 0 {
+					pctx.LastScanAt = scan.CreatedAt
+					pctx.LastScanText = truncateText(strings.Join(strings.Fields(scan.OCRText), " "), 80)
+				}
+				pctx.RecentActions = append(pctx.RecentActions, PromptAction{Label: "扫描菜单", At: scan.CreatedAt})
+			}
+		}
+	}
+
+	if b.searches != nil {
+		if entries, err := b.searches.ListRecent(userID, 5); err != nil {
+			log.Printf("prompt context searches failed (user %d): %v", userID, err)
+		} else {
+			for _, entry := range entries {
+				pctx.RecentSearches = append(pctx.RecentSearches, entry.Query)
+				pctx.RecentActions = append(pctx.RecentActions, PromptAction{Label: "搜索食物", At: entry.CreatedAt})
+			}
+		}
+	}
+
+	sort.SliceStable(pctx.RecentActions, func(i, j int) bool {
+		return pctx.RecentActions[i].At.After(pctx.RecentActions[j].At)
+	})
+	if len(pctx.RecentActions) > 5 {
+		pctx.RecentActions = pctx.RecentActions[:5]
+	}
+	return pctx
+}
+
+// DietRestrictions 只读取用户设置中的忌口，供不需要完整上下文的任务（排队的识别、缓存菜单）使用。
+func (b *PromptContextBuilder) DietRestrictions(userID int64) *DietRestrictions {
+	if b == nil || b.settings == nil {
+		return nil
+	}
+	settings, err := b.settings.Values(userID)
+	if err != nil {
+		log.Printf("diet restrictions settings failed (user %d): %v", userID, err)
+		return nil
+	}
+	return DietRestrictionsFromSettings(settings)
+}
+
+// NutrientBudget 每日限量与当天已摄入的微量营养素，供不需要完整上下文的任务（排队的菜单识别）标记菜品；
+// 读取失败时按当天尚未摄入处理。
+func (b *PromptContextBuilder) NutrientBudget(userID int64, at time.Time) *NutrientBudget {
+	budget := &NutrientBudget{Limits: DefaultNutrientLimits}
+	if b == nil {
+		return budget
+	}
+	if b.settings != nil {
+		if settings, err := b.settings.Values(userID); err != nil {
+			log.Printf("nutrient budget settings failed (user %d): %v", userID, err)
+		} else {
+			budget.Limits = NutrientLimitsFromSettings(settings)
+		}
+	}
+	if b.daily != nil {
+		record, err := b.daily.GetByDate(userID, at.Format("2006-01-02"))
+		if err != nil {
+			log.Printf("nutrient budget intake failed (user %d): %v", userID, err)
+		}
+		if consumed, err := b.daily.Micronutrients(userID, at, record); err != nil {
+			log.Printf("nutrient budget meals failed (user %d): %v", userID, err)
+		} else {
+			budget.Consumed = consumed
+		}
+	}
+	return budget
+}
+
+// NutrientBudget 上下文中的每日限量与当天已摄入。
+func (p *PromptContext) NutrientBudget() *NutrientBudget {
+	limits := p.Intake.NutrientLimits
+	if len(limits) == 0 {
+		limits = DefaultNutrientLimits
+	}
+	return &NutrientBudget{Limits: limits, Consumed: p.Intake.Nutrients}
+}
+
+// Values 返回上下文对应的模板变量（用户设置字段由 buildPromptValues 展开）。
+func (p *PromptContext) Values() map[string]string {
+	values := map[string]string{
+		"user_id":                strconv.FormatInt(p.UserID, 10),
+		"menu_scanned":           "false",
+		"food_photo_taken":       "false",
+		"current_time":           p.ClientTime.Format("2006-01-02 15:04:05"),
+		"time_of_day":            TimeOfDayLabel(p.ClientTime),
+		"day_type":               DayTypeLabel(p.IsTrainingDay, p.IsCheatDay),
+		"is_training_day":        formatYesNo(p.IsTrainingDay),
+		"is_cheat_day":           formatYesNo(p.IsCheatDay),
+		"calories_consumed":      strconv.Itoa(p.Intake.Calories),
+		"macro_consumed":         fmt.Sprintf("蛋白 %dg / 碳水 %dg / 脂肪 %dg", p.Intake.Protein, p.Intake.Carbs, p.Intake.Fat),
+		"nutrient_consumed":      NutrientSummary(p.NutrientBudget().Limits, p.Intake.Nutrients),
+		"calorie_remaining":      "未知",
+		"recent_meal_summary":    orPromptEmpty(p.RecentMealSummary),
+		"recent_chat_summary":    orPromptEmpty(p.RecentChatSummary),
+		"last_eat_log_time":      formatPromptMoment(p.LastMealAt),
+		"last_scan_time":         formatPromptMoment(p.LastScanAt),
+		"last_scan_text":         orPromptEmpty(p.LastScanText),
+		"recent_search_keywords": orPromptEmpty(strings.Join(p.RecentSearches, "、")),
+		"recent_actions":         promptEmptyValue,
+		"last_weight_update":     promptEmptyValue,
+	}
+	if p.Intake.CalorieTarget > 0 {
+		remaining := p.Intake.CalorieTarget - p.Intake.Calories
+		if remaining < 0 {
+			remaining = 0
+		}
+		values["calorie_remaining"] = strconv.Itoa(remaining)
+	}
+	if len(p.RecentActions) > 0 {
+		parts := make([]string, 0, len(p.RecentActions))
+		for _, action := range p.RecentActions {
+			parts = append(parts, fmt.Sprintf("%s %s", action.At.Format("01-02 15:04"), action.Label))
+		}
+		values["recent_actions"] = strings.Join(parts, "；")
+	}
+	if p.LastWeight > 0 {
+		weight := stringifyValue(p.LastWeight) + "kg"
+		if !p.LastWeightAt.IsZero() {
+			weight = fmt.Sprintf("%s（%s 更新）", weight, p.LastWeightAt.Format("2006-01-02"))
+		}
+		values["last_weight_update"] = weight
+	}
+	return values
+}
+
+// Fingerprint 识别结果缓存使用的上下文指纹，只包含影响识别建议的稳定字段：
+// 用户设置、训练日/放纵日与调用方传入的场景字段（如备注），不含当日摄入与时间。
+func (p *PromptContext) Fingerprint(parts ...string) string {
+	payload, _ := json.Marshal(struct {
+		Settings map[string]interface{} `json:"settings"`
+		Training bool                   `json:"training"`
+		Cheat    bool                   `json:"cheat"`
+		Parts    []string               `json:"parts"`
+	}{p.Settings, p.IsTrainingDay, p.IsCheatDay, parts})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Render 取用户命中的模板版本，用上下文与场景字段渲染；未提供的变量记录日志与指标。
+func (b *PromptContextBuilder) Render(name string, pctx *PromptContext, extras map[string]string) (*RenderedPrompt, error) {
+	if b == nil {
+		return nil, ErrPromptNotFound
+	}
+	tpl, err := b.prompts.Get(name, pctx.UserID)
+	if err != nil {
+		return nil, err
+	}
+	overrides := pctx.Values()
+	for key, value := range extras {
+		overrides[key] = value
+	}
+	text, unset, err := renderPromptTemplate(tpl.Content, buildPromptValues(pctx.Settings, overrides))
+	if err != nil {
+		return nil, fmt.Errorf("render prompt %s: %w", tpl.Tag(), err)
+	}
+	if len(unset) > 0 {
+		for _, field := range unset {
+			promptUnsetMetrics.Add(name+"."+field, 1)
+		}
+		log.Printf("prompt %s rendered with unset fields: %s", tpl.Tag(), strings.Join(unset, ", "))
+	}
+	return &RenderedPrompt{Text: text, Version: tpl.Tag(), Unset: unset}, nil
+}
+
+// MenuScanPromptExtras 菜单扫描场景字段，菜品信息由模型从图片识别。
+func MenuScanPromptExtras(note string) map[string]string {
+	return map[string]string{
+		"menu_scanned":   "true",
+		"menu_scan_note": orPromptNote(note),
+		"dish_id":        promptPendingValue,
+		"dish_name":      promptPendingValue,
+		"dish_category":  promptPendingValue,
+		"nutrition_json": promptPendingValue,
+		"portion":        promptPendingValue,
+	}
+}
+
+// FoodPhotoPromptExtras 食物照片场景字段。
+func FoodPhotoPromptExtras(note string, takenAt time.Time) map[string]string {
+	return map[string]string{
+		"food_photo_taken":   "true",
+		"food_photo_note":    orPromptNote(note),
+		"food_photo_tags":    promptPendingValue,
+		"food_photo_items":   promptPendingValue,
+		"food_photo_portion": promptPendingValue,
+		"food_photo_time":    takenAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// IngredientPromptExtras 配料表场景字段。
+func IngredientPromptExtras(note string) map[string]string {
+	return map[string]string{
+		"ingredient_photo_note": orPromptNote(note),
+	}
+}
+
+func mealActionLabel(source string) string {
+	switch strings.TrimSpace(source) {
+	case "food":
+		return "拍照记录饮食"
+	case "menu":
+		return "从菜单记录饮食"
+	default:
+		return "记录饮食"
+	}
+}
+
+func formatPromptMoment(value time.Time) string {
+	if value.IsZero() {
+		return promptEmptyValue
+	}
+	return value.Format("2006-01-02 15:04")
+}
+
+func orPromptNote(note string) string {
+	if strings.TrimSpace(note) == "" {
+		return "无"
+	}
+	return strings.TrimSpace(note)
+}
+
+func orPromptEmpty(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return promptEmptyValue
+	}
+	return value
+}
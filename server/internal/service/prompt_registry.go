@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"eatclean/internal/config"
+)
+
+// 模板名称，与 config.yaml prompts 下的路径一一对应。
+const (
+	PromptMenuScan        = "menu_scan"
+	PromptFoodScan        = "food_scan"
+	PromptIngredientScan  = "ingredient_scan"
+	PromptDiscoverPlan    = "discover_plan"
+	PromptDiscoverReplace = "discover_replace"
+	PromptChat            = "chat"
+
+	promptDefaultVariant = "default"
+)
+
+var ErrPromptNotFound = errors.New("prompt template not found")
+
+// PromptTemplate 一个已加载的模板版本，Version 为内容哈希。
+type PromptTemplate struct {
+	Name     string    `json:"name"`
+	Variant  string    `json:"variant"`
+	Path     string    `json:"path"`
+	Version  string    `json:"version"`
+	Content  string    `json:"-"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// Tag 写入 AI 输出的版本标识，如 food_scan/default@3f2a9c1b7d4e。
+func (t *PromptTemplate) Tag() string {
+	if t == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s@%s", t.Name, t.Variant, t.Version)
+}
+
+// PromptValidation 单个模板的加载与试渲染结果。
+type PromptValidation struct {
+	Name          string   `json:"name"`
+	Variant       string   `json:"variant"`
+	Path          string   `json:"path"`
+	Version       string   `json:"version,omitempty"`
+	Changed       bool     `json:"changed"`
+	Error         string   `json:"error,omitempty"`
+	MissingFields []string `json:"missing_fields,omitempty"`
+}
+
+type promptSource struct {
+	name    string
+	variant string
+	path    string
+	weight  int
+}
+
+// PromptRegistry 启动时加载全部模板并校验，之后按文件变更或管理接口热更新。
+// 重新加载失败时保留上一版模板，不影响线上请求。
+type PromptRegistry struct {
+	sources map[string][]promptSource
+	// invalid 配置错误的变体（权重非正、合计超过 100 等），Load 时拒绝启动
+	invalid []string
+
+	reloadMu  sync.Mutex
+	mu        sync.RWMutex
+	templates map[string]map[string]*PromptTemplate
+	report    []PromptValidation
+}
+
+func NewPromptRegistry(cfg config.PromptConfig) *PromptRegistry {
+	defaults := map[string]string{
+		PromptMenuScan:        cfg.MenuScanPath,
+		PromptFoodScan:        cfg.FoodScanPath,
+		PromptIngredientScan:  cfg.IngredientScanPath,
+		PromptDiscoverPlan:    cfg.DiscoverPlanPath,
+		PromptDiscoverReplace: cfg.DiscoverReplacePath,
+		PromptChat:            cfg.ChatPromptPath,
+	}
+	sources := make(map[string][]promptSource, len(defaults))
+	for name, path := range defaults {
+		sources[name] = []promptSource{{name: name, variant: promptDefaultVariant, path: strings.TrimSpace(path)}}
+	}
+	var invalid []string
+	for name, variants := range cfg.Variants {
+		if _, ok := sources[name]; !ok {
+			log.Printf("prompt variants for unknown template %q ignored", name)
+			continue
+		}
+		total := 0
+		seen := map[string]bool{promptDefaultVariant: true}
+		for _, variant := range variants {
+			variantName := strings.TrimSpace(variant.Name)
+			path := strings.TrimSpace(variant.Path)
+			switch {
+			case variantName == "" || path == "":
+				invalid = append(invalid, fmt.Sprintf("%s: variant name and path are required", name))
+				continue
+			case seen[variantName]:
+				invalid = append(invalid, fmt.Sprintf("%s/%s: duplicate variant name", name, variantName))
+				continue
+			case variant.Weight <= 0 || variant.Weight > 100:
+				invalid = append(invalid, fmt.Sprintf("%s/%s: weight %d must be between 1 and 100", name, variantName, variant.Weight))
+				continue
+			}
+			seen[variantName] = true
+			total += variant.Weight
+			sources[name] = append(sources[name], promptSource{
+				name:    name,
+				variant: variantName,
+				path:    path,
+				weight:  variant.Weight,
+			})
+		}
+		if total > 100 {
+			invalid = append(invalid, fmt.Sprintf("%s: variant weights add up to %d, more than 100", name, total))
+		}
+	}
+	sort.Strings(invalid)
+	return &PromptRegistry{
+		sources:   sources,
+		invalid:   invalid,
+		templates: make(map[string]map[string]*PromptTemplate),
+	}
+}
+
+// Load 读取并校验全部模板。变体配置无效（权重非正或合计超过 100 等）、默认模板缺失或无法解析时返回错误，启动应当失败。
+func (r *PromptRegistry) Load() ([]PromptValidation, error) {
+	if r != nil && len(r.invalid) > 0 {
+		return nil, fmt.Errorf("prompt variants invalid: %s", strings.Join(r.invalid, "; "))
+	}
+	report := r.Reload()
+	var failed []string
+	for _, item := range report {
+		if item.Variant == promptDefaultVariant && item.Version == "" {
+			failed = append(failed, fmt.Sprintf("%s: %s", item.Name, item.Error))
+		}
+	}
+	if len(failed) > 0 {
+		return report, fmt.Errorf("prompt templates invalid: %s", strings.Join(failed, "; "))
+	}
+	return report, nil
+}
+
+// Reload 重新读取全部模板，内容变化且校验通过的替换旧版本。
+func (r *PromptRegistry) Reload() []PromptValidation {
+	if r == nil {
+		return nil
+	}
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	report := make([]PromptValidation, 0, len(r.sources))
+	for _, name := range r.names() {
+		for _, source := range r.sources[name] {
+			report = append(report, r.reloadSource(source))
+		}
+	}
+	r.mu.Lock()
+	r.report = report
+	r.mu.Unlock()
+	return report
+}
+
+func (r *PromptRegistry) reloadSource(source promptSource) PromptValidation {
+	result := PromptValidation{Name: source.name, Variant: source.variant, Path: source.path}
+	current := r.lookup(source.name, source.variant)
+	if current != nil {
+		result.Version = current.Version
+	}
+
+	data, err := os.ReadFile(source.path)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	content := string(data)
+	version := promptVersion(content)
+	if current != nil && current.Version == version {
+		result.MissingFields = r.cachedMissingFields(source)
+		return result
+	}
+
+	missing, err := ValidatePromptTemplate(content)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if len(missing) > 0 {
+		log.Printf("prompt %s/%s references unset fields: %s", source.name, source.variant, strings.Join(missing, ", "))
+	}
+
+	tpl := &PromptTemplate{
+		Name:     source.name,
+		Variant:  source.variant,
+		Path:     source.path,
+		Version:  version,
+		Content:  content,
+		LoadedAt: time.Now(),
+	}
+	r.mu.Lock()
+	if r.templates[source.name] == nil {
+		r.templates[source.name] = make(map[string]*PromptTemplate)
+	}
+	r.templates[source.name][source.variant] = tpl
+	r.mu.Unlock()
+	if current != nil {
+		log.Printf("prompt %s reloaded: %s -> %s", source.name, current.Tag(), tpl.Tag())
+	}
+
+	result.Version = version
+	result.Changed = true
+	result.MissingFields = missing
+	return result
+}
+
+func (r *PromptRegistry) cachedMissingFields(source promptSource) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, item := range r.report {
+		if item.Name == source.name && item.Variant == source.variant {
+			return item.MissingFields
+		}
+	}
+	return nil
+}
+
+// Watch 按间隔轮询模板文件，ctx 结束时退出。
+func (r *PromptRegistry) Watch(ctx context.Context, interval time.Duration) {
+	if r == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, item := range r.Reload() {
+				if item.Error != "" {
+					log.Printf("prompt %s/%s reload failed, keeping previous version: %s", item.Name, item.Variant, item.Error)
+				}
+			}
+		}
+	}
+}
+
+// Get 返回用户命中的模板版本。A/B 变体按 (模板名, 用户) 哈希稳定分桶，变体不可用时回落默认模板。
+func (r *PromptRegistry) Get(name string, userID int64) (*PromptTemplate, error) {
+	if r == nil {
+		return nil, ErrPromptNotFound
+	}
+	variant := r.variantFor(name, userID)
+	if tpl := r.lookup(name, variant); tpl != nil {
+		return tpl, nil
+	}
+	if tpl := r.lookup(name, promptDefaultVariant); tpl != nil {
+		return tpl, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+}
+
+// Status 返回当前加载的模板及最近一次校验结果。
+func (r *PromptRegistry) Status() ([]PromptTemplate, []PromptValidation) {
+	if r == nil {
+		return nil, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	templates := make([]PromptTemplate, 0, len(r.templates))
+	for _, variants := range r.templates {
+		for _, tpl := range variants {
+			templates = append(templates, *tpl)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Name == templates[j].Name {
+			return templates[i].Variant < templates[j].Variant
+		}
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, append([]PromptValidation(nil), r.report...)
+}
+
+func (r *PromptRegistry) variantFor(name string, userID int64) string {
+	sources := r.sources[name]
+	if len(sources) <= 1 || userID <= 0 {
+		return promptDefaultVariant
+	}
+	hasher := fnv.New32a()
+	_, _ = fmt.Fprintf(hasher, "%s:%d", name, userID)
+	bucket := int(hasher.Sum32() % 100)
+	cumulative := 0
+	for _, source := range sources[1:] {
+		cumulative += source.weight
+		if bucket < cumulative {
+			return source.variant
+		}
+	}
+	return promptDefaultVariant
+}
+
+func (r *PromptRegistry) lookup(name string, variant string) *PromptTemplate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.templates[name][variant]
+}
+
+func (r *PromptRegistry) names() []string {
+	names := make([]string, 0, len(r.sources))
+	for name := range r.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func promptVersion(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// ValidatePromptTemplate 解析模板并用示例设置试渲染，返回模板引用但渲染时不会提供的字段。
+func ValidatePromptTemplate(content string) ([]string, error) {
+	values := buildPromptValues(samplePromptSettings(), samplePromptOverrides())
+	_, missing, err := renderPromptTemplate(content, values)
+	return missing, err
+}
+
+// samplePromptSettings 与 doc/settings.md 中的设置字段保持一致，用于模板试渲染。
+func samplePromptSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"user_id":                  "1",
+		"user_name":                "示例用户",
+		"height":                   175.0,
+		"weight":                   70.0,
+		"age":                      30.0,
+		"gender":                   "male",
+		"activity_level":           "moderate",
+		"training_experience":      "beginner",
+		"goal_type":                "fat_loss",
+		"weekly_training_days":     3.0,
+		"preferred_training_time":  "evening",
+		"training_type_preference": []interface{}{"strength"},
+		"diet_preferences":         []interface{}{"high_protein"},
+		"excluded_foods":           []interface{}{"花生"},
+		"calorie_target":           1800.0,
+		"macro_targets":            map[string]interface{}{"protein": 130.0, "carbs": 180.0, "fat": 55.0},
+		"cheat_frequency":          1.0,
+		"late_eating_habit":        "sometimes",
+		"ai_suggestion_style":      "balanced",
+		"weight_plan_mode":         "lose",
+		"weight_plan_kg":           5.0,
+		"weight_plan_days":         60.0,
+	}
+}
+
+// samplePromptOverrides 上下文字段加上各场景字段，覆盖所有 AI 入口渲染时会提供的变量。
+func samplePromptOverrides() map[string]string {
+	now := time.Now()
+	sample := &PromptContext{UserID: 1, Settings: samplePromptSettings(), ClientTime: now, TargetDate: now}
+	overrides := sample.Values()
+	for _, extras := range []map[string]string{
+		MenuScanPromptExtras(""),
+		FoodPhotoPromptExtras("", now),
+		IngredientPromptExtras(""),
+		{"current_meal_json": "{}"},
+	} {
+		for key, value := range extras {
+			overrides[key] = value
+		}
+	}
+	return overrides
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"eatclean/internal/model"
+)
+
+// 快捷记录的打分参数。
+const (
+	quickPickHalfLifeDays   = 14.0 // 最近一次记录的时间衰减半衰期
+	quickPickOtherSlotShare = 0.3  // 其他时段的常吃条目按该比例计分
+	quickPickFavoriteBonus  = 2.0  // 收藏条目额外加分
+	quickPickTrainingBoost  = 1.2  // 训练日高蛋白条目加权
+	quickPickHighProteinG   = 20.0 // 高蛋白条目的每份蛋白质下限
+	quickPickRecentDays     = 14   // 可一键复制的同时段历史餐的回溯天数
+	quickPickRecentMeals    = 3
+	quickPickRecordScan     = 60
+)
+
+// QuickPick 一条快捷记录建议，Item 可直接写入 POST /meals 的 items。
+type QuickPick struct {
+	Name         string                 `json:"name"`
+	Item         map[string]interface{} `json:"item"`
+	Score        float64                `json:"score"`
+	Count        int                    `json:"count,omitempty"`
+	LastLoggedAt *time.Time             `json:"last_logged_at,omitempty"`
+	FavoriteID   int64                  `json:"favorite_id,omitempty"`
+}
+
+// QuickPickMeal 同一时段的历史餐，可通过 POST /meals/copy 复制。
+type QuickPickMeal struct {
+	RecordID   int64     `json:"record_id"`
+	Source     string    `json:"source"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Names      []string  `json:"names"`
+	Kcal       int       `json:"kcal"`
+}
+
+// QuickPicks 快捷记录结果及所依据的上下文。
+type QuickPicks struct {
+	TimeOfDay   string          `json:"time_of_day"`
+	DayType     string          `json:"day_type"`
+	Items       []QuickPick     `json:"items"`
+	RecentMeals []QuickPickMeal `json:"recent_meals"`
+}
+
+// QuickPickService 根据时段、训练日/放纵日、常吃条目与收藏生成快捷记录建议。
+type QuickPickService struct {
+	meals     *MealRecordService
+	favorites *FavoriteService
+	settings  *SettingsService
+}
+
+func NewQuickPickService(meals *MealRecordService, favorites *FavoriteService, settings *SettingsService) *QuickPickService {
+	return &QuickPickService{meals: meals, favorites: favorites, settings: settings}
+}
+
+// Suggest 当前时段的常吃条目按次数与时间衰减计分，其他时段折减；收藏加分；训练日优先高蛋白。
+func (s *QuickPickService) Suggest(userID int64, clientTime time.Time, limit int) (*QuickPicks, error) {
+	slot := TimeOfDayLabel(clientTime)
+	isTraining, isCheat := ComputeDayFlags(s.userSettings(userID), clientTime)
+	result := &QuickPicks{
+		TimeOfDay:   slot,
+		DayType:     DayTypeLabel(isTraining, isCheat),
+		Items:       []QuickPick{},
+		RecentMeals: []QuickPickMeal{},
+	}
+
+	picks := map[string]*QuickPick{}
+	frequent, err := s.meals.FrequentItems(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range frequent {
+		days := clientTime.Sub(entry.LastLoggedAt).Hours() / 24
+		score := float64(entry.Count) * math.Pow(0.5, math.Max(days, 0)/quickPickHalfLifeDays)
+		if entry.MealSlot != slot {
+			score *= quickPickOtherSlotShare
+		}
+		pick, ok := picks[entry.NormalizedName]
+		if !ok {
+			lastLogged := entry.LastLoggedAt
+			pick = &QuickPick{Name: entry.Name, Item: entry.Item, LastLoggedAt: &lastLogged}
+			picks[entry.NormalizedName] = pick
+		} else if entry.MealSlot == slot {
+			pick.Item = entry.Item
+		}
+		pick.Score += score
+		pick.Count += entry.Count
+		if pick.LastLoggedAt.Before(entry.LastLoggedAt) {
+			lastLogged := entry.LastLoggedAt
+			pick.LastLoggedAt = &lastLogged
+		}
+	}
+
+	favorites, err := s.favorites.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, favorite := range favorites {
+		if len(favorite.Item) == 0 {
+			continue
+		}
+		pick, ok := picks[favorite.NormalizedName]
+		if !ok {
+			pick = &QuickPick{Name: favorite.Name, Item: favorite.Item}
+			picks[favorite.NormalizedName] = pick
+		}
+		pick.FavoriteID = favorite.ID
+		pick.Score += quickPickFavoriteBonus
+	}
+
+	for _, pick := range picks {
+		if isTraining && model.NutrientValue(pick.Item, "protein") >= quickPickHighProteinG {
+			pick.Score *= quickPickTrainingBoost
+		}
+		pick.Score = roundScore(pick.Score)
+		result.Items = append(result.Items, *pick)
+	}
+	sort.Slice(result.Items, func(i, j int) bool {
+		if result.Items[i].Score != result.Items[j].Score {
+			return result.Items[i].Score > result.Items[j].Score
+		}
+		return result.Items[i].Name < result.Items[j].Name
+	})
+	if len(result.Items) > limit {
+		result.Items = result.Items[:limit]
+	}
+
+	recent, err := s.meals.ListByUser(userID, quickPickRecordScan)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := clientTime.AddDate(0, 0, -quickPickRecentDays)
+	for _, record := range recent {
+		if len(result.RecentMeals) >= quickPickRecentMeals || record.RecordedAt.Before(cutoff) {
+			break
+		}
+		if TimeOfDayLabel(record.RecordedAt) != slot {
+			continue
+		}
+		if meal, ok := quickPickMealOf(&record); ok {
+			result.RecentMeals = append(result.RecentMeals, meal)
+		}
+	}
+	return result, nil
+}
+
+func (s *QuickPickService) userSettings(userID int64) map[string]interface{} {
+	settings, _ := s.settings.Values(userID)
+	return settings
+}
+
+func quickPickMealOf(record *model.MealRecord) (QuickPickMeal, bool) {
+	items := frequentItemsOf(record)
+	if len(items) == 0 {
+		return QuickPickMeal{}, false
+	}
+	meal := QuickPickMeal{
+		RecordID:   record.ID,
+		Source:     record.Source,
+		RecordedAt: record.RecordedAt,
+		Names:      make([]string, 0, len(items)),
+	}
+	kcal := 0.0
+	for _, item := range items {
+		meal.Names = append(meal.Names, item.Name)
+		kcal += model.NutrientValue(item.Item, "kcal", "calories")
+	}
+	meal.Kcal = int(math.Round(kcal))
+	return meal, true
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"eatclean/internal/config"
+	"eatclean/internal/repository"
+)
+
+// 额度预留类型：points 为中间件按积分预留，其余为免费用户按次数预留。
+const (
+	QuotaKindPoints    = "points"
+	QuotaKindMenuScan  = "menu_scan"
+	QuotaKindMealPhoto = "meal_photo"
+	QuotaKindChat      = "chat"
+)
+
+// QuotaHoldContextKey 请求内的预留集合在 echo.Context 中的 key。
+const QuotaHoldContextKey = "quota_hold"
+
+var (
+	// ErrQuotaExceeded 已用量加未确认的预留超过额度。
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrQuotaTooManyInFlight 同时进行的计费请求达到上限。
+	ErrQuotaTooManyInFlight = errors.New("too many in-flight requests")
+)
+
+// quotaMetrics 统计：<kind>.reserved / .exceeded、in_flight_rejected、committed、released
+var quotaMetrics = expvar.NewMap("quota_reservation")
+
+// QuotaCheck 一次预留的参数。Used 返回已落库的用量（不含预留），在用户锁内调用。
+type QuotaCheck struct {
+	Kind  string
+	Units int
+	Limit int
+	Used  func() (int, error)
+	// Retain 请求不写用量记录：确认后的预留从 Since 起继续计入额度，直到窗口结束
+	Retain bool
+	Since  time.Time
+	// LimitInFlight 按 max_in_flight 限制该类型未确认的 AI 预留份数，本次预留也计入其中
+	LimitInFlight bool
+}
+
+// QuotaService 调用 AI 前原子预留额度：同一用户的“统计已用量 + 未确认预留 → 写入预留”在 advisory lock 内完成，
+// 成功后确认（之后由落库的记录计数；不落库的请求以 Retain 预留计数），失败后释放。未确认的预留超过 TTL 自动失效。
+type QuotaService struct {
+	repo *repository.QuotaReservationRepository
+	cfg  config.QuotaConfig
+}
+
+func NewQuotaService(repo *repository.QuotaReservationRepository, cfg config.QuotaConfig) *QuotaService {
+	return &QuotaService{repo: repo, cfg: cfg}
+}
+
+func (s *QuotaService) IsEnabled() bool {
+	return s != nil && s.repo != nil
+}
+
+// Reserve 检查并预留，返回预留后的剩余额度；hold 非空时记入请求的预留集合。
+// 未配置存储时退化为普通的计数检查。
+func (s *QuotaService) Reserve(userID int64, check QuotaCheck, hold *QuotaHold) (int, error) {
+	if !s.IsEnabled() {
+		used, err := check.Used()
+		if err != nil {
+			return 0, err
+		}
+		if used+check.Units > check.Limit {
+			return 0, ErrQuotaExceeded
+		}
+		return check.Limit - used - check.Units, nil
+	}
+
+	var remaining int
+	var reservationID int64
+	err := s.repo.WithUserLock(userID, func(tx *sql.Tx) error {
+		count, reserved, err := s.repo.Active(tx, userID, check.Kind, check.Since)
+		if err != nil {
+			return err
+		}
+		if check.LimitInFlight && count >= s.cfg.MaxInFlight {
+			return ErrQuotaTooManyInFlight
+		}
+		used, err := check.Used()
+		if err != nil {
+			return err
+		}
+		if used+reserved+check.Units > check.Limit {
+			return ErrQuotaExceeded
+		}
+		reservationID, err = s.repo.Insert(tx, userID, check.Kind, check.Units, time.Duration(s.cfg.ReservationTTLMinutes)*time.Minute, check.Retain, check.LimitInFlight)
+		remaining = check.Limit - used - reserved - check.Units
+		return err
+	})
+	switch {
+	case errors.Is(err, ErrQuotaTooManyInFlight):
+		quotaMetrics.Add("in_flight_rejected", 1)
+		return 0, err
+	case errors.Is(err, ErrQuotaExceeded):
+		quotaMetrics.Add(check.Kind+".exceeded", 1)
+		return 0, err
+	case err != nil:
+		return 0, err
+	}
+	quotaMetrics.Add(check.Kind+".reserved", 1)
+	hold.add(reservationID)
+	return remaining, nil
+}
+
+// Commit 确认预留：对应记录已落库（或任务已完成）。
+func (s *QuotaService) Commit(ids []int64) {
+	s.settle(ids, "committed")
+}
+
+// Release 释放预留：请求失败，不计入额度。
+func (s *QuotaService) Release(ids []int64) {
+	s.settle(ids, "released")
+}
+
+func (s *QuotaService) settle(ids []int64, status string) {
+	if !s.IsEnabled() || len(ids) == 0 {
+		return
+	}
+	if err := s.repo.Settle(ids, status); err != nil {
+		log.Printf("quota reservation %s failed: %v", status, err)
+		return
+	}
+	quotaMetrics.Add(status, int64(len(ids)))
+}
+
+// NewHold 创建一次请求的预留集合。
+func (s *QuotaService) NewHold() *QuotaHold {
+	return &QuotaHold{quota: s}
+}
+
+// Start 每天清理两天前的预留记录。
+func (s *QuotaService) Start(ctx context.Context) {
+	if !s.IsEnabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.repo.DeleteOlderThan(2); err != nil {
+					log.Printf("quota reservation cleanup failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// QuotaHold 一次请求持有的全部预留，请求结束时统一确认或释放；转交异步任务后由任务结束时处理。
+type QuotaHold struct {
+	quota    *QuotaService
+	mu       sync.Mutex
+	ids      []int64
+	detached bool
+}
+
+func (h *QuotaHold) add(id int64) {
+	if h == nil || id == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ids = append(h.ids, id)
+}
+
+// IDs 当前持有的预留。
+func (h *QuotaHold) IDs() []int64 {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]int64(nil), h.ids...)
+}
+
+// Detach 预留已转交异步任务，请求结束时不再处理。
+func (h *QuotaHold) Detach() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.detached = true
+}
+
+// Finish 请求成功时确认、失败时释放全部预留。
+func (h *QuotaHold) Finish(success bool) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	ids := h.ids
+	detached := h.detached
+	h.ids = nil
+	h.mu.Unlock()
+	if detached {
+		return
+	}
+	if success {
+		h.quota.Commit(ids)
+	} else {
+		h.quota.Release(ids)
+	}
+}
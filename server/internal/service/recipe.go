@@ -0,0 +1,375 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
+
+// 菜谱限制。
+const (
+	recipeListLimit      = 100
+	maxRecipeIngredients = 50
+	maxRecipeServings    = 50
+	maxRecipeLogServings = 20
+)
+
+var (
+	ErrRecipeNotFound = errors.New("recipe not found")
+	// ErrRecipeInvalid 标题、份数或食材不符合要求，错误信息可直接返回给客户端。
+	ErrRecipeInvalid = errors.New("invalid recipe")
+)
+
+// ingredientAmountPattern 拆分“鸡胸肉 150g”“鸡蛋2个”“米饭半碗”中的名称与用量。
+var ingredientAmountPattern = regexp.MustCompile(`^(.+?)\s*((?:\d+(?:\.\d+)?|[半一两二三四五六七八九十])\s*\S{1,3})$`)
+
+// RecipeService 用户菜谱：食材按 自建食物 > 食物成分表 > 菜品缓存 解析营养，合计后按份数得出每份营养。
+type RecipeService struct {
+	repo    *repository.RecipeRepository
+	foods   *FoodService
+	customs *CustomFoodService
+	dishes  *DishService
+}
+
+func NewRecipeService(repo *repository.RecipeRepository, foods *FoodService, customs *CustomFoodService, dishes *DishService) *RecipeService {
+	return &RecipeService{repo: repo, foods: foods, customs: customs, dishes: dishes}
+}
+
+func (s *RecipeService) IsEnabled() bool {
+	return s != nil && s.repo != nil
+}
+
+// Save 校验、解析食材并计算营养后写入；ID 非 0 时更新已有菜谱（来源字段不可修改，未指定新图片时保留原图片）。
+func (s *RecipeService) Save(recipe *model.Recipe) error {
+	if !s.IsEnabled() {
+		return errors.New("recipe storage not configured")
+	}
+	if err := validateRecipe(recipe); err != nil {
+		return err
+	}
+	var previous *model.Recipe
+	if recipe.ID != 0 {
+		existing, err := s.Get(recipe.UserID, recipe.ID)
+		if err != nil {
+			return err
+		}
+		previous = existing
+		recipe.Source = existing.Source
+		recipe.SourceRef = existing.SourceRef
+		if recipe.ImageAssetID == 0 {
+			recipe.ImageAssetID = existing.ImageAssetID
+			recipe.ImageKey = existing.ImageKey
+		}
+	}
+	if recipe.Source == "" {
+		recipe.Source = model.RecipeSourceManual
+	}
+	recipe.Ingredients = s.ResolveIngredients(recipe.UserID, recipe.Ingredients)
+	recipe.Nutrition = computeRecipeNutrition(recipe.Ingredients, recipe.Servings)
+	if previous != nil && !allIngredientsResolved(recipe.Ingredients) {
+		if estimate := rescaleEstimate(previous, recipe); estimate != nil {
+			recipe.Nutrition = estimate
+		}
+	}
+	if recipe.ID == 0 {
+		return s.repo.Create(recipe)
+	}
+	updated, err := s.repo.Update(recipe)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrRecipeNotFound
+	}
+	return nil
+}
+
+// ImportDiscoverCard 把发现页餐食卡片（title/ingredients/instructions/calories...）保存为 1 份的菜谱。
+// 食材未能全部解析时，每份营养沿用卡片上的估算值。
+func (s *RecipeService) ImportDiscoverCard(userID int64, card map[string]interface{}) (*model.Recipe, error) {
+	if !s.IsEnabled() {
+		return nil, errors.New("recipe storage not configured")
+	}
+	title := readString(card["title"])
+	if title == "" {
+		title = readString(card["name"])
+	}
+	recipe := &model.Recipe{
+		UserID:       userID,
+		Title:        title,
+		Servings:     1,
+		Instructions: readString(card["instructions"]),
+		Source:       model.RecipeSourceDiscover,
+		SourceRef:    readString(card["id"]),
+	}
+	for _, text := range cardIngredientTexts(card["ingredients"]) {
+		recipe.Ingredients = append(recipe.Ingredients, ParseIngredientText(text))
+	}
+	if err := validateRecipe(recipe); err != nil {
+		return nil, err
+	}
+	recipe.Ingredients = s.ResolveIngredients(userID, recipe.Ingredients)
+	recipe.Nutrition = computeRecipeNutrition(recipe.Ingredients, recipe.Servings)
+	if !allIngredientsResolved(recipe.Ingredients) {
+		estimate := model.NutritionFromMap(card, model.NutritionSourceAIPlan)
+		if serving, ok := estimate.Serving(); ok {
+			recipe.Nutrition = (&model.Nutrition{
+				PerServing:   &serving,
+				ServingSizeG: recipeServingGrams(recipe.Ingredients, recipe.Servings),
+				Confidence:   estimate.Confidence,
+				Source:       model.NutritionSourceAIPlan,
+			}).Complete()
+		}
+	}
+	if err := s.repo.Create(recipe); err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+func (s *RecipeService) Get(userID int64, id int64) (*model.Recipe, error) {
+	if !s.IsEnabled() {
+		return nil, ErrRecipeNotFound
+	}
+	recipe, err := s.repo.FindByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if recipe == nil {
+		return nil, ErrRecipeNotFound
+	}
+	return recipe, nil
+}
+
+func (s *RecipeService) List(userID int64) ([]model.Recipe, error) {
+	if !s.IsEnabled() {
+		return nil, nil
+	}
+	return s.repo.ListByUser(userID, recipeListLimit)
+}
+
+func (s *RecipeService) Delete(userID int64, id int64) (bool, error) {
+	if !s.IsEnabled() {
+		return false, nil
+	}
+	return s.repo.Delete(userID, id)
+}
+
+// MealItem 把 servings 份菜谱转换为就餐记录条目，份量单位为 份，之后可按 portion 规则修改。
+func (s *RecipeService) MealItem(recipe *model.Recipe, servings float64) (map[string]interface{}, error) {
+	if servings <= 0 || servings > maxRecipeLogServings {
+		return nil, fmt.Errorf("%w: servings must be between 0 and %d", ErrRecipeInvalid, maxRecipeLogServings)
+	}
+	perServing, ok := recipe.Nutrition.Serving()
+	if !ok {
+		return nil, fmt.Errorf("%w: recipe has no nutrition", ErrRecipeInvalid)
+	}
+	item := map[string]interface{}{
+		"id":         fmt.Sprintf("recipe_%d", recipe.ID),
+		"name":       recipe.Title,
+		"recipe_id":  recipe.ID,
+		"restaurant": "我的菜谱",
+		"tag":        "菜谱",
+		"source":     "recipe",
+	}
+	ApplyNutritionToMap(item, perServing)
+	if servingG := recipe.Nutrition.ServingSizeG; servingG > 0 {
+		item["grams"] = servingG
+		item["serving_g"] = servingG
+		if per100, ok := recipe.Nutrition.Per100(); ok {
+			setPer100Keys(item, per100)
+		}
+		return ScalePortion(item, servings, model.UnitServing)
+	}
+	// 每份克数未知时不能按克换算，只记录份数
+	ApplyNutritionToMap(item, perServing.Scale(servings))
+	item["quantity"] = math.Round(servings*100) / 100
+	item["unit"] = model.UnitServing
+	return item, nil
+}
+
+// ResolveIngredients 计算每种食材的克数与营养。名称为空的食材会被丢弃。
+func (s *RecipeService) ResolveIngredients(userID int64, ingredients []model.RecipeIngredient) []model.RecipeIngredient {
+	out := make([]model.RecipeIngredient, 0, len(ingredients))
+	names := make([]string, 0, len(ingredients))
+	for _, ingredient := range ingredients {
+		ingredient.Name = strings.TrimSpace(ingredient.Name)
+		if ingredient.Name == "" {
+			continue
+		}
+		ingredient.Resolved = false
+		ingredient.Source = ""
+		ingredient.Nutrition = nil
+		if unit, ok := model.NormalizeUnit(ingredient.Unit); ok {
+			ingredient.Unit = unit
+			if ingredient.Quantity > 0 {
+				ingredient.Grams = roundGrams(ingredient.Quantity * model.UnitGrams(model.NormalizeFoodName(ingredient.Name), unit, 0))
+			}
+		}
+		out = append(out, ingredient)
+		names = append(names, ingredient.Name)
+	}
+
+	foods, err := s.foods.FindByNames(names)
+	if err != nil {
+		foods = map[string]model.Food{}
+	}
+	var dishes map[string]model.Dish
+	if s.dishes.IsEnabled() {
+		if dishes, err = s.dishes.FindByNames(names); err != nil {
+			dishes = nil
+		}
+	}
+	for idx := range out {
+		ingredient := &out[idx]
+		if ingredient.Grams <= 0 {
+			continue
+		}
+		norm := model.NormalizeFoodName(ingredient.Name)
+		var nutrition *model.Nutrition
+		if custom, err := s.customs.Lookup(userID, ingredient.Name); err == nil && custom != nil {
+			nutrition = custom.Nutrition
+		} else if food, ok := foods[norm]; ok {
+			nutrition = food.Nutrition
+		} else if dish, ok := dishes[norm]; ok {
+			nutrition = dish.Nutrition
+		}
+		facts, ok := nutrition.ForGrams(ingredient.Grams)
+		if !ok {
+			continue
+		}
+		ingredient.Resolved = true
+		ingredient.Source = nutrition.Source
+		ingredient.Nutrition = &facts
+	}
+	return out
+}
+
+// ParseIngredientText 解析发现页卡片中的食材文本，没有可识别的用量时只保留名称。
+func ParseIngredientText(text string) model.RecipeIngredient {
+	text = strings.TrimSpace(text)
+	match := ingredientAmountPattern.FindStringSubmatch(text)
+	if match == nil {
+		return model.RecipeIngredient{Name: text}
+	}
+	quantity, unit, ok := model.ParsePortion(match[2])
+	if !ok {
+		return model.RecipeIngredient{Name: text}
+	}
+	return model.RecipeIngredient{Name: strings.TrimSpace(match[1]), Quantity: quantity, Unit: unit}
+}
+
+// computeRecipeNutrition 已解析食材的合计除以份数；可信度按热量加权，并按未解析食材的比例折减。
+func computeRecipeNutrition(ingredients []model.RecipeIngredient, servings float64) *model.Nutrition {
+	if servings <= 0 {
+		return nil
+	}
+	var total model.NutritionFacts
+	var weightedConfidence, energy float64
+	resolved := 0
+	for _, ingredient := range ingredients {
+		if !ingredient.Resolved || ingredient.Nutrition == nil {
+			continue
+		}
+		resolved++
+		total = total.Add(*ingredient.Nutrition)
+		weightedConfidence += ingredient.Nutrition.EnergyKcal * model.DefaultNutritionConfidence(ingredient.Source)
+		energy += ingredient.Nutrition.EnergyKcal
+	}
+	if resolved == 0 || total.IsZero() {
+		return nil
+	}
+	perServing := total.Scale(1 / servings)
+	confidence := model.DefaultNutritionConfidence(model.NutritionSourceRecipe)
+	if energy > 0 {
+		confidence = weightedConfidence / energy
+	}
+	confidence *= float64(resolved) / float64(len(ingredients))
+	return (&model.Nutrition{
+		PerServing:   &perServing,
+		ServingSizeG: recipeServingGrams(ingredients, servings),
+		Confidence:   math.Round(confidence*100) / 100,
+		Source:       model.NutritionSourceRecipe,
+	}).Complete()
+}
+
+// rescaleEstimate 导入时保存的卡片估算（整份菜谱的营养）在份数变化后按比例换算为新的每份营养，
+// 没有卡片估算时返回 nil。
+func rescaleEstimate(previous *model.Recipe, next *model.Recipe) *model.Nutrition {
+	if previous.Nutrition == nil || previous.Nutrition.Source != model.NutritionSourceAIPlan {
+		return nil
+	}
+	perServing, ok := previous.Nutrition.Serving()
+	if !ok || previous.Servings <= 0 {
+		return nil
+	}
+	scaled := perServing.Scale(previous.Servings / next.Servings)
+	estimate := *previous.Nutrition
+	estimate.PerServing = &scaled
+	estimate.Per100g = nil
+	estimate.ServingSizeG = recipeServingGrams(next.Ingredients, next.Servings)
+	return estimate.Complete()
+}
+
+// recipeServingGrams 每份克数，只在所有食材克数已知时给出。
+func recipeServingGrams(ingredients []model.RecipeIngredient, servings float64) float64 {
+	if len(ingredients) == 0 || servings <= 0 {
+		return 0
+	}
+	total := 0.0
+	for _, ingredient := range ingredients {
+		if ingredient.Grams <= 0 {
+			return 0
+		}
+		total += ingredient.Grams
+	}
+	return roundGrams(total / servings)
+}
+
+func allIngredientsResolved(ingredients []model.RecipeIngredient) bool {
+	for _, ingredient := range ingredients {
+		if !ingredient.Resolved {
+			return false
+		}
+	}
+	return len(ingredients) > 0
+}
+
+func validateRecipe(recipe *model.Recipe) error {
+	recipe.Title = strings.TrimSpace(recipe.Title)
+	recipe.Instructions = strings.TrimSpace(recipe.Instructions)
+	switch {
+	case recipe.Title == "":
+		return fmt.Errorf("%w: title is required", ErrRecipeInvalid)
+	case recipe.Servings <= 0 || recipe.Servings > maxRecipeServings:
+		return fmt.Errorf("%w: servings must be between 0 and %d", ErrRecipeInvalid, maxRecipeServings)
+	case len(recipe.Ingredients) == 0:
+		return fmt.Errorf("%w: at least one ingredient is required", ErrRecipeInvalid)
+	case len(recipe.Ingredients) > maxRecipeIngredients:
+		return fmt.Errorf("%w: at most %d ingredients", ErrRecipeInvalid, maxRecipeIngredients)
+	}
+	return nil
+}
+
+func cardIngredientTexts(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		texts := make([]string, 0, len(v))
+		for _, item := range v {
+			if text := strings.TrimSpace(readString(item)); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		return texts
+	case string:
+		return strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == '，' || r == '、' || r == ';' || r == '；' })
+	}
+	return nil
+}
@@ -1,6 +1,11 @@
 package service
 
-import "eatclean/internal/repository"
+import (
+	"encoding/json"
+
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
 
 type SettingsService struct {
 	repo *repository.SettingsRepository
@@ -17,3 +22,23 @@ func (s *SettingsService) Upsert(userID int64, settingsJSON []byte) error {
 func (s *SettingsService) Get(userID int64) ([]byte, error) {
 	return s.repo.Get(userID)
 }
+
+func (s *SettingsService) GetRecord(userID int64) (*model.UserSettings, error) {
+	return s.repo.GetRecord(userID)
+}
+
+// Values 解码后的用户设置，未设置或无法解码时返回空 map。
+func (s *SettingsService) Values(userID int64) (map[string]interface{}, error) {
+	settings := map[string]interface{}{}
+	if s == nil {
+		return settings, nil
+	}
+	record, err := s.GetRecord(userID)
+	if err != nil || record == nil || len(record.Settings) == 0 {
+		return settings, err
+	}
+	if err := json.Unmarshal(record.Settings, &settings); err != nil || settings == nil {
+		return map[string]interface{}{}, nil
+	}
+	return settings, nil
+}
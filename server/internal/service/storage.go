@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"eatclean/internal/repository"
+)
+
+// ossUserRoot 用户上传目录根，每个用户只能读写 users/{owner}/ 下的对象。
+const ossUserRoot = "users"
+
+// maxInlineImageBytes 内联传给模型的单张图片上限。
+const maxInlineImageBytes = 10 << 20
+
+var (
+	// ErrObjectNotOwned 对象不在当前用户目录下，不允许签名或传给模型。
+	ErrObjectNotOwned = errors.New("object does not belong to user")
+	// ErrInvalidUploadCategory 上传分类不在白名单内。
+	ErrInvalidUploadCategory = errors.New("invalid upload category")
+)
+
+// ossUploadCategories 客户端可申请的上传分类。
+var ossUploadCategories = map[string]bool{
+	"chat":       true,
+	"food":       true,
+	"menu":       true,
+	"ingredient": true,
+	"avatar":     true,
+}
+
+var ossUploadExts = map[string]bool{
+	"jpg":  true,
+	"jpeg": true,
+	"png":  true,
+	"heic": true,
+	"webp": true,
+}
+
+// UploadGrant 一次直传授权：服务端生成的对象名、每个对象的预签名上传请求，
+// 以及（OSS 配置了 RoleArn 时）只能写入用户目录的 STS 凭证。
+type UploadGrant struct {
+	Backend      string
+	UploadPrefix string
+	Keys         []string
+	Uploads      []PresignedUpload
+	Credentials  *StorageCredentials
+}
+
+// StorageService 按用户目录约束对象访问，具体读写交给 ObjectStore。
+type StorageService struct {
+	store  ObjectStore
+	users  *repository.UserRepository
+	refs   *repository.ImageRetentionRepository
+	legacy []string
+	inline bool
+}
+
+// NewStorageService inlineImages 取值见 storage.inline_images，legacyPrefixes 见 storage.legacy_prefixes。
+func NewStorageService(store ObjectStore, users *repository.UserRepository, refs *repository.ImageRetentionRepository, inlineImages string, legacyPrefixes []string) *StorageService {
+	return &StorageService{
+		store:  store,
+		users:  users,
+		refs:   refs,
+		legacy: legacyPrefixes,
+		inline: shouldInlineImages(inlineImages, store),
+	}
+}
+
+// Store 返回底层存储后端。
+func (s *StorageService) Store() ObjectStore {
+	if s == nil {
+		return nil
+	}
+	return s.store
+}
+
+// Backend 当前存储后端名称。
+func (s *StorageService) Backend() string {
+	if s == nil || s.store == nil {
+		return ""
+	}
+	return s.store.Backend()
+}
+
+// UserPrefix 用户上传目录：有 unionid 时为 users/{unionid}/，否则为 users/u{id}/。
+func (s *StorageService) UserPrefix(userID int64) (string, error) {
+	prefixes, err := s.ownerPrefixes(userID)
+	if err != nil {
+		return "", err
+	}
+	return prefixes[0], nil
+}
+
+// ownerPrefixes 用户拥有的全部目录。后绑定 unionid 的用户，之前 users/u{id}/ 下的图片仍归其所有。
+func (s *StorageService) ownerPrefixes(userID int64) ([]string, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user id")
+	}
+	legacy := fmt.Sprintf("%s/u%d/", ossUserRoot, userID)
+	if s.users == nil {
+		return []string{legacy}, nil
+	}
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+	if user.UnionID != nil {
+		unionID := strings.TrimSpace(*user.UnionID)
+		if unionID != "" && !strings.ContainsAny(unionID, "/\\") {
+			return []string{ossUserRoot + "/" + unionID + "/", legacy}, nil
+		}
+	}
+	return []string{legacy}, nil
+}
+
+// GrantUpload 为一批待上传文件生成对象名与直传授权。
+func (s *StorageService) GrantUpload(ctx context.Context, userID int64, category string, exts []string, ttl time.Duration) (*UploadGrant, error) {
+	if s == nil || s.store == nil {
+		return nil, ErrStorageNotConfigured
+	}
+	prefix, err := s.UserPrefix(userID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.NewUploadKeys(userID, category, exts)
+	if err != nil {
+		return nil, err
+	}
+	grant := &UploadGrant{Backend: s.store.Backend(), UploadPrefix: prefix, Keys: keys}
+	for _, key := range keys {
+		upload, err := s.store.PresignUpload(key, mime.TypeByExtension(path.Ext(key)), ttl)
+		if err != nil {
+			return nil, err
+		}
+		grant.Uploads = append(grant.Uploads, *upload)
+	}
+	if store, ok := s.store.(*ossObjectStore); ok && store.stsEnabled() {
+		credentials, err := store.AssumeUserRole(ctx, userID, prefix)
+		if err != nil {
+			return nil, err
+		}
+		grant.Credentials = credentials
+	}
+	return grant, nil
+}
+
+// NewUploadKeys 由服务端生成上传对象名：users/{owner}/{category}/{yyyy/MM/dd}/{随机}.{ext}，
+// 每个 ext 对应一个 key。客户端必须使用返回的 key 上传。
+func (s *StorageService) NewUploadKeys(userID int64, category string, exts []string) ([]string, error) {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if !ossUploadCategories[category] {
+		return nil, ErrInvalidUploadCategory
+	}
+	prefix, err := s.UserPrefix(userID)
+	if err != nil {
+		return nil, err
+	}
+	datePath := time.Now().Format("2006/01/02")
+	keys := make([]string, 0, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if !ossUploadExts[ext] {
+			ext = "jpg"
+		}
+		random := make([]byte, 12)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		keys = append(keys, fmt.Sprintf("%s%s/%s/%s.%s", prefix, category, datePath, hex.EncodeToString(random), ext))
+	}
+	return keys, nil
+}
+
+// CheckOwnership 解析对象名并确认其位于用户目录下（或是该用户记录引用的旧对象），返回对象名。
+func (s *StorageService) CheckOwnership(userID int64, rawURL string) (string, error) {
+	if s == nil || s.store == nil {
+		return "", ErrStorageNotConfigured
+	}
+	objectKey, err := s.store.KeyFromURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(objectKey, "..") || strings.Contains(objectKey, "\\") || path.Clean(objectKey) != objectKey {
+		return "", ErrObjectNotOwned
+	}
+	prefixes, err := s.ownerPrefixes(userID)
+	if err != nil {
+		return "", err
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(objectKey, prefix) && len(objectKey) > len(prefix) {
+			return objectKey, nil
+		}
+	}
+	legacy, err := s.legacyObject(userID, objectKey)
+	if err != nil {
+		return "", err
+	}
+	if !legacy {
+		return "", ErrObjectNotOwned
+	}
+	return objectKey, nil
+}
+
+// legacyObject 按用户目录存放之前上传、位于 storage.legacy_prefixes 下的对象，
+// 只有该用户自己的记录引用过时才视为其所有，其他用户无法借此访问。
+func (s *StorageService) legacyObject(userID int64, objectKey string) (bool, error) {
+	if s.refs == nil || strings.HasPrefix(objectKey, ossUserRoot+"/") {
+		return false, nil
+	}
+	matched := false
+	for _, prefix := range s.legacy {
+		if prefix == "*" || (prefix != "" && strings.HasPrefix(objectKey, prefix)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false, nil
+	}
+	return s.refs.UserReferencesImage(userID, objectKey)
+}
+
+// CheckOwnershipAll 逐个校验图片归属，用于不签名直接保存或转发的场景。
+func (s *StorageService) CheckOwnershipAll(userID int64, urls []string) error {
+	for _, raw := range urls {
+		if _, err := s.CheckOwnership(userID, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SignURLs 为用户自己的对象签名，任意一个不属于该用户时返回 ErrObjectNotOwned。
+func (s *StorageService) SignURLs(userID int64, urls []string, ttl time.Duration) ([]string, error) {
+	if len(urls) == 0 {
+		return []string{}, nil
+	}
+	result := make([]string, 0, len(urls))
+	for _, raw := range urls {
+		signed, err := s.SignURL(userID, raw, ttl)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, signed)
+	}
+	return result, nil
+}
+
+func (s *StorageService) SignURL(userID int64, rawURL string, ttl time.Duration) (string, error) {
+	objectKey, err := s.CheckOwnership(userID, rawURL)
+	if err != nil {
+		return "", err
+	}
+	return s.store.PresignDownload(objectKey, ttl)
+}
+
+// ModelImageURLs 生成传给视觉模型的图片地址：模型能访问存储时为签名 URL，否则为内联 base64 data URL。
+func (s *StorageService) ModelImageURLs(ctx context.Context, userID int64, urls []string, ttl time.Duration) ([]string, error) {
+	if s == nil || !s.inline {
+		return s.SignURLs(userID, urls, ttl)
+	}
+	result := make([]string, 0, len(urls))
+	for _, raw := range urls {
+		objectKey, err := s.CheckOwnership(userID, raw)
+		if err != nil {
+			return nil, err
+		}
+		dataURL, err := s.inlineObject(ctx, objectKey)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, dataURL)
+	}
+	return result, nil
+}
+
+func (s *StorageService) inlineObject(ctx context.Context, objectKey string) (string, error) {
+	body, err := s.store.Open(ctx, objectKey)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxInlineImageBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxInlineImageBytes {
+		return "", fmt.Errorf("image %s exceeds inline limit", objectKey)
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = "image/jpeg"
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -4,164 +4,73 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"math"
-	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
+	"text/template"
 	"time"
 
-	"eatclean/internal/config"
-
 	"github.com/labstack/gommon/log"
 )
 
-var (
-	systemPromptOnce     sync.Once
-	systemPromptTemplate string
-	systemPromptErr      error
-
-	menuPromptOnce     sync.Once
-	menuPromptTemplate string
-	menuPromptErr      error
-
-	foodPromptOnce     sync.Once
-	foodPromptTemplate string
-	foodPromptErr      error
-
-	ingredientPromptOnce     sync.Once
-	ingredientPromptTemplate string
-	ingredientPromptErr      error
-
-	discoverPlanOnce     sync.Once
-	discoverPlanTemplate string
-	discoverPlanErr      error
-
-	discoverReplaceOnce     sync.Once
-	discoverReplaceTemplate string
-	discoverReplaceErr      error
-
-	chatPromptOnce     sync.Once
-	chatPromptTemplate string
-	chatPromptErr      error
-)
-
-func LoadMenuScanPromptTemplate() (string, error) {
-	menuPromptOnce.Do(func() {
-		cfg, err := config.Load()
-		if err != nil {
-			menuPromptErr = err
-			return
-		}
-		path := strings.TrimSpace(cfg.Prompts.MenuScanPath)
-		data, err := os.ReadFile(path)
-		if err != nil {
-			menuPromptErr = err
-			return
-		}
-		menuPromptTemplate = string(data)
-	})
-	return menuPromptTemplate, menuPromptErr
+func BuildSystemPrompt(template string, settings map[string]interface{}, overrides map[string]string) string {
+	return BuildPromptParts(template, settings, overrides)
 }
 
-func LoadFoodScanPromptTemplate() (string, error) {
-	foodPromptOnce.Do(func() {
-		cfg, err := config.Load()
-		if err != nil {
-			foodPromptErr = err
-			return
-		}
-		path := strings.TrimSpace(cfg.Prompts.FoodScanPath)
-		data, err := os.ReadFile(path)
-		if err != nil {
-			foodPromptErr = err
-			return
-		}
-		foodPromptTemplate = string(data)
-	})
-	return foodPromptTemplate, foodPromptErr
-}
+// BuildPromptParts returns system/user parts by splitting template with "#####"
+// If no delimiter, systemPart = rendered template, userPart = "".
+func BuildPromptParts(myTemplate string, settings map[string]interface{}, overrides map[string]string) string {
+	values := buildPromptValues(settings, overrides)
 
-func LoadIngredientScanPromptTemplate() (string, error) {
-	ingredientPromptOnce.Do(func() {
-		cfg, err := config.Load()
-		if err != nil {
-			ingredientPromptErr = err
-			return
-		}
-		path := strings.TrimSpace(cfg.Prompts.IngredientScanPath)
-		data, err := os.ReadFile(path)
-		if err != nil {
-			ingredientPromptErr = err
-			return
-		}
-		ingredientPromptTemplate = string(data)
-	})
-	return ingredientPromptTemplate, ingredientPromptErr
-}
+	t, err := template.New("prompt").Parse(myTemplate)
+	if err != nil {
+		log.Errorf("Failed to parse template: %v", err)
+		return ""
+	}
 
-func LoadDiscoverPlanPromptTemplate() (string, error) {
-	discoverPlanOnce.Do(func() {
-		cfg, err := config.Load()
-		if err != nil {
-			discoverPlanErr = err
-			return
-		}
-		path := strings.TrimSpace(cfg.Prompts.DiscoverPlanPath)
-		data, err := os.ReadFile(path)
-		if err != nil {
-			discoverPlanErr = err
-			return
-		}
-		discoverPlanTemplate = string(data)
-	})
-	return discoverPlanTemplate, discoverPlanErr
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, values); err != nil {
+		log.Errorf("Failed to execute template: %v", err)
+		return ""
+	}
+	log.Printf("System Prompt: %s", buf.String())
+	return strings.TrimSpace(buf.String())
 }
 
-func LoadDiscoverReplacePromptTemplate() (string, error) {
-	discoverReplaceOnce.Do(func() {
-		cfg, err := config.Load()
-		if err != nil {
-			discoverReplaceErr = err
-			return
-		}
-		path := strings.TrimSpace(cfg.Prompts.DiscoverReplacePath)
-		data, err := os.ReadFile(path)
-		if err != nil {
-			discoverReplaceErr = err
-			return
-		}
-		discoverReplaceTemplate = string(data)
-	})
-	return discoverReplaceTemplate, discoverReplaceErr
-}
+var promptMissingKeyPattern = regexp.MustCompile(`map has no entry for key "([^"]+)"`)
 
-func LoadChatPromptTemplate() (string, error) {
-	chatPromptOnce.Do(func() {
-		cfg, err := config.Load()
-		if err != nil {
-			chatPromptErr = err
-			return
+// renderPromptTemplate 渲染模板并返回未提供的变量名，缺失变量按空字符串渲染。
+func renderPromptTemplate(content string, values map[string]string) (string, []string, error) {
+	t, err := template.New("prompt").Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", nil, err
+	}
+	filled := make(map[string]string, len(values))
+	for key, value := range values {
+		filled[key] = value
+	}
+	var missing []string
+	for i := 0; i < 64; i++ {
+		var buf bytes.Buffer
+		err := t.Execute(&buf, filled)
+		if err == nil {
+			sort.Strings(missing)
+			return strings.TrimSpace(buf.String()), missing, nil
 		}
-		path := strings.TrimSpace(cfg.Prompts.ChatPromptPath)
-		data, err := os.ReadFile(path)
-		if err != nil {
-			chatPromptErr = err
-			return
+		match := promptMissingKeyPattern.FindStringSubmatch(err.Error())
+		if match == nil {
+			return "", missing, err
 		}
-		chatPromptTemplate = string(data)
-	})
-	return chatPromptTemplate, chatPromptErr
-}
-
-func BuildSystemPrompt(template string, settings map[string]interface{}, overrides map[string]string) string {
-	return BuildPromptParts(template, settings, overrides)
+		missing = append(missing, match[1])
+		filled[match[1]] = ""
+	}
+	return "", missing, fmt.Errorf("too many missing fields")
 }
 
-// BuildPromptParts returns system/user parts by splitting template with "#####"
-// If no delimiter, systemPart = rendered template, userPart = "".
-func BuildPromptParts(myTemplate string, settings map[string]interface{}, overrides map[string]string) string {
+// buildPromptValues 把用户设置与上下文字段展开为模板变量。
+func buildPromptValues(settings map[string]interface{}, overrides map[string]string) map[string]string {
 	values := make(map[string]string, len(settings)+8)
 	for key, value := range settings {
 		values[key] = stringifyValue(value)
@@ -179,11 +88,16 @@ func BuildPromptParts(myTemplate string, settings map[string]interface{}, overri
 			values["bmi"] = fmt.Sprintf("%.1f", bmi)
 		}
 	}
+	if targets, err := ComputeNutritionTargets(settings, time.Now()); err == nil {
+		values["bmr"] = strconv.Itoa(targets.BMR)
+		values["tdee"] = strconv.Itoa(targets.TDEE)
+		values["recommended_calorie_target"] = strconv.Itoa(targets.CalorieTarget)
+	}
 
 	values["current_time"] = time.Now().Format("2006-01-02 15:04:05")
 
 	if _, ok := values["is_training_day"]; !ok {
-		isTraining, isCheat := computeDayFlags(settings)
+		isTraining, isCheat := ComputeDayFlags(settings, time.Now())
 		values["is_training_day"] = formatYesNo(isTraining)
 		values["is_cheat_day"] = formatYesNo(isCheat)
 	}
@@ -191,20 +105,7 @@ func BuildPromptParts(myTemplate string, settings map[string]interface{}, overri
 	for key, value := range overrides {
 		values[key] = value
 	}
-
-	t, err := template.New("prompt").Parse(myTemplate)
-	if err != nil {
-		log.Errorf("Failed to parse template: %v", err)
-		return ""
-	}
-
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, values); err != nil {
-		log.Errorf("Failed to execute template: %v", err)
-		return ""
-	}
-	log.Printf("System Prompt: %s", buf.String())
-	return strings.TrimSpace(buf.String())
+	return values
 }
 
 func stringifyValue(value interface{}) string {
@@ -274,13 +175,14 @@ func toFloat(value interface{}) (float64, bool) {
 	return 0, false
 }
 
-func computeDayFlags(settings map[string]interface{}) (bool, bool) {
-	now := time.Now()
-	weekday := int(now.Weekday())
+// ComputeDayFlags 按用户设置判断指定日期是否训练日/放纵日，放纵日优先。
+// 优先级：每月指定日期 > 每周指定星期 > 每周训练天数/放纵频率。
+func ComputeDayFlags(settings map[string]interface{}, date time.Time) (bool, bool) {
+	weekday := int(date.Weekday())
 	if weekday == 0 {
 		weekday = 7
 	}
-	dayOfMonth := now.Day()
+	dayOfMonth := date.Day()
 
 	trainingDays := readIntSlice(settings["monthly_training_days"])
 	cheatDays := readIntSlice(settings["monthly_cheat_days"])
@@ -293,6 +195,17 @@ func computeDayFlags(settings map[string]interface{}) (bool, bool) {
 		return isTraining, isCheat
 	}
 
+	weeklyTrainingList := readIntSlice(settings["weekly_training_days_list"])
+	weeklyCheatList := readIntSlice(settings["weekly_cheat_days_list"])
+	if len(weeklyTrainingList) > 0 || len(weeklyCheatList) > 0 {
+		isTraining := containsInt(weeklyTrainingList, weekday)
+		isCheat := containsInt(weeklyCheatList, weekday)
+		if isCheat {
+			isTraining = false
+		}
+		return isTraining, isCheat
+	}
+
 	weeklyTraining := clampInt(readIntValue(settings["weekly_training_days"]), 0, 7)
 	cheatFrequency := clampInt(readIntValue(settings["cheat_frequency"]), 0, 7)
 
@@ -304,6 +217,30 @@ func computeDayFlags(settings map[string]interface{}) (bool, bool) {
 	return isTraining, isCheat
 }
 
+func DayTypeLabel(isTraining, isCheat bool) string {
+	if isTraining {
+		return "训练日"
+	}
+	if isCheat {
+		return "放纵日"
+	}
+	return "正常日"
+}
+
+func TimeOfDayLabel(value time.Time) string {
+	hour := value.Hour()
+	switch {
+	case hour >= 5 && hour < 11:
+		return "早上"
+	case hour >= 11 && hour < 14:
+		return "中午"
+	case hour >= 14 && hour < 18:
+		return "下午"
+	default:
+		return "晚上"
+	}
+}
+
 func readIntValue(value interface{}) int {
 	if value == nil {
 		return 0
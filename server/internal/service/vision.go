@@ -1,42 +1,31 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"eatclean/internal/config"
 )
 
 type VisionService struct {
-	apiKey  string
-	baseURL string
-	model   string
-	client  *http.Client
+	ai *aiClient
 }
 
-func NewVisionService(cfg *config.QwenConfig) *VisionService {
-	if cfg == nil {
-		return &VisionService{}
-	}
-	return &VisionService{
-		apiKey:  cfg.APIKey,
-		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
-		model:   cfg.Model,
-		client: &http.Client{
-			Timeout: 45 * time.Second,
-		},
-	}
+func NewVisionService(cfg *config.QwenConfig, transport *AITransport) *VisionService {
+	return &VisionService{ai: newAIClient(cfg, transport)}
 }
 
 func (s *VisionService) IsEnabled() bool {
-	return s.apiKey != "" && s.baseURL != "" && s.model != ""
+	return s.ai.isEnabled()
+}
+
+// RouteAvailable 路由熔断时返回 false，调用方可直接返回降级结果。
+func (s *VisionService) RouteAvailable(route string) bool {
+	return s.ai.routeAvailable(route)
 }
 
 func (s *VisionService) ExtractMenuText(ctx context.Context, images [][]byte) (string, error) {
@@ -44,7 +33,7 @@ func (s *VisionService) ExtractMenuText(ctx context.Context, images [][]byte) (s
 		return "", errors.New("vision service not configured")
 	}
 	prompt := "请识别菜单图片中的菜品名称，只输出菜名列表，每行一个，不要添加多余说明。"
-	return s.callVision(ctx, images, prompt, "")
+	return s.callVision(ctx, AIRouteMenuScan, images, prompt, "")
 }
 
 func (s *VisionService) IdentifyFoodItems(ctx context.Context, images [][]byte) (string, error) {
@@ -52,7 +41,7 @@ func (s *VisionService) IdentifyFoodItems(ctx context.Context, images [][]byte)
 		return "", errors.New("vision service not configured")
 	}
 	prompt := "请识别照片中的食物名称，只输出食物名称列表，每行一个，不要添加多余说明。"
-	return s.callVision(ctx, images, prompt, "")
+	return s.callVision(ctx, AIRouteFoodScan, images, prompt, "")
 }
 
 func (s *VisionService) ExtractMenuTextFromURLs(ctx context.Context, urls []string) (string, error) {
@@ -60,7 +49,7 @@ func (s *VisionService) ExtractMenuTextFromURLs(ctx context.Context, urls []stri
 		return "", errors.New("vision service not configured")
 	}
 	prompt := "请识别菜单图片中的菜品名称，只输出菜名列表，每行一个，不要添加多余说明。"
-	return s.callVisionWithURLs(ctx, urls, prompt, "")
+	return s.callVisionWithURLs(ctx, AIRouteMenuScan, urls, prompt, "")
 }
 
 func (s *VisionService) IdentifyFoodItemsFromURLs(ctx context.Context, urls []string) (string, error) {
@@ -68,12 +57,13 @@ func (s *VisionService) IdentifyFoodItemsFromURLs(ctx context.Context, urls []st
 		return "", errors.New("vision service not configured")
 	}
 	prompt := "请识别照片中的食物名称，只输出食物名称列表，每行一个，不要添加多余说明。"
-	return s.callVisionWithURLs(ctx, urls, prompt, "")
+	return s.callVisionWithURLs(ctx, AIRouteFoodScan, urls, prompt, "")
 }
 
-func (s *VisionService) AnalyzeMenuFromURLs(ctx context.Context, urls []string, systemPrompt string) (string, error) {
+// AnalyzeMenuFromURLs 返回解码并校验后的结构化结果与模型原始回复。
+func (s *VisionService) AnalyzeMenuFromURLs(ctx context.Context, urls []string, systemPrompt string) (*AIDishAnalysis, string, error) {
 	if !s.IsEnabled() {
-		return "", errors.New("vision service not configured")
+		return nil, "", errors.New("vision service not configured")
 	}
 	prompt := strings.TrimSpace(`
 你将收到一到多张菜单照片。请结合系统模板中的用户画像、目标与约束，输出详尽建议，并生成可直接用于“菜单卡片”的数据。
@@ -97,6 +87,11 @@ func (s *VisionService) AnalyzeMenuFromURLs(ctx context.Context, urls []string,
       "protein": 0,
       "carbs": 0,
       "fat": 0,
+      "sodium_mg": 0,
+      "sugar_g": 0,
+      "added_sugar_g": 0,
+      "fiber_g": 0,
+      "saturated_fat_g": 0,
       "tag": "推荐/高蛋白/低脂/谨慎等标签",
       "recommended": true,
       "components": ["主要食材1", "主要食材2"],
@@ -108,16 +103,23 @@ func (s *VisionService) AnalyzeMenuFromURLs(ctx context.Context, urls []string,
 
 规则：
 - kcal/protein/carbs/fat 必须为整数。
+- sodium_mg 单位为毫克，sugar_g/added_sugar_g/fiber_g/saturated_fat_g 单位为克，与 kcal 使用相同份量，可保留一位小数；added_sugar_g 只计烹饪或加工中额外添加的糖，无法估算时填 0。
 - scoreColor 使用 8 位 ARGB 十六进制字符串（不带 #），推荐可用 ff13ec5b，谨慎可用 fffd166。
 - 如果菜名不确定请合理估算，但不要留空。
 - 已经是菜单扫描场景，不要再建议 action=xiangji；优先建议 action=discover / action=record_meal / action=ai_replace。
 `)
-	return s.callVisionWithURLs(ctx, urls, prompt, systemPrompt)
+	analysis := &AIDishAnalysis{}
+	raw, err := s.callVisionStructured(ctx, AIRouteMenuScan, urls, prompt, systemPrompt, analysis)
+	if err != nil {
+		return nil, raw, err
+	}
+	return analysis, raw, nil
 }
 
-func (s *VisionService) AnalyzeFoodFromURLs(ctx context.Context, urls []string, systemPrompt string) (string, error) {
+// AnalyzeFoodFromURLs 返回解码并校验后的结构化结果与模型原始回复。
+func (s *VisionService) AnalyzeFoodFromURLs(ctx context.Context, urls []string, systemPrompt string) (*AIDishAnalysis, string, error) {
 	if !s.IsEnabled() {
-		return "", errors.New("vision service not configured")
+		return nil, "", errors.New("vision service not configured")
 	}
 	prompt := strings.TrimSpace(`
 你将收到一到多张食物照片。请结合系统模板中的用户画像、目标与约束，输出详尽建议，并生成餐品卡片数据。
@@ -140,6 +142,13 @@ func (s *VisionService) AnalyzeFoodFromURLs(ctx context.Context, urls []string,
       "protein": 0,
       "carbs": 0,
       "fat": 0,
+      "sodium_mg": 0,
+      "sugar_g": 0,
+      "added_sugar_g": 0,
+      "fiber_g": 0,
+      "saturated_fat_g": 0,
+      "weight_g": 0,
+      "portion": "1碗",
       "tag": "识别结果",
       "recommended": true,
       "components": ["主要食材1", "主要食材2"],
@@ -150,16 +159,24 @@ func (s *VisionService) AnalyzeFoodFromURLs(ctx context.Context, urls []string,
 }
 
 规则：
-- kcal/protein/carbs/fat 必须为整数。
+- kcal/protein/carbs/fat 必须为整数，对应照片中的实际份量。
+- sodium_mg 单位为毫克，sugar_g/added_sugar_g/fiber_g/saturated_fat_g 单位为克，与 kcal 使用相同份量，可保留一位小数；added_sugar_g 只计烹饪或加工中额外添加的糖，无法估算时填 0。
+- weight_g 为照片中该菜品的估算重量（克，整数）；portion 用“数量+单位”描述份量，单位限 g/ml/碗/个/片/份/勺，如“1碗”“2个”“半份”。
 - scoreColor 使用 8 位 ARGB 十六进制字符串（不带 #），推荐可用 ff13ec5b，谨慎可用 fffd166。
 - 如果不确定请合理估算，但不要留空。
 `)
-	return s.callVisionWithURLs(ctx, urls, prompt, systemPrompt)
+	analysis := &AIDishAnalysis{}
+	raw, err := s.callVisionStructured(ctx, AIRouteFoodScan, urls, prompt, systemPrompt, analysis)
+	if err != nil {
+		return nil, raw, err
+	}
+	return analysis, raw, nil
 }
 
-func (s *VisionService) AnalyzeIngredientFromURLs(ctx context.Context, urls []string, systemPrompt string) (string, error) {
+// AnalyzeIngredientFromURLs 返回解码并校验后的结构化结果与模型原始回复。
+func (s *VisionService) AnalyzeIngredientFromURLs(ctx context.Context, urls []string, systemPrompt string) (*AIDishAnalysis, string, error) {
 	if !s.IsEnabled() {
-		return "", errors.New("vision service not configured")
+		return nil, "", errors.New("vision service not configured")
 	}
 	prompt := strings.TrimSpace(`
 你将收到一到多张配料表照片（可能包含营养成分表、配料列表、致敏物提示）。请结合系统模板中的用户画像、目标与限制，输出详尽分析并给出是否推荐食用结论。
@@ -194,6 +211,11 @@ func (s *VisionService) AnalyzeIngredientFromURLs(ctx context.Context, urls []st
       "protein": 0,
       "carbs": 0,
       "fat": 0,
+      "sodium_mg": 0,
+      "sugar_g": 0,
+      "added_sugar_g": 0,
+      "fiber_g": 0,
+      "saturated_fat_g": 0,
       "tag": "配料分析",
       "recommended": true,
       "components": ["主要配料1", "主要配料2"],
@@ -205,14 +227,84 @@ func (s *VisionService) AnalyzeIngredientFromURLs(ctx context.Context, urls []st
 
 规则：
 - kcal/protein/carbs/fat 必须为整数；尽量给出每 100g 估算值。
+- sodium_mg 单位为毫克，sugar_g/added_sugar_g/fiber_g/saturated_fat_g 单位为克，与 kcal 使用相同份量，可保留一位小数；added_sugar_g 只计烹饪或加工中额外添加的糖，无法估算时填 0。
 - 严格识别过敏原（如乳制品、坚果、甲壳类、大豆、麸质等）并体现在 risk_alerts。
 - 若存在反式脂肪酸、过高钠、高糖浆、代糖争议等，务必指出。
 - 输出字段必须完整，不得省略。
 `)
-	return s.callVisionWithURLs(ctx, urls, prompt, systemPrompt)
+	analysis := &AIDishAnalysis{}
+	raw, err := s.callVisionStructured(ctx, AIRouteIngredientScan, urls, prompt, systemPrompt, analysis)
+	if err != nil {
+		return nil, raw, err
+	}
+	return analysis, raw, nil
 }
 
-func (s *VisionService) callVision(ctx context.Context, images [][]byte, prompt string, systemPrompt string) (string, error) {
+// AnalyzeNutritionLabelFromURLs 逐行读取包装上的营养成分表，返回原样读数与模型原始回复；
+// 单位换算、NRV% 补全与能量一致性校验见 ParseNutritionLabel。
+func (s *VisionService) AnalyzeNutritionLabelFromURLs(ctx context.Context, urls []string) (*AINutritionLabel, string, error) {
+	if !s.IsEnabled() {
+		return nil, "", errors.New("vision service not configured")
+	}
+	prompt := strings.TrimSpace(`
+你将收到包装食品的营养成分表（营养成分表 / Nutrition Facts）照片。请逐行抄录表格中的数值，不要估算或换算。
+
+输出要求：
+- 只输出 JSON 对象，不要输出 Markdown 或多余解释。
+- 字段格式如下：
+{
+  "product_name": "包装上的商品名称，看不到则为空字符串",
+  "basis": "100g",
+  "serving_size": 0,
+  "serving_unit": "g",
+  "items": [
+    {"name": "能量", "value": 1500, "unit": "kJ", "nrv_percent": 18},
+    {"name": "蛋白质", "value": 6.5, "unit": "g", "nrv_percent": 11},
+    {"name": "脂肪", "value": 15.2, "unit": "g", "nrv_percent": 25},
+    {"name": "碳水化合物", "value": 60.1, "unit": "g", "nrv_percent": 20},
+    {"name": "钠", "value": 420, "unit": "mg", "nrv_percent": 21}
+  ]
+}
+
+规则：
+- basis 为表头的计量基准：每100克 填 100g，每100毫升 填 100ml，每份 填 serving。
+- 表格同时有每100克与每份两列时，只抄录每100克一列，并在 serving_size 填每份的克数或毫升数。
+- basis 为 serving 时 serving_size 必须填写；没有份量信息时 serving_size 填 0。
+- items 按表格顺序列出所有行（含饱和脂肪、糖、膳食纤维等），name 与 unit 照抄表格，value 与 nrv_percent 为数字，没有 NRV% 的行填 0。
+- 能量行保留原单位（kJ 或 kcal），不要自行换算。
+`)
+	label := &AINutritionLabel{}
+	raw, err := s.callVisionStructured(ctx, AIRouteLabelScan, urls, prompt, "", label)
+	if err != nil {
+		return nil, raw, err
+	}
+	return label, raw, nil
+}
+
+// ClassifyImages 轻量相关性预检：判断每张图片是食物、菜单、配料表还是其他内容。
+func (s *VisionService) ClassifyImages(ctx context.Context, urls []string) (*AIImageCheck, error) {
+	if !s.IsEnabled() {
+		return nil, errors.New("vision service not configured")
+	}
+	prompt := strings.TrimSpace(`
+判断每张图片的主要内容，按输入顺序输出，只输出 JSON 对象：
+{"images": [{"category": "food", "confidence": 0.9, "reason": "简短理由"}]}
+
+category 取值：
+- food：菜品、饭菜、零食、饮料等可食用的食物实物
+- menu：餐厅菜单、价目表、外卖菜单截图
+- ingredient_label：包装食品的配料表或营养成分表
+- other：与饮食无关的内容（人像、风景、文档、纯色、无法辨认等）
+confidence 为 0-1 的小数。
+`)
+	check := &AIImageCheck{}
+	if _, err := s.callVisionStructured(ctx, AIRouteImagePrecheck, urls, prompt, "", check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+func (s *VisionService) callVision(ctx context.Context, route string, images [][]byte, prompt string, systemPrompt string) (string, error) {
 	if len(images) == 0 {
 		return "", errors.New("no images provided")
 	}
@@ -221,6 +313,7 @@ func (s *VisionService) callVision(ctx context.Context, images [][]byte, prompt
 		if len(data) == 0 {
 			continue
 		}
+		data = modelImageBytes(data)
 		mime := http.DetectContentType(data)
 		if !strings.HasPrefix(mime, "image/") {
 			mime = "image/jpeg"
@@ -238,13 +331,34 @@ func (s *VisionService) callVision(ctx context.Context, images [][]byte, prompt
 		"text": prompt,
 	})
 
-	return s.callVisionWithContent(ctx, content, systemPrompt)
+	return s.callVisionWithContent(ctx, route, content, systemPrompt)
 }
 
-func (s *VisionService) callVisionWithURLs(ctx context.Context, urls []string, prompt string, systemPrompt string) (string, error) {
+func (s *VisionService) callVisionWithURLs(ctx context.Context, route string, urls []string, prompt string, systemPrompt string) (string, error) {
 	if len(urls) == 0 {
 		return "", errors.New("no image urls provided")
 	}
+	content := buildImageURLContent(urls)
+	content = append(content, map[string]interface{}{
+		"type": "text",
+		"text": prompt,
+	})
+	return s.callVisionWithContent(ctx, route, content, systemPrompt)
+}
+
+func (s *VisionService) callVisionStructured(ctx context.Context, route string, urls []string, prompt string, systemPrompt string, out AIOutput) (string, error) {
+	content := buildImageURLContent(urls)
+	if len(content) == 0 {
+		return "", errors.New("no image urls provided")
+	}
+	content = append(content, map[string]interface{}{
+		"type": "text",
+		"text": prompt,
+	})
+	return s.ai.completeStructured(ctx, route, buildVisionMessages(content, systemPrompt), out)
+}
+
+func buildImageURLContent(urls []string) []map[string]interface{} {
 	content := make([]map[string]interface{}, 0, len(urls)+1)
 	for _, url := range urls {
 		if strings.TrimSpace(url) == "" {
@@ -257,14 +371,10 @@ func (s *VisionService) callVisionWithURLs(ctx context.Context, urls []string, p
 			},
 		})
 	}
-	content = append(content, map[string]interface{}{
-		"type": "text",
-		"text": prompt,
-	})
-	return s.callVisionWithContent(ctx, content, systemPrompt)
+	return content
 }
 
-func (s *VisionService) callVisionWithContent(ctx context.Context, content []map[string]interface{}, systemPrompt string) (string, error) {
+func buildVisionMessages(content []map[string]interface{}, systemPrompt string) []map[string]interface{} {
 	messages := make([]map[string]interface{}, 0, 2)
 	if strings.TrimSpace(systemPrompt) != "" {
 		messages = append(messages, map[string]interface{}{
@@ -272,72 +382,12 @@ func (s *VisionService) callVisionWithContent(ctx context.Context, content []map
 			"content": systemPrompt,
 		})
 	}
-	messages = append(messages, map[string]interface{}{
+	return append(messages, map[string]interface{}{
 		"role":    "user",
 		"content": content,
 	})
-	reqBody := map[string]interface{}{
-		"model":    s.model,
-		"messages": messages,
-	}
-	payload, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", err
-	}
-
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodPost,
-		fmt.Sprintf("%s/chat/completions", s.baseURL),
-		bytes.NewReader(payload),
-	)
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Authorization", "Bearer "+s.apiKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	var decoded struct {
-		Choices []struct {
-			Message struct {
-				Content interface{} `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Error *struct {
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
-		return "", err
-	}
-	if decoded.Error != nil {
-		return "", errors.New(decoded.Error.Message)
-	}
-	if len(decoded.Choices) == 0 {
-		return "", errors.New("empty response")
-	}
+}
 
-	contentValue := decoded.Choices[0].Message.Content
-	switch value := contentValue.(type) {
-	case string:
-		return strings.TrimSpace(value), nil
-	case []interface{}:
-		var parts []string
-		for _, item := range value {
-			if itemMap, ok := item.(map[string]interface{}); ok {
-				if text, ok := itemMap["text"].(string); ok {
-					parts = append(parts, text)
-				}
-			}
-		}
-		return strings.TrimSpace(strings.Join(parts, "\n")), nil
-	default:
-		return "", errors.New("unexpected response format")
-	}
+func (s *VisionService) callVisionWithContent(ctx context.Context, route string, content []map[string]interface{}, systemPrompt string) (string, error) {
+	return s.ai.complete(ctx, route, buildVisionMessages(content, systemPrompt))
 }
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"eatclean/internal/config"
+	"eatclean/internal/model"
+	"eatclean/internal/repository"
+)
+
+// visionCacheMetrics 识别结果缓存统计：<route>.hit / <route>.near_hit / <route>.miss / <route>.error
+var visionCacheMetrics = expvar.NewMap("vision_cache")
+
+// visionCacheCandidates 近似匹配时最多比较的历史缓存条数
+const visionCacheCandidates = 50
+
+// VisionCacheKey 缓存键：图片集合（内容哈希与感知哈希）、提示词版本与用户上下文指纹。
+type VisionCacheKey struct {
+	Route         string
+	ContentHashes []string
+	PHashes       []int64
+	PromptVersion string
+	Fingerprint   string
+}
+
+func NewVisionCacheKey(route string, images *ResolvedImages, promptVersion string, fingerprint string) VisionCacheKey {
+	key := VisionCacheKey{Route: route, PromptVersion: promptVersion, Fingerprint: fingerprint}
+	if images != nil {
+		key.ContentHashes = images.ContentHashes
+		key.PHashes = images.PHashes
+	}
+	return key
+}
+
+func (k VisionCacheKey) valid() bool {
+	return k.Route != "" && k.PromptVersion != "" && len(k.ContentHashes) > 0
+}
+
+// imageKey 图片集合的哈希，与图片顺序无关。
+func (k VisionCacheKey) imageKey() string {
+	hashes := append([]string(nil), k.ContentHashes...)
+	sort.Strings(hashes)
+	sum := sha256.Sum256([]byte(strings.Join(hashes, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+type visionCacheResult struct {
+	Analysis *AIDishAnalysis `json:"analysis"`
+	Raw      string          `json:"raw"`
+}
+
+// VisionCacheService 视觉识别结果缓存。读写失败只记录日志并按未命中处理，不影响识别流程。
+type VisionCacheService struct {
+	repo *repository.VisionCacheRepository
+	cfg  config.VisionCacheConfig
+}
+
+func NewVisionCacheService(repo *repository.VisionCacheRepository, cfg config.VisionCacheConfig) *VisionCacheService {
+	return &VisionCacheService{repo: repo, cfg: cfg}
+}
+
+func (s *VisionCacheService) enabled() bool {
+	return s != nil && s.repo != nil && s.cfg.Enabled
+}
+
+// ChargeOnHit 命中缓存时是否仍计入额度。
+func (s *VisionCacheService) ChargeOnHit() bool {
+	return s == nil || s.cfg.ChargeOnHit
+}
+
+// Lookup 先按图片集合精确匹配，再在同一用户的近期缓存中按感知哈希查找近似图片。
+func (s *VisionCacheService) Lookup(userID int64, key VisionCacheKey) (*AIDishAnalysis, string, bool) {
+	if !s.enabled() || !key.valid() {
+		return nil, "", false
+	}
+	since := time.Now().Add(-time.Duration(s.cfg.WindowHours) * time.Hour)
+	entry, err := s.repo.FindExact(userID, key.Route, key.imageKey(), key.PromptVersion, key.Fingerprint, since)
+	if err != nil {
+		log.Printf("vision cache lookup failed (user %d, route %s): %v", userID, key.Route, err)
+		visionCacheMetrics.Add(key.Route+".error", 1)
+		return nil, "", false
+	}
+	kind := "hit"
+	if entry == nil && s.cfg.MaxHashDistance > 0 {
+		entry = s.findNear(userID, key, since)
+		kind = "near_hit"
+	}
+	if entry == nil {
+		visionCacheMetrics.Add(key.Route+".miss", 1)
+		return nil, "", false
+	}
+	var result visionCacheResult
+	if err := json.Unmarshal(entry.Result, &result); err != nil || result.Analysis == nil {
+		visionCacheMetrics.Add(key.Route+".miss", 1)
+		return nil, "", false
+	}
+	if err := s.repo.MarkHit(entry.ID); err != nil {
+		log.Printf("vision cache mark hit failed (entry %d): %v", entry.ID, err)
+	}
+	visionCacheMetrics.Add(key.Route+"."+kind, 1)
+	return result.Analysis, result.Raw, true
+}
+
+// Store 写入识别结果，失败只记录日志。
+func (s *VisionCacheService) Store(userID int64, key VisionCacheKey, analysis *AIDishAnalysis, raw string) {
+	if !s.enabled() || !key.valid() || analysis == nil {
+		return
+	}
+	result, err := json.Marshal(visionCacheResult{Analysis: analysis, Raw: raw})
+	if err != nil {
+		return
+	}
+	entry := &model.VisionCacheEntry{
+		UserID:             userID,
+		Route:              key.Route,
+		ImageKey:           key.imageKey(),
+		ImagePHashes:       key.PHashes,
+		PromptVersion:      key.PromptVersion,
+		ContextFingerprint: key.Fingerprint,
+		Result:             result,
+	}
+	if err := s.repo.Upsert(entry); err != nil {
+		log.Printf("vision cache store failed (user %d, route %s): %v", userID, key.Route, err)
+		visionCacheMetrics.Add(key.Route+".error", 1)
+	}
+}
+
+func (s *VisionCacheService) findNear(userID int64, key VisionCacheKey, since time.Time) *model.VisionCacheEntry {
+	if len(key.PHashes) != len(key.ContentHashes) {
+		return nil
+	}
+	for _, hash := range key.PHashes {
+		if hash == 0 {
+			return nil
+		}
+	}
+	candidates, err := s.repo.ListCandidates(userID, key.Route, key.PromptVersion, key.Fingerprint, len(key.PHashes), since, visionCacheCandidates)
+	if err != nil {
+		log.Printf("vision cache candidates failed (user %d, route %s): %v", userID, key.Route, err)
+		return nil
+	}
+	for i := range candidates {
+		if phashSetsNear(key.PHashes, candidates[i].ImagePHashes, s.cfg.MaxHashDistance) {
+			return &candidates[i]
+		}
+	}
+	return nil
+}
+
+// phashSetsNear 每张图片都能在另一组中找到不同的近似图片（距离不超过 maxDistance）。
+func phashSetsNear(a []int64, b []int64, maxDistance int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	used := make([]bool, len(b))
+	for _, hash := range a {
+		matched := false
+		for j, other := range b {
+			if used[j] || other == 0 {
+				continue
+			}
+			if HammingDistance(hash, other) <= maxDistance {
+				used[j] = true
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
@@ -19,6 +19,15 @@ func Success(c echo.Context, data interface{}) error {
 	})
 }
 
+// Accepted 已受理的异步任务（202），data 中带任务 ID 供客户端轮询。
+func Accepted(c echo.Context, data interface{}) error {
+	return c.JSON(http.StatusAccepted, Response{
+		Code:    0,
+		Message: "accepted",
+		Data:    data,
+	})
+}
+
 func Error(c echo.Context, statusCode int, message string) error {
 	return c.JSON(statusCode, Response{
 		Code:    statusCode,
@@ -26,6 +35,15 @@ func Error(c echo.Context, statusCode int, message string) error {
 	})
 }
 
+// ErrorWithData 带业务数据的错误响应，如错误码与明细。
+func ErrorWithData(c echo.Context, statusCode int, message string, data interface{}) error {
+	return c.JSON(statusCode, Response{
+		Code:    statusCode,
+		Message: message,
+		Data:    data,
+	})
+}
+
 func BadRequest(c echo.Context, message string) error {
 	return Error(c, http.StatusBadRequest, message)
 }